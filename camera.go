@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"time"
 
-	usb "github.com/kevmo314/go-usb"
 	"github.com/kevmo314/go-uvc/pkg/descriptors"
 	"github.com/kevmo314/go-uvc/pkg/requests"
+	"github.com/kevmo314/go-uvc/pkg/transfers"
 )
 
 var availableDescriptors = []descriptors.CameraTerminalControlDescriptor{
@@ -35,7 +35,7 @@ var availableDescriptors = []descriptors.CameraTerminalControlDescriptor{
 }
 
 type CameraTerminal struct {
-	handle           *usb.DeviceHandle
+	handle           transfers.Transport
 	ifaceNum         uint8
 	CameraDescriptor *descriptors.CameraTerminalDescriptor
 }
@@ -54,6 +54,9 @@ func (ct *CameraTerminal) GetSupportedControls() []descriptors.CameraTerminalCon
 func (ct *CameraTerminal) IsControlRequestSupported(desc descriptors.CameraTerminalControlDescriptor) bool {
 	byteIndex := desc.FeatureBit() / 8
 	bitIndex := desc.FeatureBit() % 8
+	if byteIndex >= len(ct.CameraDescriptor.ControlsBitmask) {
+		return false
+	}
 	return (ct.CameraDescriptor.ControlsBitmask[byteIndex] & (1 << bitIndex)) != 0
 }
 
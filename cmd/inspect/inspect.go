@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"image"
@@ -17,6 +18,7 @@ import (
 	"github.com/kevmo314/go-uvc"
 	"github.com/kevmo314/go-uvc/pkg/decode"
 	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/transfers"
 	"github.com/rivo/tview"
 )
 
@@ -98,38 +100,67 @@ func main() {
 
 	log.SetOutput(logText)
 
-	active := &atomic.Uint32{}
+	var stopStream func()
 
 	for _, si := range info.StreamingInterfaces {
 		streamingIfaces.AddItem(fmt.Sprintf("Interface %d", si.InterfaceNumber()), fmt.Sprintf("v%s", si.UVCVersionString()), 0, func() {
 			for fdIndex, d := range si.Descriptors {
 				if fd, ok := d.(descriptors.FormatDescriptor); ok {
 					formats.AddItem(formatDescriptorTitle(fd), formatDescriptorSubtitle(fd), 0, func() {
-						frs := si.Descriptors[fdIndex+1 : fdIndex+int(NumFrameDescriptors(fd))+1]
-						for _, fr := range frs {
+						// the frames follow their format, possibly interleaved with unknown descriptors.
+						for _, fr := range si.Descriptors[fdIndex+1:] {
+							if _, ok := fr.(descriptors.FormatDescriptor); ok {
+								break
+							}
 							if fr, ok := fr.(descriptors.FrameDescriptor); ok {
 								frames.AddItem(frameDescriptorTitle(fr), frameDescriptorSubtitle(fr), 0, func() {
-									track := active.Add(1)
+									if stopStream != nil {
+										stopStream()
+										stopStream = nil
+									}
 									reader, err := si.ClaimFrameReader(fd.Index(), fr.Index())
 									if err != nil {
 										log.Printf("error claiming frame reader: %s", err)
 										return
 									}
-									decoder, err := decode.NewFrameReaderDecoder(reader, fd, fr)
+									decoder, err := decode.NewDescriptorDecoder(fd, fr)
 									if err != nil {
+										reader.Close()
 										log.Printf("error creating decoder: %s", err)
 										return
 									}
-									if *render {
+									ctx, cancel := context.WithCancel(context.Background())
+									// the preview only cares about the latest frame.
+									stream, err := reader.StartStream(ctx, transfers.StreamOptions{BufferDepth: 1, DropPolicy: transfers.DropOldest})
+									if err != nil {
+										cancel()
+										reader.Close()
+										log.Printf("error starting stream: %s", err)
+										return
+									}
+									stopStream = func() {
+										cancel()
+										reader.Close()
+									}
+									go func() {
+										defer decoder.Close()
 										g := &Display{}
-										go func() {
-											defer reader.Close()
-											for active.Load() == track {
-												img, err := decoder.ReadFrame()
-												if err != nil {
-													log.Printf("error reading frame: %s", err)
-													continue
-												}
+										t0 := time.Now().Add(-1 * time.Second)
+										for f := range stream {
+											err := decoder.WriteUSBFrame(f)
+											f.Release()
+											if err != nil {
+												log.Printf("error decoding frame: %s", err)
+												continue
+											}
+											img, err := decoder.ReadFrame()
+											if err == decode.ErrEAGAIN {
+												continue
+											} else if err != nil {
+												log.Printf("error reading frame: %s", err)
+												continue
+											}
+											if *render {
 												if g.frame.Swap(ebiten.NewImageFromImage(img)) == nil {
 													go func() {
 														if err := ebiten.RunGame(g); err != nil {
@@ -137,30 +168,24 @@ func main() {
 														}
 													}()
 												}
+												continue
 											}
-										}()
-									} else {
-										go func() {
-											defer reader.Close()
-											t0 := time.Now().Add(-1 * time.Second)
-											for active.Load() == track {
-												img, err := decoder.ReadFrame()
-												if err != nil {
-													log.Printf("error reading frame: %s", err)
-													return
-												}
-												t1 := time.Now()
-												if t1.Sub(t0) < 50*time.Millisecond {
-													continue
-												}
-												t0 = t1
-												w := 64
-												h := img.Bounds().Dy() * w / img.Bounds().Dx()
-												preview.SetImage(resize(img, w, h))
-												app.ForceDraw()
+											t1 := time.Now()
+											if t1.Sub(t0) < 50*time.Millisecond {
+												continue
 											}
-										}()
-									}
+											t0 = t1
+											w := 64
+											h := img.Bounds().Dy() * w / img.Bounds().Dx()
+											preview.SetImage(resize(img, w, h))
+											stats := reader.Stats()
+											preview.SetTitle(fmt.Sprintf("Preview (%.1f/%.1f fps, %d dropped, %d corrupt)", stats.FPS, stats.NominalFPS, stats.DroppedFrames, stats.CorruptFrames))
+											app.ForceDraw()
+										}
+										if err := reader.Err(); err != nil {
+											log.Printf("error reading frame: %s", err)
+										}
+									}()
 									app.SetFocus(controlIfaces)
 								})
 							}
@@ -620,7 +645,7 @@ func frameDescriptorSubtitle(fd descriptors.FrameDescriptor) string {
 }
 
 func controlInterfaceTitle(ci *uvc.ControlInterface) string {
-	switch ci.Descriptor.(type) {
+	switch d := ci.Descriptor.(type) {
 	case *descriptors.HeaderDescriptor:
 		return "Header"
 	case *descriptors.InputTerminalDescriptor:
@@ -637,6 +662,10 @@ func controlInterfaceTitle(ci *uvc.ControlInterface) string {
 		return "Encoding Unit"
 	case *descriptors.ExtensionUnitDescriptor:
 		return "Extension Unit"
+	case *descriptors.StandardVideoControlInterruptEndpointDescriptor:
+		return "Interrupt Endpoint"
+	case *descriptors.UnknownDescriptor:
+		return fmt.Sprintf("Unknown (type %#02x, subtype %#02x)", d.Type, d.Subtype)
 	default:
 		return "Unknown"
 	}
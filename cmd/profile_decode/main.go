@@ -4,7 +4,6 @@ import (
 	"flag"
 	"fmt"
 	"image"
-	"io"
 	"log"
 	"os"
 	"runtime"
@@ -67,7 +66,7 @@ found:
 
 	log.Printf("Profiling %d frames...\n", *frames)
 
-	var totalRead, totalReadAll, totalDecode, totalConvert time.Duration
+	var totalRead, totalDecode, totalConvert time.Duration
 	var readCount, decodeCount, convertCount int
 
 	start := time.Now()
@@ -83,22 +82,15 @@ found:
 		totalRead += readTime
 		readCount++
 
-		// Time io.ReadAll
-		t1 := time.Now()
-		data, _ := io.ReadAll(frame)
-		readAllTime := time.Since(t1)
-		totalReadAll += readAllTime
-
-		if len(data) == 0 {
+		if frame.Len() == 0 {
+			frame.Release()
 			continue
 		}
 
-		// Reconstruct frame for decoder
-		frame.Payloads = []*transfers.Payload{{Data: data}}
-
 		// Time decode
 		t2 := time.Now()
 		err = decoder.WriteUSBFrame(frame)
+		frame.Release()
 		if err != nil {
 			continue
 		}
@@ -134,7 +126,6 @@ found:
 	if readCount > 0 {
 		fmt.Printf("USB ReadFrame:\n")
 		fmt.Printf("  Total: %v, Count: %d, Avg: %v\n", totalRead, readCount, totalRead/time.Duration(readCount))
-		fmt.Printf("  io.ReadAll: Total: %v, Avg: %v\n", totalReadAll, totalReadAll/time.Duration(readCount))
 	}
 
 	if decodeCount > 0 {
@@ -147,10 +138,17 @@ found:
 		fmt.Printf("  Total: %v, Count: %d, Avg: %v\n", totalConvert, convertCount, totalConvert/time.Duration(convertCount))
 	}
 
+	stats := reader.Stats()
+	fmt.Printf("\nStream:\n")
+	fmt.Printf("  Frames: %d, Corrupt: %d, FPS: %.2f (nominal %.2f)\n", stats.Frames, stats.CorruptFrames, stats.FPS, stats.NominalFPS)
+	fmt.Printf("  Throughput: %.2f MB/s\n", stats.Throughput()/1e6)
+	if t := stats.Transfer; t != nil {
+		fmt.Printf("  Transfers: %d, Transfer errors: %d, Packet errors: %v\n", t.Transfers, t.TransferErrors, t.PacketErrors)
+	}
+
 	fmt.Println("\n=== Breakdown ===")
-	total := totalRead + totalReadAll + totalDecode + totalConvert
+	total := totalRead + totalDecode + totalConvert
 	fmt.Printf("USB Read:     %.1f%%\n", float64(totalRead)/float64(total)*100)
-	fmt.Printf("io.ReadAll:   %.1f%%\n", float64(totalReadAll)/float64(total)*100)
 	fmt.Printf("H264 Decode:  %.1f%%\n", float64(totalDecode)/float64(total)*100)
 	fmt.Printf("RGBA Convert: %.1f%%\n", float64(totalConvert)/float64(total)*100)
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"log"
+	"os"
+
+	"github.com/kevmo314/go-uvc"
+	"github.com/kevmo314/go-uvc/pkg/decode"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/usbmon"
+)
+
+func main() {
+	path := flag.String("capture", "capture.pcapng", "path to a usbmon pcap or pcapng capture")
+	address := flag.Int("device", 0, "address of the device to replay, the first device with descriptors if 0")
+	output := flag.String("output", "frame", "output filename prefix (will save as frame_N.png)")
+	flag.Parse()
+
+	f, err := os.Open(*path)
+	if err != nil {
+		log.Fatalf("Failed to open capture: %v", err)
+	}
+	captures, err := usbmon.Import(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Failed to import capture: %v", err)
+	}
+
+	var capture *usbmon.Capture
+	for _, c := range captures {
+		log.Printf("Device %d.%d: %d control transfers, descriptors captured: %v", c.Bus, c.Address, len(c.Controls), c.Descriptor != nil)
+		if capture == nil && c.Descriptor != nil && (*address == 0 || int(c.Address) == *address) {
+			capture = c
+		}
+	}
+	if capture == nil {
+		log.Fatal("No device with captured descriptors found, start the capture before plugging in the device")
+	}
+
+	for endpoint, n := range capture.LostPayloads {
+		log.Printf("Endpoint %#02x: %d payloads lost in the capture", endpoint, n)
+	}
+
+	commits, err := capture.Commits()
+	if err != nil {
+		log.Fatalf("Failed to recover commits: %v", err)
+	}
+	sim, err := capture.Device()
+	if err != nil {
+		log.Fatalf("Failed to build device: %v", err)
+	}
+	info, err := uvc.NewUVCDeviceWithTransport(sim).DeviceInfo()
+	if err != nil {
+		log.Fatalf("Failed to get device info: %v", err)
+	}
+
+	for _, si := range info.StreamingInterfaces {
+		commit, ok := commits[si.InterfaceNumber()]
+		if !ok {
+			continue
+		}
+		log.Printf("Interface %d committed format %d, frame %d", si.InterfaceNumber(), commit.FormatIndex, commit.FrameIndex)
+
+		var format descriptors.FormatDescriptor
+		var frame descriptors.FrameDescriptor
+		for _, desc := range si.Descriptors {
+			switch d := desc.(type) {
+			case descriptors.FormatDescriptor:
+				if d.Index() == commit.FormatIndex {
+					format = d
+				}
+			case descriptors.FrameDescriptor:
+				if format != nil && frame == nil && d.Index() == commit.FrameIndex {
+					frame = d
+				}
+			}
+		}
+		if format == nil {
+			log.Printf("Format %d not found", commit.FormatIndex)
+			continue
+		}
+
+		reader, err := si.ClaimFrameReaderWithProbeCommit(commit)
+		if err != nil {
+			log.Fatalf("Failed to claim frame reader: %v", err)
+		}
+		decoder, err := decode.NewFrameReaderDecoder(reader, format, frame)
+		if err != nil {
+			log.Fatalf("Failed to create decoder: %v", err)
+		}
+
+		for i := 1; ; i++ {
+			img, err := decoder.ReadFrame()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				log.Printf("Error reading frame %d: %v", i, err)
+				break
+			}
+			filename := fmt.Sprintf("%s_%d_%d.png", *output, si.InterfaceNumber(), i)
+			out, err := os.Create(filename)
+			if err != nil {
+				log.Fatalf("Failed to create file %s: %v", filename, err)
+			}
+			if err := png.Encode(out, img); err != nil {
+				log.Printf("Failed to encode frame %d: %v", i, err)
+			}
+			out.Close()
+		}
+		stats := reader.Stats()
+		log.Printf("Replayed %d frames, %d corrupt", stats.Frames, stats.CorruptFrames)
+		reader.Close()
+	}
+}
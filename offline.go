@@ -0,0 +1,165 @@
+//go:build !windows
+
+package uvc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/transfers"
+)
+
+// ErrOffline is returned by the requests of devices parsed from descriptor dumps.
+var ErrOffline = errors.New("device parsed from descriptors is offline")
+
+// ParseConfigDescriptor builds the DeviceInfo of a video device from a dump of its descriptors, without
+// a device. raw is a configuration descriptor including its interface, endpoint and class-specific
+// descriptors, optionally preceded by the device descriptor as in the sysfs descriptors file. Control
+// and streaming requests on the result fail with ErrOffline.
+func ParseConfigDescriptor(raw []byte) (*DeviceInfo, error) {
+	t, err := newOfflineTransport(raw)
+	if err != nil {
+		return nil, err
+	}
+	return NewUVCDeviceWithTransport(t).DeviceInfo()
+}
+
+// ReadRecording opens a recording made by FrameReader.Record for replay. The recorded streaming
+// interface is built from the recorded configuration descriptor by ParseConfigDescriptor.
+func ReadRecording(r io.Reader) (*transfers.Recording, error) {
+	return transfers.ReadRecording(r, func(config []byte) ([]*transfers.StreamingInterface, error) {
+		info, err := ParseConfigDescriptor(config)
+		if err != nil {
+			return nil, err
+		}
+		return info.StreamingInterfaces, nil
+	})
+}
+
+// ParseAudioConfigDescriptor builds the AudioDeviceInfo of an audio device from a dump of its
+// descriptors, like ParseConfigDescriptor.
+func ParseAudioConfigDescriptor(raw []byte) (*AudioDeviceInfo, error) {
+	t, err := newOfflineTransport(raw)
+	if err != nil {
+		return nil, err
+	}
+	return NewUACDeviceWithTransport(t).DeviceInfo()
+}
+
+// ParseHexDump decodes a hex dump of descriptors, such as the bytes printed by lsusb -v or copied
+// from a capture. Bytes may be separated by whitespace or commas and prefixed with 0x. Tokens ending
+// with a colon, like offsets, and lines starting with # are ignored.
+func ParseHexDump(s string) ([]byte, error) {
+	var buf []byte
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, tok := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' }) {
+			if strings.HasSuffix(tok, ":") {
+				continue
+			}
+			tok = strings.TrimPrefix(strings.TrimPrefix(tok, "0x"), "0X")
+			b, err := hex.DecodeString(tok)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex %q: %w", tok, err)
+			}
+			buf = append(buf, b...)
+		}
+	}
+	return buf, nil
+}
+
+// offlineTransport serves the descriptors of a dump and fails every request.
+type offlineTransport struct {
+	desc   usb.DeviceDescriptor
+	config *usb.ConfigDescriptor
+}
+
+func newOfflineTransport(raw []byte) (*offlineTransport, error) {
+	t := &offlineTransport{}
+	// USB 2.0 spec, section 9.6.1: the device descriptor is 18 bytes of type 0x01.
+	if len(raw) >= 18 && raw[0] == 18 && raw[1] == 0x01 {
+		if err := binary.Read(bytes.NewReader(raw[:18]), binary.LittleEndian, &t.desc); err != nil {
+			return nil, err
+		}
+		raw = raw[18:]
+	}
+	if len(raw) < 9 || raw[1] != 0x02 {
+		return nil, fmt.Errorf("not a configuration descriptor")
+	}
+	// a sysfs dump holds every configuration, keep the first one.
+	if total := int(binary.LittleEndian.Uint16(raw[2:4])); total >= 9 && total < len(raw) {
+		raw = raw[:total]
+	}
+	t.config = &usb.ConfigDescriptor{}
+	if err := t.config.Unmarshal(raw); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration descriptor: %w", err)
+	}
+	return t, nil
+}
+
+func (t *offlineTransport) Descriptor() usb.DeviceDescriptor {
+	return t.desc
+}
+
+func (t *offlineTransport) ConfigDescriptorByValue(value uint8) (*usb.ConfigDescriptor, error) {
+	return t.config, nil
+}
+
+func (t *offlineTransport) StringDescriptor(index uint8) (string, error) {
+	return "", ErrOffline
+}
+
+func (t *offlineTransport) GetSpeed() (usb.Speed, error) {
+	return usb.SpeedUnknown, ErrOffline
+}
+
+func (t *offlineTransport) ControlTransfer(requestType, request uint8, value, index uint16, data []byte, timeout time.Duration) (int, error) {
+	return 0, ErrOffline
+}
+
+func (t *offlineTransport) BulkTransfer(endpoint uint8, data []byte, timeout time.Duration) (int, error) {
+	return 0, ErrOffline
+}
+
+func (t *offlineTransport) BulkTransferWithOptions(endpoint uint8, data []byte, timeout time.Duration, allowZeroLength bool) (int, error) {
+	return 0, ErrOffline
+}
+
+func (t *offlineTransport) IsochronousTransfer(endpoint uint8, data []byte, numPackets int, packetSize int, timeout time.Duration) ([]usb.IsoPacketResult, error) {
+	return nil, ErrOffline
+}
+
+func (t *offlineTransport) DetachKernelDriver(iface uint8) error {
+	return ErrOffline
+}
+
+func (t *offlineTransport) ClaimInterface(iface uint8) error {
+	return ErrOffline
+}
+
+func (t *offlineTransport) ReleaseInterface(iface uint8) error {
+	return ErrOffline
+}
+
+func (t *offlineTransport) SetInterfaceAltSetting(iface, altSetting uint8) error {
+	return ErrOffline
+}
+
+func (t *offlineTransport) ClearHalt(endpoint uint8) error {
+	return ErrOffline
+}
+
+func (t *offlineTransport) Close() error {
+	return nil
+}
+
+var _ transfers.Transport = (*offlineTransport)(nil)
@@ -0,0 +1,170 @@
+//go:build !windows
+
+package uvc
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata/descriptors")
+
+// describe renders the video and audio functions parsed from a descriptor dump.
+func describe(raw []byte) string {
+	var b strings.Builder
+	info, err := ParseConfigDescriptor(raw)
+	if err != nil {
+		fmt.Fprintf(&b, "video: %v\n", err)
+	} else {
+		fmt.Fprintf(&b, "video: UVC %x.%02x\n", info.bcdUVC>>8, info.bcdUVC&0xff)
+		for _, ci := range info.ControlInterfaces {
+			fmt.Fprintf(&b, "  control %T %+v\n", ci.Descriptor, ci.Descriptor)
+			if ci.CameraTerminal != nil {
+				for _, c := range ci.CameraTerminal.GetSupportedControls() {
+					fmt.Fprintf(&b, "    %T\n", c)
+				}
+			}
+			if ci.ProcessingUnit != nil {
+				for _, c := range ci.ProcessingUnit.GetSupportedControls() {
+					fmt.Fprintf(&b, "    %T\n", c)
+				}
+			}
+		}
+		for _, si := range info.StreamingInterfaces {
+			fmt.Fprintf(&b, "  streaming interface %d\n", si.InterfaceNumber())
+			for _, d := range si.Descriptors {
+				fmt.Fprintf(&b, "    %T %+v\n", d, d)
+			}
+		}
+	}
+	audio, err := ParseAudioConfigDescriptor(raw)
+	if err != nil {
+		fmt.Fprintf(&b, "audio: %v\n", err)
+	} else {
+		fmt.Fprintf(&b, "audio: ADC %x.%02x\n", audio.bcdADC>>8, audio.bcdADC&0xff)
+		for _, as := range audio.StreamingInterfaces {
+			fmt.Fprintf(&b, "  streaming interface %d: endpoint %#02x, %d bytes, format type %d tag %#04x, %d channels, %d bits, %v Hz\n",
+				as.InterfaceNumber(), as.EndpointAddress, as.MaxPacketSize, as.FormatType, as.FormatTag, as.NrChannels, as.BitResolution, as.SamplingFreqs)
+		}
+		for _, ms := range audio.MIDIInterfaces {
+			fmt.Fprintf(&b, "  MIDI interface %d: %d in jacks, %d out jacks\n", ms.InterfaceNumber(), ms.NumInJacks, ms.NumOutJacks)
+		}
+	}
+	return b.String()
+}
+
+// TestParseConfigDescriptor_Golden parses the descriptor dumps of testdata/descriptors and compares
+// the result with their golden files. Run with -update to regenerate them after adding a dump.
+func TestParseConfigDescriptor_Golden(t *testing.T) {
+	dumps, err := filepath.Glob("testdata/descriptors/*.hex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) == 0 {
+		t.Fatal("no descriptor dumps found")
+	}
+	for _, dump := range dumps {
+		t.Run(filepath.Base(dump), func(t *testing.T) {
+			text, err := os.ReadFile(dump)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := ParseHexDump(string(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := describe(raw)
+			golden := strings.TrimSuffix(dump, ".hex") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("parsed descriptors differ from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestParseConfigDescriptor_Offline(t *testing.T) {
+	text, err := os.ReadFile("testdata/descriptors/simulated-webcam.hex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ParseHexDump(string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the configuration alone, as read from a capture.
+	info, err := ParseConfigDescriptor(raw[18:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ci := range info.ControlInterfaces {
+		if ci.ProcessingUnit != nil {
+			if err := ci.ProcessingUnit.Get(&descriptors.BrightnessControl{}); !errors.Is(err, ErrOffline) {
+				t.Errorf("Get = %v, want ErrOffline", err)
+			}
+		}
+	}
+	if _, err := info.StreamingInterfaces[0].ClaimFrameReader(1, 1); !errors.Is(err, ErrOffline) {
+		t.Errorf("ClaimFrameReader = %v, want ErrOffline", err)
+	}
+
+	if _, err := ParseConfigDescriptor(raw[:18]); err == nil {
+		t.Error("device descriptor alone accepted")
+	}
+}
+
+func TestParseHexDump(t *testing.T) {
+	got, err := ParseHexDump("# comment\n0000: 09 02 0x1a,0X00\r\n  0102\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "\x09\x02\x1a\x00\x01\x02" {
+		t.Errorf("got % x", got)
+	}
+	if _, err := ParseHexDump("09 0g"); err == nil {
+		t.Error("invalid hex accepted")
+	}
+}
+
+// FuzzParseConfigDescriptor feeds malformed descriptors through the parsing of a whole device.
+func FuzzParseConfigDescriptor(f *testing.F) {
+	dumps, err := filepath.Glob("testdata/descriptors/*.hex")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, dump := range dumps {
+		text, err := os.ReadFile(dump)
+		if err != nil {
+			f.Fatal(err)
+		}
+		raw, err := ParseHexDump(string(text))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(raw)
+	}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		if info, err := ParseConfigDescriptor(raw); err == nil && info == nil {
+			t.Fatal("nil device info without error")
+		}
+		if info, err := ParseAudioConfigDescriptor(raw); err == nil && info == nil {
+			t.Fatal("nil audio device info without error")
+		}
+	})
+}
@@ -0,0 +1,311 @@
+package decode
+
+import "image"
+
+// Demosaic selects how the UncompressedDecoder interpolates the missing colors of Bayer frames.
+type Demosaic int
+
+const (
+	// DemosaicBilinear averages the neighbors of each missing color.
+	DemosaicBilinear Demosaic = iota
+	// DemosaicNearest copies the missing colors from the same 2x2 cell, the fastest and blockiest.
+	DemosaicNearest
+	// DemosaicEdgeAware interpolates green along the direction of the smaller gradient and red and
+	// blue from the color differences to green, as in Hamilton and Adams, US patent 5629734. It
+	// avoids most of the zippering of bilinear interpolation on edges.
+	DemosaicEdgeAware
+)
+
+func (a Demosaic) String() string {
+	switch a {
+	case DemosaicBilinear:
+		return "Bilinear"
+	case DemosaicNearest:
+		return "Nearest"
+	case DemosaicEdgeAware:
+		return "EdgeAware"
+	}
+	return "Unknown"
+}
+
+// SetDemosaic selects the demosaic algorithm of Bayer formats, bilinear by default. It applies to
+// the frames written afterwards.
+func (d *UncompressedDecoder) SetDemosaic(a Demosaic) {
+	d.demosaic = a
+}
+
+// colors of the pixels of a Bayer pattern.
+const (
+	red = iota
+	green
+	blue
+)
+
+// cfa is a Bayer color filter array, the colors of the pixels (0, 0), (1, 0), (0, 1) and (1, 1).
+type cfa [4]int
+
+var (
+	bggr = cfa{blue, green, green, red}
+	gbrg = cfa{green, blue, red, green}
+	grbg = cfa{green, red, blue, green}
+	rggb = cfa{red, green, green, blue}
+)
+
+func (p cfa) at(x, y int) int {
+	return p[y%2*2+x%2]
+}
+
+// unpackFunc returns the samples of a frame scaled to 16 bits, in scan order.
+type unpackFunc func(pkt []byte, width, height int) []uint16
+
+// scale16 extends a sample of the given number of bits to 16 bits by replicating its high bits, so
+// that full scale maps to 0xffff.
+func scale16(v uint16, bits int) uint16 {
+	return v<<(16-bits) | v>>(2*bits-16)
+}
+
+func unpack8(pkt []byte, width, height int) []uint16 {
+	plane := make([]uint16, width*height)
+	for i := range plane {
+		plane[i] = scale16(uint16(pkt[i]), 8)
+	}
+	return plane
+}
+
+// unpack16 returns an unpackFunc of little endian 16-bit samples holding bits in their low bits.
+func unpack16(bits int) unpackFunc {
+	return func(pkt []byte, width, height int) []uint16 {
+		plane := make([]uint16, width*height)
+		for i := range plane {
+			v := uint16(pkt[2*i]) | uint16(pkt[2*i+1])<<8
+			plane[i] = scale16(v&(1<<bits-1), bits)
+		}
+		return plane
+	}
+}
+
+// mipi10Stride is the row size of MIPI CSI-2 RAW10 frames, which pack four pixels in five bytes.
+func mipi10Stride(width int) int {
+	return (width + 3) / 4 * 5
+}
+
+// unpackMIPI10 unpacks MIPI CSI-2 RAW10 frames. The first four bytes of a group hold the high bits
+// of the pixels, the fifth byte their two low bits, the first pixel in the least significant ones.
+func unpackMIPI10(pkt []byte, width, height int) []uint16 {
+	plane := make([]uint16, width*height)
+	stride := mipi10Stride(width)
+	for y := 0; y < height; y++ {
+		row := pkt[y*stride : (y+1)*stride]
+		for x := 0; x < width; x++ {
+			g := row[x/4*5 : x/4*5+5]
+			v := uint16(g[x%4])<<2 | uint16(g[4]>>(x%4*2)&0x03)
+			plane[y*width+x] = scale16(v, 10)
+		}
+	}
+	return plane
+}
+
+// mipi12Stride is the row size of MIPI CSI-2 RAW12 frames, which pack two pixels in three bytes.
+func mipi12Stride(width int) int {
+	return (width + 1) / 2 * 3
+}
+
+// unpackMIPI12 unpacks MIPI CSI-2 RAW12 frames. The first two bytes of a group hold the high bits
+// of the pixels, the third byte their four low bits, the first pixel in the least significant ones.
+func unpackMIPI12(pkt []byte, width, height int) []uint16 {
+	plane := make([]uint16, width*height)
+	stride := mipi12Stride(width)
+	for y := 0; y < height; y++ {
+		row := pkt[y*stride : (y+1)*stride]
+		for x := 0; x < width; x++ {
+			g := row[x/2*3 : x/2*3+3]
+			v := uint16(g[x%2])<<4 | uint16(g[2]>>(x%2*4)&0x0f)
+			plane[y*width+x] = scale16(v, 12)
+		}
+	}
+	return plane
+}
+
+// gray returns a decoder of luma only formats into image.Gray16.
+func gray(unpack unpackFunc) func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	return func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+		img := image.NewGray16(d.rect())
+		for i, v := range unpack(pkt, d.width, d.height) {
+			img.Pix[2*i], img.Pix[2*i+1] = uint8(v>>8), uint8(v)
+		}
+		return img, nil
+	}
+}
+
+// bayer returns a decoder of Bayer formats into image.RGBA64, demosaiced with the algorithm
+// selected by SetDemosaic.
+func bayer(p cfa, unpack unpackFunc) func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	return func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+		m := &mosaic{raw: unpack(pkt, d.width, d.height), width: d.width, height: d.height, cfa: p}
+		var planes [3][]uint16
+		switch d.demosaic {
+		case DemosaicNearest:
+			planes = m.nearest()
+		case DemosaicEdgeAware:
+			planes = m.edgeAware()
+		default:
+			planes = m.bilinear()
+		}
+		img := image.NewRGBA64(d.rect())
+		for i := 0; i < d.width*d.height; i++ {
+			s := img.Pix[8*i : 8*i+8 : 8*i+8]
+			s[0], s[1] = uint8(planes[red][i]>>8), uint8(planes[red][i])
+			s[2], s[3] = uint8(planes[green][i]>>8), uint8(planes[green][i])
+			s[4], s[5] = uint8(planes[blue][i]>>8), uint8(planes[blue][i])
+			s[6], s[7] = 0xff, 0xff
+		}
+		return img, nil
+	}
+}
+
+// mosaic is a Bayer frame with an even width and height.
+type mosaic struct {
+	raw           []uint16
+	width, height int
+	cfa           cfa
+}
+
+func (m *mosaic) in(x, y int) bool {
+	return x >= 0 && x < m.width && y >= 0 && y < m.height
+}
+
+func (m *mosaic) at(x, y int) int32 {
+	return int32(m.raw[y*m.width+x])
+}
+
+// planes returns the red, green and blue planes holding the samples of their color.
+func (m *mosaic) planes() [3][]uint16 {
+	var planes [3][]uint16
+	for c := range planes {
+		planes[c] = make([]uint16, len(m.raw))
+	}
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			planes[m.cfa.at(x, y)][y*m.width+x] = m.raw[y*m.width+x]
+		}
+	}
+	return planes
+}
+
+// nearest copies every missing color from the closest pixel of that color in the same 2x2 cell,
+// preferring the pixel on the same row.
+func (m *mosaic) nearest() [3][]uint16 {
+	planes := m.planes()
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			for c := range planes {
+				if c == m.cfa.at(x, y) {
+					continue
+				}
+				for _, n := range [][2]int{{x ^ 1, y}, {x, y ^ 1}, {x ^ 1, y ^ 1}} {
+					if m.cfa.at(n[0], n[1]) == c {
+						planes[c][y*m.width+x] = m.raw[n[1]*m.width+n[0]]
+						break
+					}
+				}
+			}
+		}
+	}
+	return planes
+}
+
+// bilinear sets every missing color to the average of the pixels of that color in the 3x3
+// neighborhood.
+func (m *mosaic) bilinear() [3][]uint16 {
+	planes := m.planes()
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			var sum, n [3]int32
+			for ny := y - 1; ny <= y+1; ny++ {
+				for nx := x - 1; nx <= x+1; nx++ {
+					if m.in(nx, ny) {
+						c := m.cfa.at(nx, ny)
+						sum[c] += m.at(nx, ny)
+						n[c]++
+					}
+				}
+			}
+			for c := range planes {
+				if c != m.cfa.at(x, y) {
+					planes[c][y*m.width+x] = uint16(sum[c] / n[c])
+				}
+			}
+		}
+	}
+	return planes
+}
+
+// edgeAware interpolates green at red and blue pixels along the direction with the smaller
+// gradient, corrected by the laplacian of the pixel's own color, then red and blue from the
+// average difference to green of their neighbors.
+func (m *mosaic) edgeAware() [3][]uint16 {
+	planes := m.planes()
+	g := planes[green]
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			if m.cfa.at(x, y) == green {
+				continue
+			}
+			if x < 2 || x >= m.width-2 || y < 2 || y >= m.height-2 {
+				// too close to the border for the laplacian, average the available neighbors.
+				var sum, n int32
+				for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+					if m.in(x+d[0], y+d[1]) {
+						sum += m.at(x+d[0], y+d[1])
+						n++
+					}
+				}
+				g[y*m.width+x] = uint16(sum / n)
+				continue
+			}
+			c := m.at(x, y)
+			lh := 2*c - m.at(x-2, y) - m.at(x+2, y)
+			lv := 2*c - m.at(x, y-2) - m.at(x, y+2)
+			gh := (m.at(x-1, y)+m.at(x+1, y))/2 + lh/4
+			gv := (m.at(x, y-1)+m.at(x, y+1))/2 + lv/4
+			dh := abs(m.at(x-1, y)-m.at(x+1, y)) + abs(lh)
+			dv := abs(m.at(x, y-1)-m.at(x, y+1)) + abs(lv)
+			switch {
+			case dh < dv:
+				g[y*m.width+x] = clamp16(int64(gh))
+			case dv < dh:
+				g[y*m.width+x] = clamp16(int64(gv))
+			default:
+				g[y*m.width+x] = clamp16(int64((gh + gv) / 2))
+			}
+		}
+	}
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			own := m.cfa.at(x, y)
+			for _, c := range []int{red, blue} {
+				if c == own {
+					continue
+				}
+				var sum, n int32
+				for ny := y - 1; ny <= y+1; ny++ {
+					for nx := x - 1; nx <= x+1; nx++ {
+						if m.in(nx, ny) && m.cfa.at(nx, ny) == c {
+							sum += m.at(nx, ny) - int32(g[ny*m.width+nx])
+							n++
+						}
+					}
+				}
+				planes[c][y*m.width+x] = clamp16(int64(int32(g[y*m.width+x]) + sum/n))
+			}
+		}
+	}
+	return planes
+}
+
+func abs(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
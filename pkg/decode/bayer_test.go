@@ -0,0 +1,136 @@
+package decode
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// extend returns the sample of the given number of bits that holds an 8-bit value, replicating its
+// high bits into the low ones.
+func extend(v uint8, bits int) uint16 {
+	return uint16(v)<<(bits-8) | uint16(v)>>(16-bits)
+}
+
+// packLE16 returns a little endian 16-bit sample holding v extended to bits.
+func packLE16(v uint8, bits int) []byte {
+	s := extend(v, bits)
+	return []byte{uint8(s), uint8(s >> 8)}
+}
+
+func packMIPI10(sample func(x, y int) uint8) []byte {
+	var buf []byte
+	for y := 0; y < patternHeight; y++ {
+		for x := 0; x < patternWidth; x += 4 {
+			g := make([]byte, 5)
+			for i := 0; i < 4; i++ {
+				s := extend(sample(x+i, y), 10)
+				g[i] = uint8(s >> 2)
+				g[4] |= uint8(s&0x03) << (i * 2)
+			}
+			buf = append(buf, g...)
+		}
+	}
+	return buf
+}
+
+func packMIPI12(sample func(x, y int) uint8) []byte {
+	var buf []byte
+	for y := 0; y < patternHeight; y++ {
+		for x := 0; x < patternWidth; x += 2 {
+			g := make([]byte, 3)
+			for i := 0; i < 2; i++ {
+				s := extend(sample(x+i, y), 12)
+				g[i] = uint8(s >> 4)
+				g[2] |= uint8(s&0x0f) << (i * 4)
+			}
+			buf = append(buf, g...)
+		}
+	}
+	return buf
+}
+
+// mosaicOf returns the Bayer samples of p for an image.
+func mosaicOf(p cfa, img func(x, y int) color.RGBA) func(x, y int) uint8 {
+	return func(x, y int) uint8 {
+		c := img(x, y)
+		return [3]uint8{c.R, c.G, c.B}[p.at(x, y)]
+	}
+}
+
+var bayerTests = []struct {
+	fourcc string
+	cfa    cfa
+	pack   func(sample func(x, y int) uint8) []byte
+	bits   int
+}{
+	{"BA81", bggr, pack8, 8},
+	{"GBRG", gbrg, pack8, 8},
+	{"GRBG", grbg, pack8, 8},
+	{"RGGB", rggb, pack8, 8},
+	{"pBAA", bggr, packMIPI10, 10},
+	{"pGAA", gbrg, packMIPI10, 10},
+	{"pgAA", grbg, packMIPI10, 10},
+	{"pRAA", rggb, packMIPI10, 10},
+	{"pBCC", bggr, packMIPI12, 12},
+	{"pGCC", gbrg, packMIPI12, 12},
+	{"pgCC", grbg, packMIPI12, 12},
+	{"pRCC", rggb, packMIPI12, 12},
+}
+
+func pack8(sample func(x, y int) uint8) []byte {
+	return pack(1, func(x, y int) []byte { return []byte{sample(x, y)} })
+}
+
+func TestBayerDecoder(t *testing.T) {
+	flat := color.RGBA{0x30, 0x90, 0xe0, 0xff}
+	for _, tc := range bayerTests {
+		t.Run(tc.fourcc, func(t *testing.T) {
+			for _, a := range []Demosaic{DemosaicNearest, DemosaicBilinear, DemosaicEdgeAware} {
+				d, err := NewUncompressedDecoder([4]byte([]byte(tc.fourcc)), patternWidth, patternHeight)
+				if err != nil {
+					t.Fatal(err)
+				}
+				d.SetDemosaic(a)
+				// every algorithm reproduces a uniform color.
+				frame := tc.pack(mosaicOf(tc.cfa, func(x, y int) color.RGBA { return flat }))
+				if _, err := d.Write(frame); err != nil {
+					t.Fatal(err)
+				}
+				img, err := d.ReadFrame()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := img.(*image.RGBA64); !ok {
+					t.Fatalf("image is a %T, want an image.RGBA64", img)
+				}
+				checkPixels(t, img, func(x, y int) color.Color { return flat }, 0)
+
+				// the pattern is constant over the 2x2 cells, only nearest reproduces it exactly.
+				if _, err := d.Write(tc.pack(mosaicOf(tc.cfa, patternColor))); err != nil {
+					t.Fatal(err)
+				}
+				if img, err = d.ReadFrame(); err != nil {
+					t.Fatal(err)
+				}
+				if a == DemosaicNearest {
+					checkPixels(t, img, rgbPattern, 0)
+				}
+				// deeper samples only differ in their low bits, the 8-bit formats have the goldens.
+				if tc.bits == 8 {
+					checkGolden(t, img, filepath.Join("testdata", "bayer", tc.fourcc+"-"+strings.ToLower(a.String())+".png"))
+				}
+			}
+		})
+	}
+}
+
+func TestNewUncompressedDecoder_OddBayer(t *testing.T) {
+	for _, fourcc := range []string{"RGGB", "pRAA", "pRCC"} {
+		if _, err := NewUncompressedDecoder([4]byte([]byte(fourcc)), 15, 8); err == nil {
+			t.Errorf("NewUncompressedDecoder(%q) with an odd width succeeded", fourcc)
+		}
+	}
+}
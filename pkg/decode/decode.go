@@ -23,6 +23,11 @@ func NewDescriptorDecoder(fd descriptors.FormatDescriptor, fr descriptors.FrameD
 		return NewH264Decoder()
 	case *descriptors.VP8FormatDescriptor:
 		return NewVP8Decoder()
+	case *descriptors.DVFormatDescriptor:
+		return NewDVDecoder()
+	case *descriptors.MPEG2TSFormatDescriptor:
+		// stream based formats have no frame descriptors, fr is unused.
+		return NewMPEG2TSDecoder(int(fd.StrideLength))
 	case *descriptors.FrameBasedFormatDescriptor:
 		fcc, err := fd.FourCC()
 		if err != nil {
@@ -41,7 +46,10 @@ func NewDescriptorDecoder(fd descriptors.FormatDescriptor, fr descriptors.FrameD
 		if err != nil {
 			return nil, err
 		}
-		fr := fr.(*descriptors.UncompressedFrameDescriptor)
+		fr, ok := fr.(*descriptors.UncompressedFrameDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("unsupported frame descriptor %T for uncompressed format", fr)
+		}
 		return NewUncompressedDecoder(fcc, int(fr.Width), int(fr.Height))
 	}
 	return nil, fmt.Errorf("unsupported frame descriptor: %#v", fd)
@@ -73,7 +81,9 @@ func (d *FrameReaderDecoder) ReadFrame() (image.Image, error) {
 		if err != nil {
 			return nil, err
 		}
-		if err := d.dec.WriteUSBFrame(fr); err != nil {
+		err = d.dec.WriteUSBFrame(fr)
+		fr.Release()
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -7,7 +7,7 @@ import (
 
 // RGB is an in-memory image whose At method returns [color.RGB] values.
 type RGB struct {
-	// Pix holds the image's pixels, in R, G, B, A order. The pixel at
+	// Pix holds the image's pixels, in R, G, B order. The pixel at
 	// (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*3].
 	Pix []uint8
 	// Stride is the Pix stride (in bytes) between vertically adjacent pixels.
@@ -32,7 +32,7 @@ func (p *RGB) RGBAAt(x, y int) color.RGBA {
 	}
 	i := p.PixOffset(x, y)
 	s := p.Pix[i : i+3 : i+3] // Small cap improves performance, see https://golang.org/issue/27857
-	return color.RGBA{s[0], s[1], s[2], 0}
+	return color.RGBA{s[0], s[1], s[2], 0xff}
 }
 
 // PixOffset returns the index of the first element of Pix that corresponds to
@@ -61,7 +61,7 @@ func (p *RGB) SubImage(r image.Rectangle) image.Image {
 
 // BGR is an in-memory image whose At method returns [color.BGR] values.
 type BGR struct {
-	// Pix holds the image's pixels, in R, G, B, A order. The pixel at
+	// Pix holds the image's pixels, in B, G, R order. The pixel at
 	// (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*3].
 	Pix []uint8
 	// Stride is the Pix stride (in bytes) between vertically adjacent pixels.
@@ -86,7 +86,7 @@ func (p *BGR) BGRAAt(x, y int) color.RGBA {
 	}
 	i := p.PixOffset(x, y)
 	s := p.Pix[i : i+3 : i+3] // Small cap improves performance, see https://golang.org/issue/27857
-	return color.RGBA{s[2], s[1], s[0], 0}
+	return color.RGBA{s[2], s[1], s[0], 0xff}
 }
 
 // PixOffset returns the index of the first element of Pix that corresponds to
@@ -71,6 +71,30 @@ func NewVP8Decoder() (*LibAVCodecDecoder, error) {
 	return newDecoder(C.AV_CODEC_ID_VP8)
 }
 
+// NewMPEG2Decoder creates a decoder for MPEG-1 and MPEG-2 video.
+func NewMPEG2Decoder() (*LibAVCodecDecoder, error) {
+	return newDecoder(C.AV_CODEC_ID_MPEG2VIDEO)
+}
+
+// DVDecoder decodes DV frames. Every DV frame is intra coded, so unlike H264 frames they are sent to
+// the decoder as they are.
+type DVDecoder struct {
+	*LibAVCodecDecoder
+}
+
+func NewDVDecoder() (*DVDecoder, error) {
+	d, err := newDecoder(C.AV_CODEC_ID_DVVIDEO)
+	if err != nil {
+		return nil, err
+	}
+	return &DVDecoder{d}, nil
+}
+
+func (d *DVDecoder) WriteUSBFrame(fr *transfers.Frame) error {
+	_, err := d.Write(fr.Bytes())
+	return err
+}
+
 func (d *LibAVCodecDecoder) Close() error {
 	C.av_frame_free(&d.frame)
 	C.av_packet_free(&d.pkt)
@@ -143,24 +167,12 @@ func findNALUnits(data []byte) [][3]int {
 }
 
 func (d *LibAVCodecDecoder) WriteUSBFrame(fr *transfers.Frame) error {
-	// Concatenate all payload data into a single buffer
-	// H264 NAL units can span multiple UVC payloads
-	totalSize := 0
-	for _, p := range fr.Payloads {
-		totalSize += len(p.Data)
-	}
-
-	if totalSize == 0 {
+	// H264 NAL units can span multiple UVC payloads, the frame holds them contiguously.
+	buf := fr.Bytes()
+	if len(buf) == 0 {
 		return nil
 	}
 
-	buf := make([]byte, totalSize)
-	offset := 0
-	for _, p := range fr.Payloads {
-		copy(buf[offset:], p.Data)
-		offset += len(p.Data)
-	}
-
 	// Parse NAL units and extract SPS/PPS if present
 	nalUnits := findNALUnits(buf)
 	hasIDR := false
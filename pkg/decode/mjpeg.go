@@ -26,12 +26,8 @@ func (d *MJPEGDecoder) Write(pkt []byte) (int, error) {
 }
 
 func (d *MJPEGDecoder) WriteUSBFrame(fr *transfers.Frame) error {
-	img, err := jpeg.Decode(fr)
-	if err != nil {
-		return err
-	}
-	d.imagesBuf = append(d.imagesBuf, img)
-	return nil
+	_, err := d.Write(fr.Bytes())
+	return err
 }
 
 func (d *MJPEGDecoder) ReadFrame() (image.Image, error) {
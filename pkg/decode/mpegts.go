@@ -0,0 +1,82 @@
+package decode
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/kevmo314/go-uvc/pkg/mpegts"
+	"github.com/kevmo314/go-uvc/pkg/transfers"
+)
+
+// MPEG2TSDecoder demultiplexes an MPEG-2 transport stream and decodes its first video elementary
+// stream. Write takes transport stream bytes, for instance read from a transfers.StreamReader.
+type MPEG2TSDecoder struct {
+	demux *mpegts.Demuxer
+	video VideoDecoder
+	pid   uint16
+}
+
+func NewMPEG2TSDecoder(stride int) (*MPEG2TSDecoder, error) {
+	return &MPEG2TSDecoder{demux: mpegts.NewDemuxerWithStride(stride)}, nil
+}
+
+// newElementaryDecoder creates a decoder for a video stream type.
+func newElementaryDecoder(t mpegts.StreamType) (VideoDecoder, error) {
+	switch t {
+	case mpegts.StreamTypeH264:
+		return NewH264Decoder()
+	case mpegts.StreamTypeMPEG1Video, mpegts.StreamTypeMPEG2Video:
+		return NewMPEG2Decoder()
+	}
+	return nil, fmt.Errorf("unsupported elementary stream type: %s", t)
+}
+
+func (d *MPEG2TSDecoder) Write(pkt []byte) (int, error) {
+	d.demux.Write(pkt)
+	for {
+		pes, ok := d.demux.Next()
+		if !ok {
+			return len(pkt), nil
+		}
+		if d.video == nil {
+			if !pes.Type.IsVideo() {
+				continue
+			}
+			dec, err := newElementaryDecoder(pes.Type)
+			if err != nil {
+				return 0, err
+			}
+			d.video, d.pid = dec, pes.PID
+		}
+		if pes.PID != d.pid {
+			continue
+		}
+		if _, err := d.video.Write(pes.Data); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (d *MPEG2TSDecoder) WriteUSBFrame(fr *transfers.Frame) error {
+	_, err := d.Write(fr.Bytes())
+	return err
+}
+
+func (d *MPEG2TSDecoder) ReadFrame() (image.Image, error) {
+	if d.video == nil {
+		return nil, ErrEAGAIN
+	}
+	return d.video.ReadFrame()
+}
+
+// Streams returns the elementary streams of the transport stream seen so far.
+func (d *MPEG2TSDecoder) Streams() []mpegts.Stream {
+	return d.demux.Streams()
+}
+
+func (d *MPEG2TSDecoder) Close() error {
+	if d.video == nil {
+		return nil
+	}
+	return d.video.Close()
+}
@@ -8,14 +8,84 @@ import (
 	"github.com/kevmo314/go-uvc/pkg/transfers"
 )
 
+// UncompressedDecoder decodes frames of the uncompressed formats, identified by the FourCC of their
+// GUID. UVC spec 1.5, Payload_uncompressed section 2.
 type UncompressedDecoder struct {
 	images        []image.Image
 	fourcc        [4]byte
 	width, height int
+	format        uncompressedFormat
+	demosaic      Demosaic
 }
 
+// uncompressedFormat describes how to decode an uncompressed format.
+type uncompressedFormat struct {
+	// size returns the size of a frame in bytes.
+	size func(width, height int) int
+	// subsampled is set for formats with chroma subsampling or a Bayer pattern, which need an even
+	// width and height.
+	subsampled bool
+	decode     func(d *UncompressedDecoder, pkt []byte) (image.Image, error)
+}
+
+var uncompressedFormats = map[[4]byte]uncompressedFormat{
+	// packed 4:2:2, two pixels in four bytes.
+	{'Y', 'U', 'Y', '2'}: {size: bytesPerPixel(2), subsampled: true, decode: packed422(0, 1, 3)},
+	{'Y', 'U', 'Y', 'V'}: {size: bytesPerPixel(2), subsampled: true, decode: packed422(0, 1, 3)},
+	{'U', 'Y', 'V', 'Y'}: {size: bytesPerPixel(2), subsampled: true, decode: packed422(1, 0, 2)},
+	{'Y', 'V', 'Y', 'U'}: {size: bytesPerPixel(2), subsampled: true, decode: packed422(0, 3, 1)},
+	// 4:2:0, a luma plane followed by interleaved chroma.
+	{'N', 'V', '1', '2'}: {size: size420, subsampled: true, decode: semiPlanar420(nv12Rows, 0, 1)},
+	{'N', 'V', '2', '1'}: {size: size420, subsampled: true, decode: semiPlanar420(nv12Rows, 1, 0)},
+	// 4:2:0, two luma rows followed by a row of interleaved chroma.
+	{'M', '4', '2', '0'}: {size: size420, subsampled: true, decode: semiPlanar420(m420Rows, 0, 1)},
+	// planar 4:2:0.
+	{'I', '4', '2', '0'}: {size: size420, subsampled: true, decode: planar420(false)},
+	{'I', 'Y', 'U', 'V'}: {size: size420, subsampled: true, decode: planar420(false)},
+	{'Y', 'V', '1', '2'}: {size: size420, subsampled: true, decode: planar420(true)},
+	// luma only.
+	{'Y', '8', '0', '0'}: {size: bytesPerPixel(1), decode: gray8},
+	{'Y', '8', ' ', ' '}: {size: bytesPerPixel(1), decode: gray8},
+	{'G', 'R', 'E', 'Y'}: {size: bytesPerPixel(1), decode: gray8},
+	{'Y', '1', '6', ' '}: {size: bytesPerPixel(2), decode: gray16},
+	// RGB.
+	{'B', 'G', 'R', '3'}: {size: bytesPerPixel(3), decode: bgr24},
+	{'R', 'G', 'B', '3'}: {size: bytesPerPixel(3), decode: rgb24},
+	{'R', 'G', 'B', 'P'}: {size: bytesPerPixel(2), decode: rgb565},
+	// 10-bit 4:2:0 in the high bits of 16-bit samples, laid out like NV12.
+	{'P', '0', '1', '0'}: {size: func(w, h int) int { return 2 * size420(w, h) }, subsampled: true, decode: p010},
+	// 10 and 12-bit luma, in the low bits of 16-bit samples or packed as MIPI CSI-2 RAW10 and RAW12.
+	{'Y', '1', '0', ' '}: {size: bytesPerPixel(2), decode: gray(unpack16(10))},
+	{'Y', '1', '2', ' '}: {size: bytesPerPixel(2), decode: gray(unpack16(12))},
+	{'Y', '1', '0', 'P'}: {size: mipi10Size, decode: gray(unpackMIPI10)},
+	{'Y', '1', '2', 'P'}: {size: mipi12Size, decode: gray(unpackMIPI12)},
+	// 8-bit Bayer, demosaiced into 16-bit RGB.
+	{'B', 'A', '8', '1'}: {size: bytesPerPixel(1), subsampled: true, decode: bayer(bggr, unpack8)},
+	{'G', 'B', 'R', 'G'}: {size: bytesPerPixel(1), subsampled: true, decode: bayer(gbrg, unpack8)},
+	{'G', 'R', 'B', 'G'}: {size: bytesPerPixel(1), subsampled: true, decode: bayer(grbg, unpack8)},
+	{'R', 'G', 'G', 'B'}: {size: bytesPerPixel(1), subsampled: true, decode: bayer(rggb, unpack8)},
+	// 10-bit Bayer packed as MIPI CSI-2 RAW10.
+	{'p', 'B', 'A', 'A'}: {size: mipi10Size, subsampled: true, decode: bayer(bggr, unpackMIPI10)},
+	{'p', 'G', 'A', 'A'}: {size: mipi10Size, subsampled: true, decode: bayer(gbrg, unpackMIPI10)},
+	{'p', 'g', 'A', 'A'}: {size: mipi10Size, subsampled: true, decode: bayer(grbg, unpackMIPI10)},
+	{'p', 'R', 'A', 'A'}: {size: mipi10Size, subsampled: true, decode: bayer(rggb, unpackMIPI10)},
+	// 12-bit Bayer packed as MIPI CSI-2 RAW12.
+	{'p', 'B', 'C', 'C'}: {size: mipi12Size, subsampled: true, decode: bayer(bggr, unpackMIPI12)},
+	{'p', 'G', 'C', 'C'}: {size: mipi12Size, subsampled: true, decode: bayer(gbrg, unpackMIPI12)},
+	{'p', 'g', 'C', 'C'}: {size: mipi12Size, subsampled: true, decode: bayer(grbg, unpackMIPI12)},
+	{'p', 'R', 'C', 'C'}: {size: mipi12Size, subsampled: true, decode: bayer(rggb, unpackMIPI12)},
+}
+
+// NewUncompressedDecoder returns a decoder for frames of the given FourCC and size.
 func NewUncompressedDecoder(fourcc [4]byte, width, height int) (*UncompressedDecoder, error) {
-	return &UncompressedDecoder{fourcc: fourcc, width: width, height: height}, nil
+	f, ok := uncompressedFormats[fourcc]
+	if !ok {
+		return nil, fmt.Errorf("unsupported uncompressed format %q", fourcc[:])
+	}
+	if width <= 0 || height <= 0 || f.subsampled && (width%2 != 0 || height%2 != 0) {
+		return nil, fmt.Errorf("invalid frame size %dx%d for %q", width, height, fourcc[:])
+	}
+	return &UncompressedDecoder{fourcc: fourcc, width: width, height: height, format: f}, nil
 }
 
 func (d *UncompressedDecoder) ReadFrame() (image.Image, error) {
@@ -27,35 +97,188 @@ func (d *UncompressedDecoder) ReadFrame() (image.Image, error) {
 	return img, nil
 }
 
+// Write decodes a frame. The image does not reference pkt.
 func (d *UncompressedDecoder) Write(pkt []byte) (int, error) {
-	switch d.fourcc {
-	case [4]byte{'I', '4', '2', '0'}:
-		img := image.NewYCbCr(image.Rect(0, 0, d.width, d.height), image.YCbCrSubsampleRatio420)
-		img.Y = pkt[:d.width*d.height]
-		img.Cb = pkt[d.width*d.height : d.width*d.height*2]
-		img.Cr = pkt[d.width*d.height*2 : d.width*d.height*3]
-		return len(pkt), nil
-	case [4]byte{'Y', 'U', 'Y', '2'}:
-		img := image.NewYCbCr(image.Rect(0, 0, d.width, d.height), image.YCbCrSubsampleRatio422)
-		img.Y = pkt[:d.width*d.height]
-		img.Cb = pkt[d.width*d.height : d.width*d.height*2]
-		img.Cr = pkt[d.width*d.height*2 : d.width*d.height*3]
-		return len(pkt), nil
-	}
-	return 0, fmt.Errorf("unknown FourCC for GUID %s", d.fourcc)
+	if n := d.format.size(d.width, d.height); len(pkt) < n {
+		return 0, fmt.Errorf("%q frame of %d bytes, want %d: %w", d.fourcc[:], len(pkt), n, io.ErrUnexpectedEOF)
+	}
+	img, err := d.format.decode(d, pkt)
+	if err != nil {
+		return 0, err
+	}
+	d.images = append(d.images, img)
+	return len(pkt), nil
 }
 
 func (d *UncompressedDecoder) WriteUSBFrame(fr *transfers.Frame) error {
-	buf, err := io.ReadAll(fr)
-	if err != nil {
-		return err
-	}
-	if _, err := d.Write(buf); err != nil {
-		return err
-	}
-	return nil
+	_, err := d.Write(fr.Bytes())
+	return err
 }
 
 func (d *UncompressedDecoder) Close() error {
 	return nil
 }
+
+func (d *UncompressedDecoder) rect() image.Rectangle {
+	return image.Rect(0, 0, d.width, d.height)
+}
+
+func bytesPerPixel(n int) func(width, height int) int {
+	return func(width, height int) int { return n * width * height }
+}
+
+func size420(width, height int) int {
+	return width*height + width*height/2
+}
+
+func mipi10Size(width, height int) int {
+	return mipi10Stride(width) * height
+}
+
+func mipi12Size(width, height int) int {
+	return mipi12Stride(width) * height
+}
+
+// packed422 decodes 4:2:2 formats that pack two pixels in four bytes. yi is the offset of the first
+// luma sample, the second one follows two bytes later.
+func packed422(yi, cbi, cri int) func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	return func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+		img := image.NewYCbCr(d.rect(), image.YCbCrSubsampleRatio422)
+		for y := 0; y < d.height; y++ {
+			row := pkt[y*d.width*2 : (y+1)*d.width*2]
+			ys := img.Y[y*img.YStride:]
+			cbs, crs := img.Cb[y*img.CStride:], img.Cr[y*img.CStride:]
+			for x := 0; x < d.width/2; x++ {
+				m := row[x*4 : x*4+4]
+				ys[2*x], ys[2*x+1] = m[yi], m[yi+2]
+				cbs[x], crs[x] = m[cbi], m[cri]
+			}
+		}
+		return img, nil
+	}
+}
+
+// nv12Rows returns the offsets of a luma row and of a chroma row of NV12 and NV21 frames.
+func nv12Rows(width, height int) (luma, chroma func(int) int) {
+	return func(y int) int { return y * width },
+		func(j int) int { return width*height + j*width }
+}
+
+// m420Rows returns the offsets of a luma row and of a chroma row of M420 frames, which repeat two
+// luma rows and a chroma row.
+func m420Rows(width, height int) (luma, chroma func(int) int) {
+	return func(y int) int { return (y/2*3 + y%2) * width },
+		func(j int) int { return (j*3 + 2) * width }
+}
+
+// semiPlanar420 decodes 4:2:0 formats with interleaved chroma rows. cbi and cri are the offsets of
+// the chroma samples within a pair.
+func semiPlanar420(rows func(width, height int) (luma, chroma func(int) int), cbi, cri int) func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	return func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+		img := image.NewYCbCr(d.rect(), image.YCbCrSubsampleRatio420)
+		luma, chroma := rows(d.width, d.height)
+		for y := 0; y < d.height; y++ {
+			copy(img.Y[y*img.YStride:y*img.YStride+d.width], pkt[luma(y):])
+		}
+		for j := 0; j < d.height/2; j++ {
+			row := pkt[chroma(j) : chroma(j)+d.width]
+			cbs, crs := img.Cb[j*img.CStride:], img.Cr[j*img.CStride:]
+			for x := 0; x < d.width/2; x++ {
+				cbs[x], crs[x] = row[2*x+cbi], row[2*x+cri]
+			}
+		}
+		return img, nil
+	}
+}
+
+// planar420 decodes 4:2:0 formats with a plane per component, the Cr plane first if crFirst is set.
+func planar420(crFirst bool) func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	return func(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+		img := image.NewYCbCr(d.rect(), image.YCbCrSubsampleRatio420)
+		n, c := d.width*d.height, d.width*d.height/4
+		copy(img.Y, pkt[:n])
+		cb, cr := pkt[n:n+c], pkt[n+c:n+2*c]
+		if crFirst {
+			cb, cr = cr, cb
+		}
+		copy(img.Cb, cb)
+		copy(img.Cr, cr)
+		return img, nil
+	}
+}
+
+func gray8(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	img := image.NewGray(d.rect())
+	copy(img.Pix, pkt)
+	return img, nil
+}
+
+// gray16 decodes little endian 16-bit luma, image.Gray16 is big endian.
+func gray16(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	img := image.NewGray16(d.rect())
+	for i := 0; i < len(img.Pix); i += 2 {
+		img.Pix[i], img.Pix[i+1] = pkt[i+1], pkt[i]
+	}
+	return img, nil
+}
+
+func bgr24(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	n := d.width * d.height * 3
+	return &BGR{Pix: append([]byte(nil), pkt[:n]...), Stride: d.width * 3, Rect: d.rect()}, nil
+}
+
+func rgb24(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	n := d.width * d.height * 3
+	return &RGB{Pix: append([]byte(nil), pkt[:n]...), Stride: d.width * 3, Rect: d.rect()}, nil
+}
+
+// rgb565 decodes little endian 16-bit pixels with red in the high bits.
+func rgb565(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	img := image.NewRGBA(d.rect())
+	for i := 0; i < d.width*d.height; i++ {
+		v := uint16(pkt[2*i]) | uint16(pkt[2*i+1])<<8
+		r, g, b := uint8(v>>11), uint8(v>>5&0x3f), uint8(v&0x1f)
+		// replicate the high bits so that full scale maps to 0xff.
+		img.Pix[4*i+0] = r<<3 | r>>2
+		img.Pix[4*i+1] = g<<2 | g>>4
+		img.Pix[4*i+2] = b<<3 | b>>2
+		img.Pix[4*i+3] = 0xff
+	}
+	return img, nil
+}
+
+// p010 decodes 10-bit 4:2:0 frames into 16-bit RGB with the conversion of image/color.YCbCrToRGB.
+func p010(d *UncompressedDecoder, pkt []byte) (image.Image, error) {
+	sample := func(i int) int32 {
+		v := uint16(pkt[2*i]) | uint16(pkt[2*i+1])<<8
+		return int32(v | v>>10)
+	}
+	img := image.NewRGBA64(d.rect())
+	n := d.width * d.height
+	for y := 0; y < d.height; y++ {
+		for x := 0; x < d.width; x++ {
+			c := n + y/2*d.width + x/2*2
+			r, g, b := ycbcrToRGB16(sample(y*d.width+x), sample(c), sample(c+1))
+			i := img.PixOffset(x, y)
+			img.Pix[i+0], img.Pix[i+1] = uint8(r>>8), uint8(r)
+			img.Pix[i+2], img.Pix[i+3] = uint8(g>>8), uint8(g)
+			img.Pix[i+4], img.Pix[i+5] = uint8(b>>8), uint8(b)
+			img.Pix[i+6], img.Pix[i+7] = 0xff, 0xff
+		}
+	}
+	return img, nil
+}
+
+// ycbcrToRGB16 converts full range 16-bit BT.601 samples, like color.YCbCrToRGB does for 8 bits.
+func ycbcrToRGB16(y, cb, cr int32) (uint16, uint16, uint16) {
+	cb, cr = cb-0x8000, cr-0x8000
+	// the coefficients are scaled by 1<<16.
+	r := int64(y) + (91881*int64(cr))>>16
+	g := int64(y) - (22554*int64(cb)+46802*int64(cr))>>16
+	b := int64(y) + (116130*int64(cb))>>16
+	return clamp16(r), clamp16(g), clamp16(b)
+}
+
+func clamp16(v int64) uint16 {
+	return uint16(min(max(v, 0), 0xffff))
+}
@@ -0,0 +1,260 @@
+package decode
+
+import (
+	"errors"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden images of testdata")
+
+const patternWidth, patternHeight = 16, 8
+
+// patternColor returns the color of the test pattern at (x, y): color bars darkening from top to
+// bottom, constant over 2x2 blocks so that chroma subsampling is lossless.
+func patternColor(x, y int) color.RGBA {
+	bars := []color.RGBA{
+		{0xff, 0xff, 0xff, 0xff}, {0xff, 0xff, 0x00, 0xff}, {0x00, 0xff, 0xff, 0xff}, {0x00, 0xff, 0x00, 0xff},
+		{0xff, 0x00, 0xff, 0xff}, {0xff, 0x00, 0x00, 0xff}, {0x00, 0x00, 0xff, 0xff}, {0x40, 0x80, 0xc0, 0xff},
+	}
+	c := bars[x/2]
+	scale := func(v uint8) uint8 { return uint8(int(v) * (255 - y/2*60) / 255) }
+	return color.RGBA{scale(c.R), scale(c.G), scale(c.B), 0xff}
+}
+
+func patternYCbCr(x, y int) color.YCbCr {
+	c := patternColor(x, y)
+	yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+	return color.YCbCr{yy, cb, cr}
+}
+
+// pack builds a frame by appending the bytes returned by f for every pixel, or for every 2x2 block
+// of pixels if step is 2.
+func pack(step int, f func(x, y int) []byte) []byte {
+	var buf []byte
+	for y := 0; y < patternHeight; y += step {
+		for x := 0; x < patternWidth; x += step {
+			buf = append(buf, f(x, y)...)
+		}
+	}
+	return buf
+}
+
+func packPacked422(order string) []byte {
+	var buf []byte
+	for y := 0; y < patternHeight; y++ {
+		for x := 0; x < patternWidth; x += 2 {
+			c0, c1 := patternYCbCr(x, y), patternYCbCr(x+1, y)
+			for _, s := range order {
+				buf = append(buf, map[rune]uint8{'Y': c0.Y, 'y': c1.Y, 'U': c0.Cb, 'V': c0.Cr}[s])
+			}
+		}
+	}
+	return buf
+}
+
+func lumaPlane() []byte {
+	return pack(1, func(x, y int) []byte { return []byte{patternYCbCr(x, y).Y} })
+}
+
+func chromaPlane(cr bool) []byte {
+	return pack(2, func(x, y int) []byte {
+		if c := patternYCbCr(x, y); cr {
+			return []byte{c.Cr}
+		} else {
+			return []byte{c.Cb}
+		}
+	})
+}
+
+func interleavedChroma(crFirst bool) []byte {
+	return pack(2, func(x, y int) []byte {
+		c := patternYCbCr(x, y)
+		if crFirst {
+			return []byte{c.Cr, c.Cb}
+		}
+		return []byte{c.Cb, c.Cr}
+	})
+}
+
+func packM420() []byte {
+	luma, chroma := lumaPlane(), interleavedChroma(false)
+	var buf []byte
+	for j := 0; j < patternHeight/2; j++ {
+		buf = append(buf, luma[2*j*patternWidth:(2*j+2)*patternWidth]...)
+		buf = append(buf, chroma[j*patternWidth:(j+1)*patternWidth]...)
+	}
+	return buf
+}
+
+// p010Sample returns a little endian P010 sample holding the 10-bit extension of an 8-bit value.
+func p010Sample(v uint8) []byte {
+	s := (uint16(v)<<2 | uint16(v)>>6) << 6
+	return []byte{uint8(s), uint8(s >> 8)}
+}
+
+func packP010() []byte {
+	buf := pack(1, func(x, y int) []byte { return p010Sample(patternYCbCr(x, y).Y) })
+	return append(buf, pack(2, func(x, y int) []byte {
+		c := patternYCbCr(x, y)
+		return append(p010Sample(c.Cb), p010Sample(c.Cr)...)
+	})...)
+}
+
+func packRGB565(x, y int) []byte {
+	c := patternColor(x, y)
+	v := uint16(c.R>>3)<<11 | uint16(c.G>>2)<<5 | uint16(c.B>>3)
+	return []byte{uint8(v), uint8(v >> 8)}
+}
+
+func ycbcrPattern(x, y int) color.Color { return patternYCbCr(x, y) }
+
+func rgbPattern(x, y int) color.Color { return patternColor(x, y) }
+
+func grayPattern(x, y int) color.Color { return color.Gray{patternYCbCr(x, y).Y} }
+
+var uncompressedTests = []struct {
+	fourcc string
+	frame  []byte
+	want   func(x, y int) color.Color
+	// tolerance is the maximum difference from want per 8-bit channel.
+	tolerance int
+}{
+	{"YUY2", packPacked422("YUyV"), ycbcrPattern, 0},
+	{"UYVY", packPacked422("UYVy"), ycbcrPattern, 0},
+	{"YVYU", packPacked422("YVyU"), ycbcrPattern, 0},
+	{"NV12", append(lumaPlane(), interleavedChroma(false)...), ycbcrPattern, 0},
+	{"NV21", append(lumaPlane(), interleavedChroma(true)...), ycbcrPattern, 0},
+	{"M420", packM420(), ycbcrPattern, 0},
+	{"I420", append(append(lumaPlane(), chromaPlane(false)...), chromaPlane(true)...), ycbcrPattern, 0},
+	{"YV12", append(append(lumaPlane(), chromaPlane(true)...), chromaPlane(false)...), ycbcrPattern, 0},
+	{"Y800", lumaPlane(), grayPattern, 0},
+	{"GREY", lumaPlane(), grayPattern, 0},
+	{"Y16 ", pack(1, func(x, y int) []byte { v := patternYCbCr(x, y).Y; return []byte{v, v} }), grayPattern, 0},
+	{"BGR3", pack(1, func(x, y int) []byte { c := patternColor(x, y); return []byte{c.B, c.G, c.R} }), rgbPattern, 0},
+	{"RGB3", pack(1, func(x, y int) []byte { c := patternColor(x, y); return []byte{c.R, c.G, c.B} }), rgbPattern, 0},
+	{"RGBP", pack(1, packRGB565), rgbPattern, 8},
+	{"P010", packP010(), ycbcrPattern, 2},
+	{"Y10 ", pack(1, func(x, y int) []byte { return packLE16(patternYCbCr(x, y).Y, 10) }), grayPattern, 0},
+	{"Y12 ", pack(1, func(x, y int) []byte { return packLE16(patternYCbCr(x, y).Y, 12) }), grayPattern, 0},
+	{"Y10P", packMIPI10(func(x, y int) uint8 { return patternYCbCr(x, y).Y }), grayPattern, 0},
+	{"Y12P", packMIPI12(func(x, y int) uint8 { return patternYCbCr(x, y).Y }), grayPattern, 0},
+}
+
+// checkGolden compares img with the golden image at path, writing it instead with -update.
+func checkGolden(t *testing.T, img image.Image, path string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	defer f.Close()
+	golden, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if golden.Bounds() != img.Bounds() {
+		t.Fatalf("bounds = %v, golden %v", img.Bounds(), golden.Bounds())
+	}
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			if got, want := color.RGBA64Model.Convert(img.At(x, y)), color.RGBA64Model.Convert(golden.At(x, y)); got != want {
+				t.Fatalf("pixel (%d, %d) = %v, golden %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// checkPixels compares img with want, allowing tolerance per 8-bit channel.
+func checkPixels(t *testing.T, img image.Image, want func(x, y int) color.Color, tolerance int) {
+	t.Helper()
+	diff := func(a, b uint32) int { return max(int(a>>8)-int(b>>8), int(b>>8)-int(a>>8)) }
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			wr, wg, wb, wa := want(x, y).RGBA()
+			if diff(r, wr) > tolerance || diff(g, wg) > tolerance || diff(b, wb) > tolerance || a != wa {
+				t.Fatalf("pixel (%d, %d) = %04x %04x %04x %04x, want %04x %04x %04x %04x", x, y, r, g, b, a, wr, wg, wb, wa)
+			}
+		}
+	}
+}
+
+func TestUncompressedDecoder(t *testing.T) {
+	for _, tc := range uncompressedTests {
+		t.Run(strings.TrimSpace(tc.fourcc), func(t *testing.T) {
+			d, err := NewUncompressedDecoder([4]byte([]byte(tc.fourcc)), patternWidth, patternHeight)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.ReadFrame(); !errors.Is(err, ErrEAGAIN) {
+				t.Fatalf("ReadFrame before Write = %v, want ErrEAGAIN", err)
+			}
+			if _, err := d.Write(tc.frame[:len(tc.frame)-1]); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("Write of a short frame = %v, want io.ErrUnexpectedEOF", err)
+			}
+			if n, err := d.Write(tc.frame); err != nil || n != len(tc.frame) {
+				t.Fatalf("Write = %d, %v", n, err)
+			}
+			img, err := d.ReadFrame()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Bounds() != image.Rect(0, 0, patternWidth, patternHeight) {
+				t.Fatalf("bounds = %v", img.Bounds())
+			}
+			// the image must not reference the frame.
+			for i := range tc.frame {
+				tc.frame[i] ^= 0xff
+			}
+			defer func() {
+				for i := range tc.frame {
+					tc.frame[i] ^= 0xff
+				}
+			}()
+			checkPixels(t, img, tc.want, tc.tolerance)
+			checkGolden(t, img, filepath.Join("testdata", "uncompressed", strings.TrimSpace(tc.fourcc)+".png"))
+		})
+	}
+}
+
+func TestNewUncompressedDecoder_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		fourcc        string
+		width, height int
+	}{
+		{"ABCD", 16, 8},
+		{"YUY2", 15, 8},
+		{"NV12", 16, 7},
+		{"Y800", 0, 8},
+	} {
+		if _, err := NewUncompressedDecoder([4]byte([]byte(tc.fourcc)), tc.width, tc.height); err == nil {
+			t.Errorf("NewUncompressedDecoder(%q, %d, %d) succeeded", tc.fourcc, tc.width, tc.height)
+		}
+	}
+	// odd sizes are fine without chroma subsampling.
+	if _, err := NewUncompressedDecoder([4]byte{'Y', '8', '0', '0'}, 15, 7); err != nil {
+		t.Errorf("NewUncompressedDecoder with an odd size failed: %v", err)
+	}
+}
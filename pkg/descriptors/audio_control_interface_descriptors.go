@@ -1,6 +1,9 @@
 package descriptors
 
-import "io"
+import (
+	"fmt"
+	"io"
+)
 
 type AudioControlInterfaceDescriptorSubtype byte
 
@@ -59,6 +62,18 @@ func (achd *AudioControlHeaderDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (achd *AudioControlHeaderDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(8+len(achd.InterfaceNr), ClassSpecificDescriptorTypeInterface, uint8(AudioControlInterfaceDescriptorSubtypeHeader))
+	if err != nil {
+		return nil, err
+	}
+	buf[3], buf[4] = uint8(achd.BcdADC), uint8(achd.BcdADC>>8)
+	buf[5], buf[6] = uint8(achd.TotalLength), uint8(achd.TotalLength>>8)
+	buf[7] = uint8(len(achd.InterfaceNr))
+	copy(buf[8:], achd.InterfaceNr)
+	return buf, nil
+}
+
 // AudioInputTerminalDescriptor represents an input terminal descriptor
 type AudioInputTerminalDescriptor struct {
 	TerminalID    uint8
@@ -88,6 +103,21 @@ func (aitd *AudioInputTerminalDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (aitd *AudioInputTerminalDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(12, ClassSpecificDescriptorTypeInterface, uint8(AudioControlInterfaceDescriptorSubtypeInputTerminal))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = aitd.TerminalID
+	buf[4], buf[5] = uint8(aitd.TerminalType), uint8(aitd.TerminalType>>8)
+	buf[6] = aitd.AssocTerminal
+	buf[7] = aitd.NrChannels
+	buf[8], buf[9] = uint8(aitd.ChannelConfig), uint8(aitd.ChannelConfig>>8)
+	buf[10] = aitd.ChannelNames
+	buf[11] = aitd.Terminal
+	return buf, nil
+}
+
 // AudioOutputTerminalDescriptor represents an output terminal descriptor
 type AudioOutputTerminalDescriptor struct {
 	TerminalID    uint8
@@ -113,6 +143,19 @@ func (aotd *AudioOutputTerminalDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (aotd *AudioOutputTerminalDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(9, ClassSpecificDescriptorTypeInterface, uint8(AudioControlInterfaceDescriptorSubtypeOutputTerminal))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = aotd.TerminalID
+	buf[4], buf[5] = uint8(aotd.TerminalType), uint8(aotd.TerminalType>>8)
+	buf[6] = aotd.AssocTerminal
+	buf[7] = aotd.SourceID
+	buf[8] = aotd.Terminal
+	return buf, nil
+}
+
 // AudioFeatureUnitDescriptor represents a feature unit descriptor
 type AudioFeatureUnitDescriptor struct {
 	UnitID      uint8
@@ -133,6 +176,9 @@ func (afud *AudioFeatureUnitDescriptor) UnmarshalBinary(buf []byte) error {
 	afud.UnitID = buf[3]
 	afud.SourceID = buf[4]
 	afud.ControlSize = buf[5]
+	if afud.ControlSize == 0 {
+		return ErrInvalidDescriptor
+	}
 
 	// Calculate number of controls
 	numControls := (len(buf) - 7) / int(afud.ControlSize)
@@ -145,3 +191,19 @@ func (afud *AudioFeatureUnitDescriptor) UnmarshalBinary(buf []byte) error {
 	afud.Feature = buf[6+len(afud.Controls)]
 	return nil
 }
+
+func (afud *AudioFeatureUnitDescriptor) MarshalBinary() ([]byte, error) {
+	if afud.ControlSize == 0 || len(afud.Controls)%int(afud.ControlSize) != 0 {
+		return nil, fmt.Errorf("%d bytes of controls are not a multiple of the control size %d: %w", len(afud.Controls), afud.ControlSize, ErrInvalidDescriptor)
+	}
+	buf, err := newDescriptor(7+len(afud.Controls), ClassSpecificDescriptorTypeInterface, uint8(AudioControlInterfaceDescriptorSubtypeFeatureUnit))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = afud.UnitID
+	buf[4] = afud.SourceID
+	buf[5] = afud.ControlSize
+	copy(buf[6:], afud.Controls)
+	buf[6+len(afud.Controls)] = afud.Feature
+	return buf, nil
+}
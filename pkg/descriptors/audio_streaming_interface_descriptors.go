@@ -1,7 +1,5 @@
 package descriptors
 
-import "io"
-
 type AudioStreamingInterfaceDescriptorSubtype byte
 
 const (
@@ -28,8 +26,8 @@ type StandardAudioStreamingInterfaceDescriptor struct {
 }
 
 func (sasid *StandardAudioStreamingInterfaceDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 9); err != nil {
+		return err
 	}
 	// TODO: check the descriptor type, this is not the class specific one.
 	// if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
@@ -44,3 +42,16 @@ func (sasid *StandardAudioStreamingInterfaceDescriptor) UnmarshalBinary(buf []by
 	sasid.DescriptionIndex = buf[8]
 	return nil
 }
+
+func (sasid *StandardAudioStreamingInterfaceDescriptor) MarshalBinary() ([]byte, error) {
+	return []byte{
+		9, 0x04, // bLength, INTERFACE
+		sasid.InterfaceNumber,
+		sasid.AlternateSetting,
+		sasid.NumEndpoints,
+		sasid.InterfaceClass,
+		sasid.InterfaceSubClass,
+		sasid.InterfaceProtocol,
+		sasid.DescriptionIndex,
+	}, nil
+}
@@ -3,6 +3,8 @@ package descriptors
 import (
 	"encoding"
 	"encoding/binary"
+	"fmt"
+	"io"
 	"time"
 )
 
@@ -42,7 +44,12 @@ type VideoProbeCommitControl struct {
 	LayoutPerStream           [4]uint16
 }
 
+// MarshalInto encodes the control into buf, which holds the 26 bytes of UVC 1.0, the 34 bytes of UVC
+// 1.1 or the 48 bytes of UVC 1.5.
 func (vpcc *VideoProbeCommitControl) MarshalInto(buf []byte) error {
+	if n := len(buf); n < 26 || (n > 26 && n < 34) || (n > 34 && n < 48) {
+		return fmt.Errorf("probe and commit control of %d bytes: %w", n, io.ErrShortBuffer)
+	}
 	binary.LittleEndian.PutUint16(buf[0:2], vpcc.HintBitmask)
 	buf[2] = vpcc.FormatIndex
 	buf[3] = vpcc.FrameIndex
@@ -82,6 +89,10 @@ func (vpcc *VideoProbeCommitControl) MarshalBinary() ([]byte, error) {
 }
 
 func (vpcc *VideoProbeCommitControl) UnmarshalBinary(buf []byte) error {
+	// UVC 1.0 devices send 26 bytes, UVC 1.1 devices 34 and UVC 1.5 devices 48.
+	if len(buf) < 26 {
+		return io.ErrShortBuffer
+	}
 	// this descriptor is not length and control-selector prefixed because
 	// libusb unwraps the control transfers for us.
 	vpcc.HintBitmask = binary.LittleEndian.Uint16(buf[0:2])
@@ -100,7 +111,7 @@ func (vpcc *VideoProbeCommitControl) UnmarshalBinary(buf []byte) error {
 	vpcc.MaxVideoFrameSize = binary.LittleEndian.Uint32(buf[18:22])
 	vpcc.MaxPayloadTransferSize = binary.LittleEndian.Uint32(buf[22:26])
 
-	if len(buf) > 26 {
+	if len(buf) >= 34 {
 		vpcc.ClockFrequency = binary.LittleEndian.Uint32(buf[26:30])
 		vpcc.FramingInfoBitmask = buf[30]
 		vpcc.PreferedVersion = buf[31]
@@ -108,7 +119,7 @@ func (vpcc *VideoProbeCommitControl) UnmarshalBinary(buf []byte) error {
 		vpcc.MaxVersion = buf[33]
 	}
 
-	if len(buf) > 34 {
+	if len(buf) >= 48 {
 		vpcc.Usage = buf[34]
 		vpcc.BitDepthLuma = buf[35]
 		vpcc.SettingsBitmask = buf[36]
@@ -122,6 +133,17 @@ func (vpcc *VideoProbeCommitControl) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+// Simulcast reports whether more than one simulcast stream is enabled in LayoutPerStream.
+func (vpcc *VideoProbeCommitControl) Simulcast() bool {
+	n := 0
+	for _, l := range vpcc.LayoutPerStream {
+		if StreamLayout(l).Enabled() {
+			n++
+		}
+	}
+	return n > 1
+}
+
 // Control Request for Scanning Mode as defined in UVC spec 1.5, 4.2.2.1.1
 type ScanningModeControl struct {
 	Mode ScanningMode
@@ -142,6 +164,9 @@ func (smc *ScanningModeControl) MarshalBinary() ([]byte, error) {
 }
 
 func (smc *ScanningModeControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	smc.Mode = ScanningMode(buf[0])
 	return nil
 }
@@ -166,6 +191,9 @@ func (aemc *AutoExposureModeControl) MarshalBinary() ([]byte, error) {
 }
 
 func (aemc *AutoExposureModeControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	aemc.Mode = AutoExposureMode(buf[0])
 	return nil
 }
@@ -190,6 +218,9 @@ func (aepc *AutoExposurePriorityControl) MarshalBinary() ([]byte, error) {
 }
 
 func (aepc *AutoExposurePriorityControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	aepc.Priority = AutoExposurePriority(buf[0])
 	return nil
 }
@@ -214,6 +245,9 @@ func (etac *ExposureTimeAbsoluteControl) MarshalBinary() ([]byte, error) {
 }
 
 func (etrc *ExposureTimeAbsoluteControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 4 {
+		return io.ErrShortBuffer
+	}
 	etrc.Time = binary.LittleEndian.Uint32(buf)
 	return nil
 }
@@ -238,6 +272,9 @@ func (etrc *ExposureTimeRelativeControl) MarshalBinary() ([]byte, error) {
 }
 
 func (etrc *ExposureTimeRelativeControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	etrc.Time = ExposureTimeRelative(buf[0])
 	return nil
 }
@@ -262,6 +299,9 @@ func (fac *FocusAbsoluteControl) MarshalBinary() ([]byte, error) {
 }
 
 func (fac *FocusAbsoluteControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	fac.Focus = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -288,6 +328,9 @@ func (frc *FocusRelativeControl) MarshalBinary() ([]byte, error) {
 }
 
 func (frc *FocusRelativeControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	frc.Focus = FocusRelative(buf[0])
 	frc.Speed = buf[1]
 	return nil
@@ -313,6 +356,9 @@ func (fsrc *FocusSimpleRangeControl) MarshalBinary() ([]byte, error) {
 }
 
 func (fsrc *FocusSimpleRangeControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	fsrc.Focus = FocusSimple(buf[0])
 	return nil
 }
@@ -341,6 +387,9 @@ func (fac *FocusAutoControl) MarshalBinary() ([]byte, error) {
 }
 
 func (fac *FocusAutoControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	fac.FocusAuto = buf[0] == 1
 	return nil
 }
@@ -365,6 +414,9 @@ func (iac *IrisAbsoluteControl) MarshalBinary() ([]byte, error) {
 }
 
 func (iac *IrisAbsoluteControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	iac.Aperture = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -389,6 +441,9 @@ func (irc *IrisRelativeControl) MarshalBinary() ([]byte, error) {
 }
 
 func (irc *IrisRelativeControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	irc.Aperture = IrisRelative(buf[0])
 	return nil
 }
@@ -413,6 +468,9 @@ func (zac *ZoomAbsoluteControl) MarshalBinary() ([]byte, error) {
 }
 
 func (zac *ZoomAbsoluteControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	zac.ObjectiveFocalLength = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -447,6 +505,9 @@ func (zrc *ZoomRelativeControl) MarshalBinary() ([]byte, error) {
 }
 
 func (zrc *ZoomRelativeControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 3 {
+		return io.ErrShortBuffer
+	}
 	zrc.Zoom = ZoomRelative(buf[0])
 	zrc.DigitalZoom = buf[1] == 1
 	zrc.Speed = buf[2]
@@ -475,6 +536,9 @@ func (ptac *PanTiltAbsoluteControl) MarshalBinary() ([]byte, error) {
 }
 
 func (ptac *PanTiltAbsoluteControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 8 {
+		return io.ErrShortBuffer
+	}
 	ptac.PanAbsolute = int32(binary.LittleEndian.Uint32(buf[0:4]))
 	ptac.TiltAbsolute = int32(binary.LittleEndian.Uint32(buf[4:8]))
 	return nil
@@ -506,6 +570,9 @@ func (ptrc *PanTiltRelativeControl) MarshalBinary() ([]byte, error) {
 }
 
 func (ptrc *PanTiltRelativeControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 4 {
+		return io.ErrShortBuffer
+	}
 	ptrc.PanRelative = PanRelative(buf[0])
 	ptrc.PanSpeed = uint8(buf[1])
 	ptrc.TiltRelative = TiltRelative(buf[2])
@@ -533,7 +600,10 @@ func (rac *RollAbsoluteControl) MarshalBinary() ([]byte, error) {
 }
 
 func (rac *RollAbsoluteControl) UnmarshalBinary(buf []byte) error {
-	rac.RollAbsolute = int16(binary.LittleEndian.Uint32(buf))
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
+	rac.RollAbsolute = int16(binary.LittleEndian.Uint16(buf))
 	return nil
 }
 
@@ -559,6 +629,9 @@ func (rrc *RollRelativeControl) MarshalBinary() ([]byte, error) {
 }
 
 func (rrc *RollRelativeControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	rrc.RollRelative = RollRelative(buf[0])
 	rrc.Speed = buf[1]
 	return nil
@@ -590,6 +663,9 @@ func (pc *PrivacyControl) MarshalBinary() ([]byte, error) {
 }
 
 func (pc *PrivacyControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	pc.Privacy = buf[0] == 1
 	return nil
 }
@@ -629,6 +705,9 @@ func (dwc *DigitalWindowControl) MarshalBinary() ([]byte, error) {
 }
 
 func (dwc *DigitalWindowControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 12 {
+		return io.ErrShortBuffer
+	}
 	dwc.Top = int16(binary.LittleEndian.Uint16(buf[0:2]))
 	dwc.Left = int16(binary.LittleEndian.Uint16(buf[2:4]))
 	dwc.Bottom = int16(binary.LittleEndian.Uint16(buf[4:6]))
@@ -669,6 +748,9 @@ func (roic *RegionOfInterestControl) MarshalBinary() ([]byte, error) {
 }
 
 func (roic *RegionOfInterestControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 10 {
+		return io.ErrShortBuffer
+	}
 	roic.Top = int16(binary.LittleEndian.Uint16(buf[0:2]))
 	roic.Left = int16(binary.LittleEndian.Uint16(buf[2:4]))
 	roic.Bottom = int16(binary.LittleEndian.Uint16(buf[4:6]))
@@ -2,6 +2,8 @@ package descriptors
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"testing"
 	"time"
 )
@@ -118,6 +120,15 @@ func TestVideoProbeCommitControl_MarshalInto(t *testing.T) {
 	}
 }
 
+func TestVideoProbeCommitControl_MarshalIntoInvalidSize(t *testing.T) {
+	vpcc := &VideoProbeCommitControl{FormatIndex: 1, FrameIndex: 1}
+	for _, n := range []int{0, 25, 27, 33, 35, 47} {
+		if err := vpcc.MarshalInto(make([]byte, n)); !errors.Is(err, io.ErrShortBuffer) {
+			t.Errorf("MarshalInto(%d) error = %v, want io.ErrShortBuffer", n, err)
+		}
+	}
+}
+
 func TestVideoProbeCommitControl_FrameIntervalConversion(t *testing.T) {
 	// Test that frame interval is correctly converted to/from 100ns units
 	vpcc := &VideoProbeCommitControl{
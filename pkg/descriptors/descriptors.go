@@ -1,5 +1,12 @@
 package descriptors
 
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
 func copyGUID(dst, src []byte) {
 	// copy according to the GUID format defined in UVC spec 1.5, section 2.9.
 	dst[0] = src[3]
@@ -19,3 +26,71 @@ func copyGUID(dst, src []byte) {
 	dst[14] = src[14]
 	dst[15] = src[15]
 }
+
+// newDescriptor returns a buffer for a class-specific descriptor of the given length, type and subtype,
+// or an error if the length does not fit bLength.
+func newDescriptor(length int, descriptorType ClassSpecificDescriptorType, subtype uint8) ([]byte, error) {
+	if length > 0xff {
+		return nil, fmt.Errorf("descriptor length %d exceeds 255 bytes: %w", length, ErrInvalidDescriptor)
+	}
+	buf := make([]byte, length)
+	buf[0] = uint8(length)
+	buf[1] = uint8(descriptorType)
+	buf[2] = subtype
+	return buf, nil
+}
+
+// putFrameInterval writes a frame interval in the 100 ns units of the descriptors.
+func putFrameInterval(buf []byte, d time.Duration) {
+	binary.LittleEndian.PutUint32(buf, uint32(d/100/time.Nanosecond))
+}
+
+// putFrameIntervals writes the frame intervals of a frame descriptor: the discrete intervals if there
+// are any, otherwise the continuous range. bFrameIntervalType is the number of discrete intervals.
+func putFrameIntervals(buf []byte, minimum, maximum, step time.Duration, discrete []time.Duration) {
+	if len(discrete) == 0 {
+		putFrameInterval(buf[0:4], minimum)
+		putFrameInterval(buf[4:8], maximum)
+		putFrameInterval(buf[8:12], step)
+		return
+	}
+	for i, d := range discrete {
+		putFrameInterval(buf[4*i:4+4*i], d)
+	}
+}
+
+// frameIntervalsLength returns the length of the frame intervals written by putFrameIntervals.
+func frameIntervalsLength(discrete []time.Duration) int {
+	if len(discrete) == 0 {
+		return 12
+	}
+	return 4 * len(discrete)
+}
+
+// checkDescriptor returns an error unless buf holds a whole descriptor, as given by its bLength, of
+// at least minLength bytes.
+func checkDescriptor(buf []byte, minLength int) error {
+	if len(buf) == 0 || len(buf) < int(buf[0]) {
+		return io.ErrShortBuffer
+	}
+	if int(buf[0]) < minLength {
+		return fmt.Errorf("descriptor length %d is shorter than %d bytes: %w", buf[0], minLength, ErrInvalidDescriptor)
+	}
+	return nil
+}
+
+// SplitDescriptors splits a buffer of concatenated descriptors, such as the extra descriptors of an
+// interface, at their bLength fields. It fails if a descriptor is shorter than its two byte header or
+// runs past the end of the buffer.
+func SplitDescriptors(buf []byte) ([][]byte, error) {
+	var blocks [][]byte
+	for i := 0; i < len(buf); {
+		n := int(buf[i])
+		if n < 2 || i+n > len(buf) {
+			return nil, fmt.Errorf("malformed descriptor at offset %d: %w", i, ErrInvalidDescriptor)
+		}
+		blocks = append(blocks, buf[i:i+n])
+		i += n
+	}
+	return blocks, nil
+}
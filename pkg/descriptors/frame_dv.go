@@ -12,18 +12,25 @@ type DVStreamHeader struct {
 }
 
 func (dvsh *DVStreamHeader) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 2); err != nil {
+		return err
 	}
 	dvsh.BitFieldHeader = buf[1]
 	offset := 2
 	if dvsh.HasPTS() {
+		if int(buf[0]) < offset+4 {
+			return io.ErrShortBuffer
+		}
 		dvsh.PTS = binary.LittleEndian.Uint32(buf[offset : offset+4])
 		offset += 4
 	}
 	if dvsh.HasSCR() {
-		dvsh.SCR = binary.LittleEndian.Uint64(buf[offset : offset+8])
-		offset += 8
+		// the SCR is a 32 bit source time clock followed by a 16 bit SOF token counter.
+		if int(buf[0]) < offset+6 {
+			return io.ErrShortBuffer
+		}
+		dvsh.SCR = uint64(binary.LittleEndian.Uint32(buf[offset:offset+4])) | uint64(binary.LittleEndian.Uint16(buf[offset+4:offset+6]))<<32
+		offset += 6
 	}
 	return nil
 }
@@ -63,8 +70,8 @@ type DVFormatDescriptor struct {
 }
 
 func (dvfd *DVFormatDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 9); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -78,6 +85,54 @@ func (dvfd *DVFormatDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (dvfd *DVFormatDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(9, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFormatDV))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = dvfd.FormatIndex
+	binary.LittleEndian.PutUint32(buf[4:8], dvfd.MaxVideoFrameBufferSize)
+	buf[8] = dvfd.FormatType
+	return buf, nil
+}
+
+// UVC DV payload specification 1.5: bFormatType bits D6..0 select the variant and D7 the
+// frame rate.
+const (
+	DVFormatTypeSD  = 0x00 // SD-DV
+	DVFormatTypeSDL = 0x01 // SDL-DV
+	DVFormatTypeHD  = 0x02 // HD-DV
+)
+
+// Variant returns the DV variant, one of the DVFormatType constants.
+func (dvfd *DVFormatDescriptor) Variant() uint8 {
+	return dvfd.FormatType & 0x7f
+}
+
+// Is50Hz reports whether the format is 50 Hz (625/50, PAL), as opposed to 60 Hz (525/60, NTSC).
+func (dvfd *DVFormatDescriptor) Is50Hz() bool {
+	return dvfd.FormatType&0x80 != 0
+}
+
+// FrameSize returns the size of a DIF frame of the format, or zero for unknown variants.
+func (dvfd *DVFormatDescriptor) FrameSize() int {
+	// IEC 61834: 10 DIF sequences of 12000 bytes at 60 Hz, 12 at 50 Hz. SDL-DV halves the
+	// sequences and HD-DV doubles them.
+	size := 120000
+	if dvfd.Is50Hz() {
+		size = 144000
+	}
+	switch dvfd.Variant() {
+	case DVFormatTypeSD:
+		return size
+	case DVFormatTypeSDL:
+		return size / 2
+	case DVFormatTypeHD:
+		return size * 2
+	}
+	return 0
+}
+
 func (dvfd *DVFormatDescriptor) isStreamingInterface() {}
 
 func (dvfd *DVFormatDescriptor) isFormatDescriptor() {}
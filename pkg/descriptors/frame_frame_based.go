@@ -17,18 +17,25 @@ type FrameBasedStreamHeader struct {
 }
 
 func (fbsh *FrameBasedStreamHeader) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 2); err != nil {
+		return err
 	}
 	fbsh.BitFieldHeader = buf[1]
 	offset := 2
 	if fbsh.HasPTS() {
+		if int(buf[0]) < offset+4 {
+			return io.ErrShortBuffer
+		}
 		fbsh.PTS = binary.LittleEndian.Uint32(buf[offset : offset+4])
 		offset += 4
 	}
 	if fbsh.HasSCR() {
-		fbsh.SCR = binary.LittleEndian.Uint64(buf[offset : offset+8])
-		offset += 8
+		// the SCR is a 32 bit source time clock followed by a 16 bit SOF token counter.
+		if int(buf[0]) < offset+6 {
+			return io.ErrShortBuffer
+		}
+		fbsh.SCR = uint64(binary.LittleEndian.Uint32(buf[offset:offset+4])) | uint64(binary.LittleEndian.Uint16(buf[offset+4:offset+6]))<<32
+		offset += 6
 	}
 	return nil
 }
@@ -75,8 +82,8 @@ type FrameBasedFormatDescriptor struct {
 }
 
 func (fbfd *FrameBasedFormatDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 28); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -97,6 +104,26 @@ func (fbfd *FrameBasedFormatDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (fbfd *FrameBasedFormatDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(28, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFormatFrameBased))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = fbfd.FormatIndex
+	buf[4] = fbfd.NumFrameDescriptors
+	copyGUID(buf[5:21], fbfd.GUIDFormat[:])
+	buf[21] = fbfd.BitsPerPixel
+	buf[22] = fbfd.DefaultFrameIndex
+	buf[23] = fbfd.AspectRatioX
+	buf[24] = fbfd.AspectRatioY
+	buf[25] = fbfd.InterlaceFlags
+	buf[26] = fbfd.CopyProtect
+	if fbfd.VariableSize {
+		buf[27] = 1
+	}
+	return buf, nil
+}
+
 func (fbfd *FrameBasedFormatDescriptor) FourCC() ([4]byte, error) {
 	if strings.HasSuffix(fbfd.GUIDFormat.String(), "-0000-0010-8000-00aa00389b71") {
 		buf := [4]byte{}
@@ -130,8 +157,8 @@ type FrameBasedFrameDescriptor struct {
 }
 
 func (fbfd *FrameBasedFrameDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 26); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -148,6 +175,9 @@ func (fbfd *FrameBasedFrameDescriptor) UnmarshalBinary(buf []byte) error {
 	fbfd.DefaultFrameInterval = time.Duration(binary.LittleEndian.Uint32(buf[17:21])) * 100 * time.Nanosecond
 
 	n := buf[21]
+	if n == 0 && buf[0] < 38 || int(buf[0]) < 26+4*int(n) {
+		return ErrInvalidDescriptor
+	}
 
 	fbfd.BytesPerLine = binary.LittleEndian.Uint32(buf[22:26])
 
@@ -166,6 +196,25 @@ func (fbfd *FrameBasedFrameDescriptor) UnmarshalBinary(buf []byte) error {
 	}
 }
 
+func (fbfd *FrameBasedFrameDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(26+frameIntervalsLength(fbfd.DiscreteFrameIntervals), ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFrameFrameBased))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = fbfd.FrameIndex
+	buf[4] = fbfd.Capabilities
+	binary.LittleEndian.PutUint16(buf[5:7], fbfd.Width)
+	binary.LittleEndian.PutUint16(buf[7:9], fbfd.Height)
+	binary.LittleEndian.PutUint32(buf[9:13], fbfd.MinBitRate)
+	binary.LittleEndian.PutUint32(buf[13:17], fbfd.MaxBitRate)
+	putFrameInterval(buf[17:21], fbfd.DefaultFrameInterval)
+	buf[21] = uint8(len(fbfd.DiscreteFrameIntervals))
+	binary.LittleEndian.PutUint32(buf[22:26], fbfd.BytesPerLine)
+	c := &fbfd.ContinuousFrameInterval
+	putFrameIntervals(buf[26:], c.MinFrameInterval, c.MaxFrameInterval, c.FrameIntervalStep, fbfd.DiscreteFrameIntervals)
+	return buf, nil
+}
+
 func (fbfd *FrameBasedFrameDescriptor) isStreamingInterface() {}
 
 func (fbfd *FrameBasedFrameDescriptor) isFrameDescriptor() {}
@@ -2,6 +2,7 @@ package descriptors
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 	"time"
 )
@@ -14,20 +15,27 @@ type H264StreamHeader struct {
 }
 
 func (hsh *H264StreamHeader) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 2); err != nil {
+		return err
 	}
 	hsh.BitFieldHeader = buf[1]
 	offset := 2
 	if hsh.HasPTS() {
+		if int(buf[0]) < offset+4 {
+			return io.ErrShortBuffer
+		}
 		hsh.PTS = binary.LittleEndian.Uint32(buf[offset : offset+4])
 		offset += 4
 	}
 	if hsh.HasSCR() {
-		hsh.SCR = binary.LittleEndian.Uint64(buf[offset : offset+8])
-		offset += 8
+		// the SCR is a 32 bit source time clock followed by a 16 bit SOF token counter.
+		if int(buf[0]) < offset+6 {
+			return io.ErrShortBuffer
+		}
+		hsh.SCR = uint64(binary.LittleEndian.Uint32(buf[offset:offset+4])) | uint64(binary.LittleEndian.Uint16(buf[offset+4:offset+6]))<<32
+		offset += 6
 	}
-	if len(buf) >= offset+2 {
+	if int(buf[0]) >= offset+2 {
 		hsh.SLI = binary.LittleEndian.Uint16(buf[offset : offset+2])
 		offset += 2
 	}
@@ -66,6 +74,70 @@ func (hsh *H264StreamHeader) EndOfHeader() bool {
 	return hsh.BitFieldHeader&0b10000000 != 0
 }
 
+// LayerID returns the stream and layer the payload belongs to.
+func (hsh *H264StreamHeader) LayerID() LayerID {
+	return LayerID(hsh.SLI)
+}
+
+// LayerID is the wLayerOrViewID field that follows the standard payload header of simulcast and
+// scalable H.264 and VP8 streams. UVC H.264 payload spec 1.5, section 2.2.
+type LayerID uint16
+
+// DependencyID returns the spatial layer.
+func (id LayerID) DependencyID() int {
+	return int(id & 0x7)
+}
+
+// TemporalID returns the temporal layer.
+func (id LayerID) TemporalID() int {
+	return int(id >> 3 & 0x7)
+}
+
+// QualityID returns the quality layer.
+func (id LayerID) QualityID() int {
+	return int(id >> 6 & 0x7)
+}
+
+// StreamID returns the simulcast stream.
+func (id LayerID) StreamID() int {
+	return int(id >> 10 & 0x7)
+}
+
+func (id LayerID) String() string {
+	return fmt.Sprintf("stream %d (dependency %d, temporal %d, quality %d)", id.StreamID(), id.DependencyID(), id.TemporalID(), id.QualityID())
+}
+
+// StreamLayout is an entry of bmLayoutPerStream in the video probe and commit controls, describing the
+// layers of one simulcast stream. A zero layout disables the stream. UVC H.264 payload spec 1.5,
+// section 3.3.
+type StreamLayout uint16
+
+// NewStreamLayout returns the layout of a stream with the given number of spatial, temporal and
+// quality layers, each at most 7.
+func NewStreamLayout(spatial, temporal, quality int) StreamLayout {
+	return StreamLayout(spatial&0x7 | (temporal&0x7)<<3 | (quality&0x7)<<6)
+}
+
+// Enabled reports whether the stream is sent.
+func (l StreamLayout) Enabled() bool {
+	return l != 0
+}
+
+// SpatialLayers returns the number of spatial layers of the stream.
+func (l StreamLayout) SpatialLayers() int {
+	return int(l & 0x7)
+}
+
+// TemporalLayers returns the number of temporal layers of the stream.
+func (l StreamLayout) TemporalLayers() int {
+	return int(l >> 3 & 0x7)
+}
+
+// QualityLayers returns the number of quality layers of the stream.
+func (l StreamLayout) QualityLayers() int {
+	return int(l >> 6 & 0x7)
+}
+
 type H264FormatDescriptor struct {
 	FormatIndex                                           uint8
 	NumFrameDescriptors                                   uint8
@@ -95,11 +167,14 @@ type H264FormatDescriptor struct {
 	MaxMBPerSecTwoResolutionsFullScalability              uint16
 	MaxMBPerSecThreeResolutionsFullScalability            uint16
 	MaxMBPerSecFourResolutionsFullScalability             uint16
+
+	// Simulcast is set for the H.264 simulcast format descriptor, which has the same layout.
+	Simulcast bool
 }
 
 func (hfd *H264FormatDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 52); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -108,6 +183,7 @@ func (hfd *H264FormatDescriptor) UnmarshalBinary(buf []byte) error {
 		VideoStreamingInterfaceDescriptorSubtype(buf[2]) != VideoStreamingInterfaceDescriptorSubtypeFormatH264Simulcast {
 		return ErrInvalidDescriptor
 	}
+	hfd.Simulcast = VideoStreamingInterfaceDescriptorSubtype(buf[2]) == VideoStreamingInterfaceDescriptorSubtypeFormatH264Simulcast
 	hfd.FormatIndex = buf[3]
 	hfd.NumFrameDescriptors = buf[4]
 	hfd.DefaultFrameIndex = buf[5]
@@ -140,6 +216,50 @@ func (hfd *H264FormatDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (hfd *H264FormatDescriptor) MarshalBinary() ([]byte, error) {
+	subtype := VideoStreamingInterfaceDescriptorSubtypeFormatH264
+	if hfd.Simulcast {
+		subtype = VideoStreamingInterfaceDescriptorSubtypeFormatH264Simulcast
+	}
+	buf, err := newDescriptor(52, ClassSpecificDescriptorTypeInterface, uint8(subtype))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = hfd.FormatIndex
+	buf[4] = hfd.NumFrameDescriptors
+	buf[5] = hfd.DefaultFrameIndex
+	buf[6] = hfd.MaxCodecConfigDelay
+	buf[7] = hfd.SupportedSliceModesBitmask
+	buf[8] = hfd.SupportedSyncFrameTypesBitmask
+	buf[9] = hfd.ResolutionScaling
+	buf[11] = hfd.SupportedRateControlModesBitmask
+	for i, v := range []uint16{
+		hfd.MaxMBPerSecOneResolutionNoScalability,
+		hfd.MaxMBPerSecTwoResolutionsNoScalability,
+		hfd.MaxMBPerSecThreeResolutionsNoScalability,
+		hfd.MaxMBPerSecFourResolutionsNoScalability,
+		hfd.MaxMBPerSecOneResolutionTemporalScalability,
+		hfd.MaxMBPerSecTwoResolutionsTemporalScalability,
+		hfd.MaxMBPerSecThreeResolutionsTemporalScalability,
+		hfd.MaxMBPerSecFourResolutionsTemporalScalability,
+		hfd.MaxMBPerSecOneResolutionTemporalQualityScalability,
+		hfd.MaxMBPerSecTwoResolutionsTemporalQualityScalability,
+		hfd.MaxMBPerSecThreeResolutionsTemporalQualityScalability,
+		hfd.MaxMBPerSecFourResolutionsTemporalQualityScalability,
+		hfd.MaxMBPerSecOneResolutionTemporalSpatialScalability,
+		hfd.MaxMBPerSecTwoResolutionsTemporalSpatialScalability,
+		hfd.MaxMBPerSecThreeResolutionsTemporalSpatialScalability,
+		hfd.MaxMBPerSecFourResolutionsTemporalSpatialScalability,
+		hfd.MaxMBPerSecOneResolutionFullScalability,
+		hfd.MaxMBPerSecTwoResolutionsFullScalability,
+		hfd.MaxMBPerSecThreeResolutionsFullScalability,
+		hfd.MaxMBPerSecFourResolutionsFullScalability,
+	} {
+		binary.LittleEndian.PutUint16(buf[12+2*i:14+2*i], v)
+	}
+	return buf, nil
+}
+
 func (hfd *H264FormatDescriptor) isStreamingInterface() {}
 
 func (hfd *H264FormatDescriptor) isFormatDescriptor() {}
@@ -164,8 +284,8 @@ type H264FrameDescriptor struct {
 }
 
 func (hfd *H264FrameDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 44); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -188,13 +308,43 @@ func (hfd *H264FrameDescriptor) UnmarshalBinary(buf []byte) error {
 	hfd.MinBitRate = binary.LittleEndian.Uint32(buf[31:35])
 	hfd.MaxBitRate = binary.LittleEndian.Uint32(buf[35:39])
 	hfd.DefaultFrameInterval = time.Duration(binary.LittleEndian.Uint32(buf[39:43])) * 100 * time.Nanosecond
-	n := buf[43]
-	for i := uint8(0); i < n; i++ {
+	n := int(buf[43])
+	if int(buf[0]) < 44+4*n {
+		return ErrInvalidDescriptor
+	}
+	hfd.FrameIntervals = make([]time.Duration, n)
+	for i := 0; i < n; i++ {
 		hfd.FrameIntervals[i] = time.Duration(binary.LittleEndian.Uint32(buf[44+i*4:48+i*4])) * 100 * time.Nanosecond
 	}
 	return nil
 }
 
+func (hfd *H264FrameDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(44+4*len(hfd.FrameIntervals), ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFrameH264))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = hfd.FrameIndex
+	binary.LittleEndian.PutUint16(buf[4:6], hfd.Width)
+	binary.LittleEndian.PutUint16(buf[6:8], hfd.Height)
+	binary.LittleEndian.PutUint16(buf[8:10], hfd.SARWidth)
+	binary.LittleEndian.PutUint16(buf[10:12], hfd.SARHeight)
+	binary.LittleEndian.PutUint16(buf[12:14], hfd.Profile)
+	buf[14] = hfd.LevelIDC
+	binary.LittleEndian.PutUint32(buf[17:21], hfd.SupportedUsagesBitmask)
+	binary.LittleEndian.PutUint16(buf[21:23], hfd.CapabilitiesBitmask)
+	binary.LittleEndian.PutUint32(buf[23:27], hfd.SVCCapabilitiesBitmask)
+	binary.LittleEndian.PutUint32(buf[27:31], hfd.MVCCapabilitiesBitmask)
+	binary.LittleEndian.PutUint32(buf[31:35], hfd.MinBitRate)
+	binary.LittleEndian.PutUint32(buf[35:39], hfd.MaxBitRate)
+	putFrameInterval(buf[39:43], hfd.DefaultFrameInterval)
+	buf[43] = uint8(len(hfd.FrameIntervals))
+	for i, d := range hfd.FrameIntervals {
+		putFrameInterval(buf[44+4*i:48+4*i], d)
+	}
+	return buf, nil
+}
+
 func (hfd *H264FrameDescriptor) isStreamingInterface() {}
 
 func (hfd *H264FrameDescriptor) isFrameDescriptor() {}
@@ -13,18 +13,25 @@ type MJPEGStreamHeader struct {
 }
 
 func (msh *MJPEGStreamHeader) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 2); err != nil {
+		return err
 	}
 	msh.BitFieldHeader = buf[1]
 	offset := 2
 	if msh.HasPTS() {
+		if int(buf[0]) < offset+4 {
+			return io.ErrShortBuffer
+		}
 		msh.PTS = binary.LittleEndian.Uint32(buf[offset : offset+4])
 		offset += 4
 	}
 	if msh.HasSCR() {
-		msh.SCR = binary.LittleEndian.Uint64(buf[offset : offset+8])
-		offset += 8
+		// the SCR is a 32 bit source time clock followed by a 16 bit SOF token counter.
+		if int(buf[0]) < offset+6 {
+			return io.ErrShortBuffer
+		}
+		msh.SCR = uint64(binary.LittleEndian.Uint32(buf[offset:offset+4])) | uint64(binary.LittleEndian.Uint16(buf[offset+4:offset+6]))<<32
+		offset += 6
 	}
 	return nil
 }
@@ -68,8 +75,8 @@ type MJPEGFormatDescriptor struct {
 }
 
 func (mfd *MJPEGFormatDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 11); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -88,6 +95,22 @@ func (mfd *MJPEGFormatDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (mfd *MJPEGFormatDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(11, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFormatMJPEG))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = mfd.FormatIndex
+	buf[4] = mfd.NumFrameDescriptors
+	buf[5] = mfd.Flags
+	buf[6] = mfd.DefaultFrameIndex
+	buf[7] = mfd.AspectRatioX
+	buf[8] = mfd.AspectRatioY
+	buf[9] = mfd.InterlaceFlags
+	buf[10] = mfd.CopyProtect
+	return buf, nil
+}
+
 func (mfd *MJPEGFormatDescriptor) isStreamingInterface() {}
 
 func (mfd *MJPEGFormatDescriptor) isFormatDescriptor() {}
@@ -111,8 +134,8 @@ type MJPEGFrameDescriptor struct {
 }
 
 func (mfd *MJPEGFrameDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 26); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -130,6 +153,9 @@ func (mfd *MJPEGFrameDescriptor) UnmarshalBinary(buf []byte) error {
 	mfd.DefaultFrameInterval = time.Duration(binary.LittleEndian.Uint32(buf[21:25])) * 100 * time.Nanosecond
 
 	n := buf[25]
+	if n == 0 && buf[0] < 38 || int(buf[0]) < 26+4*int(n) {
+		return ErrInvalidDescriptor
+	}
 
 	if n == 0 {
 		// Continuous frame intervals
@@ -146,6 +172,25 @@ func (mfd *MJPEGFrameDescriptor) UnmarshalBinary(buf []byte) error {
 	}
 }
 
+func (mfd *MJPEGFrameDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(26+frameIntervalsLength(mfd.DiscreteFrameIntervals), ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFrameMJPEG))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = mfd.FrameIndex
+	buf[4] = mfd.Capabilities
+	binary.LittleEndian.PutUint16(buf[5:7], mfd.Width)
+	binary.LittleEndian.PutUint16(buf[7:9], mfd.Height)
+	binary.LittleEndian.PutUint32(buf[9:13], mfd.MinBitRate)
+	binary.LittleEndian.PutUint32(buf[13:17], mfd.MaxBitRate)
+	binary.LittleEndian.PutUint32(buf[17:21], mfd.MaxVideoFrameBufferSize)
+	putFrameInterval(buf[21:25], mfd.DefaultFrameInterval)
+	c := &mfd.ContinuousFrameInterval
+	buf[25] = uint8(len(mfd.DiscreteFrameIntervals))
+	putFrameIntervals(buf[26:], c.MinFrameInterval, c.MaxFrameInterval, c.FrameIntervalStep, mfd.DiscreteFrameIntervals)
+	return buf, nil
+}
+
 func (mfd *MJPEGFrameDescriptor) isStreamingInterface() {}
 
 func (mfd *MJPEGFrameDescriptor) isFrameDescriptor() {}
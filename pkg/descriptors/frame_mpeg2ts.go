@@ -1,18 +1,14 @@
 package descriptors
 
-import (
-	"io"
-
-	"github.com/google/uuid"
-)
+import "github.com/google/uuid"
 
 type MPEG2TSStreamHeader struct {
 	BitFieldHeader uint8
 }
 
 func (msh *MPEG2TSStreamHeader) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 2); err != nil {
+		return err
 	}
 	msh.BitFieldHeader = buf[1]
 	return nil
@@ -47,8 +43,8 @@ type MPEG2TSFormatDescriptor struct {
 }
 
 func (mfd *MPEG2TSFormatDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 7); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -60,10 +56,26 @@ func (mfd *MPEG2TSFormatDescriptor) UnmarshalBinary(buf []byte) error {
 	mfd.DataOffset = buf[4]
 	mfd.PacketLength = buf[5]
 	mfd.StrideLength = buf[6]
-	copyGUID(mfd.GUIDStrideFormat[:], buf[7:23])
+	// guidStrideFormat was added in UVC 1.5.
+	if buf[0] >= 23 {
+		copyGUID(mfd.GUIDStrideFormat[:], buf[7:23])
+	}
 	return nil
 }
 
+func (mfd *MPEG2TSFormatDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(23, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFormatMPEG2TS))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = mfd.FormatIndex
+	buf[4] = mfd.DataOffset
+	buf[5] = mfd.PacketLength
+	buf[6] = mfd.StrideLength
+	copyGUID(buf[7:23], mfd.GUIDStrideFormat[:])
+	return buf, nil
+}
+
 func (mfd *MPEG2TSFormatDescriptor) isStreamingInterface() {}
 
 func (mfd *MPEG2TSFormatDescriptor) isFormatDescriptor() {}
@@ -14,18 +14,25 @@ type StreamBasedStreamHeader struct {
 }
 
 func (sbsh *StreamBasedStreamHeader) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 2); err != nil {
+		return err
 	}
 	sbsh.BitFieldHeader = buf[1]
 	offset := 2
 	if sbsh.HasPTS() {
+		if int(buf[0]) < offset+4 {
+			return io.ErrShortBuffer
+		}
 		sbsh.PTS = binary.LittleEndian.Uint32(buf[offset : offset+4])
 		offset += 4
 	}
 	if sbsh.HasSCR() {
-		sbsh.SCR = binary.LittleEndian.Uint64(buf[offset : offset+8])
-		offset += 8
+		// the SCR is a 32 bit source time clock followed by a 16 bit SOF token counter.
+		if int(buf[0]) < offset+6 {
+			return io.ErrShortBuffer
+		}
+		sbsh.SCR = uint64(binary.LittleEndian.Uint32(buf[offset:offset+4])) | uint64(binary.LittleEndian.Uint16(buf[offset+4:offset+6]))<<32
+		offset += 6
 	}
 	return nil
 }
@@ -65,8 +72,8 @@ type StreamBasedFormatDescriptor struct {
 }
 
 func (sbfd *StreamBasedFormatDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 24); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -80,6 +87,17 @@ func (sbfd *StreamBasedFormatDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (sbfd *StreamBasedFormatDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(24, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFormatStreamBased))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = sbfd.FormatIndex
+	copyGUID(buf[4:20], sbfd.GUIDFormat[:])
+	binary.LittleEndian.PutUint32(buf[20:24], sbfd.PacketLength)
+	return buf, nil
+}
+
 func (sbfd *StreamBasedFormatDescriptor) isStreamingInterface() {}
 
 func (sbfd *StreamBasedFormatDescriptor) isFormatDescriptor() {}
@@ -17,18 +17,25 @@ type UncompressedStreamHeader struct {
 }
 
 func (ush *UncompressedStreamHeader) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 2); err != nil {
+		return err
 	}
 	ush.BitFieldHeader = buf[1]
 	offset := 2
 	if ush.HasPTS() {
+		if int(buf[0]) < offset+4 {
+			return io.ErrShortBuffer
+		}
 		ush.PTS = binary.LittleEndian.Uint32(buf[offset : offset+4])
 		offset += 4
 	}
 	if ush.HasSCR() {
-		ush.SCR = binary.LittleEndian.Uint64(buf[offset : offset+8])
-		offset += 8
+		// the SCR is a 32 bit source time clock followed by a 16 bit SOF token counter.
+		if int(buf[0]) < offset+6 {
+			return io.ErrShortBuffer
+		}
+		ush.SCR = uint64(binary.LittleEndian.Uint32(buf[offset:offset+4])) | uint64(binary.LittleEndian.Uint16(buf[offset+4:offset+6]))<<32
+		offset += 6
 	}
 	return nil
 }
@@ -74,8 +81,8 @@ type UncompressedFormatDescriptor struct {
 }
 
 func (ufd *UncompressedFormatDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 27); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -95,7 +102,32 @@ func (ufd *UncompressedFormatDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (ufd *UncompressedFormatDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(27, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFormatUncompressed))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = ufd.FormatIndex
+	buf[4] = ufd.NumFrameDescriptors
+	copyGUID(buf[5:21], ufd.GUIDFormat[:])
+	buf[21] = ufd.BitsPerPixel
+	buf[22] = ufd.DefaultFrameIndex
+	buf[23] = ufd.AspectRatioX
+	buf[24] = ufd.AspectRatioY
+	buf[25] = ufd.InterlaceFlagsBitmask
+	buf[26] = ufd.CopyProtect
+	return buf, nil
+}
+
+// guidRGB24 is MEDIASUBTYPE_RGB24, which devices send for 24-bit BGR.
+var guidRGB24 = uuid.MustParse("e436eb7d-524f-11ce-9f53-0020af0ba770")
+
+// FourCC returns the FourCC of the format GUID. MEDIASUBTYPE_RGB24 is reported as BGR3, the byte
+// order of its pixels.
 func (ufd *UncompressedFormatDescriptor) FourCC() ([4]byte, error) {
+	if ufd.GUIDFormat == guidRGB24 {
+		return [4]byte{'B', 'G', 'R', '3'}, nil
+	}
 	if strings.HasSuffix(ufd.GUIDFormat.String(), "-0000-0010-8000-00aa00389b71") {
 		buf := [4]byte{}
 		binary.LittleEndian.PutUint32(buf[:], ufd.GUIDFormat.ID())
@@ -127,8 +159,8 @@ type UncompressedFrameDescriptor struct {
 }
 
 func (ufd *UncompressedFrameDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 26); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -146,6 +178,9 @@ func (ufd *UncompressedFrameDescriptor) UnmarshalBinary(buf []byte) error {
 	ufd.DefaultFrameInterval = time.Duration(binary.LittleEndian.Uint32(buf[21:25])) * 100 * time.Nanosecond
 
 	n := buf[25]
+	if n == 0 && buf[0] < 38 || int(buf[0]) < 26+4*int(n) {
+		return ErrInvalidDescriptor
+	}
 
 	if n == 0 {
 		// Continuous frame intervals
@@ -162,6 +197,25 @@ func (ufd *UncompressedFrameDescriptor) UnmarshalBinary(buf []byte) error {
 	}
 }
 
+func (ufd *UncompressedFrameDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(26+frameIntervalsLength(ufd.DiscreteFrameIntervals), ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFrameUncompressed))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = ufd.FrameIndex
+	buf[4] = ufd.Capabilities
+	binary.LittleEndian.PutUint16(buf[5:7], ufd.Width)
+	binary.LittleEndian.PutUint16(buf[7:9], ufd.Height)
+	binary.LittleEndian.PutUint32(buf[9:13], ufd.MinBitRate)
+	binary.LittleEndian.PutUint32(buf[13:17], ufd.MaxBitRate)
+	binary.LittleEndian.PutUint32(buf[17:21], ufd.MaxVideoFrameBufferSize)
+	putFrameInterval(buf[21:25], ufd.DefaultFrameInterval)
+	c := &ufd.ContinuousFrameInterval
+	buf[25] = uint8(len(ufd.DiscreteFrameIntervals))
+	putFrameIntervals(buf[26:], c.MinFrameInterval, c.MaxFrameInterval, c.FrameIntervalStep, ufd.DiscreteFrameIntervals)
+	return buf, nil
+}
+
 func (ufd *UncompressedFrameDescriptor) isStreamingInterface() {}
 
 func (ufd *UncompressedFrameDescriptor) isFrameDescriptor() {}
@@ -16,22 +16,32 @@ type VP8StreamHeader struct {
 }
 
 func (vph *VP8StreamHeader) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 4); err != nil {
+		return err
 	}
 	vph.BitFieldHeader0 = buf[1]
 	vph.BitFieldHeader1 = buf[2]
 	vph.BitFieldHeader2 = buf[3]
 	offset := 4
 	if vph.HasPTS() {
+		if int(buf[0]) < offset+4 {
+			return io.ErrShortBuffer
+		}
 		vph.PTS = binary.LittleEndian.Uint32(buf[offset : offset+4])
 		offset += 4
 	}
 	if vph.HasSCR() {
-		vph.SCR = binary.LittleEndian.Uint64(buf[offset : offset+8])
-		offset += 8
+		// the SCR is a 32 bit source time clock followed by a 16 bit SOF token counter.
+		if int(buf[0]) < offset+6 {
+			return io.ErrShortBuffer
+		}
+		vph.SCR = uint64(binary.LittleEndian.Uint32(buf[offset:offset+4])) | uint64(binary.LittleEndian.Uint16(buf[offset+4:offset+6]))<<32
+		offset += 6
 	}
 	if vph.HasSLI() {
+		if int(buf[0]) < offset+2 {
+			return io.ErrShortBuffer
+		}
 		vph.SLI = binary.LittleEndian.Uint16(buf[offset : offset+2])
 		offset += 2
 	}
@@ -96,11 +106,14 @@ type VP8FormatDescriptor struct {
 	ResolutionScaling                uint8
 	SupportedRateControlModesBitmask uint8
 	MaxMBPerSec                      uint16
+
+	// Simulcast is set for the VP8 simulcast format descriptor, which has the same layout.
+	Simulcast bool
 }
 
 func (vfd *VP8FormatDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 13); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -108,6 +121,7 @@ func (vfd *VP8FormatDescriptor) UnmarshalBinary(buf []byte) error {
 	if VideoStreamingInterfaceDescriptorSubtype(buf[2]) != VideoStreamingInterfaceDescriptorSubtypeFormatVP8 && VideoStreamingInterfaceDescriptorSubtype(buf[2]) != VideoStreamingInterfaceDescriptorSubtypeFormatVP8Simulcast {
 		return ErrInvalidDescriptor
 	}
+	vfd.Simulcast = VideoStreamingInterfaceDescriptorSubtype(buf[2]) == VideoStreamingInterfaceDescriptorSubtypeFormatVP8Simulcast
 	vfd.FormatIndex = buf[3]
 	vfd.NumFrameDescriptors = buf[4]
 	vfd.DefaultFrameIndex = buf[5]
@@ -120,6 +134,27 @@ func (vfd *VP8FormatDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (vfd *VP8FormatDescriptor) MarshalBinary() ([]byte, error) {
+	subtype := VideoStreamingInterfaceDescriptorSubtypeFormatVP8
+	if vfd.Simulcast {
+		subtype = VideoStreamingInterfaceDescriptorSubtypeFormatVP8Simulcast
+	}
+	buf, err := newDescriptor(13, ClassSpecificDescriptorTypeInterface, uint8(subtype))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = vfd.FormatIndex
+	buf[4] = vfd.NumFrameDescriptors
+	buf[5] = vfd.DefaultFrameIndex
+	buf[6] = vfd.MaxCodecConfigDelay
+	buf[7] = vfd.SupportedPartitionCount
+	buf[8] = vfd.SupportedSyncFrameTypesBitmask
+	buf[9] = vfd.ResolutionScaling
+	buf[10] = vfd.SupportedRateControlModesBitmask
+	binary.LittleEndian.PutUint16(buf[11:13], vfd.MaxMBPerSec)
+	return buf, nil
+}
+
 func (vfd *VP8FormatDescriptor) isStreamingInterface() {}
 
 func (vfd *VP8FormatDescriptor) isFormatDescriptor() {}
@@ -140,8 +175,8 @@ type VP8FrameDescriptor struct {
 }
 
 func (vfd *VP8FrameDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 31); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -158,13 +193,38 @@ func (vfd *VP8FrameDescriptor) UnmarshalBinary(buf []byte) error {
 	vfd.MinBitRate = binary.LittleEndian.Uint32(buf[18:22])
 	vfd.MaxBitRate = binary.LittleEndian.Uint32(buf[22:26])
 	vfd.DefaultFrameInterval = time.Duration(binary.LittleEndian.Uint32(buf[26:30])) * 100 * time.Nanosecond
-	n := buf[30]
-	for i := uint8(0); i < n; i++ {
+	n := int(buf[30])
+	if int(buf[0]) < 31+4*n {
+		return ErrInvalidDescriptor
+	}
+	vfd.FrameIntervals = make([]time.Duration, n)
+	for i := 0; i < n; i++ {
 		vfd.FrameIntervals[i] = time.Duration(binary.LittleEndian.Uint32(buf[31+i*4:35+i*4])) * 100 * time.Nanosecond
 	}
 	return nil
 }
 
+func (vfd *VP8FrameDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(31+4*len(vfd.FrameIntervals), ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeFrameVP8))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = vfd.FrameIndex
+	binary.LittleEndian.PutUint16(buf[4:6], vfd.Width)
+	binary.LittleEndian.PutUint16(buf[6:8], vfd.Height)
+	binary.LittleEndian.PutUint32(buf[8:12], vfd.SupportedUsagesBitmask)
+	binary.LittleEndian.PutUint16(buf[12:14], vfd.CapabilitiesBitmask)
+	binary.LittleEndian.PutUint32(buf[14:18], vfd.ScalabilityCapabilitiesBitmask)
+	binary.LittleEndian.PutUint32(buf[18:22], vfd.MinBitRate)
+	binary.LittleEndian.PutUint32(buf[22:26], vfd.MaxBitRate)
+	putFrameInterval(buf[26:30], vfd.DefaultFrameInterval)
+	buf[30] = uint8(len(vfd.FrameIntervals))
+	for i, d := range vfd.FrameIntervals {
+		putFrameInterval(buf[31+4*i:35+4*i], d)
+	}
+	return buf, nil
+}
+
 func (vfd *VP8FrameDescriptor) isStreamingInterface() {}
 
 func (vfd *VP8FrameDescriptor) isFrameDescriptor() {}
@@ -0,0 +1,204 @@
+package descriptors
+
+import (
+	"encoding"
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// unmarshalers return a zero value of every type parsed from device data.
+var unmarshalers = []func() encoding.BinaryUnmarshaler{
+	func() encoding.BinaryUnmarshaler { return &AnalogVideoLockStatusControl{} },
+	func() encoding.BinaryUnmarshaler { return &AnalogVideoStandardControl{} },
+	func() encoding.BinaryUnmarshaler { return &AudioControlHeaderDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &AudioFeatureUnitDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &AudioInputTerminalDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &AudioOutputTerminalDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &AutoExposureModeControl{} },
+	func() encoding.BinaryUnmarshaler { return &AutoExposurePriorityControl{} },
+	func() encoding.BinaryUnmarshaler { return &BacklightCompensationControl{} },
+	func() encoding.BinaryUnmarshaler { return &BrightnessControl{} },
+	func() encoding.BinaryUnmarshaler { return &CameraTerminalDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &ColorMatchingDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &ContrastAutoControl{} },
+	func() encoding.BinaryUnmarshaler { return &ContrastControl{} },
+	func() encoding.BinaryUnmarshaler { return &DVFormatDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &DVStreamHeader{} },
+	func() encoding.BinaryUnmarshaler { return &DigitalMultiplerControl{} },
+	func() encoding.BinaryUnmarshaler { return &DigitalMultiplerLimitControl{} },
+	func() encoding.BinaryUnmarshaler { return &DigitalWindowControl{} },
+	func() encoding.BinaryUnmarshaler { return &EncodingUnitDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &ExposureTimeAbsoluteControl{} },
+	func() encoding.BinaryUnmarshaler { return &ExposureTimeRelativeControl{} },
+	func() encoding.BinaryUnmarshaler { return &ExtensionUnitDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &FocusAbsoluteControl{} },
+	func() encoding.BinaryUnmarshaler { return &FocusAutoControl{} },
+	func() encoding.BinaryUnmarshaler { return &FocusRelativeControl{} },
+	func() encoding.BinaryUnmarshaler { return &FocusSimpleRangeControl{} },
+	func() encoding.BinaryUnmarshaler { return &FrameBasedFormatDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &FrameBasedFrameDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &FrameBasedStreamHeader{} },
+	func() encoding.BinaryUnmarshaler { return &GainControl{} },
+	func() encoding.BinaryUnmarshaler { return &GammaControl{} },
+	func() encoding.BinaryUnmarshaler { return &H264FormatDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &H264FrameDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &H264StreamHeader{} },
+	func() encoding.BinaryUnmarshaler { return &HeaderDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &HueAutoControl{} },
+	func() encoding.BinaryUnmarshaler { return &HueControl{} },
+	func() encoding.BinaryUnmarshaler { return &InputHeaderDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &InputTerminalDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &InterfaceAssociationDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &IrisAbsoluteControl{} },
+	func() encoding.BinaryUnmarshaler { return &IrisRelativeControl{} },
+	func() encoding.BinaryUnmarshaler { return &MJPEGFormatDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &MJPEGFrameDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &MJPEGStreamHeader{} },
+	func() encoding.BinaryUnmarshaler { return &MPEG2TSFormatDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &MPEG2TSStreamHeader{} },
+	func() encoding.BinaryUnmarshaler { return &OutputHeaderDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &OutputTerminalDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &PanTiltAbsoluteControl{} },
+	func() encoding.BinaryUnmarshaler { return &PanTiltRelativeControl{} },
+	func() encoding.BinaryUnmarshaler { return &PowerLineFrequencyControl{} },
+	func() encoding.BinaryUnmarshaler { return &PrivacyControl{} },
+	func() encoding.BinaryUnmarshaler { return &ProcessingUnitDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &RegionOfInterestControl{} },
+	func() encoding.BinaryUnmarshaler { return &RollAbsoluteControl{} },
+	func() encoding.BinaryUnmarshaler { return &RollRelativeControl{} },
+	func() encoding.BinaryUnmarshaler { return &SaturationControl{} },
+	func() encoding.BinaryUnmarshaler { return &ScanningModeControl{} },
+	func() encoding.BinaryUnmarshaler { return &SelectorUnitDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &SharpnessControl{} },
+	func() encoding.BinaryUnmarshaler { return &StandardAudioStreamingInterfaceDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &StandardVideoControlInterfaceDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &StandardVideoControlInterruptEndpointDescriptor{} },
+	func() encoding.BinaryUnmarshaler {
+		return &StandardVideoStreamingBulkStillImageDataEndpointDescriptor{}
+	},
+	func() encoding.BinaryUnmarshaler { return &StandardVideoStreamingBulkVideoDataEndpointDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &StandardVideoStreamingInterfaceDescriptor{} },
+	func() encoding.BinaryUnmarshaler {
+		return &StandardVideoStreamingIsochronousVideoDataEndpointDescriptor{}
+	},
+	func() encoding.BinaryUnmarshaler { return &StillImageFrameDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &StreamBasedFormatDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &StreamBasedStreamHeader{} },
+	func() encoding.BinaryUnmarshaler { return &UncompressedFormatDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &UncompressedFrameDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &UncompressedStreamHeader{} },
+	func() encoding.BinaryUnmarshaler { return &UnknownDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &VP8FormatDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &VP8FrameDescriptor{} },
+	func() encoding.BinaryUnmarshaler { return &VP8StreamHeader{} },
+	func() encoding.BinaryUnmarshaler { return &VideoProbeCommitControl{} },
+	func() encoding.BinaryUnmarshaler { return &WhiteBalanceComponentAutoControl{} },
+	func() encoding.BinaryUnmarshaler { return &WhiteBalanceComponentControl{} },
+	func() encoding.BinaryUnmarshaler { return &WhiteBalanceTemperatureAutoControl{} },
+	func() encoding.BinaryUnmarshaler { return &WhiteBalanceTemperatureControl{} },
+	func() encoding.BinaryUnmarshaler { return &ZoomAbsoluteControl{} },
+	func() encoding.BinaryUnmarshaler { return &ZoomRelativeControl{} },
+}
+
+// fuzzSeeds returns the encodings of random valid descriptors, as a seed corpus for the fuzz targets.
+func fuzzSeeds(tb testing.TB) [][]byte {
+	names := make([]string, 0, len(descriptorGenerators))
+	for name := range descriptorGenerators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	g := descriptorGen{rand.New(rand.NewSource(1))}
+	var seeds [][]byte
+	for _, name := range names {
+		buf, err := descriptorGenerators[name](g).MarshalBinary()
+		if err != nil {
+			tb.Fatalf("%s: %v", name, err)
+		}
+		seeds = append(seeds, buf)
+	}
+	return seeds
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	for i, seed := range fuzzSeeds(f) {
+		f.Add(uint8(i), seed)
+	}
+	f.Add(uint8(0), []byte{})
+	f.Fuzz(func(t *testing.T, kind uint8, buf []byte) {
+		u := unmarshalers[int(kind)%len(unmarshalers)]()
+		if err := u.UnmarshalBinary(buf); err != nil {
+			return
+		}
+		// whatever parses must encode again.
+		if m, ok := u.(encoding.BinaryMarshaler); ok {
+			m.MarshalBinary()
+		}
+	})
+}
+
+func FuzzUnmarshalControlInterface(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		desc, err := UnmarshalControlInterface(buf)
+		if err != nil {
+			return
+		}
+		if desc == nil {
+			t.Fatal("nil descriptor without error")
+		}
+		desc.MarshalBinary()
+	})
+}
+
+func FuzzUnmarshalStreamingInterface(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		desc, err := UnmarshalStreamingInterface(buf)
+		if err != nil {
+			return
+		}
+		if desc == nil {
+			t.Fatal("nil descriptor without error")
+		}
+		desc.MarshalBinary()
+	})
+}
+
+func FuzzSplitDescriptors(f *testing.F) {
+	f.Add([]byte{9, 0x24, 1, 0, 0, 0, 0, 0, 0, 3, 0x24, 2})
+	f.Add([]byte{0})
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		blocks, err := SplitDescriptors(buf)
+		if err != nil {
+			return
+		}
+		n := 0
+		for _, block := range blocks {
+			if len(block) < 2 || int(block[0]) != len(block) {
+				t.Fatalf("block %x does not match its length", block)
+			}
+			n += len(block)
+		}
+		if n != len(buf) {
+			t.Fatalf("blocks cover %d of %d bytes", n, len(buf))
+		}
+	})
+}
+
+func TestSplitDescriptors(t *testing.T) {
+	blocks, err := SplitDescriptors([]byte{3, 0x24, 1, 2, 0x24})
+	if err != nil || len(blocks) != 2 || len(blocks[0]) != 3 || len(blocks[1]) != 2 {
+		t.Errorf("SplitDescriptors = %x, %v", blocks, err)
+	}
+	for _, buf := range [][]byte{{0, 0x24, 1}, {1}, {3, 0x24, 1, 4, 0x24}} {
+		if _, err := SplitDescriptors(buf); !errors.Is(err, ErrInvalidDescriptor) {
+			t.Errorf("SplitDescriptors(%x) = %v, want ErrInvalidDescriptor", buf, err)
+		}
+	}
+}
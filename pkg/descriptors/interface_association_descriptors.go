@@ -1,30 +1,47 @@
 // This file implements the descriptors as defined in the UVC spec 1.5, section 3.6.
 package descriptors
 
-import "io"
-
+// InterfaceAssociationDescriptor groups the interfaces of a video function, as defined in UVC spec
+// 1.5, section 3.6 and the USB 2.0 interface association ECN.
 type InterfaceAssociationDescriptor struct {
+	FirstInterface   uint8
 	InterfaceCount   uint8
 	DescriptionIndex uint8
 }
 
+// interfaceAssociationDescriptorType is the bDescriptorType of interface association descriptors.
+const interfaceAssociationDescriptorType = 0x0B
+
 func (iad *InterfaceAssociationDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 8); err != nil {
+		return err
 	}
-	if buf[1] != 0xEF { // TODO: where does this come from
+	if buf[1] != interfaceAssociationDescriptorType {
 		return ErrInvalidDescriptor
 	}
-	iad.InterfaceCount = buf[2]
-	if ClassCode(buf[3]) != ClassCodeVideo {
+	iad.FirstInterface = buf[2]
+	iad.InterfaceCount = buf[3]
+	if ClassCode(buf[4]) != ClassCodeVideo {
 		return ErrInvalidDescriptor
 	}
-	if SubclassCode(buf[4]) != SubclassCodeVideoInterfaceCollection {
+	if SubclassCode(buf[5]) != SubclassCodeVideoInterfaceCollection {
 		return ErrInvalidDescriptor
 	}
-	if ProtocolCode(buf[5]) != ProtocolCodeUndefined {
+	if ProtocolCode(buf[6]) != ProtocolCodeUndefined {
 		return ErrInvalidDescriptor
 	}
-	iad.DescriptionIndex = buf[6]
+	iad.DescriptionIndex = buf[7]
 	return nil
 }
+
+func (iad *InterfaceAssociationDescriptor) MarshalBinary() ([]byte, error) {
+	return []byte{
+		8, interfaceAssociationDescriptorType,
+		iad.FirstInterface,
+		iad.InterfaceCount,
+		uint8(ClassCodeVideo),
+		uint8(SubclassCodeVideoInterfaceCollection),
+		uint8(ProtocolCodeUndefined),
+		iad.DescriptionIndex,
+	}, nil
+}
@@ -0,0 +1,351 @@
+package descriptors
+
+import (
+	"bytes"
+	"encoding"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// descriptorGen draws random field values for descriptor property tests.
+type descriptorGen struct {
+	*rand.Rand
+}
+
+func (g descriptorGen) u8() uint8   { return uint8(g.Uint32()) }
+func (g descriptorGen) u16() uint16 { return uint16(g.Uint32()) }
+
+func (g descriptorGen) bytes(n int) []byte {
+	buf := make([]byte, n)
+	g.Read(buf)
+	return buf
+}
+
+func (g descriptorGen) guid() (guid [16]byte) {
+	g.Read(guid[:])
+	return guid
+}
+
+// interval returns a frame interval representable in the 100 ns units of the descriptors.
+func (g descriptorGen) interval() time.Duration {
+	return time.Duration(g.Uint32()) * 100 * time.Nanosecond
+}
+
+func (g descriptorGen) intervals(n int) []time.Duration {
+	intervals := make([]time.Duration, n)
+	for i := range intervals {
+		intervals[i] = g.interval()
+	}
+	return intervals
+}
+
+// frameIntervals fills the frame intervals of an uncompressed, MJPEG or frame based frame descriptor,
+// either discrete or continuous.
+func (g descriptorGen) frameIntervals(c *struct{ MinFrameInterval, MaxFrameInterval, FrameIntervalStep time.Duration }) []time.Duration {
+	if n := g.Intn(8); n > 0 {
+		return g.intervals(n)
+	}
+	c.MinFrameInterval, c.MaxFrameInterval, c.FrameIntervalStep = g.interval(), g.interval(), g.interval()
+	return nil
+}
+
+type binaryDescriptor interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// descriptorGenerators return a random valid value of every descriptor type.
+var descriptorGenerators = map[string]func(g descriptorGen) binaryDescriptor{
+	"StandardVideoControlInterface": func(g descriptorGen) binaryDescriptor {
+		return &StandardVideoControlInterfaceDescriptor{InterfaceNumber: g.u8(), AlternateSetting: g.u8(), NumEndpoints: g.u8(), DescriptionIndex: g.u8()}
+	},
+	"Header": func(g descriptorGen) binaryDescriptor {
+		return &HeaderDescriptor{UVC: g.u16(), TotalLength: g.u16(), ClockFrequency: g.Uint32(), VideoStreamingInterfaceIndexes: g.bytes(g.Intn(4))}
+	},
+	"InputTerminal": func(g descriptorGen) binaryDescriptor {
+		return &InputTerminalDescriptor{TerminalID: g.u8(), TerminalType: InputTerminalType(g.u16()), AssociatedTerminalID: g.u8(), DescriptionIndex: g.u8()}
+	},
+	"OutputTerminal": func(g descriptorGen) binaryDescriptor {
+		return &OutputTerminalDescriptor{TerminalID: g.u8(), TerminalType: OutputTerminalType(g.u16()), AssociatedTerminalID: g.u8(), SourceID: g.u8(), DescriptionIndex: g.u8()}
+	},
+	"CameraTerminal": func(g descriptorGen) binaryDescriptor {
+		return &CameraTerminalDescriptor{
+			InputTerminalDescriptor: InputTerminalDescriptor{TerminalID: g.u8(), TerminalType: InputTerminalTypeCamera, AssociatedTerminalID: g.u8(), DescriptionIndex: g.u8()},
+			ObjectiveFocalLengthMin: g.u16(),
+			ObjectiveFocalLengthMax: g.u16(),
+			OcularFocalLength:       g.u16(),
+			ControlsBitmask:         g.bytes(g.Intn(4)),
+		}
+	},
+	"SelectorUnit": func(g descriptorGen) binaryDescriptor {
+		return &SelectorUnitDescriptor{UnitID: g.u8(), SourceID: g.bytes(g.Intn(4)), DescriptionIndex: g.u8()}
+	},
+	"ProcessingUnit": func(g descriptorGen) binaryDescriptor {
+		return &ProcessingUnitDescriptor{UnitID: g.u8(), SourceID: g.u8(), MaxMultiplier: g.u16(), ControlsBitmask: g.bytes(g.Intn(4)), DescriptionIndex: g.u8(), VideoStandardsBitmask: g.u8()}
+	},
+	"EncodingUnit": func(g descriptorGen) binaryDescriptor {
+		return &EncodingUnitDescriptor{UnitID: g.u8(), SourceID: g.u8(), DescriptionIndex: g.u8(), ControlsBitmask: g.Uint32() & 0xffffff, ControlsRuntimeBitmask: g.Uint32() & 0xffffff}
+	},
+	"ExtensionUnit": func(g descriptorGen) binaryDescriptor {
+		return &ExtensionUnitDescriptor{UnitID: g.u8(), GUIDExtensionCode: g.guid(), NumControls: g.u8(), SourceIDs: g.bytes(g.Intn(4)), ControlsBitmask: g.bytes(g.Intn(4)), DescriptionIndex: g.u8()}
+	},
+	"VideoControlInterruptEndpoint": func(g descriptorGen) binaryDescriptor {
+		return &StandardVideoControlInterruptEndpointDescriptor{MaxTransferSize: g.u16()}
+	},
+	"Unknown": func(g descriptorGen) binaryDescriptor {
+		raw := g.bytes(3 + g.Intn(8))
+		raw[0] = uint8(len(raw))
+		return &UnknownDescriptor{Type: raw[1], Subtype: raw[2], Raw: raw}
+	},
+	"InterfaceAssociation": func(g descriptorGen) binaryDescriptor {
+		return &InterfaceAssociationDescriptor{FirstInterface: g.u8(), InterfaceCount: g.u8(), DescriptionIndex: g.u8()}
+	},
+	"StandardVideoStreamingInterface": func(g descriptorGen) binaryDescriptor {
+		return &StandardVideoStreamingInterfaceDescriptor{InterfaceNumber: g.u8(), AlternateSetting: g.u8(), NumEndpoints: g.u8(), DescriptionIndex: g.u8()}
+	},
+	"InputHeader": func(g descriptorGen) binaryDescriptor {
+		d := &InputHeaderDescriptor{TotalLength: g.u16(), EndpointAddress: g.u8(), InfoBitmask: g.u8(), TerminalLink: g.u8(), StillCaptureMethod: g.u8(), TriggerSupport: g.u8(), TriggerUsage: g.u8()}
+		d.ControlBitmasks = make([][]byte, g.Intn(4))
+		n := 1 + g.Intn(2)
+		for i := range d.ControlBitmasks {
+			d.ControlBitmasks[i] = g.bytes(n)
+		}
+		return d
+	},
+	"OutputHeader": func(g descriptorGen) binaryDescriptor {
+		d := &OutputHeaderDescriptor{TotalLength: g.u16(), EndpointAddress: g.u8(), TerminalLink: g.u8()}
+		d.ControlBitmasks = make([][]byte, g.Intn(4))
+		n := 1 + g.Intn(2)
+		for i := range d.ControlBitmasks {
+			d.ControlBitmasks[i] = g.bytes(n)
+		}
+		return d
+	},
+	"StillImageFrame": func(g descriptorGen) binaryDescriptor {
+		d := &StillImageFrameDescriptor{EndpointAddress: g.u8(), CompressionPatterns: g.bytes(g.Intn(4))}
+		d.ImageSizePatterns = make([]struct{ Width, Height uint16 }, g.Intn(4))
+		for i := range d.ImageSizePatterns {
+			d.ImageSizePatterns[i].Width, d.ImageSizePatterns[i].Height = g.u16(), g.u16()
+		}
+		return d
+	},
+	"ColorMatching": func(g descriptorGen) binaryDescriptor {
+		return &ColorMatchingDescriptor{ColorPrimaries: g.u8(), TransferCharacteristics: g.u8(), MatrixCoefficients: g.u8()}
+	},
+	"UncompressedFormat": func(g descriptorGen) binaryDescriptor {
+		return &UncompressedFormatDescriptor{FormatIndex: g.u8(), NumFrameDescriptors: g.u8(), GUIDFormat: g.guid(), BitsPerPixel: g.u8(), DefaultFrameIndex: g.u8(), AspectRatioX: g.u8(), AspectRatioY: g.u8(), InterlaceFlagsBitmask: g.u8(), CopyProtect: g.u8()}
+	},
+	"UncompressedFrame": func(g descriptorGen) binaryDescriptor {
+		d := &UncompressedFrameDescriptor{FrameIndex: g.u8(), Capabilities: g.u8(), Width: g.u16(), Height: g.u16(), MinBitRate: g.Uint32(), MaxBitRate: g.Uint32(), MaxVideoFrameBufferSize: g.Uint32(), DefaultFrameInterval: g.interval()}
+		d.DiscreteFrameIntervals = g.frameIntervals(&d.ContinuousFrameInterval)
+		return d
+	},
+	"MJPEGFormat": func(g descriptorGen) binaryDescriptor {
+		return &MJPEGFormatDescriptor{FormatIndex: g.u8(), NumFrameDescriptors: g.u8(), Flags: g.u8(), DefaultFrameIndex: g.u8(), AspectRatioX: g.u8(), AspectRatioY: g.u8(), InterlaceFlags: g.u8(), CopyProtect: g.u8()}
+	},
+	"MJPEGFrame": func(g descriptorGen) binaryDescriptor {
+		d := &MJPEGFrameDescriptor{FrameIndex: g.u8(), Capabilities: g.u8(), Width: g.u16(), Height: g.u16(), MinBitRate: g.Uint32(), MaxBitRate: g.Uint32(), MaxVideoFrameBufferSize: g.Uint32(), DefaultFrameInterval: g.interval()}
+		d.DiscreteFrameIntervals = g.frameIntervals(&d.ContinuousFrameInterval)
+		return d
+	},
+	"FrameBasedFormat": func(g descriptorGen) binaryDescriptor {
+		return &FrameBasedFormatDescriptor{FormatIndex: g.u8(), NumFrameDescriptors: g.u8(), GUIDFormat: g.guid(), BitsPerPixel: g.u8(), DefaultFrameIndex: g.u8(), AspectRatioX: g.u8(), AspectRatioY: g.u8(), InterlaceFlags: g.u8(), CopyProtect: g.u8(), VariableSize: g.Intn(2) == 1}
+	},
+	"FrameBasedFrame": func(g descriptorGen) binaryDescriptor {
+		d := &FrameBasedFrameDescriptor{FrameIndex: g.u8(), Capabilities: g.u8(), Width: g.u16(), Height: g.u16(), MinBitRate: g.Uint32(), MaxBitRate: g.Uint32(), DefaultFrameInterval: g.interval(), BytesPerLine: g.Uint32()}
+		d.DiscreteFrameIntervals = g.frameIntervals(&d.ContinuousFrameInterval)
+		return d
+	},
+	"H264Format": func(g descriptorGen) binaryDescriptor {
+		d := &H264FormatDescriptor{FormatIndex: g.u8(), NumFrameDescriptors: g.u8(), DefaultFrameIndex: g.u8(), MaxCodecConfigDelay: g.u8(), SupportedSliceModesBitmask: g.u8(), SupportedSyncFrameTypesBitmask: g.u8(), ResolutionScaling: g.u8(), SupportedRateControlModesBitmask: g.u8(), Simulcast: g.Intn(2) == 1}
+		// the MaxMBPerSec fields follow the bitmasks.
+		v := reflect.ValueOf(d).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).Kind() == reflect.Uint16 {
+				v.Field(i).SetUint(uint64(g.u16()))
+			}
+		}
+		return d
+	},
+	"H264Frame": func(g descriptorGen) binaryDescriptor {
+		return &H264FrameDescriptor{FrameIndex: g.u8(), Width: g.u16(), Height: g.u16(), SARWidth: g.u16(), SARHeight: g.u16(), Profile: g.u16(), LevelIDC: g.u8(), SupportedUsagesBitmask: g.Uint32(), CapabilitiesBitmask: g.u16(), SVCCapabilitiesBitmask: g.Uint32(), MVCCapabilitiesBitmask: g.Uint32(), MinBitRate: g.Uint32(), MaxBitRate: g.Uint32(), DefaultFrameInterval: g.interval(), FrameIntervals: g.intervals(g.Intn(8))}
+	},
+	"VP8Format": func(g descriptorGen) binaryDescriptor {
+		return &VP8FormatDescriptor{FormatIndex: g.u8(), NumFrameDescriptors: g.u8(), DefaultFrameIndex: g.u8(), MaxCodecConfigDelay: g.u8(), SupportedPartitionCount: g.u8(), SupportedSyncFrameTypesBitmask: g.u8(), ResolutionScaling: g.u8(), SupportedRateControlModesBitmask: g.u8(), MaxMBPerSec: g.u16(), Simulcast: g.Intn(2) == 1}
+	},
+	"VP8Frame": func(g descriptorGen) binaryDescriptor {
+		return &VP8FrameDescriptor{FrameIndex: g.u8(), Width: g.u16(), Height: g.u16(), SupportedUsagesBitmask: g.Uint32(), CapabilitiesBitmask: g.u16(), ScalabilityCapabilitiesBitmask: g.Uint32(), MinBitRate: g.Uint32(), MaxBitRate: g.Uint32(), DefaultFrameInterval: g.interval(), FrameIntervals: g.intervals(g.Intn(8))}
+	},
+	"DVFormat": func(g descriptorGen) binaryDescriptor {
+		return &DVFormatDescriptor{FormatIndex: g.u8(), MaxVideoFrameBufferSize: g.Uint32(), FormatType: g.u8()}
+	},
+	"MPEG2TSFormat": func(g descriptorGen) binaryDescriptor {
+		return &MPEG2TSFormatDescriptor{FormatIndex: g.u8(), DataOffset: g.u8(), PacketLength: g.u8(), StrideLength: g.u8(), GUIDStrideFormat: g.guid()}
+	},
+	"StreamBasedFormat": func(g descriptorGen) binaryDescriptor {
+		return &StreamBasedFormatDescriptor{FormatIndex: g.u8(), GUIDFormat: g.guid(), PacketLength: g.Uint32()}
+	},
+	"IsochronousVideoDataEndpoint": func(g descriptorGen) binaryDescriptor {
+		return &StandardVideoStreamingIsochronousVideoDataEndpointDescriptor{EndpointAddress: g.u8(), AttributesBitmask: g.u8(), MaxPacketSize: g.u16(), Interval: g.u8()}
+	},
+	"BulkVideoDataEndpoint": func(g descriptorGen) binaryDescriptor {
+		return &StandardVideoStreamingBulkVideoDataEndpointDescriptor{EndpointAddress: g.u8(), MaxPacketSize: g.u16(), Interval: g.u8()}
+	},
+	"BulkStillImageDataEndpoint": func(g descriptorGen) binaryDescriptor {
+		return &StandardVideoStreamingBulkStillImageDataEndpointDescriptor{EndpointAddress: 0x80 | g.u8()&0x07, MaxPacketSize: g.u16()}
+	},
+	"AudioControlHeader": func(g descriptorGen) binaryDescriptor {
+		nr := g.bytes(g.Intn(4))
+		return &AudioControlHeaderDescriptor{BcdADC: g.u16(), TotalLength: g.u16(), InCollection: uint8(len(nr)), InterfaceNr: nr}
+	},
+	"AudioInputTerminal": func(g descriptorGen) binaryDescriptor {
+		return &AudioInputTerminalDescriptor{TerminalID: g.u8(), TerminalType: g.u16(), AssocTerminal: g.u8(), NrChannels: g.u8(), ChannelConfig: g.u16(), ChannelNames: g.u8(), Terminal: g.u8()}
+	},
+	"AudioOutputTerminal": func(g descriptorGen) binaryDescriptor {
+		return &AudioOutputTerminalDescriptor{TerminalID: g.u8(), TerminalType: g.u16(), AssocTerminal: g.u8(), SourceID: g.u8(), Terminal: g.u8()}
+	},
+	"AudioFeatureUnit": func(g descriptorGen) binaryDescriptor {
+		size := 1 + g.Intn(2)
+		return &AudioFeatureUnitDescriptor{UnitID: g.u8(), SourceID: g.u8(), ControlSize: uint8(size), Controls: g.bytes(size * (1 + g.Intn(3))), Feature: g.u8()}
+	},
+	"StandardAudioStreamingInterface": func(g descriptorGen) binaryDescriptor {
+		return &StandardAudioStreamingInterfaceDescriptor{InterfaceNumber: g.u8(), AlternateSetting: g.u8(), NumEndpoints: g.u8(), InterfaceClass: g.u8(), InterfaceSubClass: g.u8(), InterfaceProtocol: g.u8(), DescriptionIndex: g.u8()}
+	},
+}
+
+func TestMarshalBinary_RoundTrip(t *testing.T) {
+	for name, gen := range descriptorGenerators {
+		t.Run(name, func(t *testing.T) {
+			property := func(seed int64) bool {
+				want := gen(descriptorGen{rand.New(rand.NewSource(seed))})
+				buf, err := want.MarshalBinary()
+				if err != nil {
+					t.Logf("MarshalBinary(%+v) failed: %v", want, err)
+					return false
+				}
+				if int(buf[0]) != len(buf) {
+					t.Logf("bLength = %d, marshalled %d bytes", buf[0], len(buf))
+					return false
+				}
+				got := reflect.New(reflect.TypeOf(want).Elem()).Interface().(binaryDescriptor)
+				if err := got.UnmarshalBinary(buf); err != nil {
+					t.Logf("UnmarshalBinary(% x) failed: %v", buf, err)
+					return false
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Logf("round trip of % x\ngot  %+v\nwant %+v", buf, got, want)
+					return false
+				}
+				again, err := got.MarshalBinary()
+				if err != nil || !bytes.Equal(again, buf) {
+					t.Logf("remarshalled % x, want % x (err %v)", again, buf, err)
+					return false
+				}
+				return true
+			}
+			if err := quick.Check(property, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMarshalBinary_Bytes(t *testing.T) {
+	tests := []struct {
+		name string
+		desc binaryDescriptor
+		buf  []byte
+	}{
+		{"camera terminal", &CameraTerminalDescriptor{}, []byte{
+			0x12, 0x24, 0x02, 0x01, 0x01, 0x02, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // focal lengths
+			0x03, 0x0a, 0x00, 0x00,
+		}},
+		{"camera terminal, uvc 1.0", &CameraTerminalDescriptor{}, []byte{
+			0x11, 0x24, 0x02, 0x01, 0x01, 0x02, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x02, 0x2a, 0x00,
+		}},
+		{"processing unit", &ProcessingUnitDescriptor{}, []byte{
+			0x0c, 0x24, 0x05, 0x02, 0x01, 0x00, 0x00, 0x02, 0x01, 0x00, 0x00, 0x00,
+		}},
+		{"processing unit, uvc 1.0", &ProcessingUnitDescriptor{}, []byte{
+			0x0b, 0x24, 0x05, 0x02, 0x01, 0x00, 0x40, 0x02, 0x7f, 0x15, 0x00,
+		}},
+		{"encoding unit", &EncodingUnitDescriptor{}, []byte{
+			0x0d, 0x24, 0x07, 0x04, 0x02, 0x00, 0x03, 0xff, 0x0f, 0x00, 0x08, 0x00, 0x00,
+		}},
+		{"extension unit", &ExtensionUnitDescriptor{}, []byte{
+			0x1b, 0x24, 0x06, 0x06,
+			0x6a, 0xd1, 0x49, 0x2c, 0xb8, 0x32, 0x85, 0x44, 0x3e, 0xa8, 0x64, 0x3a, 0x15, 0x23, 0x62, 0xf2,
+			0x06, 0x01, 0x02, 0x02, 0x3f, 0x00, 0x00,
+		}},
+		{"output terminal", &OutputTerminalDescriptor{}, []byte{
+			0x09, 0x24, 0x03, 0x03, 0x01, 0x01, 0x00, 0x02, 0x00,
+		}},
+		{"input header", &InputHeaderDescriptor{}, []byte{
+			0x0f, 0x24, 0x01, 0x02, 0x65, 0x00, 0x81, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x04,
+		}},
+		{"uncompressed format", &UncompressedFormatDescriptor{}, []byte{
+			0x1b, 0x24, 0x04, 0x01, 0x02,
+			0x59, 0x55, 0x59, 0x32, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71,
+			0x10, 0x01, 0x00, 0x00, 0x00, 0x00,
+		}},
+		{"uncompressed frame, discrete", &UncompressedFrameDescriptor{}, []byte{
+			0x1e, 0x24, 0x05, 0x01, 0x00, 0x80, 0x02, 0xe0, 0x01,
+			0x00, 0x00, 0xca, 0x08, 0x00, 0x00, 0xca, 0x08, 0x00, 0x60, 0x09, 0x00,
+			0x15, 0x16, 0x05, 0x00, 0x01, 0x15, 0x16, 0x05, 0x00,
+		}},
+		{"mjpeg frame, continuous", &MJPEGFrameDescriptor{}, []byte{
+			0x26, 0x24, 0x07, 0x01, 0x00, 0x80, 0x07, 0x38, 0x04,
+			0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x20, 0x00, 0x48, 0x3f, 0x00,
+			0x15, 0x16, 0x05, 0x00, 0x00,
+			0x15, 0x16, 0x05, 0x00, 0x2a, 0x2c, 0x0a, 0x00, 0x0a, 0x00, 0x00, 0x00,
+		}},
+		{"h264 simulcast format", &H264FormatDescriptor{}, append([]byte{
+			0x34, 0x24, 0x15, 0x01, 0x01, 0x01, 0x02, 0x03, 0x01, 0x00, 0x00, 0x03,
+		}, make([]byte, 40)...)},
+		{"still image frame", &StillImageFrameDescriptor{}, []byte{
+			0x0f, 0x24, 0x03, 0x00, 0x02, 0x80, 0x02, 0xe0, 0x01, 0x40, 0x01, 0xf0, 0x00, 0x01, 0x00,
+		}},
+		{"interface association", &InterfaceAssociationDescriptor{}, []byte{
+			0x08, 0x0b, 0x00, 0x02, 0x0e, 0x03, 0x00, 0x02,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.desc.UnmarshalBinary(tt.buf); err != nil {
+				t.Fatalf("UnmarshalBinary failed: %v", err)
+			}
+			buf, err := tt.desc.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary failed: %v", err)
+			}
+			if !bytes.Equal(buf, tt.buf) {
+				t.Errorf("MarshalBinary() = % x, want % x", buf, tt.buf)
+			}
+		})
+	}
+}
+
+func TestMarshalBinary_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		desc encoding.BinaryMarshaler
+	}{
+		{"uneven control bitmasks", &InputHeaderDescriptor{ControlBitmasks: [][]byte{{0}, {0, 0}}}},
+		{"too many frame intervals", &UncompressedFrameDescriptor{DiscreteFrameIntervals: make([]time.Duration, 60)}},
+		{"zero audio control size", &AudioFeatureUnitDescriptor{Controls: []byte{0}}},
+		{"still image endpoint direction", &StandardVideoStreamingBulkStillImageDataEndpointDescriptor{EndpointAddress: 0x02}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.desc.MarshalBinary(); err == nil {
+				t.Error("MarshalBinary succeeded, want an error")
+			}
+		})
+	}
+}
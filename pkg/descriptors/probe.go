@@ -0,0 +1,261 @@
+package descriptors
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProbeHint is a bit of bmHint in the video probe and commit controls, marking the fields the device
+// should keep fixed while negotiating. UVC spec 1.5, section 4.3.1.1.
+type ProbeHint uint16
+
+const (
+	ProbeHintFrameInterval  ProbeHint = 1 << 0
+	ProbeHintKeyFrameRate   ProbeHint = 1 << 1
+	ProbeHintPFrameRate     ProbeHint = 1 << 2
+	ProbeHintCompQuality    ProbeHint = 1 << 3
+	ProbeHintCompWindowSize ProbeHint = 1 << 4
+)
+
+// Usage is the bUsage field of the video probe and commit controls, selecting the use case the
+// encoder optimizes for. UVC H.264 payload spec 1.5, section 3.3: modes 1-8 are real-time, 9-16
+// broadcast, 17-24 storage and 25-31 multiview, the constants are the first mode of their range.
+type Usage uint8
+
+const (
+	UsageRealtime  Usage = 1
+	UsageBroadcast Usage = 9
+	UsageStorage   Usage = 17
+	UsageMultiview Usage = 25
+)
+
+// Base returns the first mode of the range u belongs to, or u itself if it is reserved.
+func (u Usage) Base() Usage {
+	if u < UsageRealtime || u > UsageMultiview+6 {
+		return u
+	}
+	return (u-1)/8*8 + 1
+}
+
+func (u Usage) String() string {
+	var name string
+	switch u.Base() {
+	case UsageRealtime:
+		name = "Realtime"
+	case UsageBroadcast:
+		name = "Broadcast"
+	case UsageStorage:
+		name = "Storage"
+	case UsageMultiview:
+		name = "Multiview"
+	default:
+		if u == 0 {
+			return "Unspecified"
+		}
+		return fmt.Sprintf("Usage(%d)", uint8(u))
+	}
+	if u != u.Base() {
+		return fmt.Sprintf("%s mode %d", name, u-u.Base()+1)
+	}
+	return name
+}
+
+// RateControlMode is the rate control mode of one simulcast stream in bmRateControlModes. UVC H.264
+// payload spec 1.5, section 3.3.
+type RateControlMode uint8
+
+const (
+	RateControlNone                      RateControlMode = 0
+	RateControlVBR                       RateControlMode = 1
+	RateControlCBR                       RateControlMode = 2
+	RateControlConstantQP                RateControlMode = 3
+	RateControlGlobalVBR                 RateControlMode = 4
+	RateControlVBRWithoutUnderflow       RateControlMode = 5
+	RateControlGlobalVBRWithoutUnderflow RateControlMode = 6
+)
+
+func (m RateControlMode) String() string {
+	switch m {
+	case RateControlNone:
+		return "None"
+	case RateControlVBR:
+		return "VBR"
+	case RateControlCBR:
+		return "CBR"
+	case RateControlConstantQP:
+		return "ConstantQP"
+	case RateControlGlobalVBR:
+		return "GlobalVBR"
+	case RateControlVBRWithoutUnderflow:
+		return "VBRWithoutUnderflow"
+	case RateControlGlobalVBRWithoutUnderflow:
+		return "GlobalVBRWithoutUnderflow"
+	}
+	return fmt.Sprintf("RateControlMode(%d)", uint8(m))
+}
+
+// StreamRateControlMode returns the rate control mode of simulcast stream i.
+func (vpcc *VideoProbeCommitControl) StreamRateControlMode(i int) RateControlMode {
+	return RateControlMode(vpcc.RateControlModes >> (4 * i) & 0xf)
+}
+
+// StreamLayout returns the layout of simulcast stream i.
+func (vpcc *VideoProbeCommitControl) StreamLayout(i int) StreamLayout {
+	return StreamLayout(vpcc.LayoutPerStream[i])
+}
+
+// ProbeBuilder builds the fields of a video probe control to request from a device. Only the fields
+// that were set are applied, the others keep the values the device reports, so a builder can be
+// applied on top of the result of GET_MAX or GET_DEF.
+type ProbeBuilder struct {
+	formatIndex, frameIndex uint8
+	ops                     []func(*VideoProbeCommitControl)
+	err                     error
+}
+
+// NewProbe starts a probe for the given format and frame.
+func NewProbe(formatIndex, frameIndex uint8) *ProbeBuilder {
+	return &ProbeBuilder{formatIndex: formatIndex, frameIndex: frameIndex}
+}
+
+func (b *ProbeBuilder) set(op func(*VideoProbeCommitControl)) *ProbeBuilder {
+	b.ops = append(b.ops, op)
+	return b
+}
+
+// Err returns the first invalid argument passed to the builder, which is then not applied.
+func (b *ProbeBuilder) Err() error {
+	return b.err
+}
+
+// checkStream validates the index of a simulcast stream, of which there are at most four.
+func (b *ProbeBuilder) checkStream(i int) bool {
+	if i < 0 || i >= 4 {
+		if b.err == nil {
+			b.err = fmt.Errorf("simulcast stream %d out of range [0, 3]", i)
+		}
+		return false
+	}
+	return true
+}
+
+// FormatIndex returns the format the probe requests.
+func (b *ProbeBuilder) FormatIndex() uint8 {
+	return b.formatIndex
+}
+
+// FrameIndex returns the frame the probe requests.
+func (b *ProbeBuilder) FrameIndex() uint8 {
+	return b.frameIndex
+}
+
+// Hint marks fields the device should keep fixed. The setters below mark their own fields.
+func (b *ProbeBuilder) Hint(h ProbeHint) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) { vpcc.HintBitmask |= uint16(h) })
+}
+
+// FrameInterval requests a frame interval.
+func (b *ProbeBuilder) FrameInterval(d time.Duration) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) {
+		vpcc.FrameInterval = d
+		vpcc.HintBitmask |= uint16(ProbeHintFrameInterval)
+	})
+}
+
+// KeyFrameRate requests a key frame every n frames.
+func (b *ProbeBuilder) KeyFrameRate(n uint16) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) {
+		vpcc.KeyFrameRate = n
+		vpcc.HintBitmask |= uint16(ProbeHintKeyFrameRate)
+	})
+}
+
+// PFrameRate requests n P frames per key frame.
+func (b *ProbeBuilder) PFrameRate(n uint16) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) {
+		vpcc.PFrameRate = n
+		vpcc.HintBitmask |= uint16(ProbeHintPFrameRate)
+	})
+}
+
+// CompQuality requests a compression quality between 1 and 10000.
+func (b *ProbeBuilder) CompQuality(q uint16) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) {
+		vpcc.CompQuality = q
+		vpcc.HintBitmask |= uint16(ProbeHintCompQuality)
+	})
+}
+
+// CompWindowSize requests the window of frames over which the average frame size is kept.
+func (b *ProbeBuilder) CompWindowSize(n uint16) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) {
+		vpcc.CompWindowSize = n
+		vpcc.HintBitmask |= uint16(ProbeHintCompWindowSize)
+	})
+}
+
+// Usage selects the use case the encoder optimizes for.
+func (b *ProbeBuilder) Usage(u Usage) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) { vpcc.Usage = uint8(u) })
+}
+
+// BitDepthLuma requests the luma bit depth, such as 8 or 10. bBitDepthLuma holds the depth itself,
+// bit_depth_luma_minus8 + 8 of the H.264 sequence parameter set.
+func (b *ProbeBuilder) BitDepthLuma(bits int) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) { vpcc.BitDepthLuma = uint8(bits) })
+}
+
+// Settings sets bmSettings, whose bits are defined by the payload specification of the format.
+func (b *ProbeBuilder) Settings(bitmask uint8) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) { vpcc.SettingsBitmask = bitmask })
+}
+
+// MaxReferenceFrames requests the maximum number of reference frames. bMaxNumberOfRefFramesPlus1 holds
+// the number plus one.
+func (b *ProbeBuilder) MaxReferenceFrames(n int) *ProbeBuilder {
+	return b.set(func(vpcc *VideoProbeCommitControl) { vpcc.MaxNumberOfRefFramesPlus1 = uint8(n + 1) })
+}
+
+// Layout enables simulcast stream i, between 0 and 3, with the given layers, keeping its rate control
+// mode.
+func (b *ProbeBuilder) Layout(i int, layout StreamLayout) *ProbeBuilder {
+	if !b.checkStream(i) {
+		return b
+	}
+	return b.set(func(vpcc *VideoProbeCommitControl) { vpcc.LayoutPerStream[i] = uint16(layout) })
+}
+
+// Stream enables simulcast stream i, between 0 and 3, with the given layers and rate control mode.
+func (b *ProbeBuilder) Stream(i int, layout StreamLayout, mode RateControlMode) *ProbeBuilder {
+	if !b.checkStream(i) {
+		return b
+	}
+	return b.set(func(vpcc *VideoProbeCommitControl) {
+		vpcc.LayoutPerStream[i] = uint16(layout)
+		shift := 4 * i
+		vpcc.RateControlModes = vpcc.RateControlModes&^(0xf<<shift) | uint16(mode&0xf)<<shift
+	})
+}
+
+// Apply sets the fields of the probe on vpcc. It returns the error of Err without changing vpcc if
+// an argument was invalid.
+func (b *ProbeBuilder) Apply(vpcc *VideoProbeCommitControl) error {
+	if b.err != nil {
+		return b.err
+	}
+	vpcc.FormatIndex = b.formatIndex
+	vpcc.FrameIndex = b.frameIndex
+	for _, op := range b.ops {
+		op(vpcc)
+	}
+	return nil
+}
+
+// Build returns a probe control holding only the fields that were set.
+func (b *ProbeBuilder) Build() (*VideoProbeCommitControl, error) {
+	vpcc := &VideoProbeCommitControl{}
+	if err := b.Apply(vpcc); err != nil {
+		return nil, err
+	}
+	return vpcc, nil
+}
@@ -0,0 +1,88 @@
+package descriptors
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProbeBuilder(t *testing.T) {
+	probe := NewProbe(2, 3).
+		FrameInterval(time.Second/30).
+		Usage(UsageBroadcast).
+		BitDepthLuma(10).
+		MaxReferenceFrames(2).
+		Stream(0, NewStreamLayout(1, 2, 1), RateControlCBR).
+		Stream(1, NewStreamLayout(1, 1, 1), RateControlConstantQP)
+
+	// fields that were not set keep the values reported by the device.
+	vpcc := &VideoProbeCommitControl{CompQuality: 5000, MaxPayloadTransferSize: 3072, RateControlModes: 0x1000}
+	if err := probe.Apply(vpcc); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	buf, err := vpcc.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	got := &VideoProbeCommitControl{}
+	if err := got.UnmarshalBinary(buf); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if got.FormatIndex != 2 || got.FrameIndex != 3 || got.FrameInterval != 333333*100*time.Nanosecond {
+		t.Errorf("format %d, frame %d, interval %v", got.FormatIndex, got.FrameIndex, got.FrameInterval)
+	}
+	if got.HintBitmask != uint16(ProbeHintFrameInterval) {
+		t.Errorf("HintBitmask = %#x", got.HintBitmask)
+	}
+	if got.CompQuality != 5000 || got.MaxPayloadTransferSize != 3072 {
+		t.Errorf("unset fields changed: quality %d, payload size %d", got.CompQuality, got.MaxPayloadTransferSize)
+	}
+	if Usage(got.Usage) != UsageBroadcast || got.BitDepthLuma != 10 || got.MaxNumberOfRefFramesPlus1 != 3 {
+		t.Errorf("usage %v, bit depth %d, ref frames %d", Usage(got.Usage), got.BitDepthLuma, got.MaxNumberOfRefFramesPlus1)
+	}
+	if got.StreamRateControlMode(0) != RateControlCBR || got.StreamRateControlMode(1) != RateControlConstantQP || got.StreamRateControlMode(3) != RateControlVBR {
+		t.Errorf("RateControlModes = %#04x", got.RateControlModes)
+	}
+	if l := got.StreamLayout(0); l.SpatialLayers() != 1 || l.TemporalLayers() != 2 || l.QualityLayers() != 1 {
+		t.Errorf("stream 0 layout = %#04x", uint16(l))
+	}
+	if got.StreamLayout(2).Enabled() || !got.Simulcast() {
+		t.Errorf("LayoutPerStream = %v", got.LayoutPerStream)
+	}
+}
+
+func TestProbeBuilder_InvalidStream(t *testing.T) {
+	for _, i := range []int{-1, 4} {
+		probe := NewProbe(1, 1).Layout(i, NewStreamLayout(1, 1, 1)).Stream(0, NewStreamLayout(1, 1, 1), RateControlCBR)
+		if probe.Err() == nil {
+			t.Errorf("Layout(%d) accepted", i)
+		}
+		vpcc := &VideoProbeCommitControl{}
+		if err := probe.Apply(vpcc); err == nil || vpcc.FormatIndex != 0 {
+			t.Errorf("Apply after Layout(%d) = %v, format %d", i, err, vpcc.FormatIndex)
+		}
+		if _, err := NewProbe(1, 1).Stream(i, NewStreamLayout(1, 1, 1), RateControlCBR).Build(); err == nil {
+			t.Errorf("Build after Stream(%d) succeeded", i)
+		}
+	}
+}
+
+func TestUsage_String(t *testing.T) {
+	for _, tc := range []struct {
+		usage Usage
+		want  string
+	}{
+		{0, "Unspecified"},
+		{UsageRealtime, "Realtime"},
+		{3, "Realtime mode 3"},
+		{UsageBroadcast, "Broadcast"},
+		{UsageStorage, "Storage"},
+		{24, "Storage mode 8"},
+		{UsageMultiview, "Multiview"},
+		{32, "Usage(32)"},
+	} {
+		if got := tc.usage.String(); got != tc.want {
+			t.Errorf("Usage(%d).String() = %q, want %q", uint8(tc.usage), got, tc.want)
+		}
+	}
+}
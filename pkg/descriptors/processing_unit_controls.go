@@ -3,6 +3,7 @@ package descriptors
 import (
 	"encoding"
 	"encoding/binary"
+	"io"
 )
 
 type ProcessingUnitControlSelector int
@@ -83,6 +84,9 @@ func (bcc *BacklightCompensationControl) MarshalBinary() ([]byte, error) {
 }
 
 func (bcc *BacklightCompensationControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	bcc.BacklightCompensation = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -106,6 +110,9 @@ func (bc *BrightnessControl) MarshalBinary() ([]byte, error) {
 }
 
 func (bc *BrightnessControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	bc.Brightness = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -129,6 +136,9 @@ func (cc *ContrastControl) MarshalBinary() ([]byte, error) {
 }
 
 func (cc *ContrastControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	cc.Contrast = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -152,6 +162,9 @@ func (cac *ContrastAutoControl) MarshalBinary() ([]byte, error) {
 }
 
 func (cac *ContrastAutoControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	cac.Auto = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -175,6 +188,9 @@ func (gc *GainControl) MarshalBinary() ([]byte, error) {
 }
 
 func (gc *GainControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	gc.Gain = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -198,6 +214,9 @@ func (plfc *PowerLineFrequencyControl) MarshalBinary() ([]byte, error) {
 }
 
 func (plfc *PowerLineFrequencyControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	plfc.Frequency = PowerLineFrequency(binary.LittleEndian.Uint16(buf))
 	return nil
 }
@@ -221,6 +240,9 @@ func (hc *HueControl) MarshalBinary() ([]byte, error) {
 }
 
 func (hc *HueControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	hc.Hue = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -244,6 +266,9 @@ func (hac *HueAutoControl) MarshalBinary() ([]byte, error) {
 }
 
 func (hac *HueAutoControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	hac.Auto = buf[0]
 	return nil
 }
@@ -267,6 +292,9 @@ func (sc *SaturationControl) MarshalBinary() ([]byte, error) {
 }
 
 func (sc *SaturationControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	sc.Saturation = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -290,6 +318,9 @@ func (sc *SharpnessControl) MarshalBinary() ([]byte, error) {
 }
 
 func (sc *SharpnessControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	sc.Sharpness = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -313,6 +344,9 @@ func (gc *GammaControl) MarshalBinary() ([]byte, error) {
 }
 
 func (gc *GammaControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	gc.Gamma = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -336,6 +370,9 @@ func (wbt *WhiteBalanceTemperatureControl) MarshalBinary() ([]byte, error) {
 }
 
 func (wbt *WhiteBalanceTemperatureControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	wbt.WhiteBalanceTemperature = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -359,6 +396,9 @@ func (wbtac *WhiteBalanceTemperatureAutoControl) MarshalBinary() ([]byte, error)
 }
 
 func (wbtac *WhiteBalanceTemperatureAutoControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	wbtac.WhiteBalanceTemperatureAuto = buf[0]
 	return nil
 }
@@ -384,6 +424,9 @@ func (wbcc *WhiteBalanceComponentControl) MarshalBinary() ([]byte, error) {
 }
 
 func (wbcc *WhiteBalanceComponentControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 4 {
+		return io.ErrShortBuffer
+	}
 	wbcc.Blue = binary.LittleEndian.Uint16(buf[0:2])
 	wbcc.Blue = binary.LittleEndian.Uint16(buf[2:4])
 	return nil
@@ -408,6 +451,9 @@ func (wbcac *WhiteBalanceComponentAutoControl) MarshalBinary() ([]byte, error) {
 }
 
 func (wbcac *WhiteBalanceComponentAutoControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	wbcac.WhiteBalanceComponentAuto = buf[0]
 	return nil
 }
@@ -432,6 +478,9 @@ func (dmc *DigitalMultiplerControl) MarshalBinary() ([]byte, error) {
 }
 
 func (dmc *DigitalMultiplerControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	dmc.DigitalMultipler = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -455,6 +504,9 @@ func (dmlc *DigitalMultiplerLimitControl) MarshalBinary() ([]byte, error) {
 }
 
 func (dmlc *DigitalMultiplerLimitControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return io.ErrShortBuffer
+	}
 	dmlc.DigitalMultiplerLimit = binary.LittleEndian.Uint16(buf)
 	return nil
 }
@@ -478,6 +530,9 @@ func (avsc *AnalogVideoStandardControl) MarshalBinary() ([]byte, error) {
 }
 
 func (avsc *AnalogVideoStandardControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	avsc.AnalogVideoStandard = AnalogVideoStandard(buf[0])
 	return nil
 }
@@ -501,6 +556,9 @@ func (avlsc *AnalogVideoLockStatusControl) MarshalBinary() ([]byte, error) {
 }
 
 func (avlsc *AnalogVideoLockStatusControl) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 1 {
+		return io.ErrShortBuffer
+	}
 	avlsc.AnalogVideoLockStatus = AnalogVideoLockStatus(buf[0])
 	return nil
 }
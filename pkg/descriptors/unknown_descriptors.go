@@ -0,0 +1,42 @@
+package descriptors
+
+import "io"
+
+// UnknownDescriptor is a descriptor the parser does not interpret, such as a vendor-specific descriptor,
+// a class-specific endpoint descriptor or one added by a revision of the spec that is not supported
+// yet. It is kept so that tools can show it and quirk handlers can interpret it.
+type UnknownDescriptor struct {
+	// Type is bDescriptorType.
+	Type uint8
+	// Subtype is bDescriptorSubtype, or zero if the descriptor is too short to have one.
+	Subtype uint8
+	// Raw is the whole descriptor, including bLength and bDescriptorType.
+	Raw []byte
+}
+
+func (ud *UnknownDescriptor) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 || len(buf) < int(buf[0]) {
+		return io.ErrShortBuffer
+	}
+	if buf[0] < 2 {
+		return ErrInvalidDescriptor
+	}
+	ud.Type = buf[1]
+	ud.Subtype = 0
+	if buf[0] >= 3 {
+		ud.Subtype = buf[2]
+	}
+	ud.Raw = append([]byte(nil), buf[:buf[0]]...)
+	return nil
+}
+
+func (ud *UnknownDescriptor) MarshalBinary() ([]byte, error) {
+	if len(ud.Raw) < 2 || int(ud.Raw[0]) != len(ud.Raw) {
+		return nil, ErrInvalidDescriptor
+	}
+	return append([]byte(nil), ud.Raw...), nil
+}
+
+func (ud *UnknownDescriptor) isControlInterface() {}
+
+func (ud *UnknownDescriptor) isStreamingInterface() {}
@@ -0,0 +1,51 @@
+package descriptors
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnmarshalControlInterface_Unknown(t *testing.T) {
+	for _, buf := range [][]byte{
+		{5, 0x24, 0x0a, 1, 2}, // unassigned subtype
+		{6, 0x41, 0xde, 0xad, 0xbe, 0xef},
+		{2, 0x41},
+	} {
+		desc, err := UnmarshalControlInterface(buf)
+		if err != nil {
+			t.Fatalf("UnmarshalControlInterface(%x) failed: %v", buf, err)
+		}
+		ud, ok := desc.(*UnknownDescriptor)
+		if !ok {
+			t.Fatalf("UnmarshalControlInterface(%x) = %T, want *UnknownDescriptor", buf, desc)
+		}
+		if ud.Type != buf[1] || !bytes.Equal(ud.Raw, buf) || len(buf) >= 3 && ud.Subtype != buf[2] {
+			t.Errorf("UnmarshalControlInterface(%x) = %+v", buf, ud)
+		}
+	}
+
+	desc, err := UnmarshalControlInterface([]byte{5, 0x25, 0x03, 0x40, 0x00})
+	if ep, ok := desc.(*StandardVideoControlInterruptEndpointDescriptor); err != nil || !ok || ep.MaxTransferSize != 64 {
+		t.Errorf("interrupt endpoint = %+v, %v", desc, err)
+	}
+}
+
+func TestUnmarshalStreamingInterface_Unknown(t *testing.T) {
+	buf := []byte{4, 0x24, 0xf0, 1}
+	desc, err := UnmarshalStreamingInterface(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ud, ok := desc.(*UnknownDescriptor); !ok || ud.Subtype != 0xf0 || !bytes.Equal(ud.Raw, buf) {
+		t.Errorf("UnmarshalStreamingInterface = %+v", desc)
+	}
+	// the raw bytes must not alias the input.
+	buf[3] = 2
+	if desc.(*UnknownDescriptor).Raw[3] != 1 {
+		t.Error("Raw aliases the parsed buffer")
+	}
+
+	if _, err := UnmarshalStreamingInterface([]byte{0, 0x24, 0xf0}); err == nil {
+		t.Error("descriptor of length 0 accepted")
+	}
+}
@@ -1,10 +1,7 @@
 // This file implements the descriptors as defined in the UVC spec 1.5, section 3.8.
 package descriptors
 
-import (
-	"encoding/binary"
-	"io"
-)
+import "encoding/binary"
 
 type VideoControlEndpointDescriptorSubtype byte
 
@@ -20,8 +17,8 @@ type StandardVideoControlInterruptEndpointDescriptor struct {
 }
 
 func (svcie *StandardVideoControlInterruptEndpointDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 5); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeEndpoint {
 		return ErrInvalidDescriptor
@@ -29,6 +26,17 @@ func (svcie *StandardVideoControlInterruptEndpointDescriptor) UnmarshalBinary(bu
 	if VideoControlEndpointDescriptorSubtype(buf[2]) != VideoControlEndpointDescriptorSubtypeInterrupt {
 		return ErrInvalidDescriptor
 	}
-	svcie.MaxTransferSize = binary.LittleEndian.Uint16(buf[2:4])
+	svcie.MaxTransferSize = binary.LittleEndian.Uint16(buf[3:5])
 	return nil
 }
+
+func (svcie *StandardVideoControlInterruptEndpointDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(5, ClassSpecificDescriptorTypeEndpoint, uint8(VideoControlEndpointDescriptorSubtypeInterrupt))
+	if err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint16(buf[3:5], svcie.MaxTransferSize)
+	return buf, nil
+}
+
+func (svcie *StandardVideoControlInterruptEndpointDescriptor) isControlInterface() {}
@@ -8,21 +8,39 @@ import (
 )
 
 type ControlInterface interface {
+	encoding.BinaryMarshaler
 	encoding.BinaryUnmarshaler
 	isControlInterface()
 }
 
 func UnmarshalInputTerminal(buf []byte) (ControlInterface, error) {
+	if len(buf) < 6 {
+		return nil, io.ErrShortBuffer
+	}
 	var desc ControlInterface
 	switch InputTerminalType(binary.LittleEndian.Uint16(buf[4:6])) {
 	case InputTerminalTypeCamera:
 		desc = &CameraTerminalDescriptor{}
+	default:
+		desc = &InputTerminalDescriptor{}
 	}
 	return desc, desc.UnmarshalBinary(buf)
 }
 
+// UnmarshalControlInterface parses a descriptor of the video control interface or of its interrupt
+// endpoint. Descriptors it does not interpret are returned as an UnknownDescriptor.
 func UnmarshalControlInterface(buf []byte) (ControlInterface, error) {
+	if len(buf) < 2 {
+		return nil, io.ErrShortBuffer
+	}
 	var desc ControlInterface
+	if len(buf) < 3 || ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
+		desc = &UnknownDescriptor{}
+		if len(buf) >= 3 && ClassSpecificDescriptorType(buf[1]) == ClassSpecificDescriptorTypeEndpoint && VideoControlEndpointDescriptorSubtype(buf[2]) == VideoControlEndpointDescriptorSubtypeInterrupt {
+			desc = &StandardVideoControlInterruptEndpointDescriptor{}
+		}
+		return desc, desc.UnmarshalBinary(buf)
+	}
 	switch VideoControlInterfaceDescriptorSubtype(buf[2]) {
 	case VideoControlInterfaceDescriptorSubtypeHeader:
 		desc = &HeaderDescriptor{}
@@ -38,6 +56,8 @@ func UnmarshalControlInterface(buf []byte) (ControlInterface, error) {
 		desc = &EncodingUnitDescriptor{}
 	case VideoControlInterfaceDescriptorSubtypeExtensionUnit:
 		desc = &ExtensionUnitDescriptor{}
+	default:
+		desc = &UnknownDescriptor{}
 	}
 	return desc, desc.UnmarshalBinary(buf)
 }
@@ -96,8 +116,8 @@ type StandardVideoControlInterfaceDescriptor struct {
 }
 
 func (svcid *StandardVideoControlInterfaceDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 9); err != nil {
+		return err
 	}
 	// TODO: check the descriptor type, this is not the class specific one.
 	// if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
@@ -119,6 +139,19 @@ func (svcid *StandardVideoControlInterfaceDescriptor) UnmarshalBinary(buf []byte
 	return nil
 }
 
+func (svcid *StandardVideoControlInterfaceDescriptor) MarshalBinary() ([]byte, error) {
+	return []byte{
+		9, 0x04, // bLength, INTERFACE
+		svcid.InterfaceNumber,
+		svcid.AlternateSetting,
+		svcid.NumEndpoints,
+		uint8(ClassCodeVideo),
+		uint8(SubclassCodeVideoControl),
+		uint8(ProtocolCode15),
+		svcid.DescriptionIndex,
+	}, nil
+}
+
 func (svcid *StandardVideoControlInterfaceDescriptor) isControlInterface() {}
 
 // HeaderDescriptor as defined in UVC spec 1.5, 3.7.2.1
@@ -130,8 +163,8 @@ type HeaderDescriptor struct {
 }
 
 func (hd *HeaderDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 12); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -143,10 +176,26 @@ func (hd *HeaderDescriptor) UnmarshalBinary(buf []byte) error {
 	hd.TotalLength = binary.LittleEndian.Uint16(buf[5:7])
 	hd.ClockFrequency = binary.LittleEndian.Uint32(buf[7:11])
 	n := buf[11]
+	if int(buf[0]) < 12+int(n) {
+		return ErrInvalidDescriptor
+	}
 	hd.VideoStreamingInterfaceIndexes = buf[12 : 12+n]
 	return nil
 }
 
+func (hd *HeaderDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(12+len(hd.VideoStreamingInterfaceIndexes), ClassSpecificDescriptorTypeInterface, uint8(VideoControlInterfaceDescriptorSubtypeHeader))
+	if err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint16(buf[3:5], hd.UVC)
+	binary.LittleEndian.PutUint16(buf[5:7], hd.TotalLength)
+	binary.LittleEndian.PutUint32(buf[7:11], hd.ClockFrequency)
+	buf[11] = uint8(len(hd.VideoStreamingInterfaceIndexes))
+	copy(buf[12:], hd.VideoStreamingInterfaceIndexes)
+	return buf, nil
+}
+
 func (hd *HeaderDescriptor) isControlInterface() {}
 
 // InputTerminalDescriptor as defined in UVC spec 1.5, 3.7.2.1
@@ -158,8 +207,8 @@ type InputTerminalDescriptor struct {
 }
 
 func (itd *InputTerminalDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 8); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -174,6 +223,23 @@ func (itd *InputTerminalDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (itd *InputTerminalDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(8, ClassSpecificDescriptorTypeInterface, uint8(VideoControlInterfaceDescriptorSubtypeInputTerminal))
+	if err != nil {
+		return nil, err
+	}
+	itd.marshalInto(buf)
+	return buf, nil
+}
+
+// marshalInto writes the fields shared by all input terminals.
+func (itd *InputTerminalDescriptor) marshalInto(buf []byte) {
+	buf[3] = itd.TerminalID
+	binary.LittleEndian.PutUint16(buf[4:6], uint16(itd.TerminalType))
+	buf[6] = itd.AssociatedTerminalID
+	buf[7] = itd.DescriptionIndex
+}
+
 func (itd *InputTerminalDescriptor) isControlInterface() {}
 
 // OutputTerminalDescriptor as defined in UVC spec 1.5, 3.7.2.2
@@ -182,11 +248,12 @@ type OutputTerminalDescriptor struct {
 	TerminalType         OutputTerminalType
 	AssociatedTerminalID uint8
 	SourceID             uint8
+	DescriptionIndex     uint8
 }
 
 func (otd *OutputTerminalDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 8); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -198,9 +265,25 @@ func (otd *OutputTerminalDescriptor) UnmarshalBinary(buf []byte) error {
 	otd.TerminalType = OutputTerminalType(binary.LittleEndian.Uint16(buf[4:6]))
 	otd.AssociatedTerminalID = buf[6]
 	otd.SourceID = buf[7]
+	if buf[0] > 8 {
+		otd.DescriptionIndex = buf[8]
+	}
 	return nil
 }
 
+func (otd *OutputTerminalDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(9, ClassSpecificDescriptorTypeInterface, uint8(VideoControlInterfaceDescriptorSubtypeOutputTerminal))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = otd.TerminalID
+	binary.LittleEndian.PutUint16(buf[4:6], uint16(otd.TerminalType))
+	buf[6] = otd.AssociatedTerminalID
+	buf[7] = otd.SourceID
+	buf[8] = otd.DescriptionIndex
+	return buf, nil
+}
+
 func (otd *OutputTerminalDescriptor) isControlInterface() {}
 
 // CameraTerminalDescriptor as defined in UVC spec 1.5, 3.7.2.3
@@ -209,12 +292,12 @@ type CameraTerminalDescriptor struct {
 	ObjectiveFocalLengthMin uint16
 	ObjectiveFocalLengthMax uint16
 	OcularFocalLength       uint16
-	ControlsBitmask         []byte // Size 3 bitmap that indicates which controls are supported
+	ControlsBitmask         []byte // bitmap that indicates which controls are supported, 3 bytes in UVC 1.5
 }
 
 func (ctd *CameraTerminalDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 15); err != nil {
+		return err
 	}
 
 	inputTerminalDesc := &InputTerminalDescriptor{}
@@ -236,10 +319,30 @@ func (ctd *CameraTerminalDescriptor) UnmarshalBinary(buf []byte) error {
 	ctd.ObjectiveFocalLengthMin = binary.LittleEndian.Uint16(buf[8:10])
 	ctd.ObjectiveFocalLengthMax = binary.LittleEndian.Uint16(buf[10:12])
 	ctd.OcularFocalLength = binary.LittleEndian.Uint16(buf[12:14])
-	ctd.ControlsBitmask = buf[15:18]
+	n := buf[14]
+	if int(buf[0]) < 15+int(n) {
+		return ErrInvalidDescriptor
+	}
+	ctd.ControlsBitmask = make([]byte, n)
+	copy(ctd.ControlsBitmask, buf[15:15+int(n)])
 	return nil
 }
 
+func (ctd *CameraTerminalDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(15+len(ctd.ControlsBitmask), ClassSpecificDescriptorTypeInterface, uint8(VideoControlInterfaceDescriptorSubtypeInputTerminal))
+	if err != nil {
+		return nil, err
+	}
+	ctd.InputTerminalDescriptor.marshalInto(buf)
+	binary.LittleEndian.PutUint16(buf[4:6], uint16(InputTerminalTypeCamera))
+	binary.LittleEndian.PutUint16(buf[8:10], ctd.ObjectiveFocalLengthMin)
+	binary.LittleEndian.PutUint16(buf[10:12], ctd.ObjectiveFocalLengthMax)
+	binary.LittleEndian.PutUint16(buf[12:14], ctd.OcularFocalLength)
+	buf[14] = uint8(len(ctd.ControlsBitmask))
+	copy(buf[15:], ctd.ControlsBitmask)
+	return buf, nil
+}
+
 func (ctd *CameraTerminalDescriptor) isControlInterface() {}
 
 // SelectorUnitDescriptor as defined in UVC spec 1.5, 3.7.2.4
@@ -250,8 +353,8 @@ type SelectorUnitDescriptor struct {
 }
 
 func (sud *SelectorUnitDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 5); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -261,11 +364,27 @@ func (sud *SelectorUnitDescriptor) UnmarshalBinary(buf []byte) error {
 	}
 	sud.UnitID = buf[3]
 	p := buf[4]
+	if int(buf[0]) < 6+int(p) {
+		return ErrInvalidDescriptor
+	}
 	sud.SourceID = buf[5 : 5+p]
 	sud.DescriptionIndex = buf[5+p]
 	return nil
 }
 
+func (sud *SelectorUnitDescriptor) MarshalBinary() ([]byte, error) {
+	p := len(sud.SourceID)
+	buf, err := newDescriptor(6+p, ClassSpecificDescriptorTypeInterface, uint8(VideoControlInterfaceDescriptorSubtypeSelectorUnit))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = sud.UnitID
+	buf[4] = uint8(p)
+	copy(buf[5:5+p], sud.SourceID)
+	buf[5+p] = sud.DescriptionIndex
+	return buf, nil
+}
+
 func (sud *SelectorUnitDescriptor) isControlInterface() {}
 
 // ProcessingUnitDescriptor as defined in UVC spec 1.5, 3.7.2.5
@@ -276,11 +395,14 @@ type ProcessingUnitDescriptor struct {
 	ControlsBitmask       []byte
 	DescriptionIndex      uint8
 	VideoStandardsBitmask uint8
+
+	// noVideoStandards is set for UVC 1.0 descriptors, which end before bmVideoStandards.
+	noVideoStandards bool
 }
 
 func (pud *ProcessingUnitDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 8); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -292,16 +414,42 @@ func (pud *ProcessingUnitDescriptor) UnmarshalBinary(buf []byte) error {
 	pud.SourceID = buf[4]
 	pud.MaxMultiplier = binary.LittleEndian.Uint16(buf[5:7])
 	n := buf[7]
+	if int(buf[0]) < 9+int(n) {
+		return ErrInvalidDescriptor
+	}
 	pud.ControlsBitmask = make([]byte, n)
 	copy(pud.ControlsBitmask, buf[8:8+n])
 	pud.DescriptionIndex = buf[8+n]
-	if len(buf) > int(9+n) {
-		// TODO: did this not exist in USB spec 1.0?
+	// bmVideoStandards was added in UVC 1.1.
+	pud.noVideoStandards = int(buf[0]) <= 9+int(n)
+	if !pud.noVideoStandards {
 		pud.VideoStandardsBitmask = buf[9+n]
 	}
 	return nil
 }
 
+func (pud *ProcessingUnitDescriptor) MarshalBinary() ([]byte, error) {
+	n := len(pud.ControlsBitmask)
+	length := 10 + n
+	if pud.noVideoStandards {
+		length--
+	}
+	buf, err := newDescriptor(length, ClassSpecificDescriptorTypeInterface, uint8(VideoControlInterfaceDescriptorSubtypeProcessingUnit))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = pud.UnitID
+	buf[4] = pud.SourceID
+	binary.LittleEndian.PutUint16(buf[5:7], pud.MaxMultiplier)
+	buf[7] = uint8(n)
+	copy(buf[8:8+n], pud.ControlsBitmask)
+	buf[8+n] = pud.DescriptionIndex
+	if !pud.noVideoStandards {
+		buf[9+n] = pud.VideoStandardsBitmask
+	}
+	return buf, nil
+}
+
 func (pud *ProcessingUnitDescriptor) isControlInterface() {}
 
 // EncodingUnitDescriptor as defined in UVC spec 1.5, 3.7.2.6
@@ -314,8 +462,8 @@ type EncodingUnitDescriptor struct {
 }
 
 func (eud *EncodingUnitDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 13); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -326,12 +474,26 @@ func (eud *EncodingUnitDescriptor) UnmarshalBinary(buf []byte) error {
 	eud.UnitID = buf[3]
 	eud.SourceID = buf[4]
 	eud.DescriptionIndex = buf[5]
-	eud.ControlsBitmask = binary.LittleEndian.Uint32(buf[6:10])
-	// this is off by one because the bitmask is actually only the lower 3 bytes.
-	eud.ControlsRuntimeBitmask = binary.LittleEndian.Uint32(buf[9:13])
+	// buf[6] is bControlSize, always 3.
+	eud.ControlsBitmask = uint32(buf[7]) | uint32(buf[8])<<8 | uint32(buf[9])<<16
+	eud.ControlsRuntimeBitmask = uint32(buf[10]) | uint32(buf[11])<<8 | uint32(buf[12])<<16
 	return nil
 }
 
+func (eud *EncodingUnitDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(13, ClassSpecificDescriptorTypeInterface, uint8(VideoControlInterfaceDescriptorSubtypeEncodingUnit))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = eud.UnitID
+	buf[4] = eud.SourceID
+	buf[5] = eud.DescriptionIndex
+	buf[6] = 3
+	buf[7], buf[8], buf[9] = uint8(eud.ControlsBitmask), uint8(eud.ControlsBitmask>>8), uint8(eud.ControlsBitmask>>16)
+	buf[10], buf[11], buf[12] = uint8(eud.ControlsRuntimeBitmask), uint8(eud.ControlsRuntimeBitmask>>8), uint8(eud.ControlsRuntimeBitmask>>16)
+	return buf, nil
+}
+
 func (eud *EncodingUnitDescriptor) isControlInterface() {}
 
 // ExtensionUnitDescriptor as defined in UVC spec 1.5, 3.7.2.7
@@ -345,8 +507,8 @@ type ExtensionUnitDescriptor struct {
 }
 
 func (eud *ExtensionUnitDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 22); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -358,13 +520,36 @@ func (eud *ExtensionUnitDescriptor) UnmarshalBinary(buf []byte) error {
 	copyGUID(eud.GUIDExtensionCode[:], buf[4:20])
 	eud.NumControls = buf[20]
 	p := buf[21]
+	if int(buf[0]) < 23+int(p) {
+		return ErrInvalidDescriptor
+	}
 	eud.SourceIDs = make([]uint8, p)
 	copy(eud.SourceIDs, buf[22:22+p])
 	n := buf[22+p]
+	if int(buf[0]) < 24+int(p)+int(n) {
+		return ErrInvalidDescriptor
+	}
 	eud.ControlsBitmask = make([]byte, n)
 	copy(eud.ControlsBitmask, buf[23+p:23+p+n])
 	eud.DescriptionIndex = buf[23+p+n]
 	return nil
 }
 
+func (eud *ExtensionUnitDescriptor) MarshalBinary() ([]byte, error) {
+	p, n := len(eud.SourceIDs), len(eud.ControlsBitmask)
+	buf, err := newDescriptor(24+p+n, ClassSpecificDescriptorTypeInterface, uint8(VideoControlInterfaceDescriptorSubtypeExtensionUnit))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = eud.UnitID
+	copyGUID(buf[4:20], eud.GUIDExtensionCode[:])
+	buf[20] = eud.NumControls
+	buf[21] = uint8(p)
+	copy(buf[22:22+p], eud.SourceIDs)
+	buf[22+p] = uint8(n)
+	copy(buf[23+p:23+p+n], eud.ControlsBitmask)
+	buf[23+p+n] = eud.DescriptionIndex
+	return buf, nil
+}
+
 func (eud *ExtensionUnitDescriptor) isControlInterface() {}
@@ -1,10 +1,7 @@
 // This file implements the descriptors as defined in the UVC spec 1.5, section 3.10.
 package descriptors
 
-import (
-	"encoding/binary"
-	"io"
-)
+import "encoding/binary"
 
 // StandardVideoStreamingIsochronousVideoDataEndpointDescriptor as defined in UVC spec 1.5, 3.10.1.1
 type StandardVideoStreamingIsochronousVideoDataEndpointDescriptor struct {
@@ -15,8 +12,8 @@ type StandardVideoStreamingIsochronousVideoDataEndpointDescriptor struct {
 }
 
 func (svsived *StandardVideoStreamingIsochronousVideoDataEndpointDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 7); err != nil {
+		return err
 	}
 	// TODO: fix the descriptor type, this is not the class specific one.
 	// if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeEndpoint {
@@ -29,6 +26,12 @@ func (svsived *StandardVideoStreamingIsochronousVideoDataEndpointDescriptor) Unm
 	return nil
 }
 
+func (svsived *StandardVideoStreamingIsochronousVideoDataEndpointDescriptor) MarshalBinary() ([]byte, error) {
+	buf := []byte{7, 0x05, svsived.EndpointAddress, svsived.AttributesBitmask, 0, 0, svsived.Interval} // ENDPOINT
+	binary.LittleEndian.PutUint16(buf[4:6], svsived.MaxPacketSize)
+	return buf, nil
+}
+
 // StandardVideoStreamingBulkVideoDataEndpointDescriptor as defined in UVC spec 1.5, 3.10.1.2
 type StandardVideoStreamingBulkVideoDataEndpointDescriptor struct {
 	EndpointAddress uint8
@@ -37,8 +40,8 @@ type StandardVideoStreamingBulkVideoDataEndpointDescriptor struct {
 }
 
 func (svsbded *StandardVideoStreamingBulkVideoDataEndpointDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 7); err != nil {
+		return err
 	}
 	// TODO: fix the descriptor type, this is not the class specific one.
 	// if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeEndpoint {
@@ -53,6 +56,12 @@ func (svsbded *StandardVideoStreamingBulkVideoDataEndpointDescriptor) UnmarshalB
 	return nil
 }
 
+func (svsbded *StandardVideoStreamingBulkVideoDataEndpointDescriptor) MarshalBinary() ([]byte, error) {
+	buf := []byte{7, 0x05, svsbded.EndpointAddress, 0b10, 0, 0, svsbded.Interval} // ENDPOINT, Bulk
+	binary.LittleEndian.PutUint16(buf[4:6], svsbded.MaxPacketSize)
+	return buf, nil
+}
+
 // StandardVideoStreamingBulkStillImageDataEndpointDescriptor as defined in UVC spec 1.5, 3.10.1.3
 type StandardVideoStreamingBulkStillImageDataEndpointDescriptor struct {
 	EndpointAddress uint8
@@ -60,8 +69,8 @@ type StandardVideoStreamingBulkStillImageDataEndpointDescriptor struct {
 }
 
 func (svsbied *StandardVideoStreamingBulkStillImageDataEndpointDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 7); err != nil {
+		return err
 	}
 	// TODO: fix the descriptor type, this is not the class specific one.
 	// if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeEndpoint {
@@ -83,3 +92,12 @@ func (svsbied *StandardVideoStreamingBulkStillImageDataEndpointDescriptor) Unmar
 	}
 	return nil
 }
+
+func (svsbied *StandardVideoStreamingBulkStillImageDataEndpointDescriptor) MarshalBinary() ([]byte, error) {
+	if svsbied.EndpointAddress&0b10000000 == 0 || svsbied.EndpointAddress&0b01111000 != 0 {
+		return nil, ErrInvalidDescriptor
+	}
+	buf := []byte{7, 0x05, svsbied.EndpointAddress, 0b10, 0, 0, 0} // ENDPOINT, Bulk
+	binary.LittleEndian.PutUint16(buf[4:6], svsbied.MaxPacketSize)
+	return buf, nil
+}
@@ -4,16 +4,27 @@ package descriptors
 import (
 	"encoding"
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
 type StreamingInterface interface {
+	encoding.BinaryMarshaler
 	encoding.BinaryUnmarshaler
 	isStreamingInterface()
 }
 
+// UnmarshalStreamingInterface parses a descriptor of a video streaming interface. Descriptors it does
+// not interpret, including those of the streaming endpoints, are returned as an UnknownDescriptor.
 func UnmarshalStreamingInterface(buf []byte) (StreamingInterface, error) {
+	if len(buf) < 2 {
+		return nil, io.ErrShortBuffer
+	}
 	var desc StreamingInterface
+	if len(buf) < 3 || ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
+		desc = &UnknownDescriptor{}
+		return desc, desc.UnmarshalBinary(buf)
+	}
 	switch VideoStreamingInterfaceDescriptorSubtype(buf[2]) {
 	case VideoStreamingInterfaceDescriptorSubtypeInputHeader:
 		desc = &InputHeaderDescriptor{}
@@ -53,6 +64,8 @@ func UnmarshalStreamingInterface(buf []byte) (StreamingInterface, error) {
 		desc = &VP8FrameDescriptor{}
 	case VideoStreamingInterfaceDescriptorSubtypeFormatVP8Simulcast:
 		desc = &VP8FormatDescriptor{}
+	default:
+		desc = &UnknownDescriptor{}
 	}
 	return desc, desc.UnmarshalBinary(buf)
 }
@@ -91,8 +104,8 @@ type StandardVideoStreamingInterfaceDescriptor struct {
 }
 
 func (svsid *StandardVideoStreamingInterfaceDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 9); err != nil {
+		return err
 	}
 	// TODO: fix the descriptor type, this is not the class specific one.
 	// if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
@@ -114,6 +127,19 @@ func (svsid *StandardVideoStreamingInterfaceDescriptor) UnmarshalBinary(buf []by
 	return nil
 }
 
+func (svsid *StandardVideoStreamingInterfaceDescriptor) MarshalBinary() ([]byte, error) {
+	return []byte{
+		9, 0x04, // bLength, INTERFACE
+		svsid.InterfaceNumber,
+		svsid.AlternateSetting,
+		svsid.NumEndpoints,
+		uint8(ClassCodeVideo),
+		uint8(SubclassCodeVideoStreaming),
+		uint8(ProtocolCode15),
+		svsid.DescriptionIndex,
+	}, nil
+}
+
 func (svsid *StandardVideoStreamingInterfaceDescriptor) isStreamingInterface() {}
 
 // InputHeaderDescriptor as defined in UVC spec 1.5, 3.9.2.1
@@ -129,8 +155,8 @@ type InputHeaderDescriptor struct {
 }
 
 func (ihd *InputHeaderDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 13); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -147,6 +173,9 @@ func (ihd *InputHeaderDescriptor) UnmarshalBinary(buf []byte) error {
 	ihd.TriggerSupport = buf[10]
 	ihd.TriggerUsage = buf[11]
 	n := buf[12]
+	if int(buf[0]) < 13+int(p)*int(n) {
+		return ErrInvalidDescriptor
+	}
 	ihd.ControlBitmasks = make([][]byte, p)
 	for i := uint8(0); i < p; i++ {
 		ihd.ControlBitmasks[i] = buf[13+i*n : 13+(i+1)*n]
@@ -154,6 +183,45 @@ func (ihd *InputHeaderDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (ihd *InputHeaderDescriptor) MarshalBinary() ([]byte, error) {
+	p, n, err := controlBitmasksSize(ihd.ControlBitmasks)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := newDescriptor(13+p*n, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeInputHeader))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = uint8(p)
+	binary.LittleEndian.PutUint16(buf[4:6], ihd.TotalLength)
+	buf[6] = ihd.EndpointAddress
+	buf[7] = ihd.InfoBitmask
+	buf[8] = ihd.TerminalLink
+	buf[9] = ihd.StillCaptureMethod
+	buf[10] = ihd.TriggerSupport
+	buf[11] = ihd.TriggerUsage
+	buf[12] = uint8(n)
+	for i, bitmask := range ihd.ControlBitmasks {
+		copy(buf[13+i*n:], bitmask)
+	}
+	return buf, nil
+}
+
+// controlBitmasksSize returns the number of formats and the control size of the bmaControls field of
+// a header descriptor. All bitmasks must have the same size.
+func controlBitmasksSize(bitmasks [][]byte) (int, int, error) {
+	if len(bitmasks) == 0 {
+		return 0, 0, nil
+	}
+	n := len(bitmasks[0])
+	for i, bitmask := range bitmasks {
+		if len(bitmask) != n {
+			return 0, 0, fmt.Errorf("control bitmask %d has %d bytes, want %d: %w", i, len(bitmask), n, ErrInvalidDescriptor)
+		}
+	}
+	return len(bitmasks), n, nil
+}
+
 func (ihd *InputHeaderDescriptor) isStreamingInterface() {}
 
 // OutputHeaderDescriptor as defined in UVC spec 1.5, 3.9.2.2
@@ -165,8 +233,8 @@ type OutputHeaderDescriptor struct {
 }
 
 func (ohd *OutputHeaderDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 9); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -179,12 +247,36 @@ func (ohd *OutputHeaderDescriptor) UnmarshalBinary(buf []byte) error {
 	ohd.EndpointAddress = buf[6]
 	ohd.TerminalLink = buf[7]
 	n := buf[8]
+	if int(buf[0]) < 9+int(p)*int(n) {
+		return ErrInvalidDescriptor
+	}
+	ohd.ControlBitmasks = make([][]byte, p)
 	for i := uint8(0); i < p; i++ {
 		ohd.ControlBitmasks[i] = buf[9+i*n : 9+(i+1)*n]
 	}
 	return nil
 }
 
+func (ohd *OutputHeaderDescriptor) MarshalBinary() ([]byte, error) {
+	p, n, err := controlBitmasksSize(ohd.ControlBitmasks)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := newDescriptor(9+p*n, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeOutputHeader))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = uint8(p)
+	binary.LittleEndian.PutUint16(buf[4:6], ohd.TotalLength)
+	buf[6] = ohd.EndpointAddress
+	buf[7] = ohd.TerminalLink
+	buf[8] = uint8(n)
+	for i, bitmask := range ohd.ControlBitmasks {
+		copy(buf[9+i*n:], bitmask)
+	}
+	return buf, nil
+}
+
 func (ohd *OutputHeaderDescriptor) isStreamingInterface() {}
 
 // PayloadFormatDescriptor and VideoFrameDescriptor are implemented in the corresponding subpackages.
@@ -199,8 +291,8 @@ type StillImageFrameDescriptor struct {
 }
 
 func (sifd *StillImageFrameDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 5); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -209,18 +301,41 @@ func (sifd *StillImageFrameDescriptor) UnmarshalBinary(buf []byte) error {
 		return ErrInvalidDescriptor
 	}
 	sifd.EndpointAddress = buf[3]
-	n := buf[4]
-	for i := uint8(0); i < n; i++ {
+	n := int(buf[4])
+	if int(buf[0]) < 6+4*n {
+		return ErrInvalidDescriptor
+	}
+	sifd.ImageSizePatterns = make([]struct{ Width, Height uint16 }, n)
+	for i := 0; i < n; i++ {
 		sifd.ImageSizePatterns[i].Width = binary.LittleEndian.Uint16(buf[5+4*i : 7+4*i])
 		sifd.ImageSizePatterns[i].Height = binary.LittleEndian.Uint16(buf[7+4*i : 9+4*i])
 	}
-	m := buf[5+n*4]
-	for i := uint8(0); i < m; i++ {
-		sifd.CompressionPatterns[i] = buf[6+n*4+i]
+	m := int(buf[5+n*4])
+	if int(buf[0]) < 6+4*n+m {
+		return ErrInvalidDescriptor
 	}
+	sifd.CompressionPatterns = make([]uint8, m)
+	copy(sifd.CompressionPatterns, buf[6+n*4:6+n*4+m])
 	return nil
 }
 
+func (sifd *StillImageFrameDescriptor) MarshalBinary() ([]byte, error) {
+	n, m := len(sifd.ImageSizePatterns), len(sifd.CompressionPatterns)
+	buf, err := newDescriptor(6+4*n+m, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeStillImageFrame))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = sifd.EndpointAddress
+	buf[4] = uint8(n)
+	for i, size := range sifd.ImageSizePatterns {
+		binary.LittleEndian.PutUint16(buf[5+4*i:7+4*i], size.Width)
+		binary.LittleEndian.PutUint16(buf[7+4*i:9+4*i], size.Height)
+	}
+	buf[5+4*n] = uint8(m)
+	copy(buf[6+4*n:], sifd.CompressionPatterns)
+	return buf, nil
+}
+
 func (sifd *StillImageFrameDescriptor) isStreamingInterface() {}
 
 // ColorMatchingDescriptor as defined in UVC spec 1.5, 3.9.2.6
@@ -231,8 +346,8 @@ type ColorMatchingDescriptor struct {
 }
 
 func (cmd *ColorMatchingDescriptor) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
-		return io.ErrShortBuffer
+	if err := checkDescriptor(buf, 6); err != nil {
+		return err
 	}
 	if ClassSpecificDescriptorType(buf[1]) != ClassSpecificDescriptorTypeInterface {
 		return ErrInvalidDescriptor
@@ -246,4 +361,15 @@ func (cmd *ColorMatchingDescriptor) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+func (cmd *ColorMatchingDescriptor) MarshalBinary() ([]byte, error) {
+	buf, err := newDescriptor(6, ClassSpecificDescriptorTypeInterface, uint8(VideoStreamingInterfaceDescriptorSubtypeColorFormat))
+	if err != nil {
+		return nil, err
+	}
+	buf[3] = cmd.ColorPrimaries
+	buf[4] = cmd.TransferCharacteristics
+	buf[5] = cmd.MatrixCoefficients
+	return buf, nil
+}
+
 func (cmd *ColorMatchingDescriptor) isStreamingInterface() {}
@@ -0,0 +1,52 @@
+package descriptors
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestOutputHeaderDescriptor(t *testing.T) {
+	buf := []byte{
+		0x0b, 0x24, 0x02, // bLength, CS_INTERFACE, VS_OUTPUT_HEADER
+		0x02,       // bNumFormats
+		0x30, 0x00, // wTotalLength
+		0x02, // bEndpointAddress
+		0x05, // bTerminalLink
+		0x01, // bControlSize
+		0x00, 0x04,
+	}
+	d := &OutputHeaderDescriptor{}
+	if err := d.UnmarshalBinary(buf); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if d.TotalLength != 0x30 || d.EndpointAddress != 0x02 || d.TerminalLink != 0x05 {
+		t.Errorf("descriptor = %+v", d)
+	}
+	if len(d.ControlBitmasks) != 2 || !bytes.Equal(d.ControlBitmasks[1], []byte{0x04}) {
+		t.Errorf("ControlBitmasks = %v", d.ControlBitmasks)
+	}
+}
+
+func TestUncompressedFormatDescriptor_FourCC(t *testing.T) {
+	for _, tc := range []struct {
+		guid string
+		want [4]byte
+	}{
+		{"32595559-0000-0010-8000-00aa00389b71", [4]byte{'Y', 'U', 'Y', '2'}},
+		{"3231564e-0000-0010-8000-00aa00389b71", [4]byte{'N', 'V', '1', '2'}},
+		{"31384142-0000-0010-8000-00aa00389b71", [4]byte{'B', 'A', '8', '1'}},
+		{"41415270-0000-0010-8000-00aa00389b71", [4]byte{'p', 'R', 'A', 'A'}},
+		{"e436eb7d-524f-11ce-9f53-0020af0ba770", [4]byte{'B', 'G', 'R', '3'}},
+	} {
+		d := &UncompressedFormatDescriptor{GUIDFormat: uuid.MustParse(tc.guid)}
+		if got, err := d.FourCC(); err != nil || got != tc.want {
+			t.Errorf("FourCC(%s) = %q, %v, want %q", tc.guid, got[:], err, tc.want[:])
+		}
+	}
+	d := &UncompressedFormatDescriptor{GUIDFormat: uuid.MustParse("00000000-1111-2222-3333-444444444444")}
+	if _, err := d.FourCC(); err == nil {
+		t.Error("FourCC of a GUID without FourCC succeeded")
+	}
+}
@@ -0,0 +1,207 @@
+// Package dv parses DV frames (IEC 61834, SMPTE 314M) as captured from DV camcorders and analog to DV
+// converters, and writes them to raw .dv files.
+package dv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// BlockSize is the size of a DIF block.
+	BlockSize = 80
+	// SequenceSize is the size of a DIF sequence of 150 blocks.
+	SequenceSize = 150 * BlockSize
+
+	// FrameSize525 is the size of a 525/60 (NTSC) frame of 10 DIF sequences.
+	FrameSize525 = 10 * SequenceSize
+	// FrameSize625 is the size of a 625/50 (PAL) frame of 12 DIF sequences.
+	FrameSize625 = 12 * SequenceSize
+)
+
+// System is the video system of a DV frame.
+type System int
+
+const (
+	System525_60 System = iota // NTSC
+	System625_50               // PAL
+)
+
+func (s System) String() string {
+	switch s {
+	case System525_60:
+		return "525/60"
+	case System625_50:
+		return "625/50"
+	}
+	return "Unknown"
+}
+
+// FrameSize returns the size of a frame of the system.
+func (s System) FrameSize() int {
+	if s == System625_50 {
+		return FrameSize625
+	}
+	return FrameSize525
+}
+
+// FrameRate returns the nominal frame rate of the system.
+func (s System) FrameRate() float64 {
+	if s == System625_50 {
+		return 25
+	}
+	return 30000.0 / 1001
+}
+
+// Section types of the DIF block ID.
+const (
+	sectionHeader  = 0
+	sectionSubcode = 1
+	sectionVAUX    = 2
+)
+
+// Pack headers, IEC 61834-4.
+const (
+	packTimecode      = 0x13
+	packRecordingDate = 0x62
+	packRecordingTime = 0x63
+	packNoInfo        = 0xff
+)
+
+// ErrNotDV is returned for data that does not start with a DIF header block.
+var ErrNotDV = errors.New("not a DV frame")
+
+// Timecode is a SMPTE style time code.
+type Timecode struct {
+	Hours, Minutes, Seconds, Frames int
+	// DropFrame is set for 525/60 drop frame time codes.
+	DropFrame bool
+}
+
+func (tc Timecode) String() string {
+	sep := ":"
+	if tc.DropFrame {
+		sep = ";"
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%02d", tc.Hours, tc.Minutes, tc.Seconds, sep, tc.Frames)
+}
+
+// Header is the information found in the header, subcode and VAUX sections of a DV frame.
+type Header struct {
+	System System
+
+	// Timecode is the time code of the frame, set if HasTimecode.
+	Timecode    Timecode
+	HasTimecode bool
+
+	// RecordingTime is the date and time the frame was recorded in the camera's local time, or zero
+	// if the frame does not carry one, as is the case for analog to DV converters.
+	RecordingTime time.Time
+}
+
+// bcd decodes a packed BCD byte, with mask limiting the tens digit.
+func bcd(b, mask byte) int {
+	return int(b>>4&mask)*10 + int(b&0x0f)
+}
+
+// packs calls fn with each 5 byte pack in the subcode and VAUX sections of the frame.
+func packs(frame []byte, fn func(pack []byte)) {
+	for seq := 0; seq+SequenceSize <= len(frame); seq += SequenceSize {
+		// each sequence starts with a header block, two subcode blocks and three VAUX blocks.
+		for blk := 1; blk < 6; blk++ {
+			b := frame[seq+blk*BlockSize : seq+(blk+1)*BlockSize]
+			switch b[0] >> 5 {
+			case sectionSubcode:
+				// six SSYBs of a 2 byte ID, a reserved byte and a pack.
+				for i := range 6 {
+					fn(b[3+i*8+3 : 3+i*8+8])
+				}
+			case sectionVAUX:
+				for i := range 15 {
+					fn(b[3+i*5 : 3+i*5+5])
+				}
+			}
+		}
+	}
+}
+
+// ParseHeader parses the metadata of a DV frame.
+func ParseHeader(frame []byte) (*Header, error) {
+	if len(frame) < SequenceSize {
+		return nil, io.ErrShortBuffer
+	}
+	if frame[0]>>5 != sectionHeader {
+		return nil, ErrNotDV
+	}
+	h := &Header{}
+	// IEC 61834-2: the DSF flag in the header block selects the 625/50 system.
+	if frame[3]&0x80 != 0 {
+		h.System = System625_50
+	}
+
+	var date, clock []byte
+	packs(frame, func(pack []byte) {
+		switch pack[0] {
+		case packTimecode:
+			if !h.HasTimecode && pack[1] != packNoInfo {
+				h.Timecode = Timecode{
+					Hours:     bcd(pack[4], 0x3),
+					Minutes:   bcd(pack[3], 0x7),
+					Seconds:   bcd(pack[2], 0x7),
+					Frames:    bcd(pack[1], 0x3),
+					DropFrame: pack[1]&0x40 != 0,
+				}
+				h.HasTimecode = true
+			}
+		case packRecordingDate:
+			if date == nil && pack[4] != packNoInfo {
+				date = pack
+			}
+		case packRecordingTime:
+			if clock == nil && pack[4] != packNoInfo {
+				clock = pack
+			}
+		}
+	})
+	if date != nil {
+		year := bcd(date[4], 0xf)
+		if year < 25 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+		month, day := bcd(date[3], 0x1), bcd(date[2], 0x3)
+		var hour, minute, sec int
+		if clock != nil {
+			hour, minute, sec = bcd(clock[4], 0x3), bcd(clock[3], 0x7), bcd(clock[2], 0x7)
+		}
+		if month >= 1 && month <= 12 && day >= 1 && day <= 31 {
+			h.RecordingTime = time.Date(year, time.Month(month), day, hour, minute, sec, 0, time.Local)
+		}
+	}
+	return h, nil
+}
+
+// Writer writes DV frames to a raw .dv file, which is the plain concatenation of DIF frames.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteFrame writes a complete SD DV frame, checking that its size matches its system.
+func (w *Writer) WriteFrame(frame []byte) error {
+	h, err := ParseHeader(frame)
+	if err != nil {
+		return err
+	}
+	if len(frame) != h.System.FrameSize() {
+		return fmt.Errorf("%s frame has %d bytes, want %d", h.System, len(frame), h.System.FrameSize())
+	}
+	_, err = w.w.Write(frame)
+	return err
+}
@@ -0,0 +1,88 @@
+package dv
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// testFrame builds a DV frame with the DIF block IDs, time code and recording date set.
+func testFrame(system System) []byte {
+	frame := bytes.Repeat([]byte{0xff}, system.FrameSize())
+	for seq := 0; seq < len(frame); seq += SequenceSize {
+		frame[seq] = sectionHeader<<5 | 0x1f
+		frame[seq+3] = 0x3f
+		if system == System625_50 {
+			frame[seq+3] |= 0x80
+		}
+		frame[seq+BlockSize] = sectionSubcode<<5 | 0x1f
+		frame[seq+2*BlockSize] = sectionSubcode<<5 | 0x1f
+		for blk := 3; blk < 6; blk++ {
+			frame[seq+blk*BlockSize] = sectionVAUX<<5 | 0x1f
+		}
+	}
+	// 01:23:45;12, drop frame, in the fourth SSYB of the second sequence.
+	ssyb := SequenceSize + BlockSize + 3 + 3*8
+	copy(frame[ssyb+3:], []byte{packTimecode, 0x40 | 0x12, 0x45, 0x23, 0x01})
+	// recorded 2004-07-15 18:30:09.
+	vaux := 3*BlockSize + 3
+	copy(frame[vaux+5*5:], []byte{packRecordingDate, 0xff, 0x15, 0x07, 0x04})
+	copy(frame[vaux+6*5:], []byte{packRecordingTime, 0xff, 0x09, 0x30, 0x18})
+	return frame
+}
+
+func TestParseHeader(t *testing.T) {
+	for _, system := range []System{System525_60, System625_50} {
+		t.Run(system.String(), func(t *testing.T) {
+			h, err := ParseHeader(testFrame(system))
+			if err != nil {
+				t.Fatalf("ParseHeader failed: %v", err)
+			}
+			if h.System != system {
+				t.Errorf("System = %v, want %v", h.System, system)
+			}
+			if !h.HasTimecode || h.Timecode.String() != "01:23:45;12" {
+				t.Errorf("Timecode = %v (%v), want 01:23:45;12", h.Timecode, h.HasTimecode)
+			}
+			want := time.Date(2004, time.July, 15, 18, 30, 9, 0, time.Local)
+			if !h.RecordingTime.Equal(want) {
+				t.Errorf("RecordingTime = %v, want %v", h.RecordingTime, want)
+			}
+		})
+	}
+}
+
+func TestParseHeader_NoMetadata(t *testing.T) {
+	frame := testFrame(System525_60)
+	for seq := 0; seq < len(frame); seq += SequenceSize {
+		for i := seq + BlockSize; i < seq+6*BlockSize; i += BlockSize {
+			copy(frame[i+3:i+BlockSize], bytes.Repeat([]byte{0xff}, BlockSize-3))
+		}
+	}
+	h, err := ParseHeader(frame)
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	if h.HasTimecode || !h.RecordingTime.IsZero() {
+		t.Errorf("header = %+v, want no time code or recording time", h)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	frame := testFrame(System625_50)
+	if err := w.WriteFrame(frame); err != nil {
+		t.Fatalf("WriteFrame failed: %v", err)
+	}
+	if err := w.WriteFrame(frame[:FrameSize525]); err == nil {
+		t.Error("WriteFrame accepted a truncated frame")
+	}
+	if !bytes.Equal(buf.Bytes(), frame) {
+		t.Error("written data mismatch")
+	}
+	if err := w.WriteFrame(make([]byte, FrameSize525)); err != nil {
+		// a zero frame has a header block ID, so it is accepted as an NTSC frame.
+		t.Errorf("WriteFrame failed: %v", err)
+	}
+}
@@ -9,4 +9,32 @@ var (
 	CompressionFormatNV12 = CompressionFormat(uuid.MustParse("3231564E-0000-0010-8000-00AA00389B71"))
 	CompressionFormatM420 = CompressionFormat(uuid.MustParse("3032344D-0000-0010-8000-00AA00389B71"))
 	CompressionFormatI420 = CompressionFormat(uuid.MustParse("30323449-0000-0010-8000-00AA00389B71"))
+	CompressionFormatUYVY = CompressionFormat(uuid.MustParse("59565955-0000-0010-8000-00AA00389B71"))
+	CompressionFormatYVYU = CompressionFormat(uuid.MustParse("55595659-0000-0010-8000-00AA00389B71"))
+	CompressionFormatNV21 = CompressionFormat(uuid.MustParse("3132564E-0000-0010-8000-00AA00389B71"))
+	CompressionFormatYV12 = CompressionFormat(uuid.MustParse("32315659-0000-0010-8000-00AA00389B71"))
+	CompressionFormatY800 = CompressionFormat(uuid.MustParse("30303859-0000-0010-8000-00AA00389B71"))
+	CompressionFormatY8   = CompressionFormat(uuid.MustParse("20203859-0000-0010-8000-00AA00389B71"))
+	CompressionFormatY16  = CompressionFormat(uuid.MustParse("20363159-0000-0010-8000-00AA00389B71"))
+	CompressionFormatRGBP = CompressionFormat(uuid.MustParse("50424752-0000-0010-8000-00AA00389B71"))
+	CompressionFormatP010 = CompressionFormat(uuid.MustParse("30313050-0000-0010-8000-00AA00389B71"))
+	CompressionFormatY10  = CompressionFormat(uuid.MustParse("20303159-0000-0010-8000-00AA00389B71"))
+	CompressionFormatY12  = CompressionFormat(uuid.MustParse("20323159-0000-0010-8000-00AA00389B71"))
+	CompressionFormatY10P = CompressionFormat(uuid.MustParse("50303159-0000-0010-8000-00AA00389B71"))
+	CompressionFormatY12P = CompressionFormat(uuid.MustParse("50323159-0000-0010-8000-00AA00389B71"))
+	CompressionFormatBA81 = CompressionFormat(uuid.MustParse("31384142-0000-0010-8000-00AA00389B71"))
+	CompressionFormatGBRG = CompressionFormat(uuid.MustParse("47524247-0000-0010-8000-00AA00389B71"))
+	CompressionFormatGRBG = CompressionFormat(uuid.MustParse("47425247-0000-0010-8000-00AA00389B71"))
+	CompressionFormatRGGB = CompressionFormat(uuid.MustParse("42474752-0000-0010-8000-00AA00389B71"))
+	// packed as MIPI CSI-2 RAW10 and RAW12, named after their V4L2 formats.
+	CompressionFormatSBGGR10P = CompressionFormat(uuid.MustParse("41414270-0000-0010-8000-00AA00389B71"))
+	CompressionFormatSGBRG10P = CompressionFormat(uuid.MustParse("41414770-0000-0010-8000-00AA00389B71"))
+	CompressionFormatSGRBG10P = CompressionFormat(uuid.MustParse("41416770-0000-0010-8000-00AA00389B71"))
+	CompressionFormatSRGGB10P = CompressionFormat(uuid.MustParse("41415270-0000-0010-8000-00AA00389B71"))
+	CompressionFormatSBGGR12P = CompressionFormat(uuid.MustParse("43434270-0000-0010-8000-00AA00389B71"))
+	CompressionFormatSGBRG12P = CompressionFormat(uuid.MustParse("43434770-0000-0010-8000-00AA00389B71"))
+	CompressionFormatSGRBG12P = CompressionFormat(uuid.MustParse("43436770-0000-0010-8000-00AA00389B71"))
+	CompressionFormatSRGGB12P = CompressionFormat(uuid.MustParse("43435270-0000-0010-8000-00AA00389B71"))
+	// CompressionFormatRGB24 is MEDIASUBTYPE_RGB24, 24-bit pixels in B, G, R order.
+	CompressionFormatRGB24 = CompressionFormat(uuid.MustParse("E436EB7D-524F-11CE-9F53-0020AF0BA770"))
 )
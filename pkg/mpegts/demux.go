@@ -0,0 +1,348 @@
+package mpegts
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// pesStream is the reassembly state of an elementary stream.
+type pesStream struct {
+	Stream
+	pcrPID uint16
+
+	cc    uint8
+	hasCC bool
+
+	buf           []byte
+	started       bool
+	discontinuity bool
+	pcr           int64
+	hasPCR        bool
+}
+
+// Demuxer splits a transport stream into PES packets of its elementary streams. Bytes are pushed
+// with Write, in chunks of any size, and completed PES packets are taken with Next.
+//
+// The demuxer synchronizes on the sync byte of three consecutive packets and resynchronizes the same
+// way whenever a packet does not start with one, so it can start reading in the middle of a stream.
+type Demuxer struct {
+	stride int
+
+	buf        []byte
+	off        int
+	skip       int // bytes left to skip until the next packet
+	synced     bool
+	syncLosses uint64
+
+	sections map[uint16][]byte // partial PSI sections by PID
+	pmtPIDs  map[uint16]uint16 // program number by PMT PID
+	streams  map[uint16]*pesStream
+	pcr      map[uint16]int64 // last PCR by PCR PID
+
+	pkt Packet
+	out []*PES
+}
+
+// NewDemuxer creates a demuxer for a stream of 188 byte transport packets.
+func NewDemuxer() *Demuxer {
+	return NewDemuxerWithStride(PacketSize)
+}
+
+// NewDemuxerWithStride creates a demuxer for transport packets that start every stride bytes, such as
+// 192 byte timestamped packets or 204 byte packets with Reed-Solomon parity. For UVC devices, the
+// stride is the bStrideLength of the MPEG-2 TS format descriptor.
+func NewDemuxerWithStride(stride int) *Demuxer {
+	return &Demuxer{
+		stride:   max(stride, PacketSize),
+		sections: make(map[uint16][]byte),
+		pmtPIDs:  make(map[uint16]uint16),
+		streams:  make(map[uint16]*pesStream),
+		pcr:      make(map[uint16]int64),
+	}
+}
+
+// Write feeds transport stream bytes to the demuxer. It never returns an error, malformed packets are
+// skipped.
+func (d *Demuxer) Write(p []byte) (int, error) {
+	d.buf = append(d.buf, p...)
+	for {
+		buf := d.buf[d.off:]
+		if d.skip > 0 {
+			n := min(d.skip, len(buf))
+			d.off += n
+			d.skip -= n
+			if d.skip > 0 {
+				break
+			}
+			continue
+		}
+		if !d.synced {
+			o := d.findSync(buf)
+			if o < 0 {
+				// keep enough bytes to find the sync bytes once more data arrives.
+				d.off += max(len(buf)-2*d.stride, 0)
+				break
+			}
+			d.off += o
+			d.synced = true
+			continue
+		}
+		if len(buf) < PacketSize {
+			break
+		}
+		if buf[0] != SyncByte {
+			d.synced = false
+			d.syncLosses++
+			d.off++
+			continue
+		}
+		if err := d.pkt.UnmarshalBinary(buf[:PacketSize]); err == nil {
+			d.handlePacket(&d.pkt)
+		}
+		d.off += PacketSize
+		d.skip = d.stride - PacketSize
+	}
+	// move the incomplete packet to the front so the buffer does not grow.
+	d.buf = append(d.buf[:0], d.buf[d.off:]...)
+	d.off = 0
+	return len(p), nil
+}
+
+// findSync returns the offset of the first of three sync bytes spaced a stride apart, or -1.
+func (d *Demuxer) findSync(buf []byte) int {
+	for o := 0; o+2*d.stride < len(buf); o++ {
+		if buf[o] == SyncByte && buf[o+d.stride] == SyncByte && buf[o+2*d.stride] == SyncByte {
+			return o
+		}
+	}
+	return -1
+}
+
+// SyncLosses returns the number of times the demuxer lost packet synchronization.
+func (d *Demuxer) SyncLosses() uint64 {
+	return d.syncLosses
+}
+
+// Next returns the next completed PES packet. The packet's data is owned by the caller.
+func (d *Demuxer) Next() (*PES, bool) {
+	if len(d.out) == 0 {
+		return nil, false
+	}
+	p := d.out[0]
+	d.out[0] = nil
+	d.out = d.out[1:]
+	return p, true
+}
+
+// Flush completes the PES packets that are still being received, for instance at the end of a
+// stream, where no following packet marks their end.
+func (d *Demuxer) Flush() {
+	pids := make([]uint16, 0, len(d.streams))
+	for pid := range d.streams {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+	for _, pid := range pids {
+		d.emit(d.streams[pid])
+	}
+}
+
+// Streams returns the elementary streams announced by the program map tables received so far,
+// ordered by PID.
+func (d *Demuxer) Streams() []Stream {
+	streams := make([]Stream, 0, len(d.streams))
+	for _, s := range d.streams {
+		streams = append(streams, s.Stream)
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].PID < streams[j].PID })
+	return streams
+}
+
+func (d *Demuxer) handlePacket(p *Packet) {
+	if p.HasPCR {
+		d.pcr[p.PID] = p.PCR
+	}
+	if p.PID == PIDNull {
+		return
+	}
+	if p.PID == PIDPAT {
+		d.handlePSI(p)
+		return
+	}
+	if _, ok := d.pmtPIDs[p.PID]; ok {
+		d.handlePSI(p)
+		return
+	}
+	s, ok := d.streams[p.PID]
+	if !ok {
+		return
+	}
+
+	// ISO/IEC 13818-1, section 2.4.3.3: the continuity counter increments with every packet with a
+	// payload, and a packet may be sent twice.
+	if p.HasPayload() {
+		if s.hasCC && !p.Discontinuity {
+			if p.ContinuityCounter == s.cc {
+				return
+			}
+			if p.ContinuityCounter != (s.cc+1)&0x0f {
+				s.discontinuity = true
+			}
+		}
+		s.cc, s.hasCC = p.ContinuityCounter, true
+	}
+	if p.TransportError || p.Scrambling != 0 {
+		s.discontinuity = true
+		return
+	}
+
+	if p.PayloadUnitStart {
+		d.emit(s)
+		s.started = true
+		s.buf = make([]byte, 0, max(2*len(s.buf), 4096))
+		s.pcr, s.hasPCR = d.pcr[s.pcrPID]
+	}
+	if !s.started {
+		// wait for the start of the next PES packet.
+		return
+	}
+	s.buf = append(s.buf, p.Payload...)
+	// PES packets with a length can be completed without waiting for the next one to start.
+	if len(s.buf) >= 6 {
+		if length := int(binary.BigEndian.Uint16(s.buf[4:6])); length > 0 && len(s.buf) >= 6+length {
+			d.emit(s)
+		}
+	}
+}
+
+// emit parses the PES packet being received on s and queues it.
+func (d *Demuxer) emit(s *pesStream) {
+	if !s.started {
+		return
+	}
+	s.started = false
+	pes := &PES{Stream: s.Stream, PCR: s.pcr, HasPCR: s.hasPCR, Discontinuity: s.discontinuity}
+	s.discontinuity = false
+	if err := pes.UnmarshalBinary(s.buf); err != nil {
+		return
+	}
+	d.out = append(d.out, pes)
+}
+
+// handlePSI reassembles the PSI sections carried by p. ISO/IEC 13818-1, section 2.4.4.2: a section
+// starts after the pointer field of a packet with the payload unit start indicator.
+func (d *Demuxer) handlePSI(p *Packet) {
+	payload := p.Payload
+	if p.PayloadUnitStart {
+		if len(payload) == 0 {
+			return
+		}
+		pointer := int(payload[0])
+		payload = payload[1:]
+		if pointer > len(payload) {
+			delete(d.sections, p.PID)
+			return
+		}
+		if buf, ok := d.sections[p.PID]; ok {
+			// the first bytes finish the previous section.
+			d.sections[p.PID] = append(buf, payload[:pointer]...)
+			d.parseSections(p.PID)
+		}
+		d.sections[p.PID] = append([]byte(nil), payload[pointer:]...)
+	} else if buf, ok := d.sections[p.PID]; ok {
+		d.sections[p.PID] = append(buf, payload...)
+	} else {
+		return
+	}
+	d.parseSections(p.PID)
+}
+
+// parseSections handles the complete sections buffered for pid.
+func (d *Demuxer) parseSections(pid uint16) {
+	buf := d.sections[pid]
+	// 0xff marks stuffing after the last section.
+	for len(buf) >= 3 && buf[0] != 0xff {
+		length := 3 + int(binary.BigEndian.Uint16(buf[1:3])&0x0fff)
+		if len(buf) < length {
+			d.sections[pid] = buf
+			return
+		}
+		var s section
+		if err := s.UnmarshalBinary(buf[:length]); err == nil && s.CurrentNext {
+			d.handleSection(pid, &s)
+		}
+		buf = buf[length:]
+	}
+	if len(buf) > 0 && buf[0] != 0xff {
+		d.sections[pid] = buf
+		return
+	}
+	delete(d.sections, pid)
+}
+
+func (d *Demuxer) handleSection(pid uint16, s *section) {
+	switch {
+	case pid == PIDPAT && s.TableID == TableIDPAT:
+		programs, err := parsePAT(s)
+		if err != nil {
+			return
+		}
+		for _, prog := range programs {
+			d.pmtPIDs[prog.PMTPID] = prog.Number
+		}
+	case s.TableID == TableIDPMT:
+		m, err := parsePMT(s)
+		if err != nil {
+			return
+		}
+		for _, es := range m.Streams {
+			if old, ok := d.streams[es.PID]; ok && old.Stream == es && old.pcrPID == m.PCRPID {
+				continue
+			}
+			d.streams[es.PID] = &pesStream{Stream: es, pcrPID: m.PCRPID}
+		}
+	}
+}
+
+// Reader reads PES packets from a transport stream.
+type Reader struct {
+	r   io.Reader
+	d   *Demuxer
+	buf []byte
+	err error
+}
+
+// NewReader creates a reader for a stream of 188 byte transport packets.
+func NewReader(r io.Reader) *Reader {
+	return NewReaderWithStride(r, PacketSize)
+}
+
+// NewReaderWithStride creates a reader for transport packets that start every stride bytes.
+func NewReaderWithStride(r io.Reader, stride int) *Reader {
+	return &Reader{r: r, d: NewDemuxerWithStride(stride), buf: make([]byte, 64*1024)}
+}
+
+// Demuxer returns the underlying demuxer.
+func (r *Reader) Demuxer() *Demuxer {
+	return r.d
+}
+
+// ReadPES returns the next PES packet. Once the underlying reader fails, the remaining packets are
+// returned before its error.
+func (r *Reader) ReadPES() (*PES, error) {
+	for {
+		if p, ok := r.d.Next(); ok {
+			return p, nil
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		n, err := r.r.Read(r.buf)
+		r.d.Write(r.buf[:n])
+		if err != nil {
+			r.err = err
+			r.d.Flush()
+		}
+	}
+}
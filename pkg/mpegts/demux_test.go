@@ -0,0 +1,228 @@
+package mpegts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// muxer builds transport streams for tests.
+type muxer struct {
+	cc  map[uint16]uint8
+	out []byte
+}
+
+func newMuxer() *muxer {
+	return &muxer{cc: make(map[uint16]uint8)}
+}
+
+// packet writes a transport packet, padding the payload with adaptation field stuffing.
+func (m *muxer) packet(pid uint16, pusi bool, pcr int64, payload []byte) int {
+	pkt := make([]byte, PacketSize)
+	pkt[0] = SyncByte
+	binary.BigEndian.PutUint16(pkt[1:3], pid)
+	if pusi {
+		pkt[1] |= 0x40
+	}
+	var af []byte
+	if pcr >= 0 {
+		af = make([]byte, 7)
+		af[0] = 0x10
+		base, ext := pcr/300, pcr%300
+		binary.BigEndian.PutUint32(af[1:5], uint32(base>>1))
+		binary.BigEndian.PutUint16(af[5:7], uint16(base&1)<<15|0x7e00|uint16(ext))
+	}
+	room := PacketSize - 4
+	if af != nil || len(payload) < room {
+		room -= 1 + len(af)
+		if len(payload) < room {
+			af = append(af, bytes.Repeat([]byte{0xff}, room-len(payload))...)
+			if len(af) > 0 && pcr < 0 {
+				af[0] = 0
+			}
+			room = len(payload)
+		}
+	}
+	n := min(len(payload), room)
+	control := uint8(0b01)
+	off := 4
+	if af != nil || n < PacketSize-4 {
+		control |= 0b10
+		pkt[4] = uint8(len(af))
+		copy(pkt[5:], af)
+		off = 5 + len(af)
+	}
+	pkt[3] = control<<4 | m.cc[pid]
+	m.cc[pid] = (m.cc[pid] + 1) & 0x0f
+	copy(pkt[off:], payload[:n])
+	m.out = append(m.out, pkt...)
+	return n
+}
+
+// section writes a PSI section with its CRC on pid.
+func (m *muxer) section(pid uint16, tableID uint8, ext uint16, body []byte) {
+	sec := []byte{tableID, 0, 0, byte(ext >> 8), byte(ext), 0xc1, 0, 0}
+	sec = append(sec, body...)
+	binary.BigEndian.PutUint16(sec[1:3], 0xb000|uint16(len(sec)+4-3))
+	sec = binary.BigEndian.AppendUint32(sec, crc32(sec))
+	m.packet(pid, true, -1, append([]byte{0}, sec...))
+}
+
+func (m *muxer) pat(pmtPID uint16) {
+	m.section(PIDPAT, TableIDPAT, 1, []byte{0, 1, 0xe0 | byte(pmtPID>>8), byte(pmtPID)})
+}
+
+func (m *muxer) pmt(pmtPID, pcrPID, esPID uint16, st StreamType) {
+	m.section(pmtPID, TableIDPMT, 1, []byte{
+		0xe0 | byte(pcrPID>>8), byte(pcrPID), 0xf0, 0,
+		byte(st), 0xe0 | byte(esPID>>8), byte(esPID), 0xf0, 0,
+	})
+}
+
+func encodeTimestamp(prefix byte, ts int64) []byte {
+	return []byte{
+		prefix<<4 | byte(ts>>29)&0x0e | 1,
+		byte(ts >> 22),
+		byte(ts>>14) | 1,
+		byte(ts >> 7),
+		byte(ts<<1) | 1,
+	}
+}
+
+// pes writes a video PES packet with PTS and DTS, attaching pcr to the first transport packet.
+func (m *muxer) pes(pid uint16, pts, dts, pcr int64, data []byte) {
+	hdr := []byte{0, 0, 1, 0xe0, 0, 0, 0x80, 0xc0, 10}
+	hdr = append(hdr, encodeTimestamp(3, pts)...)
+	hdr = append(hdr, encodeTimestamp(1, dts)...)
+	buf := append(hdr, data...)
+	first := true
+	for len(buf) > 0 {
+		p := int64(-1)
+		if first {
+			p = pcr
+		}
+		n := m.packet(pid, first, p, buf)
+		buf = buf[n:]
+		first = false
+	}
+}
+
+func testPayload(n int, seed byte) []byte {
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = byte(i*3) + seed
+	}
+	return buf
+}
+
+func TestDemuxer(t *testing.T) {
+	m := newMuxer()
+	m.out = append(m.out, 0x47, 0x12, 0x00, 0x47) // garbage before the first packet
+	m.pat(0x100)
+	m.pmt(0x100, 0x101, 0x101, StreamTypeH264)
+	frames := [][]byte{testPayload(1000, 1), testPayload(5000, 2), testPayload(10, 3)}
+	for i, f := range frames {
+		ts := int64(i)*3000 + 1<<32 // exercise the 33rd bit
+		m.pes(0x101, ts+3000, ts, (ts-1000)*300+7, f)
+	}
+
+	r := NewReader(bytes.NewReader(m.out))
+	for i, want := range frames {
+		p, err := r.ReadPES()
+		if err != nil {
+			t.Fatalf("ReadPES %d failed: %v", i, err)
+		}
+		ts := int64(i)*3000 + 1<<32
+		if p.PID != 0x101 || p.Type != StreamTypeH264 || p.StreamID != 0xe0 {
+			t.Errorf("PES %d stream = %+v, id %#x", i, p.Stream, p.StreamID)
+		}
+		if !p.HasPTS || p.PTS != ts+3000 || !p.HasDTS || p.DTS != ts {
+			t.Errorf("PES %d PTS/DTS = %d/%d, want %d/%d", i, p.PTS, p.DTS, ts+3000, ts)
+		}
+		if !p.HasPCR || p.PCR != (ts-1000)*300+7 {
+			t.Errorf("PES %d PCR = %d, want %d", i, p.PCR, (ts-1000)*300+7)
+		}
+		if p.Discontinuity {
+			t.Errorf("PES %d has a discontinuity", i)
+		}
+		if !bytes.Equal(p.Data, want) {
+			t.Errorf("PES %d data mismatch", i)
+		}
+	}
+	if _, err := r.ReadPES(); err != io.EOF {
+		t.Errorf("ReadPES at end = %v, want io.EOF", err)
+	}
+	if streams := r.Demuxer().Streams(); len(streams) != 1 || streams[0] != (Stream{Program: 1, PID: 0x101, Type: StreamTypeH264}) {
+		t.Errorf("Streams = %+v", streams)
+	}
+}
+
+func TestDemuxer_Resync(t *testing.T) {
+	m := newMuxer()
+	m.pat(0x100)
+	m.pmt(0x100, 0x101, 0x101, StreamTypeMPEG2Video)
+	m.pes(0x101, 0, 0, 0, testPayload(2000, 1))
+	m.pes(0x101, 3000, 3000, 0, testPayload(2000, 2))
+	// drop a byte in the middle of the second PES packet, then continue with a third.
+	cut := len(m.out) - 5*PacketSize
+	stream := append(append([]byte(nil), m.out[:cut]...), m.out[cut+1:]...)
+	m.out = nil
+	m.pes(0x101, 6000, 6000, 0, testPayload(2000, 3))
+	m.pes(0x101, 9000, 9000, 0, testPayload(2000, 4))
+	stream = append(stream, m.out...)
+
+	d := NewDemuxer()
+	// feed the stream in odd sized chunks.
+	for off := 0; off < len(stream); off += 1000 {
+		d.Write(stream[off:min(off+1000, len(stream))])
+	}
+	d.Flush()
+
+	var got []*PES
+	for p, ok := d.Next(); ok; p, ok = d.Next() {
+		got = append(got, p)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d PES packets, want 4", len(got))
+	}
+	if d.SyncLosses() != 1 {
+		t.Errorf("SyncLosses = %d, want 1", d.SyncLosses())
+	}
+	if got[0].Discontinuity || !got[1].Discontinuity || got[2].Discontinuity {
+		t.Errorf("discontinuities = %v %v %v, want only the second PES", got[0].Discontinuity, got[1].Discontinuity, got[2].Discontinuity)
+	}
+	if !bytes.Equal(got[3].Data, testPayload(2000, 4)) || got[3].PTS != 9000 {
+		t.Error("PES after resync mismatch")
+	}
+}
+
+func TestDemuxer_Stride(t *testing.T) {
+	m := newMuxer()
+	m.pat(0x100)
+	m.pmt(0x100, 0x101, 0x101, StreamTypeH264)
+	m.pes(0x101, 0, 0, 0, testPayload(3000, 1))
+
+	// 192 byte packets with a 4 byte timestamp prefix, which contains sync bytes now and then.
+	var stream []byte
+	for off := 0; off < len(m.out); off += PacketSize {
+		stream = append(stream, 0, byte(off/PacketSize), 0x47*byte(off/PacketSize%2), 0)
+		stream = append(stream, m.out[off:off+PacketSize]...)
+	}
+	r := NewReaderWithStride(bytes.NewReader(stream), 192)
+	p, err := r.ReadPES()
+	if err != nil {
+		t.Fatalf("ReadPES failed: %v", err)
+	}
+	if !bytes.Equal(p.Data, testPayload(3000, 1)) {
+		t.Error("PES data mismatch")
+	}
+}
+
+func TestCRC32(t *testing.T) {
+	// a PAT section from a real stream, the CRC over the whole section including its CRC is zero.
+	pat := []byte{0x00, 0xb0, 0x0d, 0x00, 0x01, 0xc1, 0x00, 0x00, 0x00, 0x01, 0xf0, 0x00, 0x2a, 0xb1, 0x04, 0xb2}
+	if crc := crc32(pat); crc != 0 {
+		t.Errorf("crc32 = %#08x, want 0", crc)
+	}
+}
@@ -0,0 +1,147 @@
+// Package mpegts demultiplexes MPEG-2 transport streams (ISO/IEC 13818-1), as sent by UVC devices
+// that support the MPEG-2 TS format, into elementary streams.
+package mpegts
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// PacketSize is the size of a transport stream packet.
+	PacketSize = 188
+	// SyncByte starts every transport stream packet.
+	SyncByte = 0x47
+
+	// PIDPAT is the PID of the program association table.
+	PIDPAT = 0x0000
+	// PIDNull is the PID of null packets used for stuffing.
+	PIDNull = 0x1fff
+)
+
+// ClockRate is the frequency of the system clock that PCR values count in.
+const ClockRate = 27_000_000
+
+// TimestampRate is the frequency that PTS and DTS values count in.
+const TimestampRate = 90_000
+
+// ErrSync is returned when a packet does not start with the sync byte.
+var ErrSync = errors.New("missing transport stream sync byte")
+
+// StreamType is the stream_type of an elementary stream in the program map table. ISO/IEC 13818-1,
+// table 2-34.
+type StreamType uint8
+
+const (
+	StreamTypeMPEG1Video StreamType = 0x01
+	StreamTypeMPEG2Video StreamType = 0x02
+	StreamTypeMPEG1Audio StreamType = 0x03
+	StreamTypeMPEG2Audio StreamType = 0x04
+	StreamTypePrivate    StreamType = 0x06
+	StreamTypeAACADTS    StreamType = 0x0f
+	StreamTypeMPEG4Video StreamType = 0x10
+	StreamTypeAACLATM    StreamType = 0x11
+	StreamTypeH264       StreamType = 0x1b
+	StreamTypeH265       StreamType = 0x24
+	StreamTypeAC3        StreamType = 0x81
+)
+
+// IsVideo reports whether the stream type is a video codec.
+func (t StreamType) IsVideo() bool {
+	switch t {
+	case StreamTypeMPEG1Video, StreamTypeMPEG2Video, StreamTypeMPEG4Video, StreamTypeH264, StreamTypeH265:
+		return true
+	}
+	return false
+}
+
+func (t StreamType) String() string {
+	switch t {
+	case StreamTypeMPEG1Video:
+		return "MPEG-1 Video"
+	case StreamTypeMPEG2Video:
+		return "MPEG-2 Video"
+	case StreamTypeMPEG1Audio:
+		return "MPEG-1 Audio"
+	case StreamTypeMPEG2Audio:
+		return "MPEG-2 Audio"
+	case StreamTypePrivate:
+		return "Private PES"
+	case StreamTypeAACADTS:
+		return "AAC (ADTS)"
+	case StreamTypeMPEG4Video:
+		return "MPEG-4 Video"
+	case StreamTypeAACLATM:
+		return "AAC (LATM)"
+	case StreamTypeH264:
+		return "H.264"
+	case StreamTypeH265:
+		return "H.265"
+	case StreamTypeAC3:
+		return "AC-3"
+	}
+	return "Unknown"
+}
+
+// Packet is a parsed transport stream packet. ISO/IEC 13818-1, section 2.4.3.2.
+type Packet struct {
+	TransportError    bool
+	PayloadUnitStart  bool
+	PID               uint16
+	Scrambling        uint8
+	ContinuityCounter uint8
+
+	// Discontinuity is the discontinuity_indicator of the adaptation field.
+	Discontinuity bool
+	// PCR is the program clock reference in 27 MHz ticks, set if HasPCR.
+	PCR    int64
+	HasPCR bool
+
+	Payload []byte
+}
+
+// HasPayload reports whether the packet carries payload bytes.
+func (p *Packet) HasPayload() bool {
+	return len(p.Payload) > 0
+}
+
+func (p *Packet) UnmarshalBinary(buf []byte) error {
+	if len(buf) < PacketSize {
+		return io.ErrShortBuffer
+	}
+	if buf[0] != SyncByte {
+		return ErrSync
+	}
+	p.TransportError = buf[1]&0x80 != 0
+	p.PayloadUnitStart = buf[1]&0x40 != 0
+	p.PID = binary.BigEndian.Uint16(buf[1:3]) & 0x1fff
+	p.Scrambling = buf[3] >> 6
+	adaptationFieldControl := (buf[3] >> 4) & 0b11
+	p.ContinuityCounter = buf[3] & 0x0f
+	p.Discontinuity, p.PCR, p.HasPCR = false, 0, false
+	p.Payload = nil
+
+	offset := 4
+	if adaptationFieldControl&0b10 != 0 {
+		length := int(buf[4])
+		offset += 1 + length
+		if offset > PacketSize {
+			return errors.New("adaptation field exceeds packet")
+		}
+		if length > 0 {
+			flags := buf[5]
+			p.Discontinuity = flags&0x80 != 0
+			if flags&0x10 != 0 && length >= 7 {
+				// ISO/IEC 13818-1, section 2.4.3.5: 33 bit base at 90 kHz, 9 bit extension at 27 MHz.
+				base := int64(binary.BigEndian.Uint32(buf[6:10]))<<1 | int64(buf[10]>>7)
+				ext := int64(binary.BigEndian.Uint16(buf[10:12]) & 0x1ff)
+				p.PCR, p.HasPCR = base*300+ext, true
+			}
+		}
+	}
+	if adaptationFieldControl&0b01 != 0 {
+		p.Payload = buf[offset:PacketSize]
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package mpegts
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// PES is a packetized elementary stream packet, typically holding one access unit of video. ISO/IEC
+// 13818-1, section 2.4.3.6.
+type PES struct {
+	Stream
+	StreamID uint8
+
+	// PTS and DTS are the presentation and decoding time stamps in 90 kHz ticks.
+	PTS, DTS       int64
+	HasPTS, HasDTS bool
+	// PCR is the last program clock reference of the program in 27 MHz ticks received before the PES
+	// packet started.
+	PCR    int64
+	HasPCR bool
+
+	// Discontinuity is set if transport packets of the PES packet were lost or had errors.
+	Discontinuity bool
+
+	// Data is the elementary stream data of the packet.
+	Data []byte
+}
+
+// hasOptionalHeader reports whether a stream id carries the optional PES header. ISO/IEC 13818-1,
+// table 2-21.
+func hasOptionalHeader(streamID uint8) bool {
+	switch streamID {
+	case 0xbc, 0xbe, 0xbf, 0xf0, 0xf1, 0xf2, 0xf8, 0xff:
+		return false
+	}
+	return true
+}
+
+// parseTimestamp decodes a 33 bit PTS or DTS from its 5 byte encoding.
+func parseTimestamp(buf []byte) int64 {
+	return int64(buf[0]>>1&0x07)<<30 | int64(buf[1])<<22 | int64(buf[2]>>1)<<15 | int64(buf[3])<<7 | int64(buf[4]>>1)
+}
+
+// UnmarshalBinary parses the header of a complete PES packet. Data is a view into buf.
+func (p *PES) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 6 {
+		return io.ErrShortBuffer
+	}
+	if buf[0] != 0 || buf[1] != 0 || buf[2] != 1 {
+		return errors.New("missing PES start code")
+	}
+	p.StreamID = buf[3]
+	length := int(binary.BigEndian.Uint16(buf[4:6]))
+	if length > 0 {
+		if len(buf) < 6+length {
+			return io.ErrShortBuffer
+		}
+		buf = buf[:6+length]
+	}
+	p.PTS, p.DTS, p.HasPTS, p.HasDTS = 0, 0, false, false
+	if !hasOptionalHeader(p.StreamID) {
+		p.Data = buf[6:]
+		return nil
+	}
+	if len(buf) < 9 {
+		return io.ErrShortBuffer
+	}
+	headerLength := int(buf[8])
+	if len(buf) < 9+headerLength {
+		return io.ErrShortBuffer
+	}
+	if ptsDTSFlags := buf[7] >> 6; ptsDTSFlags&0b10 != 0 && headerLength >= 5 {
+		p.PTS, p.HasPTS = parseTimestamp(buf[9:14]), true
+		if ptsDTSFlags == 0b11 && headerLength >= 10 {
+			p.DTS, p.HasDTS = parseTimestamp(buf[14:19]), true
+		}
+	}
+	p.Data = buf[9+headerLength:]
+	return nil
+}
@@ -0,0 +1,139 @@
+package mpegts
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ISO/IEC 13818-1, table 2-31: table_id values.
+const (
+	TableIDPAT = 0x00
+	TableIDPMT = 0x02
+)
+
+// ErrCRC is returned when a section fails its CRC check.
+var ErrCRC = errors.New("section CRC mismatch")
+
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		crc := uint32(i) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}()
+
+// crc32 computes the CRC of ISO/IEC 13818-1, annex A. It is not the reflected IEEE CRC of hash/crc32.
+func crc32(buf []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, b := range buf {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// section is a complete PSI section. ISO/IEC 13818-1, section 2.4.4.
+type section struct {
+	TableID uint8
+	// TableIDExtension is the transport_stream_id of a PAT and the program_number of a PMT.
+	TableIDExtension uint16
+	Version          uint8
+	CurrentNext      bool
+	Data             []byte // the section body between the header and the CRC
+}
+
+func (s *section) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 3 {
+		return io.ErrShortBuffer
+	}
+	s.TableID = buf[0]
+	if buf[1]&0x80 == 0 {
+		return fmt.Errorf("table %#02x is not a long form section", s.TableID)
+	}
+	length := int(binary.BigEndian.Uint16(buf[1:3]) & 0x0fff)
+	if length < 9 || len(buf) < 3+length {
+		return io.ErrShortBuffer
+	}
+	buf = buf[:3+length]
+	if crc32(buf) != 0 {
+		return ErrCRC
+	}
+	s.TableIDExtension = binary.BigEndian.Uint16(buf[3:5])
+	s.Version = (buf[5] >> 1) & 0x1f
+	s.CurrentNext = buf[5]&0x01 != 0
+	// section_number and last_section_number are ignored, PAT and PMT fit in a single section.
+	s.Data = buf[8 : len(buf)-4]
+	return nil
+}
+
+// Program is an entry of the program association table.
+type Program struct {
+	Number uint16
+	PMTPID uint16
+}
+
+// parsePAT returns the programs of a program association section. ISO/IEC 13818-1, section 2.4.4.3.
+func parsePAT(s *section) ([]Program, error) {
+	if s.TableID != TableIDPAT {
+		return nil, fmt.Errorf("table %#02x is not a PAT", s.TableID)
+	}
+	var programs []Program
+	for buf := s.Data; len(buf) >= 4; buf = buf[4:] {
+		number := binary.BigEndian.Uint16(buf[0:2])
+		if number == 0 {
+			// network information table
+			continue
+		}
+		programs = append(programs, Program{Number: number, PMTPID: binary.BigEndian.Uint16(buf[2:4]) & 0x1fff})
+	}
+	return programs, nil
+}
+
+// Stream is an elementary stream of a program.
+type Stream struct {
+	Program uint16
+	PID     uint16
+	Type    StreamType
+}
+
+// pmt is a parsed program map section. ISO/IEC 13818-1, section 2.4.4.8.
+type pmt struct {
+	PCRPID  uint16
+	Streams []Stream
+}
+
+func parsePMT(s *section) (*pmt, error) {
+	if s.TableID != TableIDPMT {
+		return nil, fmt.Errorf("table %#02x is not a PMT", s.TableID)
+	}
+	buf := s.Data
+	if len(buf) < 4 {
+		return nil, io.ErrShortBuffer
+	}
+	m := &pmt{PCRPID: binary.BigEndian.Uint16(buf[0:2]) & 0x1fff}
+	infoLength := int(binary.BigEndian.Uint16(buf[2:4]) & 0x0fff)
+	if len(buf) < 4+infoLength {
+		return nil, io.ErrShortBuffer
+	}
+	for buf = buf[4+infoLength:]; len(buf) >= 5; {
+		esInfoLength := int(binary.BigEndian.Uint16(buf[3:5]) & 0x0fff)
+		if len(buf) < 5+esInfoLength {
+			return nil, io.ErrShortBuffer
+		}
+		m.Streams = append(m.Streams, Stream{
+			Program: s.TableIDExtension,
+			PID:     binary.BigEndian.Uint16(buf[1:3]) & 0x1fff,
+			Type:    StreamType(buf[0]),
+		})
+		buf = buf[5+esInfoLength:]
+	}
+	return m, nil
+}
@@ -0,0 +1,625 @@
+// Package simulator implements an in-memory USB video and audio class device. A Device is built from
+// the raw descriptors of a real device and serves class-specific control requests, probe and commit
+// negotiation and scripted payloads, so the stack can be run without hardware. It implements
+// transfers.Transport.
+package simulator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/requests"
+)
+
+// UVC spec 1.5, section A.9.8: the control selectors of a video streaming interface.
+const (
+	probeControl  = 0x01
+	commitControl = 0x02
+)
+
+// ControlRequest is a control transfer received by the device.
+type ControlRequest struct {
+	RequestType, Request uint8
+	Value, Index         uint16
+	// Data is the data sent with a SET request, or returned by a GET request.
+	Data []byte
+	// Err is the error returned to the host, usb.ErrPipe if the device stalled the request.
+	Err error
+}
+
+// Selector returns the control selector of the request.
+func (r ControlRequest) Selector() uint8 {
+	return uint8(r.Value >> 8)
+}
+
+type controlKey struct {
+	request  uint8
+	selector uint8
+	index    uint16
+}
+
+// streamingInterface is a video streaming interface of the device.
+type streamingInterface struct {
+	descriptors []descriptors.StreamingInterface
+	endpoint    uint8
+	probe       *descriptors.VideoProbeCommitControl
+	commit      *descriptors.VideoProbeCommitControl
+}
+
+// Device is a simulated USB video or audio class device.
+type Device struct {
+	descriptor usb.DeviceDescriptor
+	config     *usb.ConfigDescriptor
+	rawConfig  []byte
+	clockFreq  uint32
+
+	mu         sync.Mutex
+	closed     bool
+	speed      usb.Speed
+	strings    map[uint8]string
+	claimed    map[uint8]bool
+	altSetting map[uint8]uint8
+	controls   map[controlKey][]byte
+	streaming  map[uint8]*streamingInterface
+	negotiator func(*descriptors.VideoProbeCommitControl) error
+	sources    map[uint8]PayloadSource
+	written    map[uint8][][]byte
+	requests   []ControlRequest
+}
+
+// New creates a device with the given device descriptor and raw configuration descriptor, including
+// its interface, endpoint and class-specific descriptors.
+func New(desc usb.DeviceDescriptor, config []byte) (*Device, error) {
+	cd := &usb.ConfigDescriptor{}
+	if err := cd.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("invalid configuration descriptor: %w", err)
+	}
+	d := &Device{
+		descriptor: desc,
+		config:     cd,
+		rawConfig:  slices.Clone(config),
+		speed:      usb.SpeedHigh,
+		strings:    make(map[uint8]string),
+		claimed:    make(map[uint8]bool),
+		altSetting: make(map[uint8]uint8),
+		controls:   make(map[controlKey][]byte),
+		streaming:  make(map[uint8]*streamingInterface),
+		sources:    make(map[uint8]PayloadSource),
+		written:    make(map[uint8][][]byte),
+	}
+	for _, iface := range cd.Interfaces {
+		if len(iface.AltSettings) == 0 {
+			continue
+		}
+		alt := iface.AltSettings[0]
+		if alt.InterfaceClass != 0x0e {
+			continue
+		}
+		blocks, err := csInterfaceDescriptors(&alt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration descriptor: %w", err)
+		}
+		switch alt.InterfaceSubClass {
+		case 0x01:
+			for _, block := range blocks {
+				if ci, err := descriptors.UnmarshalControlInterface(block); err == nil {
+					if h, ok := ci.(*descriptors.HeaderDescriptor); ok {
+						d.clockFreq = h.ClockFrequency
+					}
+				}
+			}
+		case 0x02:
+			vs := &streamingInterface{}
+			for _, block := range blocks {
+				si, err := descriptors.UnmarshalStreamingInterface(block)
+				if err != nil {
+					continue
+				}
+				if h, ok := si.(*descriptors.InputHeaderDescriptor); ok {
+					vs.endpoint = h.EndpointAddress
+				}
+				vs.descriptors = append(vs.descriptors, si)
+			}
+			d.streaming[alt.InterfaceNumber] = vs
+		}
+	}
+	return d, nil
+}
+
+// csInterfaceDescriptors splits the class-specific descriptors of an interface, skipping the ones
+// that are not CS_INTERFACE.
+func csInterfaceDescriptors(alt *usb.InterfaceAltSetting) ([][]byte, error) {
+	blocks, err := descriptors.SplitDescriptors(alt.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("interface %d: %w", alt.InterfaceNumber, err)
+	}
+	return slices.DeleteFunc(blocks, func(b []byte) bool { return b[1] != 0x24 }), nil
+}
+
+// SetSpeed sets the bus speed reported by the device, usb.SpeedHigh by default.
+func (d *Device) SetSpeed(speed usb.Speed) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.speed = speed
+}
+
+// SetString sets the string descriptor at index.
+func (d *Device) SetString(index uint8, s string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.strings[index] = s
+}
+
+// SetControl sets the value the device returns for a GET request, such as GET_CUR or GET_MAX, of the
+// control selector on the entity and interface of index, laid out like wIndex. Requests for controls
+// without a value are stalled. A SET_CUR request updates the GET_CUR value.
+func (d *Device) SetControl(request requests.RequestCode, selector uint8, index uint16, value []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.controls[controlKey{uint8(request), selector, index}] = slices.Clone(value)
+}
+
+// Control returns the value of a control, as set by SetControl or by the host with SET_CUR.
+func (d *Device) Control(request requests.RequestCode, selector uint8, index uint16) ([]byte, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.controls[controlKey{uint8(request), selector, index}]
+	return slices.Clone(v), ok
+}
+
+// SetNegotiator sets a function that adjusts the parameters of every probe the host sets, after the
+// device filled in the frame interval, frame size and payload size from its descriptors. Returning an
+// error stalls the request.
+func (d *Device) SetNegotiator(f func(*descriptors.VideoProbeCommitControl) error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.negotiator = f
+}
+
+// Committed returns the parameters the host committed on a streaming interface.
+func (d *Device) Committed(ifnum uint8) (*descriptors.VideoProbeCommitControl, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	vs, ok := d.streaming[ifnum]
+	if !ok || vs.commit == nil {
+		return nil, false
+	}
+	c := *vs.commit
+	return &c, true
+}
+
+// SetPayloadSource sets the source of the payloads sent on an IN endpoint.
+func (d *Device) SetPayloadSource(endpoint uint8, src PayloadSource) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sources[endpoint] = src
+}
+
+// Written returns the payloads the host sent to an OUT endpoint, one per bulk transfer or isochronous
+// packet.
+func (d *Device) Written(endpoint uint8) [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.written[endpoint])
+}
+
+// Requests returns the control requests the device received, in order.
+func (d *Device) Requests() []ControlRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.requests)
+}
+
+// Claimed reports whether the host claimed an interface.
+func (d *Device) Claimed(ifnum uint8) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.claimed[ifnum]
+}
+
+// AltSetting returns the alternate setting selected on an interface.
+func (d *Device) AltSetting(ifnum uint8) uint8 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.altSetting[ifnum]
+}
+
+func (d *Device) Descriptor() usb.DeviceDescriptor {
+	return d.descriptor
+}
+
+func (d *Device) ConfigDescriptorByValue(value uint8) (*usb.ConfigDescriptor, error) {
+	return d.config, nil
+}
+
+func (d *Device) StringDescriptor(index uint8) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.strings[index]
+	if !ok {
+		return "", usb.ErrPipe
+	}
+	return s, nil
+}
+
+func (d *Device) GetSpeed() (usb.Speed, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.speed, nil
+}
+
+func (d *Device) ControlTransfer(requestType, request uint8, value, index uint16, data []byte, timeout time.Duration) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return 0, usb.ErrNoDevice
+	}
+	n, err := d.control(requestType, request, value, index, data)
+	d.requests = append(d.requests, ControlRequest{
+		RequestType: requestType,
+		Request:     request,
+		Value:       value,
+		Index:       index,
+		Data:        slices.Clone(data[:n]),
+		Err:         err,
+	})
+	return n, err
+}
+
+func (d *Device) control(requestType, request uint8, value, index uint16, data []byte) (int, error) {
+	if requestType == 0x80 && request == 0x06 {
+		return d.getDescriptor(value, data)
+	}
+	selector := uint8(value >> 8)
+	if vs, ok := d.streaming[uint8(index)]; ok && index>>8 == 0 && (selector == probeControl || selector == commitControl) {
+		return d.probeCommit(vs, request, selector, data)
+	}
+	key := controlKey{request, selector, index}
+	if requestType&0x80 != 0 {
+		v, ok := d.controls[key]
+		if !ok {
+			return 0, usb.ErrPipe
+		}
+		return copy(data, v), nil
+	}
+	if requests.RequestCode(request) != requests.RequestCodeSetCur {
+		return 0, usb.ErrPipe
+	}
+	key.request = uint8(requests.RequestCodeGetCur)
+	if _, ok := d.controls[key]; !ok {
+		// the control is not supported.
+		return 0, usb.ErrPipe
+	}
+	d.controls[key] = slices.Clone(data)
+	return len(data), nil
+}
+
+// getDescriptor serves the standard GET_DESCRIPTOR request for the device and configuration
+// descriptors. USB 2.0 spec, section 9.4.3: wValue holds the descriptor type and index, the
+// descriptor is truncated to the requested length.
+func (d *Device) getDescriptor(value uint16, data []byte) (int, error) {
+	switch value {
+	case 0x0100:
+		desc, err := binary.Append(nil, binary.LittleEndian, d.descriptor)
+		if err != nil {
+			return 0, usb.ErrPipe
+		}
+		return copy(data, desc), nil
+	case 0x0200:
+		return copy(data, d.rawConfig), nil
+	}
+	return 0, usb.ErrPipe
+}
+
+// probeCommit serves the probe and commit controls. UVC spec 1.5, section 4.3.1.1.
+func (d *Device) probeCommit(vs *streamingInterface, request, selector uint8, data []byte) (int, error) {
+	buf := make([]byte, 48)
+	switch requests.RequestCode(request) {
+	case requests.RequestCodeSetCur:
+		copy(buf, data)
+		vpcc := &descriptors.VideoProbeCommitControl{}
+		if err := vpcc.UnmarshalBinary(buf); err != nil {
+			return 0, usb.ErrPipe
+		}
+		if selector == commitControl {
+			vs.commit = vpcc
+			return len(data), nil
+		}
+		if err := d.negotiate(vs, vpcc); err != nil {
+			return 0, usb.ErrPipe
+		}
+		vs.probe = vpcc
+		return len(data), nil
+	case requests.RequestCodeGetCur:
+		vpcc := vs.probe
+		if selector == commitControl {
+			vpcc = vs.commit
+		}
+		if vpcc == nil {
+			vpcc = &descriptors.VideoProbeCommitControl{}
+			if err := d.negotiate(vs, vpcc); err != nil {
+				return 0, usb.ErrPipe
+			}
+		}
+		if err := vpcc.MarshalInto(buf); err != nil {
+			return 0, usb.ErrPipe
+		}
+		return copy(data, buf), nil
+	case requests.RequestCodeGetMin, requests.RequestCodeGetMax, requests.RequestCodeGetDef:
+		if selector == commitControl {
+			return 0, usb.ErrPipe
+		}
+		if v, ok := d.controls[controlKey{request, selector, 0}]; ok {
+			return copy(data, v), nil
+		}
+		vpcc := &descriptors.VideoProbeCommitControl{}
+		if err := d.negotiate(vs, vpcc); err != nil {
+			return 0, usb.ErrPipe
+		}
+		if err := vpcc.MarshalInto(buf); err != nil {
+			return 0, usb.ErrPipe
+		}
+		return copy(data, buf), nil
+	case requests.RequestCodeGetLen:
+		return copy(data, []byte{48, 0}), nil
+	case requests.RequestCodeGetInfo:
+		// supports GET and SET requests.
+		return copy(data, []byte{0x03}), nil
+	}
+	return 0, usb.ErrPipe
+}
+
+// negotiate fills in the fields of a probe the device is responsible for, defaulting to the first
+// format and frame.
+func (d *Device) negotiate(vs *streamingInterface, vpcc *descriptors.VideoProbeCommitControl) error {
+	if vpcc.FormatIndex == 0 {
+		vpcc.FormatIndex = 1
+	}
+	if vpcc.FrameIndex == 0 {
+		vpcc.FrameIndex = 1
+	}
+	frame, ok := vs.frame(vpcc.FormatIndex, vpcc.FrameIndex)
+	if !ok {
+		return fmt.Errorf("frame %d of format %d not found", vpcc.FrameIndex, vpcc.FormatIndex)
+	}
+	if vpcc.FrameInterval == 0 {
+		vpcc.FrameInterval = frame.interval
+	}
+	vpcc.MaxVideoFrameSize = frame.size
+	vpcc.MaxPayloadTransferSize = d.maxPayloadSize(vs.endpoint, frame.size)
+	vpcc.ClockFrequency = d.clockFreq
+	if d.negotiator != nil {
+		return d.negotiator(vpcc)
+	}
+	return nil
+}
+
+// maxPayloadSize returns the largest packet of an isochronous endpoint, or a whole frame for bulk
+// endpoints.
+func (d *Device) maxPayloadSize(endpoint uint8, frameSize uint32) uint32 {
+	size := uint32(0)
+	for _, iface := range d.config.Interfaces {
+		for _, alt := range iface.AltSettings {
+			for _, ep := range alt.Endpoints {
+				if ep.EndpointAddr != endpoint {
+					continue
+				}
+				if ep.TransferType() != usb.TransferTypeIsochronous {
+					return frameSize + 12
+				}
+				size = max(size, uint32(ep.MaxPacketSize&0x7ff)*(1+uint32(ep.MaxPacketSize>>11&3)))
+			}
+		}
+	}
+	return size
+}
+
+type frameInfo struct {
+	size     uint32
+	interval time.Duration
+}
+
+// frame returns the size and default interval of a frame.
+func (vs *streamingInterface) frame(formatIndex, frameIndex uint8) (frameInfo, bool) {
+	var format descriptors.FormatDescriptor
+	for _, desc := range vs.descriptors {
+		var (
+			index         uint8
+			width, height uint32
+			info          frameInfo
+		)
+		switch f := desc.(type) {
+		case *descriptors.DVFormatDescriptor:
+			if f.Index() == formatIndex {
+				info := frameInfo{size: uint32(f.FrameSize()), interval: 100100 * time.Second / 3000000}
+				if f.Is50Hz() {
+					info.interval = time.Second / 25
+				}
+				return info, true
+			}
+			continue
+		case *descriptors.StreamBasedFormatDescriptor:
+			// stream-based formats have no frames, their frames are at most a packet.
+			if f.Index() == formatIndex {
+				return frameInfo{size: f.PacketLength}, true
+			}
+			continue
+		case descriptors.FormatDescriptor:
+			format = f
+			continue
+		case *descriptors.UncompressedFrameDescriptor:
+			index, width, height, info.interval = f.FrameIndex, uint32(f.Width), uint32(f.Height), f.DefaultFrameInterval
+			if u, ok := format.(*descriptors.UncompressedFormatDescriptor); ok {
+				info.size = width * height * uint32(u.BitsPerPixel) / 8
+			}
+		case *descriptors.MJPEGFrameDescriptor:
+			index, width, height, info.interval = f.FrameIndex, uint32(f.Width), uint32(f.Height), f.DefaultFrameInterval
+			info.size = f.MaxVideoFrameBufferSize
+		case *descriptors.FrameBasedFrameDescriptor:
+			index, width, height, info.interval = f.FrameIndex, uint32(f.Width), uint32(f.Height), f.DefaultFrameInterval
+		case *descriptors.H264FrameDescriptor:
+			index, width, height, info.interval = f.FrameIndex, uint32(f.Width), uint32(f.Height), f.DefaultFrameInterval
+		case *descriptors.VP8FrameDescriptor:
+			index, width, height, info.interval = f.FrameIndex, uint32(f.Width), uint32(f.Height), f.DefaultFrameInterval
+		default:
+			continue
+		}
+		if format == nil || format.Index() != formatIndex || index != frameIndex {
+			continue
+		}
+		if info.size == 0 {
+			info.size = width * height * 2
+		}
+		return info, true
+	}
+	return frameInfo{}, false
+}
+
+// endpointActive reports whether the endpoint is part of the selected alternate setting of its
+// interface.
+func (d *Device) endpointActive(endpoint uint8) bool {
+	for _, iface := range d.config.Interfaces {
+		for _, alt := range iface.AltSettings {
+			if alt.AlternateSetting != d.altSetting[alt.InterfaceNumber] {
+				continue
+			}
+			for _, ep := range alt.Endpoints {
+				if ep.EndpointAddr == endpoint {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (d *Device) BulkTransfer(endpoint uint8, data []byte, timeout time.Duration) (int, error) {
+	return d.BulkTransferWithOptions(endpoint, data, timeout, false)
+}
+
+func (d *Device) BulkTransferWithOptions(endpoint uint8, data []byte, timeout time.Duration, allowZeroLength bool) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return 0, usb.ErrNoDevice
+	}
+	if !d.endpointActive(endpoint) {
+		return 0, usb.ErrPipe
+	}
+	if endpoint&0x80 == 0 {
+		d.written[endpoint] = append(d.written[endpoint], slices.Clone(data))
+		return len(data), nil
+	}
+	src, ok := d.sources[endpoint]
+	if !ok {
+		return 0, usb.ErrTimeout
+	}
+	p, err := src.NextPayload()
+	if err != nil {
+		return 0, err
+	}
+	if len(p) > len(data) {
+		return 0, usb.ErrOverflow
+	}
+	return copy(data, p), nil
+}
+
+func (d *Device) IsochronousTransfer(endpoint uint8, data []byte, numPackets int, packetSize int, timeout time.Duration) ([]usb.IsoPacketResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, usb.ErrNoDevice
+	}
+	if !d.endpointActive(endpoint) {
+		return nil, usb.ErrPipe
+	}
+	results := make([]usb.IsoPacketResult, numPackets)
+	if endpoint&0x80 == 0 {
+		for i := range results {
+			off := min(i*packetSize, len(data))
+			p := data[off:min(off+packetSize, len(data))]
+			d.written[endpoint] = append(d.written[endpoint], slices.Clone(p))
+			results[i] = usb.IsoPacketResult{Length: packetSize, ActualLength: len(p)}
+		}
+		return results, nil
+	}
+	src, ok := d.sources[endpoint]
+	if !ok {
+		return nil, usb.ErrTimeout
+	}
+	for i := range results {
+		results[i].Length = packetSize
+		p, err := src.NextPayload()
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			// the stream ended, the remaining packets are empty.
+			break
+		}
+		if len(p) > packetSize {
+			// babble, the packet is lost.
+			results[i].Status = -75 // EOVERFLOW
+			continue
+		}
+		results[i].ActualLength = copy(data[i*packetSize:], p)
+	}
+	return results, nil
+}
+
+func (d *Device) DetachKernelDriver(iface uint8) error {
+	return nil
+}
+
+func (d *Device) ClaimInterface(iface uint8) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return usb.ErrNoDevice
+	}
+	if d.config.Interface(iface) == nil {
+		return usb.ErrNotFound
+	}
+	d.claimed[iface] = true
+	return nil
+}
+
+func (d *Device) ReleaseInterface(iface uint8) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.claimed[iface] {
+		return usb.ErrNotFound
+	}
+	delete(d.claimed, iface)
+	return nil
+}
+
+func (d *Device) SetInterfaceAltSetting(iface, altSetting uint8) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return usb.ErrNoDevice
+	}
+	if d.config.InterfaceAltSetting(iface, altSetting) == nil {
+		return usb.ErrNotFound
+	}
+	d.altSetting[iface] = altSetting
+	return nil
+}
+
+func (d *Device) ClearHalt(endpoint uint8) error {
+	return nil
+}
+
+// Close disconnects the device, later transfers fail with usb.ErrNoDevice.
+func (d *Device) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	return nil
+}
@@ -0,0 +1,149 @@
+package simulator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/requests"
+)
+
+func TestWebcam_Descriptors(t *testing.T) {
+	d := Webcam()
+	cd, err := d.ConfigDescriptorByValue(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cd.Interfaces) != 2 {
+		t.Fatalf("got %d interfaces, want 2", len(cd.Interfaces))
+	}
+	if n := len(cd.Interface(WebcamStreamingInterface).AltSettings); n != 3 {
+		t.Errorf("streaming interface has %d alternate settings, want 3", n)
+	}
+	vs := d.streaming[WebcamStreamingInterface]
+	if vs == nil || vs.endpoint != WebcamEndpoint {
+		t.Fatalf("streaming interface not found: %+v", vs)
+	}
+	if info, ok := vs.frame(1, 2); !ok || info.size != 320*240*2 || info.interval != 333333*100*time.Nanosecond {
+		t.Errorf("frame 2 = %+v, %v", info, ok)
+	}
+	if d.clockFreq != 48000000 {
+		t.Errorf("clock frequency = %d", d.clockFreq)
+	}
+	if s, err := d.StringDescriptor(d.Descriptor().SerialNumberIndex); err != nil || s != "SIM0001" {
+		t.Errorf("serial number = %q, %v", s, err)
+	}
+}
+
+func TestDevice_ProbeCommit(t *testing.T) {
+	d := Webcam()
+	buf := make([]byte, 48)
+	get := uint8(requests.RequestTypeVideoInterfaceGetRequest)
+	set := uint8(requests.RequestTypeVideoInterfaceSetRequest)
+
+	if _, err := d.ControlTransfer(get, uint8(requests.RequestCodeGetMax), probeControl<<8, WebcamStreamingInterface, buf, time.Second); err != nil {
+		t.Fatalf("GET_MAX probe failed: %v", err)
+	}
+	vpcc := &descriptors.VideoProbeCommitControl{}
+	vpcc.UnmarshalBinary(buf)
+	if vpcc.FormatIndex != 1 || vpcc.FrameIndex != 1 || vpcc.MaxVideoFrameSize != 640*480*2 || vpcc.MaxPayloadTransferSize != WebcamPacketSize {
+		t.Errorf("GET_MAX probe = %+v", vpcc)
+	}
+
+	vpcc = &descriptors.VideoProbeCommitControl{FormatIndex: 1, FrameIndex: 2}
+	vpcc.MarshalInto(buf)
+	if _, err := d.ControlTransfer(set, uint8(requests.RequestCodeSetCur), probeControl<<8, WebcamStreamingInterface, buf, time.Second); err != nil {
+		t.Fatalf("SET_CUR probe failed: %v", err)
+	}
+	clear(buf)
+	if _, err := d.ControlTransfer(get, uint8(requests.RequestCodeGetCur), probeControl<<8, WebcamStreamingInterface, buf, time.Second); err != nil {
+		t.Fatalf("GET_CUR probe failed: %v", err)
+	}
+	vpcc.UnmarshalBinary(buf)
+	if vpcc.FrameIndex != 2 || vpcc.MaxVideoFrameSize != 320*240*2 || vpcc.FrameInterval != 333333*100*time.Nanosecond || vpcc.ClockFrequency != 48000000 {
+		t.Errorf("GET_CUR probe = %+v", vpcc)
+	}
+
+	vpcc.FrameIndex = 3
+	vpcc.MarshalInto(buf)
+	if _, err := d.ControlTransfer(set, uint8(requests.RequestCodeSetCur), probeControl<<8, WebcamStreamingInterface, buf, time.Second); !errors.Is(err, usb.ErrPipe) {
+		t.Errorf("SET_CUR probe of a missing frame = %v, want a stall", err)
+	}
+	if reqs := d.Requests(); len(reqs) != 4 || reqs[3].Err == nil {
+		t.Errorf("requests = %+v", reqs)
+	}
+}
+
+func TestDevice_Controls(t *testing.T) {
+	d := Webcam()
+	index := uint16(WebcamProcessingUnit)<<8 | WebcamControlInterface
+	buf := make([]byte, 2)
+	if _, err := d.ControlTransfer(uint8(requests.RequestTypeVideoInterfaceGetRequest), uint8(requests.RequestCodeGetMin), 0x02<<8, index, buf, time.Second); err != nil {
+		t.Fatalf("GET_MIN failed: %v", err)
+	}
+	if !bytes.Equal(buf, []byte{0xc0, 0xff}) {
+		t.Errorf("GET_MIN = %x", buf)
+	}
+	if _, err := d.ControlTransfer(uint8(requests.RequestTypeVideoInterfaceSetRequest), uint8(requests.RequestCodeSetCur), 0x02<<8, index, []byte{0x10, 0x00}, time.Second); err != nil {
+		t.Fatalf("SET_CUR failed: %v", err)
+	}
+	if v, _ := d.Control(requests.RequestCodeGetCur, 0x02, index); !bytes.Equal(v, []byte{0x10, 0x00}) {
+		t.Errorf("GET_CUR after SET_CUR = %x", v)
+	}
+	// hue is not supported by the processing unit.
+	if _, err := d.ControlTransfer(uint8(requests.RequestTypeVideoInterfaceSetRequest), uint8(requests.RequestCodeSetCur), 0x06<<8, index, []byte{0, 0}, time.Second); !errors.Is(err, usb.ErrPipe) {
+		t.Errorf("SET_CUR of an unsupported control = %v, want a stall", err)
+	}
+}
+
+func TestDevice_Isochronous(t *testing.T) {
+	d := Webcam()
+	frames := [][]byte{bytes.Repeat([]byte{1}, 5000), bytes.Repeat([]byte{2}, 5000)}
+	d.SetPayloadSource(WebcamEndpoint, Frames(WebcamPacketSize, frames...))
+	buf := make([]byte, 8*WebcamPacketSize)
+
+	if _, err := d.IsochronousTransfer(WebcamEndpoint, buf, 8, WebcamPacketSize, time.Second); !errors.Is(err, usb.ErrPipe) {
+		t.Errorf("transfer on the zero bandwidth alternate setting = %v, want a stall", err)
+	}
+	if err := d.SetInterfaceAltSetting(WebcamStreamingInterface, 1); err != nil {
+		t.Fatal(err)
+	}
+	results, err := d.IsochronousTransfer(WebcamEndpoint, buf, 8, WebcamPacketSize, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]byte
+	for i, r := range results {
+		if r.ActualLength > 0 {
+			got = append(got, buf[i*WebcamPacketSize:i*WebcamPacketSize+r.ActualLength])
+		}
+	}
+	// two payloads per frame.
+	if len(got) != 4 {
+		t.Fatalf("got %d payloads, want 4", len(got))
+	}
+	for i, p := range got {
+		fid, eof := p[1]&0x01 != 0, p[1]&0x02 != 0
+		if fid != (i >= 2) || eof != (i%2 == 1) {
+			t.Errorf("payload %d header = %08b", i, p[1])
+		}
+	}
+	if _, err := d.IsochronousTransfer(WebcamEndpoint, buf, 8, WebcamPacketSize, time.Second); err != io.EOF {
+		t.Errorf("transfer after the end of the stream = %v, want io.EOF", err)
+	}
+}
+
+func TestNew_MalformedClassDescriptors(t *testing.T) {
+	config := WebcamConfig()
+	// a one byte descriptor at the end of the video control interface.
+	i := bytes.Index(config, []byte{0x09, 0x04, WebcamStreamingInterface, 0})
+	config = append(config[:i:i], append([]byte{0x01}, config[i:]...)...)
+	config[2]++
+	if _, err := New(Webcam().Descriptor(), config); !errors.Is(err, descriptors.ErrInvalidDescriptor) {
+		t.Errorf("New = %v, want %v", err, descriptors.ErrInvalidDescriptor)
+	}
+}
@@ -0,0 +1,78 @@
+package simulator
+
+import (
+	usb "github.com/kevmo314/go-usb"
+)
+
+// Entities and endpoints of the device returned by Microphone.
+const (
+	MicrophoneControlInterface   = 0
+	MicrophoneStreamingInterface = 1
+	MicrophoneInputTerminal      = 1
+	MicrophoneOutputTerminal     = 2
+	MicrophoneEndpoint           = 0x81
+	// MicrophoneSampleRate is the only sampling frequency of MicrophoneStreamingInterface.
+	MicrophoneSampleRate = 48000
+	// MicrophonePacketSize is the largest isochronous packet of MicrophoneEndpoint, a millisecond of
+	// 16 bit mono samples.
+	MicrophonePacketSize = MicrophoneSampleRate / 1000 * 2
+)
+
+// MicrophoneConfig returns the configuration descriptor of a full speed UAC 1.0 microphone that
+// streams 16 bit mono PCM at 48 kHz from isochronous endpoint 0x81. Alternate setting 0 of the
+// streaming interface is zero-bandwidth, alternate setting 1 carries MicrophonePacketSize bytes per
+// frame. The endpoint supports the sampling frequency control.
+func MicrophoneConfig() []byte {
+	// UAC spec 1.0, section 4.3: the audio control interface.
+	acUnits := cat(
+		// input terminal, microphone.
+		desc([]byte{0x24, 0x02, MicrophoneInputTerminal}, le16(0x0201), []byte{0, 1}, le16(0), []byte{0, 0}),
+		// output terminal, USB streaming.
+		desc([]byte{0x24, 0x03, MicrophoneOutputTerminal}, le16(0x0101), []byte{0, MicrophoneInputTerminal, 0}),
+	)
+	acHeaderLength := 9
+	acHeader := desc([]byte{0x24, 0x01}, le16(0x0100), le16(uint16(acHeaderLength+len(acUnits))), []byte{1, MicrophoneStreamingInterface})
+
+	// UAC spec 1.0, section 4.5: the audio streaming interface.
+	body := cat(
+		desc([]byte{0x04, MicrophoneControlInterface, 0, 0, 0x01, 0x01, 0, 0}),
+		acHeader, acUnits,
+		desc([]byte{0x04, MicrophoneStreamingInterface, 0, 0, 0x01, 0x02, 0, 0}),
+		desc([]byte{0x04, MicrophoneStreamingInterface, 1, 1, 0x01, 0x02, 0, 0}),
+		// AS_GENERAL: PCM from the output terminal.
+		desc([]byte{0x24, 0x01, MicrophoneOutputTerminal, 1}, le16(0x0001)),
+		// FORMAT_TYPE_I: one channel of two byte subframes with 16 bit samples at a single frequency.
+		desc([]byte{0x24, 0x02, 0x01, 1, 2, 16, 1}, le32(MicrophoneSampleRate)[:3]),
+		// asynchronous isochronous endpoint, with the audio class bRefresh and bSynchAddress.
+		desc([]byte{0x05, MicrophoneEndpoint, 0x05}, le16(MicrophonePacketSize), []byte{1, 0, 0}),
+		// AS_GENERAL endpoint: sampling frequency control.
+		desc([]byte{0x25, 0x01, 0x01, 0}, le16(0)),
+	)
+	config := desc([]byte{0x02}, le16(uint16(9+len(body))), []byte{2, 1, 0, 0x80, 50})
+	return append(config, body...)
+}
+
+// Microphone returns a simulated full speed microphone with the configuration of MicrophoneConfig.
+func Microphone() *Device {
+	d, err := New(usb.DeviceDescriptor{
+		Length:            18,
+		DescriptorType:    0x01,
+		USBVersion:        0x0110,
+		MaxPacketSize0:    64,
+		VendorID:          0x1209,
+		ProductID:         0x0002,
+		DeviceVersion:     0x0100,
+		SerialNumberIndex: 1,
+		NumConfigurations: 1,
+	}, MicrophoneConfig())
+	if err != nil {
+		panic(err)
+	}
+	d.SetSpeed(usb.SpeedFull)
+	d.SetString(1, "SIM0002")
+
+	// UAC spec 1.0, section 5.2.3.2.3.2: the sampling frequency control of the endpoint, in Hz.
+	freq := le32(MicrophoneSampleRate)[:3]
+	d.setControlValues(0x01, MicrophoneEndpoint, freq, freq, freq, []byte{1, 0, 0}, freq)
+	return d
+}
@@ -0,0 +1,93 @@
+package simulator
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// PayloadSource produces the payloads a device sends on an IN endpoint, payload headers included. It
+// returns io.EOF once the stream ends.
+type PayloadSource interface {
+	NextPayload() ([]byte, error)
+}
+
+// PayloadFunc adapts a function to a PayloadSource, to script arbitrary payload sequences.
+type PayloadFunc func() ([]byte, error)
+
+func (f PayloadFunc) NextPayload() ([]byte, error) {
+	return f()
+}
+
+// Payloads returns a source that sends the given payloads in order.
+func Payloads(payloads ...[]byte) PayloadSource {
+	i := 0
+	return PayloadFunc(func() ([]byte, error) {
+		if i == len(payloads) {
+			return nil, io.EOF
+		}
+		i++
+		return payloads[i-1], nil
+	})
+}
+
+// FrameSource sends frames split into payloads the way a device does. UVC spec 1.5, section 2.4.3.3:
+// every payload starts with a header whose frame id toggles with every frame, and the last payload of
+// a frame has the end of frame bit set.
+type FrameSource struct {
+	// PayloadSize is the size of the payloads, header included.
+	PayloadSize int
+	// ClockFrequency is the frequency of the device clock in Hz. If set, headers carry the PTS of their
+	// frame, spaced FrameInterval apart.
+	ClockFrequency uint32
+	FrameInterval  time.Duration
+	// Repeat restarts at the first frame after the last one instead of ending the stream.
+	Repeat bool
+
+	frames [][]byte
+	frame  int
+	offset int
+	count  uint64
+}
+
+// Frames returns a source that sends the given frames in payloads of payloadSize bytes.
+func Frames(payloadSize int, frames ...[]byte) *FrameSource {
+	return &FrameSource{PayloadSize: payloadSize, frames: frames}
+}
+
+func (s *FrameSource) NextPayload() ([]byte, error) {
+	if s.frame == len(s.frames) {
+		if !s.Repeat || len(s.frames) == 0 {
+			return nil, io.EOF
+		}
+		s.frame = 0
+	}
+	headerLength := 2
+	bitmask := uint8(0b10000000)
+	if s.count%2 == 1 {
+		bitmask |= 0b00000001
+	}
+	if s.ClockFrequency != 0 {
+		headerLength = 6
+		bitmask |= 0b00000100
+	}
+	frame := s.frames[s.frame]
+	n := min(s.PayloadSize-headerLength, len(frame)-s.offset)
+	if s.offset+n == len(frame) {
+		bitmask |= 0b00000010
+	}
+	p := make([]byte, 0, headerLength+n)
+	p = append(p, uint8(headerLength), bitmask)
+	if s.ClockFrequency != 0 {
+		ticks := uint64(s.count) * uint64(s.FrameInterval) * uint64(s.ClockFrequency) / uint64(time.Second)
+		p = binary.LittleEndian.AppendUint32(p, uint32(ticks))
+	}
+	p = append(p, frame[s.offset:s.offset+n]...)
+	s.offset += n
+	if s.offset == len(frame) {
+		s.frame++
+		s.offset = 0
+		s.count++
+	}
+	return p, nil
+}
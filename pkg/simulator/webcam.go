@@ -0,0 +1,197 @@
+package simulator
+
+import (
+	"encoding/binary"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/requests"
+)
+
+// Entities and endpoints of the device returned by Webcam.
+const (
+	WebcamControlInterface   = 0
+	WebcamStreamingInterface = 1
+	WebcamCameraTerminal     = 1
+	WebcamProcessingUnit     = 2
+	WebcamOutputTerminal     = 3
+	WebcamEndpoint           = 0x81
+	// WebcamPacketSize is the largest isochronous packet of WebcamEndpoint, three 1024 byte
+	// transactions per microframe.
+	WebcamPacketSize = 3 * 1024
+
+	// The metadata streaming interface of the device returned by MetadataWebcam.
+	WebcamMetadataInterface = 2
+	WebcamMetadataEndpoint  = 0x82
+	// WebcamMetadataSize is the largest metadata frame of WebcamMetadataInterface.
+	WebcamMetadataSize = 1024
+)
+
+// WebcamConfig returns the configuration descriptor of a UVC 1.1 webcam streaming YUY2 at 640x480
+// (frame 1) and 320x240 (frame 2), both at 30 fps, from isochronous endpoint 0x81. Alternate setting 1
+// of the streaming interface carries WebcamPacketSize bytes per microframe and alternate setting 2 a
+// single 512 byte transaction. The camera terminal supports the auto-exposure mode and absolute
+// exposure time controls, the processing unit brightness.
+func WebcamConfig() []byte {
+	return webcamConfig(false)
+}
+
+// MetadataWebcamConfig returns WebcamConfig with a second streaming interface that sends metadata
+// frames of up to WebcamMetadataSize bytes from bulk endpoint 0x82, in a single stream-based format.
+func MetadataWebcamConfig() []byte {
+	return webcamConfig(true)
+}
+
+func webcamConfig(metadata bool) []byte {
+	interval := uint32(time.Second / 30 / 100)
+
+	// UVC spec 1.5, section 3.7: the video control interface.
+	vcUnits := cat(
+		// camera terminal: auto-exposure mode and exposure time absolute.
+		desc([]byte{0x24, 0x02, WebcamCameraTerminal}, le16(0x0201), []byte{0, 0}, le16(0), le16(0), le16(0), []byte{3, 0x0a, 0, 0}),
+		// processing unit: brightness.
+		desc([]byte{0x24, 0x05, WebcamProcessingUnit, WebcamCameraTerminal}, le16(0), []byte{2, 0x01, 0, 0, 0}),
+		// output terminal, USB streaming.
+		desc([]byte{0x24, 0x03, WebcamOutputTerminal}, le16(0x0101), []byte{0, WebcamProcessingUnit, 0}),
+	)
+	streamingInterfaces := []byte{WebcamStreamingInterface}
+	if metadata {
+		streamingInterfaces = append(streamingInterfaces, WebcamMetadataInterface)
+	}
+	vcHeaderLength := 12 + len(streamingInterfaces)
+	vcHeader := desc([]byte{0x24, 0x01}, le16(0x0110), le16(uint16(vcHeaderLength+len(vcUnits))), le32(48000000), []byte{uint8(len(streamingInterfaces))}, streamingInterfaces)
+
+	// UVC spec 1.5, section 3.9: the video streaming interface.
+	frame := func(index uint8, width, height uint16) []byte {
+		size := uint32(width) * uint32(height) * 2
+		bitRate := size * 8 * 30
+		return desc([]byte{0x24, 0x05, index, 0}, le16(width), le16(height), le32(bitRate), le32(bitRate), le32(size), le32(interval), []byte{1}, le32(interval))
+	}
+	yuy2 := []byte{'Y', 'U', 'Y', '2', 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71}
+	vsFormats := cat(
+		desc([]byte{0x24, 0x04, 1, 2}, yuy2, []byte{16, 1, 0, 0, 0, 0}),
+		frame(1, 640, 480),
+		frame(2, 320, 240),
+	)
+	vsHeaderLength := 14
+	vsHeader := desc([]byte{0x24, 0x01, 1}, le16(uint16(vsHeaderLength+len(vsFormats))), []byte{WebcamEndpoint, 0, WebcamOutputTerminal, 0, 0, 0, 1, 0})
+
+	isoEndpoint := func(maxPacketSize uint16) []byte {
+		return desc([]byte{0x05, WebcamEndpoint, 0x05}, le16(maxPacketSize), []byte{1})
+	}
+	body := cat(
+		// interface association, video function.
+		desc([]byte{0x0b, WebcamControlInterface, uint8(1 + len(streamingInterfaces)), 0x0e, 0x03, 0, 0}),
+		desc([]byte{0x04, WebcamControlInterface, 0, 0, 0x0e, 0x01, 0, 0}),
+		vcHeader, vcUnits,
+		desc([]byte{0x04, WebcamStreamingInterface, 0, 0, 0x0e, 0x02, 0, 0}),
+		vsHeader, vsFormats,
+		desc([]byte{0x04, WebcamStreamingInterface, 1, 1, 0x0e, 0x02, 0, 0}),
+		isoEndpoint(1024|2<<11),
+		desc([]byte{0x04, WebcamStreamingInterface, 2, 1, 0x0e, 0x02, 0, 0}),
+		isoEndpoint(512),
+	)
+	if metadata {
+		// UVC spec 1.5, section 3.9.2.3: a single stream-based format, without frame descriptors.
+		mdFormat := desc([]byte{0x24, 0x12, 1}, metadataGUID, le32(WebcamMetadataSize))
+		mdHeader := desc([]byte{0x24, 0x01, 1}, le16(uint16(vsHeaderLength+len(mdFormat))), []byte{WebcamMetadataEndpoint, 0, WebcamOutputTerminal, 0, 0, 0, 1, 0})
+		body = cat(body,
+			desc([]byte{0x04, WebcamMetadataInterface, 0, 1, 0x0e, 0x02, 0, 0}),
+			mdHeader, mdFormat,
+			desc([]byte{0x05, WebcamMetadataEndpoint, 0x02}, le16(512), []byte{0}),
+		)
+	}
+	config := desc([]byte{0x02}, le16(uint16(9+len(body))), []byte{uint8(1 + len(streamingInterfaces)), 1, 0, 0x80, 250})
+	return append(config, body...)
+}
+
+// metadataGUID is the format of the metadata interface of MetadataWebcam, a FourCC style
+// GUID of a vendor format.
+var metadataGUID = []byte{0x4d, 0x44, 0x41, 0x54, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71}
+
+// Webcam returns a simulated webcam with the configuration of WebcamConfig. Its controls start at
+// plausible values: manual exposure mode, an exposure time of 1/30 s and a brightness of 0 within
+// -64 to 64.
+func Webcam() *Device {
+	return newWebcam(WebcamConfig())
+}
+
+// MetadataWebcam returns a simulated webcam with the configuration of MetadataWebcamConfig and the
+// controls of Webcam.
+func MetadataWebcam() *Device {
+	return newWebcam(MetadataWebcamConfig())
+}
+
+func newWebcam(config []byte) *Device {
+	d, err := New(usb.DeviceDescriptor{
+		Length:            18,
+		DescriptorType:    0x01,
+		USBVersion:        0x0200,
+		DeviceClass:       0xef,
+		DeviceSubClass:    0x02,
+		DeviceProtocol:    0x01,
+		MaxPacketSize0:    64,
+		VendorID:          0x1209,
+		ProductID:         0x0001,
+		DeviceVersion:     0x0100,
+		SerialNumberIndex: 1,
+		NumConfigurations: 1,
+	}, config)
+	if err != nil {
+		panic(err)
+	}
+	d.SetString(1, "SIM0001")
+
+	ct := uint16(WebcamCameraTerminal)<<8 | WebcamControlInterface
+	// UVC spec 1.5, section 4.2.2.1.2: auto-exposure mode, a bitmap of the supported modes for GET_RES.
+	d.setControlValues(0x02, ct, []byte{0x01}, []byte{0x01}, []byte{0x01}, []byte{0x0f}, []byte{0x02})
+	// UVC spec 1.5, section 4.2.2.1.4: exposure time absolute, in 100 µs units.
+	d.setControlValues(0x04, ct, le32(333), le32(1), le32(10000), le32(1), le32(333))
+
+	pu := uint16(WebcamProcessingUnit)<<8 | WebcamControlInterface
+	// UVC spec 1.5, section 4.2.2.3.2: brightness, signed.
+	d.setControlValues(0x02, pu, le16(0), le16(uint16(0xffc0)), le16(64), le16(1), le16(0))
+	return d
+}
+
+func cat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// desc prefixes a descriptor with its length.
+func desc(parts ...[]byte) []byte {
+	b := cat(parts...)
+	return append([]byte{uint8(len(b) + 1)}, b...)
+}
+
+func le16(v uint16) []byte {
+	return binary.LittleEndian.AppendUint16(nil, v)
+}
+
+func le32(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+// setControlValues sets the GET_CUR, GET_MIN, GET_MAX, GET_RES and GET_DEF values of a control, along
+// with its GET_INFO and GET_LEN.
+func (d *Device) setControlValues(selector uint8, index uint16, cur, minimum, maximum, res, def []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	values := map[requests.RequestCode][]byte{
+		requests.RequestCodeGetCur: cur,
+		requests.RequestCodeGetMin: minimum,
+		requests.RequestCodeGetMax: maximum,
+		requests.RequestCodeGetRes: res,
+		requests.RequestCodeGetDef: def,
+		requests.RequestCodeGetLen: le16(uint16(len(cur))),
+		// supports GET and SET requests.
+		requests.RequestCodeGetInfo: {0x03},
+	}
+	for request, v := range values {
+		d.controls[controlKey{uint8(request), selector, index}] = v
+	}
+}
@@ -3,6 +3,8 @@ package transfers
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	usb "github.com/kevmo314/go-usb"
 )
@@ -27,15 +29,28 @@ type AsyncBulkReader struct {
 	endpoint  uint8
 	urbSize   int // Actual URB buffer size (capped at MaxURBBufferSize)
 	transfers []*usb.AsyncBulkTransfer
+	// bulkReader reads instead of the queued transfers on transports other than a *usb.DeviceHandle.
+	bulkReader *BulkReader
 
 	mu       sync.Mutex
 	nextRead int // Index of next transfer to read from
-	closed   bool
+	closed   atomic.Bool
+
+	stats transferCounters
 }
 
-// NewAsyncBulkReader creates a new async bulk reader with queued transfers.
+// NewAsyncBulkReader creates a new async bulk reader with queued transfers. On transports other than a
+// *usb.DeviceHandle, which can not queue transfers, it reads with a BulkReader instead.
 func (si *StreamingInterface) NewAsyncBulkReader(endpointAddress uint8, mtu uint32) (*AsyncBulkReader, error) {
-	return NewAsyncBulkReaderWithCount(si.handle, endpointAddress, mtu, DefaultNumTransfers)
+	handle, ok := usbHandle(si.handle)
+	if !ok {
+		br, err := si.NewBulkReader(endpointAddress, mtu)
+		if err != nil {
+			return nil, err
+		}
+		return &AsyncBulkReader{bulkReader: br}, nil
+	}
+	return NewAsyncBulkReaderWithCount(handle, endpointAddress, mtu, DefaultNumTransfers)
 }
 
 // NewAsyncBulkReaderWithCount creates an async bulk reader with a specific number of queued transfers.
@@ -55,6 +70,7 @@ func NewAsyncBulkReaderWithCount(handle *usb.DeviceHandle, endpointAddress uint8
 		endpoint:  endpointAddress,
 		urbSize:   urbSize,
 		transfers: make([]*usb.AsyncBulkTransfer, numTransfers),
+		stats:     transferCounters{start: time.Now()},
 	}
 
 	// Create all transfers with small URB buffers
@@ -79,6 +95,7 @@ func NewAsyncBulkReaderWithCount(handle *usb.DeviceHandle, endpointAddress uint8
 			}
 			return nil, fmt.Errorf("failed to submit initial transfer %d: %w", i, err)
 		}
+		r.stats.submitted.Add(1)
 	}
 
 	return r, nil
@@ -91,9 +108,12 @@ func (r *AsyncBulkReader) Read(buf []byte) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.closed {
+	if r.closed.Load() {
 		return 0, fmt.Errorf("reader closed")
 	}
+	if r.bulkReader != nil {
+		return r.bulkReader.Read(buf)
+	}
 
 	// Accumulate URB data directly into buf until short transfer
 	written := 0
@@ -101,35 +121,56 @@ func (r *AsyncBulkReader) Read(buf []byte) (int, error) {
 		t := r.transfers[r.nextRead]
 		data, err := t.Wait()
 		if err != nil {
+			r.stats.transferErrors.Add(1)
 			return 0, fmt.Errorf("async bulk read failed: %w", err)
 		}
+		if r.closed.Load() {
+			return 0, fmt.Errorf("reader closed")
+		}
 
 		// Copy BEFORE resubmitting to avoid race with kernel
 		n := copy(buf[written:], data)
 		written += n
 
 		// Now safe to resubmit
-		t.Submit()
+		r.stats.transfers.Add(1)
+		if err := t.Submit(); err != nil {
+			r.stats.submitted.Add(-1)
+		}
 		r.nextRead = (r.nextRead + 1) % len(r.transfers)
 
 		// Short transfer (including ZLP) signals end of payload
 		if len(data) < r.urbSize {
+			r.stats.packet(written)
 			return written, nil
 		}
 	}
 }
 
-// Close cancels all pending transfers and releases resources.
+// Stats returns a snapshot of the transfer statistics of the reader. Packets counts reassembled
+// payloads.
+func (r *AsyncBulkReader) Stats() TransferStats {
+	if r.bulkReader != nil {
+		return r.bulkReader.Stats()
+	}
+	return r.stats.snapshot()
+}
+
+// Close cancels all pending transfers and releases resources. It is safe to call while a Read is
+// blocked, the Read returns an error once its transfer is cancelled.
 func (r *AsyncBulkReader) Close() error {
-	r.mu.Lock()
-	if r.closed {
-		r.mu.Unlock()
+	if !r.closed.CompareAndSwap(false, true) {
 		return nil
 	}
-	r.closed = true
-	r.mu.Unlock()
 
-	// Cancel all transfers
+	// Cancel all transfers without holding the lock to wake up a blocked Read
+	for _, t := range r.transfers {
+		t.Cancel()
+	}
+
+	// Once Read has returned, nothing resubmits transfers so they can be reaped.
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	for _, t := range r.transfers {
 		t.Cancel()
 	}
@@ -137,6 +178,7 @@ func (r *AsyncBulkReader) Close() error {
 	for _, t := range r.transfers {
 		t.Wait() // Ignore error - we're closing
 	}
+	r.stats.submitted.Store(0)
 
 	return nil
 }
@@ -3,27 +3,23 @@ package transfers
 import (
 	"fmt"
 	"sync"
-	"sync/atomic"
+	"time"
 
 	usb "github.com/kevmo314/go-usb"
 )
 
 type AudioReader struct {
-	asi       *AudioStreamingInterface
-	handle    *usb.DeviceHandle
-	transfers []*usb.IsochronousTransfer
-	mu        sync.Mutex
+	asi        *AudioStreamingInterface
+	handle     *usb.DeviceHandle
+	syncReader *SyncIsochronousReader
+	transfers  []*usb.IsochronousTransfer
+	mu         sync.Mutex
 
 	// Current state
 	currentTx int
 	packetIdx int
 
-	// Statistics (kept for debugging)
-	transferCount  int64
-	bytesReceived  int64
-	packetsSuccess int64
-	packetsError   int64
-	packetsEmpty   int64
+	stats transferCounters
 }
 
 const (
@@ -32,10 +28,20 @@ const (
 	audioTimeout      = 5000
 )
 
+// NewAudioReader creates a reader of the isochronous endpoint of the interface. On transports other than
+// a *usb.DeviceHandle, which can not queue transfers, it reads with one synchronous transfer at a time.
 func NewAudioReader(asi *AudioStreamingInterface) (*AudioReader, error) {
+	handle, ok := usbHandle(asi.handle)
+	if !ok {
+		return &AudioReader{
+			asi:        asi,
+			syncReader: newSyncIsochronousReader(asi.handle, asi.EndpointAddress, numIsoPackets, int(asi.MaxPacketSize)),
+		}, nil
+	}
 	reader := &AudioReader{
 		asi:    asi,
-		handle: asi.handle,
+		handle: handle,
+		stats:  transferCounters{start: time.Now()},
 	}
 
 	// Initialize transfers and buffers
@@ -65,6 +71,7 @@ func (ar *AudioReader) initialize() error {
 			ar.cleanup()
 			return fmt.Errorf("failed to submit transfer: %w", err)
 		}
+		ar.stats.submitted.Add(1)
 	}
 
 	return nil
@@ -72,11 +79,15 @@ func (ar *AudioReader) initialize() error {
 
 // ReadAudio reads audio data synchronously
 func (ar *AudioReader) ReadAudio(buf []byte) (int, error) {
+	if ar.syncReader != nil {
+		return ar.syncReader.Read(buf)
+	}
 	for {
 		tx := ar.transfers[ar.currentTx]
 
 		// Wait for the current transfer to complete
 		if err := tx.Wait(); err != nil {
+			ar.stats.transferErrors.Add(1)
 			return 0, fmt.Errorf("isochronous transfer failed: %w", err)
 		}
 
@@ -85,12 +96,13 @@ func (ar *AudioReader) ReadAudio(buf []byte) (int, error) {
 		// If we've processed all packets in this transfer, resubmit it
 		if ar.packetIdx >= len(packets) {
 			// Resubmit the transfer
+			ar.stats.transfers.Add(1)
 			if err := tx.Submit(); err != nil {
+				ar.stats.submitted.Add(-1)
 				return 0, fmt.Errorf("failed to resubmit transfer: %w", err)
 			}
 			ar.packetIdx = 0
 			ar.currentTx = (ar.currentTx + 1) % len(ar.transfers)
-			atomic.AddInt64(&ar.transferCount, 1)
 			continue
 		}
 
@@ -99,14 +111,14 @@ func (ar *AudioReader) ReadAudio(buf []byte) (int, error) {
 
 		// Skip packets with errors
 		if packet.Status != 0 {
-			atomic.AddInt64(&ar.packetsError, 1)
+			ar.stats.packetError(int(packet.Status))
 			ar.packetIdx++
 			continue
 		}
 
 		// Skip empty packets
 		if packet.ActualLength == 0 {
-			atomic.AddInt64(&ar.packetsEmpty, 1)
+			ar.stats.packet(0)
 			ar.packetIdx++
 			continue
 		}
@@ -126,8 +138,7 @@ func (ar *AudioReader) ReadAudio(buf []byte) (int, error) {
 		// Copy the audio data
 		n := copy(buf, data)
 
-		atomic.AddInt64(&ar.packetsSuccess, 1)
-		atomic.AddInt64(&ar.bytesReceived, int64(n))
+		ar.stats.packet(n)
 
 		ar.packetIdx++
 
@@ -135,6 +146,14 @@ func (ar *AudioReader) ReadAudio(buf []byte) (int, error) {
 	}
 }
 
+// Stats returns a snapshot of the transfer statistics of the reader.
+func (ar *AudioReader) Stats() TransferStats {
+	if ar.syncReader != nil {
+		return ar.syncReader.Stats()
+	}
+	return ar.stats.snapshot()
+}
+
 func (ar *AudioReader) Close() error {
 	ar.mu.Lock()
 	defer ar.mu.Unlock()
@@ -154,7 +173,7 @@ func (ar *AudioReader) cleanup() {
 
 	// Release the interface
 	ifnum := ar.asi.InterfaceNumber()
-	ar.handle.ReleaseInterface(ifnum)
+	ar.asi.handle.ReleaseInterface(ifnum)
 }
 
 func (ar *AudioReader) GetAudioFormat() AudioFormat {
@@ -26,7 +26,7 @@ type AudioFormat struct {
 
 type AudioStreamingInterface struct {
 	bcdADC      uint16 // Audio Device Class version
-	handle      *usb.DeviceHandle
+	handle      Transport
 	iface       *usb.Interface
 	Descriptors []descriptors.AudioStreamingDescriptor
 
@@ -50,7 +50,7 @@ type AudioStreamingInterface struct {
 	FormatSpecific []byte
 }
 
-func NewAudioStreamingInterface(handle *usb.DeviceHandle, iface *usb.Interface, bcdADC uint16) *AudioStreamingInterface {
+func NewAudioStreamingInterface(handle Transport, iface *usb.Interface, bcdADC uint16) *AudioStreamingInterface {
 	return &AudioStreamingInterface{handle: handle, iface: iface, bcdADC: bcdADC}
 }
 
@@ -110,7 +110,7 @@ func (asi *AudioStreamingInterface) ParseDescriptor(block []byte) error {
 						}
 					} else {
 						// Discrete sampling frequencies
-						for i := uint8(0); i < samplingFreqType && 8+i*3 <= uint8(len(block)); i++ {
+						for i := 0; i < int(samplingFreqType) && 8+i*3+3 <= len(block); i++ {
 							freq := uint32(block[8+i*3]) |
 								(uint32(block[9+i*3]) << 8) |
 								(uint32(block[10+i*3]) << 16)
@@ -138,7 +138,7 @@ func (asi *AudioStreamingInterface) ParseDescriptor(block []byte) error {
 							}
 						}
 					} else {
-						for i := uint8(0); i < samplingFreqType && 9+i*3 <= uint8(len(block)); i++ {
+						for i := 0; i < int(samplingFreqType) && 9+i*3+3 <= len(block); i++ {
 							freq := uint32(block[9+i*3]) |
 								(uint32(block[10+i*3]) << 8) |
 								(uint32(block[11+i*3]) << 16)
@@ -166,7 +166,7 @@ func (asi *AudioStreamingInterface) ParseDescriptor(block []byte) error {
 						// For Type III, use the range as-is
 						asi.SamplingFreqs = []uint32{minFreq, maxFreq}
 					} else {
-						for i := uint8(0); i < samplingFreqType && 8+i*3 <= uint8(len(block)); i++ {
+						for i := 0; i < int(samplingFreqType) && 8+i*3+3 <= len(block); i++ {
 							freq := uint32(block[8+i*3]) |
 								(uint32(block[9+i*3]) << 8) |
 								(uint32(block[10+i*3]) << 16)
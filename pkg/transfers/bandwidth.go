@@ -0,0 +1,369 @@
+package transfers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// periodicBandwidth returns the number of bytes per second a bus of the given speed can schedule for
+// periodic (isochronous and interrupt) transfers.
+//
+// USB 2.0 spec, section 5.6.4: no more than 90% of a full-speed frame and 80% of a high-speed
+// microframe can be allocated to periodic transfers. USB 3.2 spec, section 8.12.6.3 similarly
+// reserves 10% of a bus interval for asynchronous traffic.
+func periodicBandwidth(speed usb.Speed) uint64 {
+	switch speed {
+	case usb.SpeedLow:
+		// low-speed devices do not support isochronous endpoints.
+		return 0
+	case usb.SpeedFull:
+		return 1500 * 1000 * 9 / 10 // 1500 bytes per 1ms frame
+	case usb.SpeedSuper:
+		return 500_000_000 * 9 / 10 // 5 Gbps, 8b/10b encoded
+	case usb.SpeedSuperPlus:
+		return 1_212_000_000 * 9 / 10 // 10 Gbps, 128b/132b encoded
+	default:
+		return 7500 * 8000 * 8 / 10 // 7500 bytes per 125us microframe
+	}
+}
+
+// getEndpointMaxPacketSize returns the number of bytes the endpoint can transfer per service interval.
+func getEndpointMaxPacketSize(endpoint usb.Endpoint) uint32 {
+	// For SuperSpeed devices, check companion descriptor
+	if c := endpoint.SSCompanion; c != nil {
+		if c.Attributes&0x80 == 0 {
+			return uint32(c.BytesPerInterval)
+		}
+		// USB 3.2 spec, section 9.6.8: the interval size of a SuperSpeedPlus isochronous endpoint is
+		// in a separate companion descriptor, estimate it from the burst size instead.
+		return uint32(endpoint.MaxPacketSize) * (uint32(c.MaxBurst) + 1) * (uint32(c.Attributes&0x03) + 1)
+	}
+	val := uint32(endpoint.MaxPacketSize & 0x07ff)
+	endpointType := usb.TransferType(endpoint.Attributes & 0x03)
+	if endpointType == usb.TransferTypeIsochronous || endpointType == usb.TransferTypeInterrupt {
+		// USB 2.0 spec, section 9.6.6: bits 12..11 of wMaxPacketSize are the number of additional
+		// transactions per microframe for high-bandwidth endpoints.
+		val *= 1 + uint32((endpoint.MaxPacketSize>>11)&3)
+	}
+	return val
+}
+
+// getEndpointInterval returns the service interval of a periodic endpoint.
+//
+// USB 2.0 spec, section 9.6.6: isochronous endpoints are serviced every 2^(bInterval-1) frames at
+// full speed or microframes at high speed and above.
+func getEndpointInterval(speed usb.Speed, endpoint usb.Endpoint) time.Duration {
+	exp := min(max(int(endpoint.Interval), 1), 16) - 1
+	if speed == usb.SpeedFull || speed == usb.SpeedLow {
+		return time.Millisecond << exp
+	}
+	return 125 * time.Microsecond << exp
+}
+
+type busKey struct {
+	bus   uint8
+	speed usb.Speed
+}
+
+// busBandwidth tracks the periodic bandwidth reserved by every isochronous reader, per bus.
+var busBandwidth = struct {
+	sync.Mutex
+	reserved map[busKey]uint64
+}{reserved: make(map[busKey]uint64)}
+
+// BandwidthPlanner selects isochronous alternate settings against the periodic bandwidth left on a bus.
+type BandwidthPlanner struct {
+	Bus   uint8
+	Speed usb.Speed
+}
+
+// Available returns the periodic bandwidth in bytes per second that is not reserved by other streams.
+func (p BandwidthPlanner) Available() uint64 {
+	busBandwidth.Lock()
+	defer busBandwidth.Unlock()
+	return p.available()
+}
+
+func (p BandwidthPlanner) available() uint64 {
+	total := periodicBandwidth(p.Speed)
+	reserved := busBandwidth.reserved[busKey{p.Bus, p.Speed}]
+	if reserved >= total {
+		return 0
+	}
+	return total - reserved
+}
+
+// AltSettingBandwidth returns the packet size and the bandwidth in bytes per second that the given
+// endpoint of an alternate setting reserves on the bus.
+func (p BandwidthPlanner) AltSettingBandwidth(altsetting *usb.InterfaceAltSetting, endpointAddress uint8) (uint32, uint64, error) {
+	j, err := findAltEndpoint(altsetting.Endpoints, endpointAddress)
+	if err != nil {
+		return 0, 0, err
+	}
+	endpoint := altsetting.Endpoints[j]
+	packetSize := getEndpointMaxPacketSize(endpoint)
+	interval := getEndpointInterval(p.Speed, endpoint)
+	return packetSize, uint64(packetSize) * uint64(time.Second) / uint64(interval), nil
+}
+
+// BandwidthReservation is the bus bandwidth held by an isochronous alternate setting.
+type BandwidthReservation struct {
+	AltSetting     *usb.InterfaceAltSetting
+	PacketSize     uint32
+	BytesPerSecond uint64
+
+	key      busKey
+	released bool
+}
+
+// Release returns the reserved bandwidth to the bus.
+func (r *BandwidthReservation) Release() {
+	busBandwidth.Lock()
+	defer busBandwidth.Unlock()
+	if r.released {
+		return
+	}
+	r.released = true
+	busBandwidth.reserved[r.key] -= r.BytesPerSecond
+	if busBandwidth.reserved[r.key] == 0 {
+		delete(busBandwidth.reserved, r.key)
+	}
+}
+
+// InsufficientBandwidthError is returned when no alternate setting can carry the negotiated payload
+// size within the bandwidth left on the bus.
+type InsufficientBandwidthError struct {
+	// Required is the bandwidth of the smallest alternate setting that carries the payload.
+	Required uint64
+	// Available is the bandwidth left on the bus.
+	Available uint64
+	// Suggestions are modes of the negotiated format that are expected to fit, if known.
+	Suggestions []ModeSuggestion
+}
+
+func (e *InsufficientBandwidthError) Error() string {
+	msg := fmt.Sprintf("insufficient isochronous bandwidth: %d bytes/s required, %d bytes/s available", e.Required, e.Available)
+	if len(e.Suggestions) > 0 {
+		s := e.Suggestions[0]
+		msg += fmt.Sprintf(", try frame %d (%dx%d) at %v", s.FrameIndex, s.Width, s.Height, s.FrameInterval)
+	}
+	return msg
+}
+
+// Reserve picks the alternate setting with the smallest bandwidth whose packet size is at least payloadSize
+// and that fits in the bandwidth left on the bus, and reserves its bandwidth.
+//
+// UVC spec 1.5, section 2.4.3: A typical use of alternate settings is to provide a way to change the bandwidth requirements an active
+// isochronous pipe imposes on the USB.
+func (p BandwidthPlanner) Reserve(iface *usb.Interface, endpointAddress uint8, payloadSize uint32) (*BandwidthReservation, error) {
+	busBandwidth.Lock()
+	defer busBandwidth.Unlock()
+
+	available := p.available()
+
+	var best, largest *BandwidthReservation
+	required, smallest := uint64(0), uint64(0)
+	for i := range iface.AltSettings {
+		altsetting := &iface.AltSettings[i]
+		if altsetting.NumEndpoints == 0 {
+			// UVC spec 1.5, section 2.4.3: All devices that transfer isochronous video data must
+			// incorporate a zero-bandwidth alternate setting for each VideoStreaming interface that has an
+			// isochronous video endpoint, and it must be the default alternate setting (alternate setting zero).
+			//
+			// in other words, if there aren't any endpoints on this alternate setting it's reserved for a zero-bandwidth
+			// alternate setting so we can't use it and should skip it.
+			continue
+		}
+		packetSize, bandwidth, err := p.AltSettingBandwidth(altsetting, endpointAddress)
+		if err != nil {
+			return nil, err
+		}
+		candidate := &BandwidthReservation{AltSetting: altsetting, PacketSize: packetSize, BytesPerSecond: bandwidth}
+		if smallest == 0 || bandwidth < smallest {
+			smallest = bandwidth
+		}
+		if bandwidth <= available && (largest == nil || packetSize > largest.PacketSize) {
+			largest = candidate
+		}
+		if packetSize < payloadSize {
+			continue
+		}
+		if required == 0 || bandwidth < required {
+			required = bandwidth
+		}
+		if bandwidth <= available && (best == nil || bandwidth < best.BytesPerSecond) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		if required != 0 || largest == nil {
+			if required == 0 {
+				required = smallest
+			}
+			return nil, &InsufficientBandwidthError{Required: required, Available: available}
+		}
+		// no alternate setting is large enough for the payload, fall back to the largest one that fits
+		// and let the device split payloads across packets.
+		best = largest
+	}
+	best.key = busKey{p.Bus, p.Speed}
+	busBandwidth.reserved[best.key] += best.BytesPerSecond
+	return best, nil
+}
+
+// bandwidthPlanner returns the planner for the bus the device is attached to.
+func (si *StreamingInterface) bandwidthPlanner() BandwidthPlanner {
+	p := BandwidthPlanner{Speed: usb.SpeedUnknown}
+	if speed, err := si.handle.GetSpeed(); err == nil {
+		p.Speed = speed
+	}
+	if h, ok := usbHandle(si.handle); ok {
+		if dev := h.Device(); dev != nil {
+			p.Bus = dev.Bus
+		}
+	}
+	return p
+}
+
+// maxModeBandwidth returns the largest bandwidth a single alternate setting of this interface can
+// carry, capped to the given number of available bytes per second.
+func (si *StreamingInterface) maxModeBandwidth(endpointAddress uint8, available uint64) uint64 {
+	p := si.bandwidthPlanner()
+	best := uint64(0)
+	for i := range si.iface.AltSettings {
+		if si.iface.AltSettings[i].NumEndpoints == 0 {
+			continue
+		}
+		if _, bw, err := p.AltSettingBandwidth(&si.iface.AltSettings[i], endpointAddress); err == nil && bw <= available {
+			best = max(best, bw)
+		}
+	}
+	return best
+}
+
+// ModeSuggestion is a frame size and interval that is expected to fit in the available bandwidth.
+type ModeSuggestion struct {
+	FormatIndex    uint8
+	FrameIndex     uint8
+	Width, Height  uint16
+	FrameInterval  time.Duration
+	BytesPerSecond uint64
+}
+
+// SuggestModes returns the frames of the negotiated format, each at the shortest supported frame interval
+// whose estimated bandwidth fits in the given number of bytes per second. Slower frame rates at the
+// negotiated frame size are listed first, followed by smaller frame sizes.
+func (si *StreamingInterface) SuggestModes(vpcc *descriptors.VideoProbeCommitControl, bytesPerSecond uint64) []ModeSuggestion {
+	var suggestions []ModeSuggestion
+	var format descriptors.FormatDescriptor
+	for _, desc := range si.Descriptors {
+		switch d := desc.(type) {
+		case descriptors.FormatDescriptor:
+			format = d
+			continue
+		case descriptors.FrameDescriptor:
+			if format == nil || format.Index() != vpcc.FormatIndex {
+				continue
+			}
+			w, h, frameSize, intervals := frameBandwidthInfo(format, d)
+			if frameSize == 0 || len(intervals) == 0 {
+				continue
+			}
+			sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+			for _, interval := range intervals {
+				if interval <= 0 {
+					continue
+				}
+				bw := frameSize * uint64(time.Second) / uint64(interval)
+				if bw <= bytesPerSecond {
+					suggestions = append(suggestions, ModeSuggestion{
+						FormatIndex:    vpcc.FormatIndex,
+						FrameIndex:     d.Index(),
+						Width:          w,
+						Height:         h,
+						FrameInterval:  interval,
+						BytesPerSecond: bw,
+					})
+					break
+				}
+			}
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if (a.FrameIndex == vpcc.FrameIndex) != (b.FrameIndex == vpcc.FrameIndex) {
+			return a.FrameIndex == vpcc.FrameIndex
+		}
+		return uint32(a.Width)*uint32(a.Height) > uint32(b.Width)*uint32(b.Height)
+	})
+	return suggestions
+}
+
+// frameBandwidthInfo returns the dimensions, the estimated size in bytes and the supported intervals
+// of a frame. Continuous intervals are expanded to their minimum and maximum, plus the steps between
+// them if there are not too many.
+func frameBandwidthInfo(format descriptors.FormatDescriptor, frame descriptors.FrameDescriptor) (uint16, uint16, uint64, []time.Duration) {
+	continuous := func(lo, hi, step time.Duration) []time.Duration {
+		if step <= 0 || (hi-lo)/step > 64 {
+			return []time.Duration{lo, hi}
+		}
+		var intervals []time.Duration
+		for i := lo; i <= hi; i += step {
+			intervals = append(intervals, i)
+		}
+		return intervals
+	}
+	// compressed frames are sized by their bit rate at the shortest frame interval.
+	bitRateSize := func(maxBitRate uint32, intervals []time.Duration) uint64 {
+		shortest := time.Duration(0)
+		for _, i := range intervals {
+			if shortest == 0 || (i > 0 && i < shortest) {
+				shortest = i
+			}
+		}
+		return uint64(maxBitRate) / 8 * uint64(shortest) / uint64(time.Second)
+	}
+	switch d := frame.(type) {
+	case *descriptors.UncompressedFrameDescriptor:
+		intervals := append([]time.Duration(nil), d.DiscreteFrameIntervals...)
+		if len(intervals) == 0 {
+			c := d.ContinuousFrameInterval
+			intervals = continuous(c.MinFrameInterval, c.MaxFrameInterval, c.FrameIntervalStep)
+		}
+		size := uint64(d.MaxVideoFrameBufferSize)
+		if f, ok := format.(*descriptors.UncompressedFormatDescriptor); ok {
+			size = uint64(d.Width) * uint64(d.Height) * uint64(f.BitsPerPixel) / 8
+		}
+		return d.Width, d.Height, size, intervals
+	case *descriptors.MJPEGFrameDescriptor:
+		intervals := append([]time.Duration(nil), d.DiscreteFrameIntervals...)
+		if len(intervals) == 0 {
+			c := d.ContinuousFrameInterval
+			intervals = continuous(c.MinFrameInterval, c.MaxFrameInterval, c.FrameIntervalStep)
+		}
+		size := bitRateSize(d.MaxBitRate, intervals)
+		if size == 0 {
+			size = uint64(d.MaxVideoFrameBufferSize)
+		}
+		return d.Width, d.Height, size, intervals
+	case *descriptors.FrameBasedFrameDescriptor:
+		intervals := append([]time.Duration(nil), d.DiscreteFrameIntervals...)
+		if len(intervals) == 0 {
+			c := d.ContinuousFrameInterval
+			intervals = continuous(c.MinFrameInterval, c.MaxFrameInterval, c.FrameIntervalStep)
+		}
+		return d.Width, d.Height, bitRateSize(d.MaxBitRate, intervals), intervals
+	case *descriptors.H264FrameDescriptor:
+		intervals := append([]time.Duration(nil), d.FrameIntervals...)
+		return d.Width, d.Height, bitRateSize(d.MaxBitRate, intervals), intervals
+	case *descriptors.VP8FrameDescriptor:
+		intervals := append([]time.Duration(nil), d.FrameIntervals...)
+		return d.Width, d.Height, bitRateSize(d.MaxBitRate, intervals), intervals
+	}
+	return 0, 0, 0, nil
+}
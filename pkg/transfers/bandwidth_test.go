@@ -0,0 +1,183 @@
+package transfers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/simulator"
+)
+
+func isoEndpoint(maxPacketSize uint16, interval uint8) usb.Endpoint {
+	return usb.Endpoint{
+		EndpointAddr:  0x81,
+		Attributes:    uint8(usb.TransferTypeIsochronous) | 0x04,
+		MaxPacketSize: maxPacketSize,
+		Interval:      interval,
+	}
+}
+
+func isoInterface(endpoints ...usb.Endpoint) *usb.Interface {
+	iface := &usb.Interface{AltSettings: []usb.InterfaceAltSetting{{InterfaceNumber: 1}}}
+	for i, ep := range endpoints {
+		iface.AltSettings = append(iface.AltSettings, usb.InterfaceAltSetting{
+			InterfaceNumber:  1,
+			AlternateSetting: uint8(i + 1),
+			NumEndpoints:     1,
+			Endpoints:        []usb.Endpoint{ep},
+		})
+	}
+	return iface
+}
+
+func TestGetEndpointMaxPacketSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint usb.Endpoint
+		want     uint32
+	}{
+		{"full speed", isoEndpoint(1023, 1), 1023},
+		{"high bandwidth x2", isoEndpoint(0x0800|1024, 1), 2048},
+		{"high bandwidth x3", isoEndpoint(0x1000|1024, 1), 3072},
+		{"high bandwidth x3 odd size", isoEndpoint(0x1000|0x0320, 1), 2400},
+		{"bulk ignores multiplier", usb.Endpoint{Attributes: uint8(usb.TransferTypeBulk), MaxPacketSize: 512}, 512},
+		{"superspeed", usb.Endpoint{
+			Attributes:    uint8(usb.TransferTypeIsochronous),
+			MaxPacketSize: 1024,
+			SSCompanion:   &usb.SuperSpeedEndpointCompanionDescriptor{MaxBurst: 15, Attributes: 2, BytesPerInterval: 49152},
+		}, 49152},
+	}
+	for _, tt := range tests {
+		if got := getEndpointMaxPacketSize(tt.endpoint); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetEndpointInterval(t *testing.T) {
+	if got := getEndpointInterval(usb.SpeedFull, isoEndpoint(1023, 1)); got != time.Millisecond {
+		t.Errorf("full speed interval = %v, want 1ms", got)
+	}
+	if got := getEndpointInterval(usb.SpeedHigh, isoEndpoint(1024, 1)); got != 125*time.Microsecond {
+		t.Errorf("high speed interval = %v, want 125us", got)
+	}
+	if got := getEndpointInterval(usb.SpeedHigh, isoEndpoint(1024, 4)); got != time.Millisecond {
+		t.Errorf("high speed interval = %v, want 1ms", got)
+	}
+}
+
+func TestBandwidthPlanner_SmallestFittingAltSetting(t *testing.T) {
+	p := BandwidthPlanner{Bus: 100, Speed: usb.SpeedHigh}
+	iface := isoInterface(
+		isoEndpoint(512, 1),
+		isoEndpoint(0x1000|1024, 1),
+		isoEndpoint(0x0800|1024, 1),
+	)
+
+	r, err := p.Reserve(iface, 0x81, 2000)
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if r.AltSetting.AlternateSetting != 3 || r.PacketSize != 2048 {
+		t.Errorf("got alt setting %d with packet size %d, want 3 with 2048", r.AltSetting.AlternateSetting, r.PacketSize)
+	}
+	if r.BytesPerSecond != 2048*8000 {
+		t.Errorf("BytesPerSecond = %d, want %d", r.BytesPerSecond, 2048*8000)
+	}
+	if got, want := p.Available(), periodicBandwidth(usb.SpeedHigh)-r.BytesPerSecond; got != want {
+		t.Errorf("Available = %d, want %d", got, want)
+	}
+	r.Release()
+	r.Release()
+	if got, want := p.Available(), periodicBandwidth(usb.SpeedHigh); got != want {
+		t.Errorf("Available after release = %d, want %d", got, want)
+	}
+}
+
+func TestBandwidthPlanner_SharedBus(t *testing.T) {
+	p := BandwidthPlanner{Bus: 101, Speed: usb.SpeedHigh}
+	iface := isoInterface(
+		isoEndpoint(0x0800|1024, 1),
+		isoEndpoint(0x1000|1024, 1),
+	)
+
+	first, err := p.Reserve(iface, 0x81, 3072)
+	if err != nil {
+		t.Fatalf("first Reserve failed: %v", err)
+	}
+	defer first.Release()
+
+	// 3072*8000 bytes/s of the 48 MB/s are taken, a second 3072-byte stream no longer fits.
+	_, err = p.Reserve(iface, 0x81, 3072)
+	var bwErr *InsufficientBandwidthError
+	if !errors.As(err, &bwErr) {
+		t.Fatalf("second Reserve error = %v, want InsufficientBandwidthError", err)
+	}
+	if bwErr.Required != 3072*8000 || bwErr.Available != periodicBandwidth(usb.SpeedHigh)-3072*8000 {
+		t.Errorf("got required %d, available %d", bwErr.Required, bwErr.Available)
+	}
+
+	// a smaller stream still fits next to the first one.
+	second, err := p.Reserve(iface, 0x81, 2048)
+	if err != nil {
+		t.Fatalf("smaller Reserve failed: %v", err)
+	}
+	second.Release()
+
+	// other buses are unaffected.
+	other, err := BandwidthPlanner{Bus: 102, Speed: usb.SpeedHigh}.Reserve(iface, 0x81, 3072)
+	if err != nil {
+		t.Fatalf("Reserve on another bus failed: %v", err)
+	}
+	other.Release()
+}
+
+func TestStreamingInterface_SuggestModes(t *testing.T) {
+	si := &StreamingInterface{Descriptors: []descriptors.StreamingInterface{
+		&descriptors.UncompressedFormatDescriptor{FormatIndex: 1, BitsPerPixel: 16},
+		&descriptors.UncompressedFrameDescriptor{
+			FrameIndex: 1, Width: 1280, Height: 720,
+			DiscreteFrameIntervals: []time.Duration{time.Second / 30, time.Second / 10, time.Second / 5},
+		},
+		&descriptors.UncompressedFrameDescriptor{
+			FrameIndex: 2, Width: 640, Height: 480,
+			DiscreteFrameIntervals: []time.Duration{time.Second / 30, time.Second / 15},
+		},
+	}}
+	vpcc := &descriptors.VideoProbeCommitControl{FormatIndex: 1, FrameIndex: 1, FrameInterval: time.Second / 30}
+
+	// 1280x720 YUY2 is 1843200 bytes per frame, 20 MB/s fits 10 fps at 720p or 30 fps at 480p.
+	got := si.SuggestModes(vpcc, 20_000_000)
+	if len(got) != 2 {
+		t.Fatalf("got %d suggestions, want 2: %+v", len(got), got)
+	}
+	if got[0].FrameIndex != 1 || got[0].FrameInterval != time.Second/10 {
+		t.Errorf("first suggestion = %+v, want frame 1 at 100ms", got[0])
+	}
+	if got[1].FrameIndex != 2 || got[1].FrameInterval != time.Second/30 {
+		t.Errorf("second suggestion = %+v, want frame 2 at 33.3ms", got[1])
+	}
+}
+
+func TestFrameReader_CloseReleasesBandwidth(t *testing.T) {
+	dev := simulator.Webcam()
+	si := webcamStreamingInterface(t, dev)
+	planner := si.bandwidthPlanner()
+	available := planner.Available()
+
+	r, err := si.ClaimFrameReader(1, 1)
+	if err != nil {
+		t.Fatalf("ClaimFrameReader failed: %v", err)
+	}
+	if got := planner.Available(); got != available-simulator.WebcamPacketSize*8000 {
+		t.Errorf("available bandwidth while streaming = %d, want %d", got, available-simulator.WebcamPacketSize*8000)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := planner.Available(); got != available {
+		t.Errorf("available bandwidth after Close = %d, want %d", got, available)
+	}
+}
@@ -3,14 +3,15 @@ package transfers
 import (
 	"fmt"
 	"time"
-
-	usb "github.com/kevmo314/go-usb"
 )
 
+// BulkReader reads payloads from a bulk IN endpoint with one synchronous transfer at a time.
 type BulkReader struct {
-	handle   *usb.DeviceHandle
+	handle   Transport
 	endpoint uint8
 	mtu      uint32
+
+	stats transferCounters
 }
 
 func (si *StreamingInterface) NewBulkReader(endpointAddress uint8, mtu uint32) (*BulkReader, error) {
@@ -18,17 +19,27 @@ func (si *StreamingInterface) NewBulkReader(endpointAddress uint8, mtu uint32) (
 		handle:   si.handle,
 		endpoint: endpointAddress,
 		mtu:      mtu,
+		stats:    transferCounters{start: time.Now()},
 	}, nil
 }
 
 func (r *BulkReader) Read(buf []byte) (int, error) {
 	n, err := r.handle.BulkTransfer(r.endpoint, buf, 5*time.Second)
 	if err != nil {
+		r.stats.transferErrors.Add(1)
 		return 0, fmt.Errorf("bulk_transfer failed: %w", err)
 	}
+	r.stats.transfers.Add(1)
+	r.stats.packet(n)
 	return n, nil
 }
 
+// Stats returns a snapshot of the transfer statistics of the reader. Every transfer carries one
+// payload, so Packets and EmptyPackets count the transfers by whether they carried data.
+func (r *BulkReader) Stats() TransferStats {
+	return r.stats.snapshot()
+}
+
 func (r *BulkReader) Close() error {
 	return nil
 }
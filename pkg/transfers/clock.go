@@ -0,0 +1,159 @@
+package transfers
+
+import (
+	"sync"
+	"time"
+)
+
+// SourceClockReference is the SCR field of a payload header.
+type SourceClockReference struct {
+	// SourceTimeClock is the device clock sampled when the payload was put on the bus.
+	SourceTimeClock uint32
+	// TokenCounter holds the USB SOF counter in bits 10..0, sampled at the same time.
+	TokenCounter uint16
+}
+
+// SOF returns the 11-bit USB frame number of the SCR sample.
+func (scr SourceClockReference) SOF() uint16 {
+	return scr.TokenCounter & 0x7ff
+}
+
+const (
+	// clockModelWindow is the number of samples the clock model regresses over.
+	clockModelWindow = 32
+	// clockModelBucket is the fraction of a second of device time each sample is picked from.
+	clockModelBucket = 10
+)
+
+type clockSample struct {
+	stc  int64 // unwrapped device clock ticks
+	host int64 // host monotonic nanoseconds since the model was created
+}
+
+// ClockModel maps the device source clock to host time. It collects pairs of SCR samples and the host
+// time at which they were received and fits a line through them, the slope of which corrects for the
+// drift between the device crystal and the host clock.
+//
+// This follows the clock recovery of the Linux uvcvideo driver, except that host side USB SOF
+// timestamps are not available to user space, so the model uses payload arrival times instead. As
+// transfer latency only ever delays arrival, the sample with the lowest latency is kept for every
+// tenth of a second, and the regression runs over the last few seconds of those.
+type ClockModel struct {
+	mu sync.Mutex
+
+	frequency uint32
+	base      time.Time
+
+	samples [clockModelWindow]clockSample
+	n, next int
+
+	bucket    clockSample // lowest latency sample of the current bucket
+	hasBucket bool
+
+	last    int64 // last unwrapped STC
+	hasLast bool
+
+	slope, intercept float64 // host ns = slope * stc + intercept
+}
+
+// NewClockModel creates a clock model for a device clock running at frequency Hz.
+func NewClockModel(frequency uint32) *ClockModel {
+	return &ClockModel{frequency: frequency, base: time.Now()}
+}
+
+// Frequency returns the nominal device clock frequency in Hz.
+func (c *ClockModel) Frequency() uint32 {
+	return c.frequency
+}
+
+// unwrap extends a 32-bit device clock value to 64 bits, picking the value closest to the last sample.
+func (c *ClockModel) unwrap(stc uint32) int64 {
+	if !c.hasLast {
+		return int64(stc)
+	}
+	return c.last + int64(int32(stc-uint32(c.last)))
+}
+
+// AddSample records that the device clock read stc at host time t.
+func (c *ClockModel) AddSample(stc uint32, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frequency == 0 {
+		return
+	}
+
+	x := c.unwrap(stc)
+	c.last, c.hasLast = x, true
+	s := clockSample{stc: x, host: int64(t.Sub(c.base))}
+	switch {
+	case !c.hasBucket:
+		c.bucket, c.hasBucket = s, true
+	case s.stc-c.bucket.stc >= int64(c.frequency/clockModelBucket) || s.stc < c.bucket.stc:
+		c.samples[c.next] = c.bucket
+		c.next = (c.next + 1) % clockModelWindow
+		c.n = min(c.n+1, clockModelWindow)
+		c.bucket = s
+	case c.latency(s) < c.latency(c.bucket):
+		c.bucket = s
+	}
+	c.fit()
+}
+
+// latency returns the host arrival time of a sample relative to the nominal device clock, which is
+// its transfer latency up to a constant.
+func (c *ClockModel) latency(s clockSample) float64 {
+	return float64(s.host) - float64(s.stc)*float64(time.Second)/float64(c.frequency)
+}
+
+// fit updates the slope and intercept by least squares over the collected samples, falling back to
+// the nominal frequency until the samples span enough time.
+func (c *ClockModel) fit() {
+	nominal := float64(time.Second) / float64(c.frequency)
+	latest := c.bucket
+
+	// work relative to the latest sample to keep the sums well conditioned.
+	var sx, sy, sxx, sxy float64
+	for i := range c.n + 1 {
+		s := c.bucket
+		if i < c.n {
+			s = c.samples[i]
+		}
+		x := float64(s.stc - latest.stc)
+		y := float64(s.host - latest.host)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	n := float64(c.n + 1)
+	slope := nominal
+	if d := n*sxx - sx*sx; c.n > 1 && d > 0 {
+		slope = (n*sxy - sx*sy) / d
+		// reject fits that are far off the nominal frequency, e.g. right after a clock discontinuity.
+		if slope < nominal*0.9 || slope > nominal*1.1 {
+			slope = nominal
+		}
+	}
+	mx, my := sx/n, sy/n
+	c.slope = slope
+	c.intercept = float64(latest.host) + my - slope*(float64(latest.stc)+mx)
+}
+
+// DeviceToHost converts a device clock value, such as a PTS, to host time. It returns false until the
+// model has received a sample.
+func (c *ClockModel) DeviceToHost(stc uint32) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.hasBucket {
+		return time.Time{}, false
+	}
+	ns := c.slope*float64(c.unwrap(stc)) + c.intercept
+	return c.base.Add(time.Duration(ns)), true
+}
+
+// Reset discards the collected samples, for instance after the stream is restarted.
+func (c *ClockModel) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n, c.next, c.hasLast, c.hasBucket = 0, 0, false, false
+}
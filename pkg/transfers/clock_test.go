@@ -0,0 +1,100 @@
+package transfers
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+func TestClockModel_DriftCorrection(t *testing.T) {
+	const freq = 48_000_000
+	c := NewClockModel(freq)
+	base := c.base
+
+	// the device crystal runs 200 ppm fast and samples arrive with up to 1ms of latency jitter.
+	rng := rand.New(rand.NewSource(1))
+	drift := 1.0002
+	stcAt := func(host time.Duration) uint32 {
+		return uint32(uint64(float64(host.Seconds())*freq*drift) + 0xfff00000)
+	}
+	for i := range 2000 {
+		host := time.Duration(i) * 2 * time.Millisecond
+		jitter := time.Duration(rng.Int63n(int64(time.Millisecond)))
+		c.AddSample(stcAt(host), base.Add(host+jitter))
+	}
+
+	// one second ahead the nominal frequency would be off by 200us, the model should do better.
+	host := 4*time.Second + time.Second
+	got, ok := c.DeviceToHost(stcAt(host))
+	if !ok {
+		t.Fatal("DeviceToHost not ready")
+	}
+	want := base.Add(host)
+	if d := got.Sub(want); d < -50*time.Microsecond || d > 50*time.Microsecond {
+		t.Errorf("DeviceToHost off by %v", d)
+	}
+}
+
+func TestClockModel_Wraparound(t *testing.T) {
+	c := NewClockModel(1_000_000)
+	base := c.base
+
+	c.AddSample(0xffffff00, base)
+	c.AddSample(0x00000100, base.Add(512*time.Microsecond))
+
+	got, ok := c.DeviceToHost(0x00000200)
+	if !ok {
+		t.Fatal("DeviceToHost not ready")
+	}
+	if d := got.Sub(base); d != 768*time.Microsecond {
+		t.Errorf("DeviceToHost = base+%v, want base+768µs", d)
+	}
+	// values slightly before the last sample map to the past, not 71 minutes in the future.
+	got, _ = c.DeviceToHost(0xffffff80)
+	if d := got.Sub(base); d != 128*time.Microsecond {
+		t.Errorf("DeviceToHost = base+%v, want base+128µs", d)
+	}
+}
+
+func TestClockModel_UnknownFrequency(t *testing.T) {
+	c := NewClockModel(0)
+	c.AddSample(1000, time.Now())
+	if _, ok := c.DeviceToHost(1000); ok {
+		t.Error("DeviceToHost succeeded without a clock frequency")
+	}
+}
+
+func TestFrameReader_Timestamps(t *testing.T) {
+	frames := testFrames(2, 3000)
+	packets := framePayloads(frames, 1024)
+	for i, pkt := range packets {
+		// PTS per frame, SCR advancing per payload.
+		binary.LittleEndian.PutUint32(pkt[2:6], uint32(1000+(i/3)*33000))
+		binary.LittleEndian.PutUint32(pkt[6:10], uint32(2000+i*11000))
+		binary.LittleEndian.PutUint16(pkt[10:12], uint16(i))
+	}
+	vpcc := &descriptors.VideoProbeCommitControl{MaxVideoFrameSize: 3000, MaxPayloadTransferSize: 1024, ClockFrequency: 1_000_000}
+	r := newFrameReader(vpcc, &packetReader{packets: packets}, 1024)
+
+	before := time.Now()
+	f, err := r.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	defer f.Release()
+	if !f.HasPTS() || f.PTS != 1000 {
+		t.Errorf("PTS = %d (%v), want 1000", f.PTS, f.HasPTS())
+	}
+	if !f.HasSCR() || f.SCR.SourceTimeClock != 24000 || f.SCR.SOF() != 2 {
+		t.Errorf("SCR = %+v, want the last payload's", f.SCR)
+	}
+	if f.CaptureTime.Before(before) || f.CaptureTime.After(time.Now()) {
+		t.Errorf("CaptureTime %v out of range", f.CaptureTime)
+	}
+	if f.PresentationTime.IsZero() {
+		t.Error("PresentationTime not set")
+	}
+}
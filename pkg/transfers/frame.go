@@ -1,90 +1,224 @@
 package transfers
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	usb "github.com/kevmo314/go-usb"
 	"github.com/kevmo314/go-uvc/pkg/descriptors"
 )
 
 type FrameReader struct {
-	handle *usb.DeviceHandle
-	iface  *usb.Interface
-	vpcc   *descriptors.VideoProbeCommitControl
-	pr     io.Reader
+	si   *StreamingInterface
+	vpcc *descriptors.VideoProbeCommitControl
+	pr   io.Reader
 
-	fid         *bool
-	buffer      []byte
-	size, patch int
+	isochronous bool
+	ctrlClaimed bool
+	bandwidth   *BandwidthReservation
+
+	fid    bool
+	hasFID bool
+
+	// scratch holds payloads that do not fit in the tail of the frame being assembled, as well as the
+	// first payload of the next frame, which is only detected once it has been read.
+	scratch   []byte
+	pending   int
+	pendingAt time.Time
+
+	frameSize int
+	into      Frame
+	clock     *ClockModel
+
+	policy       IntegrityPolicy
+	expectedSize int
+	sawEOF       bool
+	lost         uint64
+	// invalidPayloads counts the payloads with a malformed header since the previous frame.
+	invalidPayloads int
+	corrupt         atomic.Uint64
+
+	stats    frameCounters
+	streamCh atomic.Pointer[chan *Frame]
+
+	mu   sync.Mutex
+	free []*Frame
+
+	streaming atomic.Bool
+	dropped   atomic.Uint64
+	streamErr atomic.Pointer[error]
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
+// Frame is a video frame assembled into a contiguous buffer. Frames returned by ReadFrame are pooled
+// by their reader, call Release once the frame is no longer used so its buffer can be reused.
 type Frame struct {
-	Payloads      []*Payload
-	index, offset int
+	// Payloads are the headers of the payloads that make up the frame. Their data is a view into the
+	// frame's buffer and is only valid until the frame is released. Header extensions are parsed into
+	// Metadata and cleared.
+	Payloads []*Payload
+
+	// PTS is the presentation time stamp of the frame in device clock ticks, set if HasPTS.
+	PTS uint32
+	// SCR is the last source clock reference received during the frame, set if HasSCR.
+	SCR SourceClockReference
+	// CaptureTime is the host monotonic time at which the first payload of the frame was received.
+	CaptureTime time.Time
+	// PresentationTime is the PTS converted to host time by the reader's clock model. It is zero if
+	// the frame has no PTS or the device clock frequency is unknown.
+	PresentationTime time.Time
+	// Metadata holds the metadata items the device sent in extended payload headers, or the metadata
+	// paired from a metadata streaming interface by a MetadataPairer. It is nil if there is none.
+	Metadata *Metadata
+	// Integrity reports whether the frame was received completely.
+	Integrity FrameIntegrity
+	// Layer is the simulcast stream and layer of frames read by a SimulcastReader.
+	Layer descriptors.LayerID
+
+	hasPTS, hasSCR bool
+
+	buf    []byte
+	offset int
+	reader *FrameReader
+}
+
+// HasPTS reports whether the device sent a presentation time stamp for the frame.
+func (f *Frame) HasPTS() bool {
+	return f.hasPTS
+}
+
+// HasSCR reports whether the device sent a source clock reference during the frame.
+func (f *Frame) HasSCR() bool {
+	return f.hasSCR
+}
+
+// Bytes returns the frame data. The returned slice is only valid until the frame is released.
+func (f *Frame) Bytes() []byte {
+	if f.buf == nil && len(f.Payloads) > 0 {
+		// the frame was constructed by hand, concatenate its payloads.
+		for _, p := range f.Payloads {
+			f.buf = append(f.buf, p.Data...)
+		}
+	}
+	return f.buf
+}
+
+// Len returns the size of the frame data in bytes.
+func (f *Frame) Len() int {
+	return len(f.Bytes())
 }
 
 // Read reads the payload datas concatenated together.
 func (f *Frame) Read(buf []byte) (int, error) {
-	total := 0
-	for _, p := range f.Payloads {
-		total += len(p.Data)
+	data := f.Bytes()
+	if f.offset >= len(data) {
+		return 0, io.EOF
 	}
-	n := 0
-	for n < len(buf) {
-		if f.index == len(f.Payloads) {
-			if n == 0 {
-				return 0, io.EOF
-			}
-			return n, nil
-		}
-		p := f.Payloads[f.index]
-		m := copy(buf[n:], p.Data[f.offset:])
-		f.offset += m
-		n += m
-		if f.offset >= len(p.Data) {
-			f.index++
-			f.offset = 0
+	n := copy(buf, data[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+// Release returns the frame to its reader's pool. The frame and its data must not be used afterwards.
+func (f *Frame) Release() {
+	r := f.reader
+	if r == nil {
+		return
+	}
+	f.reader = nil
+	r.mu.Lock()
+	r.free = append(r.free, f)
+	r.mu.Unlock()
+}
+
+// reset clears the frame for reuse, keeping its buffer and payload headers allocated.
+func (f *Frame) reset(buf []byte) {
+	f.buf = buf[:0]
+	f.offset = 0
+	f.Payloads = f.Payloads[:0]
+	f.PTS, f.SCR, f.hasPTS, f.hasSCR = 0, SourceClockReference{}, false, false
+	f.CaptureTime, f.PresentationTime = time.Time{}, time.Time{}
+	f.Metadata = nil
+	f.Integrity = FrameIntegrity{}
+	f.Layer = 0
+}
+
+// nextPayload appends a payload header to the frame, reusing a previously allocated one if possible.
+func (f *Frame) nextPayload() *Payload {
+	n := len(f.Payloads)
+	if n < cap(f.Payloads) {
+		f.Payloads = f.Payloads[:n+1]
+		if f.Payloads[n] == nil {
+			f.Payloads[n] = &Payload{}
 		}
+	} else {
+		f.Payloads = append(f.Payloads, &Payload{})
 	}
-	return n, nil
+	p := f.Payloads[n]
+	*p = Payload{}
+	return p
+}
+
+// grow reallocates the frame buffer to fit at least n more bytes and repoints the payload data at it.
+func (f *Frame) grow(n int) {
+	buf := make([]byte, len(f.buf), max(2*cap(f.buf), len(f.buf)+n))
+	copy(buf, f.buf)
+	offset := 0
+	for _, p := range f.Payloads {
+		size := len(p.Data)
+		p.Data = buf[offset : offset+size]
+		offset += size
+	}
+	f.buf = buf
 }
 
 func (si *StreamingInterface) NewFrameReader(endpointAddress uint8, vpcc *descriptors.VideoProbeCommitControl) (*FrameReader, error) {
 	useIsochronous := len(si.iface.AltSettings) > 1
 	if useIsochronous {
-		altsetting, packetSize, err := findIsochronousAltSetting(si.iface, endpointAddress, vpcc.MaxPayloadTransferSize)
+		bw, err := si.bandwidthPlanner().Reserve(si.iface, endpointAddress, vpcc.MaxPayloadTransferSize)
 		if err != nil {
+			var bwErr *InsufficientBandwidthError
+			if errors.As(err, &bwErr) {
+				bwErr.Suggestions = si.SuggestModes(vpcc, si.maxModeBandwidth(endpointAddress, bwErr.Available))
+			}
 			return nil, err
 		}
+		altsetting, packetSize := bw.AltSetting, bw.PacketSize
 		if err := si.handle.SetInterfaceAltSetting(altsetting.InterfaceNumber, altsetting.AlternateSetting); err != nil {
+			bw.Release()
 			return nil, fmt.Errorf("set_interface_alt_setting failed: %w", err)
 		}
 		packets := min((vpcc.MaxVideoFrameSize+packetSize-1)/packetSize, 128)
 		ir, err := si.NewIsochronousReader(endpointAddress, packets, packetSize)
 		if err != nil {
+			si.handle.SetInterfaceAltSetting(altsetting.InterfaceNumber, 0)
+			bw.Release()
 			return nil, err
 		}
-		return &FrameReader{
-			handle: si.handle,
-			iface:  si.iface,
-			vpcc:   vpcc,
-			pr:     ir,
-			buffer: make([]byte, vpcc.MaxVideoFrameSize),
-		}, nil
+		r := newFrameReader(vpcc, ir, max(vpcc.MaxPayloadTransferSize, packetSize))
+		r.si = si
+		r.clock = NewClockModel(si.clockFrequency(vpcc))
+		r.expectedSize = si.fixedFrameSize(vpcc)
+		r.isochronous = true
+		r.bandwidth = bw
+		return r, nil
 	} else {
 		// Use async bulk reader for better throughput with queued URBs
 		br, err := si.NewAsyncBulkReader(endpointAddress, vpcc.MaxPayloadTransferSize)
 		if err != nil {
 			return nil, err
 		}
-		return &FrameReader{
-			handle: si.handle,
-			iface:  si.iface,
-			vpcc:   vpcc,
-			pr:     br,
-			buffer: make([]byte, vpcc.MaxVideoFrameSize),
-		}, nil
+		r := newFrameReader(vpcc, br, vpcc.MaxPayloadTransferSize)
+		r.si = si
+		r.clock = NewClockModel(si.clockFrequency(vpcc))
+		r.expectedSize = si.fixedFrameSize(vpcc)
+		return r, nil
 	}
 }
 
@@ -97,106 +231,216 @@ func findAltEndpoint(endpoints []usb.Endpoint, endpointAddress uint8) (int, erro
 	return 0, fmt.Errorf("endpoint not found")
 }
 
-func getEndpointMaxPacketSize(endpoint usb.Endpoint) uint32 {
-	// For SuperSpeed devices, check companion descriptor
-	if endpoint.SSCompanion != nil {
-		return uint32(endpoint.SSCompanion.BytesPerInterval)
-	}
-	val := uint32(endpoint.MaxPacketSize & 0x07ff)
-	endpointType := usb.TransferType(endpoint.Attributes & 0x03)
-	if endpointType == usb.TransferTypeIsochronous || endpointType == usb.TransferTypeInterrupt {
-		val *= 1 + ((val >> 1) & 3)
+// newFrameReader creates a frame reader that reads payloads of at most payloadSize bytes from pr.
+func newFrameReader(vpcc *descriptors.VideoProbeCommitControl, pr io.Reader, payloadSize uint32) *FrameReader {
+	return &FrameReader{
+		vpcc:      vpcc,
+		pr:        pr,
+		scratch:   make([]byte, payloadSize),
+		frameSize: int(vpcc.MaxVideoFrameSize) + int(payloadSize),
+		clock:     NewClockModel(vpcc.ClockFrequency),
+		stats:     frameCounters{start: time.Now()},
 	}
-	return val
 }
 
-// findIsochronousAltSetting sets the isochronous alternate setting for the given interface and endpoint address to the
-// first alternate setting that has a max packet size of at least mtu.
+// ProbeCommit returns the streaming parameters negotiated with the device.
+func (r *FrameReader) ProbeCommit() *descriptors.VideoProbeCommitControl {
+	return r.vpcc
+}
+
+// Clock returns the model that maps the device clock of this stream to host time.
+func (r *FrameReader) Clock() *ClockModel {
+	return r.clock
+}
+
+// ReadFrame reads individual payloads from the USB device and returns a constructed frame.
 //
-// UVC spec 1.5, section 2.4.3: A typical use of alternate settings is to provide a way to change the bandwidth requirements an active
-// isochronous pipe imposes on the USB.
-func findIsochronousAltSetting(iface *usb.Interface, endpointAddress uint8, payloadSize uint32) (*usb.InterfaceAltSetting, uint32, error) {
-	for i, altsetting := range iface.AltSettings {
-		if altsetting.NumEndpoints == 0 {
-			// UVC spec 1.5, section 2.4.3: All devices that transfer isochronous video data must
-			// incorporate a zero-bandwidth alternate setting for each VideoStreaming interface that has an
-			// isochronous video endpoint, and it must be the default alternate setting (alternate setting zero).
-			//
-			// in other words, if there aren't any endpoints on this alternate setting it's reserved for a zero-bandwidth
-			// alternate setting so we can't use it and should skip it.
-			continue
-		}
+// The frame is assembled into a buffer owned by the frame, so it is not overwritten by later reads.
+// Call Release on the frame once it is no longer needed to avoid allocating a new buffer per frame.
+func (r *FrameReader) ReadFrame() (*Frame, error) {
+	f := r.newFrame()
+	if err := r.assemble(f, true); err != nil {
+		f.Release()
+		return nil, err
+	}
+	return f, nil
+}
 
-		j, err := findAltEndpoint(altsetting.Endpoints, endpointAddress)
-		if err != nil {
-			return nil, 0, err
-		}
+// newFrame takes a frame from the pool, or allocates one if the pool is empty.
+func (r *FrameReader) newFrame() *Frame {
+	r.mu.Lock()
+	var f *Frame
+	if n := len(r.free); n > 0 {
+		f = r.free[n-1]
+		r.free = r.free[:n-1]
+	}
+	r.mu.Unlock()
+	if f == nil {
+		f = &Frame{buf: make([]byte, 0, r.frameSize)}
+	}
+	f.reset(f.buf)
+	f.reader = r
+	return f
+}
 
-		packetSize := getEndpointMaxPacketSize(altsetting.Endpoints[j])
-		if packetSize >= payloadSize || i == len(iface.AltSettings)-1 {
-			return &altsetting, packetSize, nil
+// ReadFrameInto reads the next frame directly into dst and returns the number of bytes written. If
+// the frame does not fit in dst, the rest of the frame is dropped and io.ErrShortBuffer is returned.
+func (r *FrameReader) ReadFrameInto(dst []byte) (int, error) {
+	r.into.reset(dst)
+	if err := r.assemble(&r.into, false); err != nil {
+		return 0, err
+	}
+	return len(r.into.buf), nil
+}
+
+// assemble reads payloads into f until the end of a frame that passes the integrity policy. If grow is
+// false and the frame does not fit in the capacity of f's buffer, io.ErrShortBuffer is returned instead
+// of reallocating it.
+func (r *FrameReader) assemble(f *Frame, grow bool) error {
+	for {
+		keep, err := r.assembleOne(f, grow)
+		if err != nil || keep {
+			return err
 		}
+		f.reset(f.buf)
 	}
-	return nil, 0, fmt.Errorf("no suitable isochronous alternate setting found for payload size %d", payloadSize)
 }
 
-// ReadFrame reads individual payloads from the USB device and returns a constructed frame.
-func (r *FrameReader) ReadFrame() (*Frame, error) {
-	var f *Frame
+// assembleOne reads payloads into f until the end of a frame and reports whether the frame should be
+// delivered.
+func (r *FrameReader) assembleOne(f *Frame, grow bool) (bool, error) {
+	started := false
 	for {
-		p := &Payload{}
-		n := 0
-		if r.patch == 0 {
-			m, err := r.pr.Read(r.buffer[r.size:])
+		var pkt []byte
+		inPlace := false
+		at := r.pendingAt
+		if r.pending > 0 {
+			pkt = r.scratch[:r.pending]
+			r.pending = 0
+		} else if tail := f.buf[len(f.buf):cap(f.buf)]; len(tail) >= len(r.scratch) {
+			// read straight into the frame buffer, the header is squashed by the data below. The read
+			// is limited to the scratch size so that the payload can be kept for the next frame.
+			n, err := r.pr.Read(tail[:len(r.scratch)])
 			if err != nil {
-				return nil, err
-			}
-			n = m
-			if err := p.UnmarshalBinary(r.buffer[r.size : r.size+n]); err != nil {
-				return nil, err
+				return false, err
 			}
+			pkt = tail[:n]
+			inPlace = true
+			at = time.Now()
 		} else {
-			if copy(r.buffer, r.buffer[r.size:r.size+r.patch]) != r.patch {
-				return nil, fmt.Errorf("copy failed")
-			}
-			r.size = r.patch
-			n = r.patch
-			r.patch = 0
-			if err := p.UnmarshalBinary(r.buffer[:r.size]); err != nil {
-				return nil, err
+			n, err := r.pr.Read(r.scratch)
+			if err != nil {
+				return false, err
 			}
+			pkt = r.scratch[:n]
+			at = time.Now()
+		}
+		if len(pkt) == 0 {
+			continue
+		}
+
+		p := f.nextPayload()
+		if err := p.UnmarshalBinary(pkt); err != nil {
+			// a corrupt payload is reported in the integrity of the frame, the integrity policy decides
+			// whether the frame is delivered.
+			f.Payloads = f.Payloads[:len(f.Payloads)-1]
+			r.invalidPayloads++
+			continue
 		}
-		if r.fid == nil || p.FrameID() != *r.fid {
+		if !r.hasFID || p.FrameID() != r.fid {
 			// frame id bit flipped, this is a new frame
-			if f != nil {
-				// set the patch to the size of the payload to indicate that
-				// the next payload should read from the existing buffer.
-				r.patch = n
-				return f, nil
+			if started {
+				// keep the payload around for the next frame.
+				f.Payloads = f.Payloads[:len(f.Payloads)-1]
+				if inPlace {
+					copy(r.scratch, pkt)
+				}
+				r.pending, r.pendingAt = len(pkt), at
+				return r.finish(f, false, grow), nil
 			}
-			f = &Frame{}
-			fid := p.FrameID()
-			r.fid = &fid
+			r.fid = p.FrameID()
+			r.hasFID = true
+			started = true
+			f.CaptureTime = at
 		}
-		if f == nil {
+		if !started {
 			// if there's no frame, ignore this payload.
 			// this can happen if the device sends frames after an end of frame bit.
+			f.Payloads = f.Payloads[:0]
 			continue
 		}
-		r.size += n
-		f.Payloads = append(f.Payloads, p)
+
+		if p.Error() {
+			f.Integrity.ErrorFlagged = true
+		}
+		if p.HasPTS() && !f.hasPTS {
+			// UVC spec 1.5, section 2.4.3.3: the PTS is the same in all payloads of a frame.
+			f.PTS, f.hasPTS = p.PTS, true
+		}
+		if p.HasSCR() {
+			f.SCR, f.hasSCR = p.SCR, true
+			r.clock.AddSample(p.SCR.SourceTimeClock, at)
+		}
+		if len(p.Extension) > 0 {
+			// the extension is overwritten by the data below, so parse it first. Headers that do not
+			// hold metadata items do not invalidate the payload data, only the metadata.
+			if f.Metadata == nil {
+				f.Metadata = &Metadata{}
+			}
+			if err := f.Metadata.append(p.Extension); err != nil {
+				f.Integrity.InvalidMetadata = true
+			}
+		}
+		p.Extension = nil
+
+		data := p.Data
+		if len(f.buf)+len(data) > cap(f.buf) {
+			if !grow {
+				f.Payloads = f.Payloads[:0]
+				return false, io.ErrShortBuffer
+			}
+			f.grow(len(data))
+		}
+		start := len(f.buf)
+		f.buf = f.buf[:start+len(data)]
+		copy(f.buf[start:], data)
+		p.Data = f.buf[start:]
 		if p.EndOfFrame() {
-			// reset the buffer
-			r.size = 0
-			return f, nil
+			return r.finish(f, true, grow), nil
 		}
 	}
 }
 
+// finish timestamps a completed frame and checks its integrity. eof is set if the frame ended with an
+// end of frame bit. It reports whether the frame should be delivered.
+func (r *FrameReader) finish(f *Frame, eof, grow bool) bool {
+	if f.hasPTS {
+		if t, ok := r.clock.DeviceToHost(f.PTS); ok {
+			f.PresentationTime = t
+		}
+	}
+	r.stats.frame(len(f.buf), f.CaptureTime)
+	return r.checkIntegrity(f, eof, grow)
+}
+
+// Close stops the transfers of this reader and releases its interfaces. The control interface stays
+// claimed as long as other readers on the same device are still open.
 func (r *FrameReader) Close() error {
-	if len(r.iface.AltSettings) == 0 {
+	r.closeOnce.Do(func() { r.closeErr = r.close() })
+	return r.closeErr
+}
+
+func (r *FrameReader) close() error {
+	if c, ok := r.pr.(io.Closer); ok {
+		c.Close()
+	}
+	if r.si == nil || len(r.si.iface.AltSettings) == 0 {
 		return nil
 	}
-	ifnum := r.iface.AltSettings[0].InterfaceNumber
-	return r.handle.ReleaseInterface(ifnum)
+	if r.isochronous {
+		// return to the zero-bandwidth alternate setting so other streams can use the bus bandwidth.
+		r.si.handle.SetInterfaceAltSetting(r.si.InterfaceNumber(), 0)
+		r.bandwidth.Release()
+	}
+	return r.si.releaseInterfaces(r.ctrlClaimed)
 }
@@ -0,0 +1,221 @@
+package transfers
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// packetReader replays a fixed sequence of payloads, looping forever. Like the isochronous reader,
+// it fails with io.ErrShortBuffer when a payload does not fit.
+type packetReader struct {
+	packets [][]byte
+	i       int
+}
+
+func (r *packetReader) Read(buf []byte) (int, error) {
+	pkt := r.packets[r.i%len(r.packets)]
+	if len(buf) < len(pkt) {
+		return 0, io.ErrShortBuffer
+	}
+	r.i++
+	return copy(buf, pkt), nil
+}
+
+// framePayloads splits each frame into payloads carrying at most payloadSize bytes including a 12 byte
+// header with PTS and SCR, toggling the frame id between frames and setting the end of frame bit.
+func framePayloads(frames [][]byte, payloadSize int) [][]byte {
+	var packets [][]byte
+	for i, frame := range frames {
+		for off := 0; off < len(frame); off += payloadSize - 12 {
+			end := min(off+payloadSize-12, len(frame))
+			bitmask := uint8(0b10001100) | uint8(i&1)
+			if end == len(frame) {
+				bitmask |= 0b10
+			}
+			pkt := append([]byte{12, bitmask, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, frame[off:end]...)
+			packets = append(packets, pkt)
+		}
+	}
+	return packets
+}
+
+func testFrames(n, size int) [][]byte {
+	frames := make([][]byte, n)
+	for i := range frames {
+		frames[i] = make([]byte, size)
+		for j := range frames[i] {
+			frames[i][j] = byte(i*7 + j)
+		}
+	}
+	return frames
+}
+
+func newTestFrameReader(packets [][]byte, maxFrameSize, payloadSize uint32) *FrameReader {
+	vpcc := &descriptors.VideoProbeCommitControl{MaxVideoFrameSize: maxFrameSize, MaxPayloadTransferSize: payloadSize}
+	return newFrameReader(vpcc, &packetReader{packets: packets}, payloadSize)
+}
+
+func TestFrameReader_ReadFrame(t *testing.T) {
+	frames := testFrames(3, 10000)
+	r := newTestFrameReader(framePayloads(frames, 1024), 10000, 1024)
+
+	var held []*Frame
+	for i, want := range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !bytes.Equal(f.Bytes(), want) {
+			t.Errorf("frame %d data mismatch", i)
+		}
+		if len(f.Payloads) != 10 {
+			t.Errorf("frame %d has %d payloads, want 10", i, len(f.Payloads))
+		}
+		held = append(held, f)
+	}
+
+	// frames must not be overwritten by later reads.
+	for i, f := range held {
+		if !bytes.Equal(f.Bytes(), frames[i]) {
+			t.Errorf("frame %d was overwritten", i)
+		}
+		got, err := io.ReadAll(f)
+		if err != nil || !bytes.Equal(got, frames[i]) {
+			t.Errorf("frame %d Read mismatch: %v", i, err)
+		}
+		f.Release()
+	}
+}
+
+func TestFrameReader_FrameIDWithoutEndOfFrame(t *testing.T) {
+	frames := testFrames(3, 3000)
+	packets := framePayloads(frames, 1024)
+	for _, pkt := range packets {
+		pkt[1] &^= 0b10
+	}
+	r := newTestFrameReader(packets, 3000, 1024)
+
+	// without end of frame bits, frames are delimited by the frame id toggling.
+	for i := range 2 {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !bytes.Equal(f.Bytes(), frames[i]) {
+			t.Errorf("frame %d data mismatch", i)
+		}
+		f.Release()
+	}
+}
+
+func TestFrameReader_GrowsOversizedFrames(t *testing.T) {
+	frames := testFrames(2, 5000)
+	r := newTestFrameReader(framePayloads(frames, 512), 1000, 512)
+
+	for i, want := range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !bytes.Equal(f.Bytes(), want) {
+			t.Errorf("frame %d data mismatch", i)
+		}
+		off := 0
+		for _, p := range f.Payloads {
+			if !bytes.Equal(p.Data, want[off:off+len(p.Data)]) {
+				t.Errorf("frame %d payload at %d does not match frame data", i, off)
+			}
+			off += len(p.Data)
+		}
+		f.Release()
+	}
+}
+
+func TestFrameReader_ReadFrameInto(t *testing.T) {
+	frames := testFrames(3, 4000)
+	r := newTestFrameReader(framePayloads(frames, 1024), 4000, 1024)
+
+	dst := make([]byte, 4000)
+	n, err := r.ReadFrameInto(dst)
+	if err != nil {
+		t.Fatalf("ReadFrameInto failed: %v", err)
+	}
+	if !bytes.Equal(dst[:n], frames[0]) {
+		t.Error("frame 0 data mismatch")
+	}
+
+	if _, err := r.ReadFrameInto(make([]byte, 100)); err != io.ErrShortBuffer {
+		t.Errorf("ReadFrameInto with a small buffer error = %v, want io.ErrShortBuffer", err)
+	}
+
+	// the truncated frame is dropped and the reader resynchronizes on the next one.
+	n, err = r.ReadFrameInto(dst)
+	if err != nil {
+		t.Fatalf("ReadFrameInto failed: %v", err)
+	}
+	if !bytes.Equal(dst[:n], frames[2]) {
+		t.Error("frame 2 data mismatch")
+	}
+}
+
+func TestFrameReader_ZeroAllocs(t *testing.T) {
+	frames := testFrames(2, 50000)
+	r := newTestFrameReader(framePayloads(frames, 3072), 50000, 3072)
+	dst := make([]byte, 50000)
+
+	// warm up the frame pool.
+	for range 4 {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Release()
+	}
+	if allocs := testing.AllocsPerRun(100, func() {
+		f, _ := r.ReadFrame()
+		f.Release()
+	}); allocs != 0 {
+		t.Errorf("ReadFrame allocated %.1f times per frame, want 0", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() {
+		r.ReadFrameInto(dst)
+	}); allocs != 0 {
+		t.Errorf("ReadFrameInto allocated %.1f times per frame, want 0", allocs)
+	}
+}
+
+func benchmarkFrameReader(b *testing.B, frameSize, payloadSize int) {
+	frames := testFrames(2, frameSize)
+	r := newTestFrameReader(framePayloads(frames, payloadSize), uint32(frameSize), uint32(payloadSize))
+	// warm up the frame pool.
+	for range 2 {
+		f, err := r.ReadFrame()
+		if err != nil {
+			b.Fatal(err)
+		}
+		f.Release()
+	}
+	b.SetBytes(int64(frameSize))
+	b.ReportAllocs()
+	for b.Loop() {
+		f, err := r.ReadFrame()
+		if err != nil {
+			b.Fatal(err)
+		}
+		f.Release()
+	}
+}
+
+// BenchmarkFrameReader_MJPEG4KIsochronous reads ~1.5 MB MJPEG frames from 3072 byte high-bandwidth packets.
+func BenchmarkFrameReader_MJPEG4KIsochronous(b *testing.B) {
+	benchmarkFrameReader(b, 1_500_000, 3072)
+}
+
+// BenchmarkFrameReader_YUY21080pBulk reads 1920x1080 YUY2 frames from 512 KB bulk payloads. At 60 fps
+// this is ~250 MB/s.
+func BenchmarkFrameReader_YUY21080pBulk(b *testing.B) {
+	benchmarkFrameReader(b, 1920*1080*2, 512*1024)
+}
@@ -0,0 +1,325 @@
+package transfers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// payloadHeaderLength is the size of the payload headers written by FrameWriter, which carry a PTS but
+// no SCR. Frames without a PTS are sent with the two byte header of bHeaderLength and bmHeaderInfo.
+const payloadHeaderLength = 6
+
+// FrameWriter sends video frames to a UVC output terminal, such as a USB display, over the OUT
+// endpoint of an output streaming interface. It is the counterpart of FrameReader: every frame is
+// split into payloads with a header carrying the frame id, end of frame bit and PTS.
+type FrameWriter struct {
+	si   *StreamingInterface
+	vpcc *descriptors.VideoProbeCommitControl
+	pw   io.Writer
+
+	isochronous bool
+	ctrlClaimed bool
+	bandwidth   *BandwidthReservation
+
+	payloadSize int
+	clockFreq   uint32
+	start       time.Time
+
+	fid bool
+	buf []byte
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// ClaimFrameWriter negotiates the given format and frame with the device and opens a frame writer on
+// this output streaming interface.
+func (si *StreamingInterface) ClaimFrameWriter(formatIndex, frameIndex uint8) (*FrameWriter, error) {
+	return si.ClaimFrameWriterWithProbe(descriptors.NewProbe(formatIndex, frameIndex))
+}
+
+// ClaimFrameWriterWithProbe is ClaimFrameWriter with the fields set on probe requested from the device.
+func (si *StreamingInterface) ClaimFrameWriterWithProbe(probe *descriptors.ProbeBuilder) (*FrameWriter, error) {
+	ctrlClaimed, err := si.claimInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	fw, err := si.negotiateFrameWriter(probe)
+	if err != nil {
+		si.releaseInterfaces(ctrlClaimed)
+		return nil, err
+	}
+	fw.ctrlClaimed = ctrlClaimed
+	return fw, nil
+}
+
+func (si *StreamingInterface) negotiateFrameWriter(probe *descriptors.ProbeBuilder) (*FrameWriter, error) {
+	vpcc, err := si.negotiate(probe)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := si.OutputHeaderForFormat(probe.FormatIndex())
+	if err != nil {
+		return nil, err
+	}
+
+	return si.NewFrameWriter(output.EndpointAddress, vpcc)
+}
+
+// NewFrameWriter creates a frame writer for the given OUT endpoint with parameters that were already
+// negotiated.
+func (si *StreamingInterface) NewFrameWriter(endpointAddress uint8, vpcc *descriptors.VideoProbeCommitControl) (*FrameWriter, error) {
+	if len(si.iface.AltSettings) > 1 {
+		bw, err := si.bandwidthPlanner().Reserve(si.iface, endpointAddress, vpcc.MaxPayloadTransferSize)
+		if err != nil {
+			return nil, err
+		}
+		altsetting, packetSize := bw.AltSetting, bw.PacketSize
+		if err := si.handle.SetInterfaceAltSetting(altsetting.InterfaceNumber, altsetting.AlternateSetting); err != nil {
+			bw.Release()
+			return nil, fmt.Errorf("set_interface_alt_setting failed: %w", err)
+		}
+		// an isochronous payload must fit in the packet of one service interval.
+		payloadSize := packetSize
+		if vpcc.MaxPayloadTransferSize != 0 {
+			payloadSize = min(payloadSize, vpcc.MaxPayloadTransferSize)
+		}
+		packets := uint32(1)
+		if vpcc.MaxVideoFrameSize > 0 {
+			packets = min((vpcc.MaxVideoFrameSize+payloadSize-1)/payloadSize, 128)
+		}
+		w := newFrameWriter(vpcc, si.NewIsochronousWriter(endpointAddress, packets, payloadSize), int(payloadSize))
+		w.si = si
+		w.clockFreq = si.clockFrequency(vpcc)
+		w.isochronous = true
+		w.bandwidth = bw
+		return w, nil
+	}
+	bw, err := si.NewBulkWriter(endpointAddress)
+	if err != nil {
+		return nil, err
+	}
+	w := newFrameWriter(vpcc, bw, int(vpcc.MaxPayloadTransferSize))
+	w.si = si
+	w.clockFreq = si.clockFrequency(vpcc)
+	return w, nil
+}
+
+// newFrameWriter creates a frame writer that writes payloads of at most payloadSize bytes to pw.
+func newFrameWriter(vpcc *descriptors.VideoProbeCommitControl, pw io.Writer, payloadSize int) *FrameWriter {
+	if payloadSize <= payloadHeaderLength {
+		// the device did not limit the payload size, send one payload per frame.
+		payloadSize = int(vpcc.MaxVideoFrameSize) + payloadHeaderLength
+	}
+	return &FrameWriter{
+		vpcc:        vpcc,
+		pw:          pw,
+		payloadSize: payloadSize,
+		clockFreq:   vpcc.ClockFrequency,
+		start:       time.Now(),
+		buf:         make([]byte, 0, payloadSize),
+	}
+}
+
+// ProbeCommit returns the streaming parameters negotiated with the device.
+func (w *FrameWriter) ProbeCommit() *descriptors.VideoProbeCommitControl {
+	return w.vpcc
+}
+
+// WriteFrame sends a frame, stamped with the time since the writer was opened in device clock ticks.
+// Frames are sent without a PTS if the device clock frequency is unknown.
+func (w *FrameWriter) WriteFrame(data []byte) error {
+	if w.clockFreq == 0 {
+		return w.writeFrame(data, 0, false)
+	}
+	ticks := uint64(time.Since(w.start)) * uint64(w.clockFreq) / uint64(time.Second)
+	return w.writeFrame(data, uint32(ticks), true)
+}
+
+// WriteFrameWithPTS sends a frame with the given presentation time stamp in device clock ticks.
+func (w *FrameWriter) WriteFrameWithPTS(data []byte, pts uint32) error {
+	return w.writeFrame(data, pts, true)
+}
+
+func (w *FrameWriter) writeFrame(data []byte, pts uint32, hasPTS bool) error {
+	if limit := w.vpcc.MaxVideoFrameSize; limit != 0 && len(data) > int(limit) {
+		return fmt.Errorf("frame of %d bytes exceeds the negotiated maximum of %d bytes", len(data), limit)
+	}
+	// UVC spec 1.5, section 2.4.3.3: the frame id toggles at the start of every frame and the last
+	// payload of a frame carries the end of frame bit.
+	w.fid = !w.fid
+	bitmask := uint8(0b10000000)
+	if w.fid {
+		bitmask |= 0b00000001
+	}
+	headerLength := 2
+	if hasPTS {
+		bitmask |= 0b00000100
+		headerLength = payloadHeaderLength
+	}
+	chunk := w.payloadSize - headerLength
+	for {
+		n := min(chunk, len(data))
+		b := bitmask
+		if n == len(data) {
+			b |= 0b00000010
+		}
+		w.buf = append(w.buf[:0], uint8(headerLength), b)
+		if hasPTS {
+			w.buf = binary.LittleEndian.AppendUint32(w.buf, pts)
+		}
+		w.buf = append(w.buf, data[:n]...)
+		if _, err := w.pw.Write(w.buf); err != nil {
+			return err
+		}
+		data = data[n:]
+		if len(data) == 0 {
+			// writers that batch payloads send the end of the frame right away.
+			if f, ok := w.pw.(interface{ Flush() error }); ok {
+				return f.Flush()
+			}
+			return nil
+		}
+	}
+}
+
+// Close stops the transfers of this writer and releases its interfaces.
+func (w *FrameWriter) Close() error {
+	w.closeOnce.Do(func() { w.closeErr = w.close() })
+	return w.closeErr
+}
+
+func (w *FrameWriter) close() error {
+	if c, ok := w.pw.(io.Closer); ok {
+		c.Close()
+	}
+	if w.si == nil || len(w.si.iface.AltSettings) == 0 {
+		return nil
+	}
+	if w.isochronous {
+		w.si.handle.SetInterfaceAltSetting(w.si.InterfaceNumber(), 0)
+		w.bandwidth.Release()
+	}
+	return w.si.releaseInterfaces(w.ctrlClaimed)
+}
+
+// BulkWriter writes payloads to a bulk OUT endpoint, one transfer per payload.
+type BulkWriter struct {
+	handle        Transport
+	endpoint      uint8
+	maxPacketSize int
+}
+
+func (si *StreamingInterface) NewBulkWriter(endpointAddress uint8) (*BulkWriter, error) {
+	for _, altsetting := range si.iface.AltSettings {
+		for _, ep := range altsetting.Endpoints {
+			if ep.EndpointAddr == endpointAddress {
+				return &BulkWriter{
+					handle:        si.handle,
+					endpoint:      endpointAddress,
+					maxPacketSize: int(getEndpointMaxPacketSize(ep)),
+				}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("endpoint %#02x not found", endpointAddress)
+}
+
+// Write sends one payload. The device detects the end of a bulk payload by a short packet, so a
+// payload that fills its last packet is followed by a zero length packet.
+func (w *BulkWriter) Write(p []byte) (int, error) {
+	n, err := w.handle.BulkTransfer(w.endpoint, p, 5*time.Second)
+	if err != nil {
+		return n, fmt.Errorf("bulk_transfer failed: %w", err)
+	}
+	if w.maxPacketSize > 0 && len(p)%w.maxPacketSize == 0 {
+		if _, err := w.handle.BulkTransferWithOptions(w.endpoint, nil, 5*time.Second, true); err != nil {
+			return n, fmt.Errorf("bulk_transfer zero length packet failed: %w", err)
+		}
+	}
+	return n, nil
+}
+
+func (w *BulkWriter) Close() error {
+	return nil
+}
+
+// IsochronousWriter writes payloads to an isochronous OUT endpoint, one packet per payload. Payloads
+// are batched into transfers of several packets, so that a transfer covers several service intervals
+// instead of one. A batch is sent when it is full, after a payload shorter than a packet, which is
+// always the last of its transfer, and on Flush.
+type IsochronousWriter struct {
+	handle     Transport
+	endpoint   uint8
+	packets    int
+	packetSize int
+
+	batch []byte
+	n     int
+	stats transferCounters
+}
+
+func (si *StreamingInterface) NewIsochronousWriter(endpointAddress uint8, packets, packetSize uint32) *IsochronousWriter {
+	return &IsochronousWriter{
+		handle:     si.handle,
+		endpoint:   endpointAddress,
+		packets:    max(int(packets), 1),
+		packetSize: int(packetSize),
+		batch:      make([]byte, 0, max(packets, 1)*packetSize),
+		stats:      transferCounters{start: time.Now()},
+	}
+}
+
+// Write queues one payload in the next packet of the batch, sending the batch if it is complete.
+func (w *IsochronousWriter) Write(p []byte) (int, error) {
+	if len(p) > w.packetSize {
+		return 0, fmt.Errorf("payload of %d bytes exceeds the packet size of %d bytes", len(p), w.packetSize)
+	}
+	w.batch = append(w.batch, p...)
+	w.n++
+	if len(p) < w.packetSize || w.n == w.packets {
+		if err := w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends the queued payloads in one transfer.
+func (w *IsochronousWriter) Flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	batch, n := w.batch, w.n
+	w.batch, w.n = w.batch[:0], 0
+	results, err := w.handle.IsochronousTransfer(w.endpoint, batch, n, w.packetSize, 5*time.Second)
+	if err != nil {
+		w.stats.transferErrors.Add(1)
+		return fmt.Errorf("isochronous transfer failed: %w", err)
+	}
+	w.stats.transfers.Add(1)
+	for i, r := range results {
+		if r.Status != 0 {
+			w.stats.packetError(r.Status)
+			return fmt.Errorf("isochronous packet %d failed with status %d", i, r.Status)
+		}
+		w.stats.packet(min(w.packetSize, len(batch)-i*w.packetSize))
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the transfer statistics of the writer.
+func (w *IsochronousWriter) Stats() TransferStats {
+	return w.stats.snapshot()
+}
+
+// Close sends the queued payloads.
+func (w *IsochronousWriter) Close() error {
+	return w.Flush()
+}
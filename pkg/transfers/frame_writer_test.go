@@ -0,0 +1,159 @@
+package transfers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// payloadRecorder records the payloads written to it.
+type payloadRecorder struct {
+	packets [][]byte
+}
+
+func (r *payloadRecorder) Write(p []byte) (int, error) {
+	r.packets = append(r.packets, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestFrameWriter(t *testing.T) {
+	vpcc := &descriptors.VideoProbeCommitControl{MaxVideoFrameSize: 3000, MaxPayloadTransferSize: 1024}
+	rec := &payloadRecorder{}
+	w := newFrameWriter(vpcc, rec, 1024)
+
+	frames := testFrames(3, 3000)
+	for i, f := range frames {
+		if err := w.WriteFrameWithPTS(f, uint32(1000*i)); err != nil {
+			t.Fatalf("WriteFrame %d failed: %v", i, err)
+		}
+	}
+	if len(rec.packets) != 9 {
+		t.Fatalf("wrote %d payloads, want 9", len(rec.packets))
+	}
+	for _, pkt := range rec.packets {
+		if len(pkt) > 1024 {
+			t.Errorf("payload of %d bytes exceeds the payload size", len(pkt))
+		}
+	}
+
+	// the payloads read back as the same frames.
+	r := newTestFrameReader(rec.packets, 3000, 1024)
+	for i, want := range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !bytes.Equal(f.Bytes(), want) {
+			t.Errorf("frame %d mismatch", i)
+		}
+		if !f.HasPTS() || f.PTS != uint32(1000*i) || !f.Integrity.Complete() {
+			t.Errorf("frame %d PTS = %d (%v), integrity %+v", i, f.PTS, f.HasPTS(), f.Integrity)
+		}
+		f.Release()
+	}
+
+	if err := w.WriteFrame(make([]byte, 3001)); err == nil {
+		t.Error("WriteFrame accepted a frame larger than the maximum frame size")
+	}
+}
+
+// isoRecorder is a transport that records the isochronous OUT transfers sent to it.
+type isoRecorder struct {
+	Transport
+	transfers [][][]byte
+}
+
+func (r *isoRecorder) IsochronousTransfer(endpoint uint8, data []byte, numPackets int, packetSize int, timeout time.Duration) ([]usb.IsoPacketResult, error) {
+	var packets [][]byte
+	results := make([]usb.IsoPacketResult, numPackets)
+	for i := range results {
+		p := data[min(i*packetSize, len(data)):min((i+1)*packetSize, len(data))]
+		packets = append(packets, append([]byte(nil), p...))
+		results[i] = usb.IsoPacketResult{Length: packetSize, ActualLength: len(p)}
+	}
+	r.transfers = append(r.transfers, packets)
+	return results, nil
+}
+
+func TestFrameWriter_PayloadHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		clockFreq    uint32
+		headerLength int
+	}{
+		{"WithoutPTS", 0, 2},
+		{"WithPTS", 48000000, 6},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vpcc := &descriptors.VideoProbeCommitControl{MaxVideoFrameSize: 3000, MaxPayloadTransferSize: 1024, ClockFrequency: tc.clockFreq}
+			rec := &payloadRecorder{}
+			w := newFrameWriter(vpcc, rec, 1024)
+			if err := w.WriteFrame(make([]byte, 3000)); err != nil {
+				t.Fatalf("WriteFrame failed: %v", err)
+			}
+
+			chunk := 1024 - tc.headerLength
+			if want := (3000 + chunk - 1) / chunk; len(rec.packets) != want {
+				t.Fatalf("wrote %d payloads, want %d", len(rec.packets), want)
+			}
+			for i, pkt := range rec.packets {
+				p := &Payload{}
+				if err := p.UnmarshalBinary(pkt); err != nil {
+					t.Fatalf("payload %d: %v", i, err)
+				}
+				if int(pkt[0]) != tc.headerLength || len(p.Extension) != 0 || p.HasPTS() != (tc.clockFreq != 0) {
+					t.Errorf("payload %d header = %x, extension %x", i, pkt[:pkt[0]], p.Extension)
+				}
+				if last := i == len(rec.packets)-1; !last && len(p.Data) != chunk {
+					t.Errorf("payload %d carries %d bytes, want %d", i, len(p.Data), chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestIsochronousWriter(t *testing.T) {
+	vpcc := &descriptors.VideoProbeCommitControl{MaxVideoFrameSize: 3000, MaxPayloadTransferSize: 256}
+	tr := &isoRecorder{}
+	si := &StreamingInterface{handle: tr}
+	w := newFrameWriter(vpcc, si.NewIsochronousWriter(0x01, 4, 256), 256)
+
+	// 3000 bytes fill 12 payloads of 250 bytes, the last one is sent by the flush at the end of the
+	// frame. 2010 bytes end with a short payload, which ends its transfer.
+	frames := append(testFrames(2, 3000), testFrames(1, 2010)...)
+	for i, f := range frames {
+		if err := w.WriteFrameWithPTS(f, uint32(i)); err != nil {
+			t.Fatalf("WriteFrame %d failed: %v", i, err)
+		}
+	}
+	var packets [][]byte
+	for _, tr := range tr.transfers {
+		if len(tr) > 4 {
+			t.Errorf("transfer of %d packets, want at most 4", len(tr))
+		}
+		for i, p := range tr[:len(tr)-1] {
+			if len(p) != 256 {
+				t.Errorf("packet %d of %d bytes before the end of its transfer", i, len(p))
+			}
+		}
+		packets = append(packets, tr...)
+	}
+	if len(tr.transfers) != 3+3+3 || len(packets) != 12+12+9 {
+		t.Fatalf("sent %d payloads in %d transfers", len(packets), len(tr.transfers))
+	}
+
+	r := newTestFrameReader(packets, 3000, 256)
+	for i, want := range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !bytes.Equal(f.Bytes(), want) || !f.Integrity.Complete() {
+			t.Errorf("frame %d mismatch, integrity %+v", i, f.Integrity)
+		}
+		f.Release()
+	}
+}
@@ -0,0 +1,237 @@
+package transfers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// payloadQueue replays a sequence of payloads once, then returns io.EOF.
+type payloadQueue struct {
+	packets [][]byte
+}
+
+func (q *payloadQueue) Read(buf []byte) (int, error) {
+	if len(q.packets) == 0 {
+		return 0, io.EOF
+	}
+	pkt := q.packets[0]
+	if len(buf) < len(pkt) {
+		return 0, io.ErrShortBuffer
+	}
+	q.packets = q.packets[1:]
+	return copy(buf, pkt), nil
+}
+
+// splitPayloads splits fuzz input into payloads, each prefixed by its length.
+func splitPayloads(data []byte) [][]byte {
+	var packets [][]byte
+	for len(data) > 0 {
+		n := min(int(data[0]), len(data)-1)
+		packets = append(packets, data[1:1+n])
+		data = data[1+n:]
+	}
+	return packets
+}
+
+// joinPayloads is the inverse of splitPayloads, for payloads shorter than 256 bytes.
+func joinPayloads(packets [][]byte) []byte {
+	var data []byte
+	for _, pkt := range packets {
+		data = append(data, byte(len(pkt)))
+		data = append(data, pkt...)
+	}
+	return data
+}
+
+func fuzzPayloadSeeds() [][]byte {
+	seeds := [][]byte{
+		joinPayloads(framePayloads(testFrames(2, 300), 128)),
+		joinPayloads(simulcastPayloads(map[descriptors.LayerID][][]byte{0: testFrames(2, 100), 1: testFrames(2, 100)}, []descriptors.LayerID{0, 1}, 64)),
+	}
+	item := metadataItem(MetadataIDFrameIllumination, []byte{1, 0, 0, 0})
+	var packets [][]byte
+	for _, pkt := range framePayloads(testFrames(2, 300), 128) {
+		ext := append([]byte{byte(12 + len(item))}, pkt[1:12]...)
+		ext = append(ext, item...)
+		packets = append(packets, append(ext, pkt[12:]...))
+	}
+	return append(seeds, joinPayloads(packets))
+}
+
+func FuzzPayloadUnmarshalBinary(f *testing.F) {
+	for _, pkt := range framePayloads(testFrames(1, 100), 64) {
+		f.Add(pkt)
+	}
+	f.Add([]byte{})
+	f.Add([]byte{2})
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		p := &Payload{}
+		if err := p.UnmarshalBinary(buf); err != nil {
+			return
+		}
+		if n := len(p.Extension) + len(p.Data); n > len(buf) {
+			t.Fatalf("payload of %d bytes holds %d bytes", len(buf), n)
+		}
+	})
+}
+
+func FuzzParseMetadata(f *testing.F) {
+	f.Add(metadataItem(MetadataIDFrameIllumination, []byte{1, 0, 0, 0}))
+	f.Add(metadataItem(MetadataIDCaptureStats, make([]byte, 72)))
+	f.Add(metadataItem(MetadataIDUsbVideoHeader, []byte{12, 0x8c, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}))
+	f.Add(metadataItem(0x80000000, []byte{20, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0, 50, 0, 0, 0}))
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		m, err := ParseMetadata(buf)
+		if err != nil {
+			return
+		}
+		m.FaceRects(0x80000000)
+	})
+}
+
+func FuzzFrameReader(f *testing.F) {
+	for _, seed := range fuzzPayloadSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		vpcc := &descriptors.VideoProbeCommitControl{MaxVideoFrameSize: 300, MaxPayloadTransferSize: 255}
+		r := newFrameReader(vpcc, &payloadQueue{packets: splitPayloads(data)}, 255)
+		for {
+			fr, err := r.ReadFrame()
+			if err != nil {
+				break
+			}
+			fr.Release()
+		}
+	})
+}
+
+func FuzzUnitReader(f *testing.F) {
+	for _, seed := range fuzzPayloadSeeds() {
+		f.Add(uint8(UnitRows), seed)
+		f.Add(uint8(UnitSlices), seed)
+	}
+	f.Fuzz(func(t *testing.T, mode uint8, data []byte) {
+		vpcc := &descriptors.VideoProbeCommitControl{MaxVideoFrameSize: 300, MaxPayloadTransferSize: 255}
+		r := newUnitReader(newFrameReader(vpcc, &payloadQueue{packets: splitPayloads(data)}, 255), UnitMode(mode%3), 16)
+		for {
+			if _, err := r.ReadUnit(); err != nil {
+				break
+			}
+		}
+	})
+}
+
+func FuzzSimulcastReader(f *testing.F) {
+	for _, seed := range fuzzPayloadSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		vpcc := &descriptors.VideoProbeCommitControl{MaxVideoFrameSize: 300, MaxPayloadTransferSize: 255}
+		r := newSimulcastReader(newFrameReader(vpcc, &payloadQueue{packets: splitPayloads(data)}, 255))
+		for {
+			fr, err := r.ReadFrame()
+			if err != nil {
+				break
+			}
+			fr.Release()
+		}
+	})
+}
+
+func fuzzProbeCommit() *descriptors.VideoProbeCommitControl {
+	return &descriptors.VideoProbeCommitControl{FormatIndex: 1, FrameIndex: 1, MaxVideoFrameSize: 300, MaxPayloadTransferSize: 128}
+}
+
+// fuzzRecording returns a recording of an uncompressed stream committed with vpcc holding the
+// payloads.
+func fuzzRecording(tb testing.TB, vpcc *descriptors.VideoProbeCommitControl, packets [][]byte) []byte {
+	probe, err := vpcc.MarshalBinary()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var extra []byte
+	for _, d := range []interface{ MarshalBinary() ([]byte, error) }{
+		&descriptors.UncompressedFormatDescriptor{FormatIndex: 1, NumFrameDescriptors: 1, BitsPerPixel: 16, DefaultFrameIndex: 1},
+		&descriptors.UncompressedFrameDescriptor{FrameIndex: 1, Width: 10, Height: 15, MaxVideoFrameBufferSize: 300, DiscreteFrameIntervals: []time.Duration{33 * time.Millisecond}},
+	} {
+		buf, err := d.MarshalBinary()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		extra = append(extra, buf...)
+	}
+
+	// a configuration with only video streaming interface 1.
+	iface := []byte{9, 0x04, 1, 0, 0, 0x0e, 0x02, 0, 0}
+	config := []byte{9, 0x02}
+	config = binary.LittleEndian.AppendUint16(config, uint16(9+len(iface)+len(extra)))
+	config = append(config, 1, 1, 0, 0x80, 50)
+	config = append(append(config, iface...), extra...)
+
+	rec := append([]byte(recordingMagic), recordingVersion)
+	rec = append(rec, make([]byte, 18)...) // device descriptor
+	rec = append(rec, 1, 0x10, 0x01)       // interface, bcdUVC
+	rec = binary.LittleEndian.AppendUint32(rec, 48000000)
+	rec = binary.LittleEndian.AppendUint64(rec, 0)
+	rec = binary.AppendUvarint(rec, uint64(len(probe)))
+	rec = append(rec, probe...)
+	rec = binary.AppendUvarint(rec, uint64(len(config)))
+	rec = append(rec, config...)
+	for _, pkt := range packets {
+		rec = binary.AppendUvarint(rec, 1000)
+		rec = binary.AppendUvarint(rec, 0)
+		rec = binary.AppendUvarint(rec, uint64(len(pkt)))
+		rec = append(rec, pkt...)
+	}
+	return rec
+}
+
+func FuzzReadRecording(f *testing.F) {
+	f.Add(fuzzRecording(f, fuzzProbeCommit(), framePayloads(testFrames(2, 300), 128)))
+	f.Add([]byte(recordingMagic))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rec, err := ReadRecording(bytes.NewReader(data), parseRecordedConfig)
+		if err != nil {
+			return
+		}
+		rec.Mode()
+		r := rec.NewFrameReader(false)
+		for {
+			fr, err := r.ReadFrame()
+			if err != nil {
+				break
+			}
+			fr.Release()
+		}
+	})
+}
+
+func FuzzAudioStreamingParseDescriptor(f *testing.F) {
+	f.Add([]byte{7, 0x24, 0x01, 1, 1, 1, 0})
+	f.Add([]byte{14, 0x24, 0x02, 0x01, 2, 2, 16, 2, 0x44, 0xac, 0, 0x80, 0xbb, 0})
+	f.Add([]byte{14, 0x24, 0x02, 0x01, 2, 2, 16, 0, 0x40, 0x1f, 0, 0x80, 0xbb, 0})
+	f.Add([]byte{15, 0x24, 0x02, 0x02, 0x80, 0x01, 0x00, 0x04, 1, 0x80, 0xbb, 0, 0, 0, 0})
+	f.Add([]byte{11, 0x24, 0x02, 0x03, 2, 2, 16, 1, 0x80, 0xbb, 0})
+	f.Fuzz(func(t *testing.T, block []byte) {
+		asi := &AudioStreamingInterface{}
+		asi.ParseDescriptor(block)
+	})
+}
+
+func FuzzMIDIStreamingParseDescriptor(f *testing.F) {
+	f.Add([]byte{7, 0x24, MS_HEADER, 0, 1, 7, 0})
+	f.Add([]byte{6, 0x24, MIDI_IN_JACK, 1, 1, 0})
+	f.Add([]byte{9, 0x24, MIDI_OUT_JACK, 1, 2, 1, 1, 1, 0})
+	f.Add([]byte{5, 0x25, 0x01, 1, 1})
+	f.Fuzz(func(t *testing.T, block []byte) {
+		msi := &MIDIStreamingInterface{}
+		msi.ParseDescriptor(block)
+		msi.ParseMIDIEndpoint(block)
+	})
+}
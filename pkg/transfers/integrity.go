@@ -0,0 +1,176 @@
+package transfers
+
+import (
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// FrameIntegrity reports how well a frame was received.
+type FrameIntegrity struct {
+	// EndOfFrame is set if the frame was terminated by a payload with the end of frame bit, as opposed
+	// to the frame id toggling.
+	EndOfFrame bool
+	// MissingEndOfFrame is set if the frame ended on a frame id toggle although the device marks the
+	// end of its frames, meaning the last payloads of the frame were lost.
+	MissingEndOfFrame bool
+	// ErrorFlagged is set if any payload of the frame had the error bit set. UVC spec 1.5, section
+	// 2.4.3.3: the cause can be read from the stream error code control.
+	ErrorFlagged bool
+	// LostPackets is the number of isochronous packets that completed with an error status since the
+	// previous frame.
+	LostPackets int
+	// InvalidPayloads is the number of payloads with a malformed header received since the previous
+	// frame. Their data is discarded, as it can not be told which frame it belongs to.
+	InvalidPayloads int
+	// BytesReceived is the size of the frame data.
+	BytesReceived int
+	// BytesExpected is the exact size of a frame for formats with a fixed frame size, such as
+	// uncompressed formats, or zero if the size varies.
+	BytesExpected int
+	// MaxBytes is the negotiated dwMaxVideoFrameSize, or zero if unknown.
+	MaxBytes int
+	// Repaired is set if the frame was padded or trimmed to BytesExpected by the IntegrityRepair policy.
+	Repaired bool
+	// InvalidMetadata is set if the metadata the device sent for the frame, in extended payload headers
+	// or on a paired metadata interface, could not be parsed. The frame data is unaffected and Metadata
+	// keeps the items parsed before the error.
+	InvalidMetadata bool
+}
+
+// Truncated reports whether the frame is smaller than its expected size.
+func (fi FrameIntegrity) Truncated() bool {
+	return fi.BytesReceived < fi.BytesExpected
+}
+
+// Oversized reports whether the frame is larger than its expected or maximum size.
+func (fi FrameIntegrity) Oversized() bool {
+	if fi.BytesExpected > 0 {
+		return fi.BytesReceived > fi.BytesExpected
+	}
+	return fi.MaxBytes > 0 && fi.BytesReceived > fi.MaxBytes
+}
+
+// Complete reports whether the frame was received without any detected errors.
+func (fi FrameIntegrity) Complete() bool {
+	return !fi.MissingEndOfFrame && !fi.ErrorFlagged && fi.LostPackets == 0 && fi.InvalidPayloads == 0 && !fi.Truncated() && !fi.Oversized()
+}
+
+// IntegrityPolicy selects what a FrameReader does with frames that are not complete.
+type IntegrityPolicy int
+
+const (
+	// IntegrityDeliver returns incomplete frames as they were received. This is the default.
+	IntegrityDeliver IntegrityPolicy = iota
+	// IntegrityDrop discards incomplete frames and reads the next one.
+	IntegrityDrop
+	// IntegrityRepair zero-fills truncated frames and trims oversized frames to their expected size
+	// for formats with a fixed frame size, and delivers other incomplete frames as they were received.
+	IntegrityRepair
+)
+
+func (p IntegrityPolicy) String() string {
+	switch p {
+	case IntegrityDeliver:
+		return "Deliver"
+	case IntegrityDrop:
+		return "Drop"
+	case IntegrityRepair:
+		return "Repair"
+	default:
+		return "Unknown"
+	}
+}
+
+// packetLossCounter is implemented by payload readers that can detect lost packets.
+type packetLossCounter interface {
+	LostPackets() uint64
+}
+
+// SetIntegrityPolicy sets the policy for incomplete frames. It must not be called concurrently with
+// ReadFrame.
+func (r *FrameReader) SetIntegrityPolicy(p IntegrityPolicy) {
+	r.policy = p
+}
+
+// CorruptFrames returns the number of incomplete frames received, whether or not they were delivered.
+func (r *FrameReader) CorruptFrames() uint64 {
+	return r.corrupt.Load()
+}
+
+// checkIntegrity fills in the integrity report of a finished frame and applies the integrity policy.
+// It returns false if the frame should be dropped. Frames are only repaired beyond the capacity of
+// their buffer if grow is set.
+func (r *FrameReader) checkIntegrity(f *Frame, eof, grow bool) bool {
+	fi := &f.Integrity
+	fi.EndOfFrame = eof
+	if eof {
+		r.sawEOF = true
+	} else {
+		fi.MissingEndOfFrame = r.sawEOF
+	}
+	if lc, ok := r.pr.(packetLossCounter); ok {
+		lost := lc.LostPackets()
+		fi.LostPackets = int(lost - r.lost)
+		r.lost = lost
+	}
+	fi.InvalidPayloads, r.invalidPayloads = r.invalidPayloads, 0
+	fi.BytesReceived = len(f.buf)
+	fi.BytesExpected = r.expectedSize
+	fi.MaxBytes = int(r.vpcc.MaxVideoFrameSize)
+	if fi.Complete() {
+		return true
+	}
+	r.corrupt.Add(1)
+	switch r.policy {
+	case IntegrityDrop:
+		return false
+	case IntegrityRepair:
+		if fi.BytesExpected > 0 && fi.BytesReceived != fi.BytesExpected && (grow || fi.BytesExpected <= cap(f.buf)) {
+			f.resize(fi.BytesExpected)
+			fi.Repaired = true
+		}
+	}
+	return true
+}
+
+// resize zero-fills or trims the frame data to n bytes. Payload data past n is trimmed with it.
+func (f *Frame) resize(n int) {
+	if n > cap(f.buf) {
+		f.grow(n - len(f.buf))
+	}
+	old := len(f.buf)
+	f.buf = f.buf[:n]
+	if n > old {
+		clear(f.buf[old:])
+		return
+	}
+	off := 0
+	for _, p := range f.Payloads {
+		size := min(len(p.Data), max(n-off, 0))
+		p.Data = p.Data[:size]
+		off += size
+	}
+}
+
+// fixedFrameSize returns the size of every frame of the negotiated format and frame if the format has
+// a fixed frame size, or zero otherwise.
+func (si *StreamingInterface) fixedFrameSize(vpcc *descriptors.VideoProbeCommitControl) int {
+	var format descriptors.FormatDescriptor
+	for _, desc := range si.Descriptors {
+		switch d := desc.(type) {
+		case *descriptors.DVFormatDescriptor:
+			// DV formats have no frame descriptors, every frame is a complete DIF frame.
+			if d.Index() == vpcc.FormatIndex {
+				return d.FrameSize()
+			}
+		case descriptors.FormatDescriptor:
+			format = d
+		case *descriptors.UncompressedFrameDescriptor:
+			f, ok := format.(*descriptors.UncompressedFormatDescriptor)
+			if !ok || f.Index() != vpcc.FormatIndex || d.Index() != vpcc.FrameIndex {
+				continue
+			}
+			return int(d.Width) * int(d.Height) * int(f.BitsPerPixel) / 8
+		}
+	}
+	return 0
+}
@@ -0,0 +1,152 @@
+package transfers
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// lossyReader wraps a packetReader and reports lost packets before the given payload indices.
+type lossyReader struct {
+	packetReader
+	lossAt map[int]bool
+	lost   uint64
+}
+
+func (r *lossyReader) Read(buf []byte) (int, error) {
+	if r.lossAt[r.i] {
+		delete(r.lossAt, r.i)
+		r.lost++
+	}
+	return r.packetReader.Read(buf)
+}
+
+func (r *lossyReader) LostPackets() uint64 { return r.lost }
+
+func TestFrameReader_Integrity(t *testing.T) {
+	frames := testFrames(4, 3000)
+	packets := framePayloads(frames, 1024)
+	// frame 1 is error flagged, frame 2 loses its last payload including the end of frame bit.
+	packets[3][1] |= 0b01000000
+	packets = append(packets[:8], packets[9:]...)
+	pr := &lossyReader{packetReader: packetReader{packets: packets}, lossAt: map[int]bool{10: true}}
+	r := newTestFrameReader(nil, 3000, 1024)
+	r.pr = pr
+	r.expectedSize = 3000
+
+	want := []FrameIntegrity{
+		{EndOfFrame: true, BytesReceived: 3000},
+		{EndOfFrame: true, ErrorFlagged: true, BytesReceived: 3000},
+		{MissingEndOfFrame: true, BytesReceived: 2024},
+		{EndOfFrame: true, LostPackets: 1, BytesReceived: 3000},
+	}
+	for i, w := range want {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		w.BytesExpected, w.MaxBytes = 3000, 3000
+		if f.Integrity != w {
+			t.Errorf("frame %d integrity = %+v, want %+v", i, f.Integrity, w)
+		}
+		if got := f.Integrity.Complete(); got != (i == 0) {
+			t.Errorf("frame %d Complete = %v", i, got)
+		}
+		f.Release()
+	}
+	if n := r.CorruptFrames(); n != 3 {
+		t.Errorf("CorruptFrames = %d, want 3", n)
+	}
+}
+
+func TestFrameReader_IntegrityPolicy(t *testing.T) {
+	frames := testFrames(4, 3000)
+	packets := framePayloads(frames, 1024)
+	// frame 1 is missing its middle payload.
+	packets = append(packets[:4], packets[5:]...)
+
+	t.Run("Drop", func(t *testing.T) {
+		r := newTestFrameReader(packets, 3000, 1024)
+		r.expectedSize = 3000
+		r.SetIntegrityPolicy(IntegrityDrop)
+		for _, i := range []int{0, 2, 3} {
+			f, err := r.ReadFrame()
+			if err != nil {
+				t.Fatalf("ReadFrame failed: %v", err)
+			}
+			if !bytes.Equal(f.Bytes(), frames[i]) {
+				t.Errorf("got wrong frame, want frame %d", i)
+			}
+			f.Release()
+		}
+	})
+
+	t.Run("Repair", func(t *testing.T) {
+		r := newTestFrameReader(packets, 3000, 1024)
+		r.expectedSize = 3000
+		r.SetIntegrityPolicy(IntegrityRepair)
+		dst := make([]byte, 3000)
+		for i := range 2 {
+			n, err := r.ReadFrameInto(dst)
+			if err != nil {
+				t.Fatalf("ReadFrameInto failed: %v", err)
+			}
+			if n != 3000 {
+				t.Errorf("frame %d has %d bytes, want 3000", i, n)
+			}
+		}
+		fi := r.into.Integrity
+		if !fi.Repaired || !fi.Truncated() || fi.BytesReceived != 1988 {
+			t.Errorf("integrity = %+v, want repaired truncated frame", fi)
+		}
+		if !bytes.Equal(dst[:1012], frames[1][:1012]) || !bytes.Equal(dst[1988:], make([]byte, 1012)) {
+			t.Error("repaired frame is not zero filled")
+		}
+	})
+}
+
+func TestFrameReader_InvalidPayloadHeader(t *testing.T) {
+	frames := testFrames(4, 3000)
+	packets := framePayloads(frames, 1024)
+	// the middle payload of frame 1 has a header too short for its PTS and SCR.
+	packets[4] = bytes.Clone(packets[4])
+	packets[4][0] = 2
+
+	r := newTestFrameReader(packets, 3000, 1024)
+	r.expectedSize = 3000
+	for i := range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		fi := f.Integrity
+		if i == 1 {
+			if fi.InvalidPayloads != 1 || fi.BytesReceived != 1988 || fi.Complete() {
+				t.Errorf("frame 1 integrity = %+v, want one invalid payload", fi)
+			}
+		} else if !fi.Complete() || !bytes.Equal(f.Bytes(), frames[i]) {
+			t.Errorf("frame %d integrity = %+v", i, fi)
+		}
+		f.Release()
+	}
+
+	r = newTestFrameReader(packets, 3000, 1024)
+	r.expectedSize = 3000
+	r.SetIntegrityPolicy(IntegrityDrop)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := r.StartStream(ctx, StreamOptions{BufferDepth: 1, DropPolicy: Block})
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	for _, i := range []int{0, 2, 3, 0, 2} {
+		f, ok := <-ch
+		if !ok {
+			t.Fatalf("stream ended: %v", r.Err())
+		}
+		if !bytes.Equal(f.Bytes(), frames[i]) {
+			t.Errorf("got wrong frame, want frame %d", i)
+		}
+		f.Release()
+	}
+}
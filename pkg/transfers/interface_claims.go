@@ -0,0 +1,80 @@
+package transfers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrInterfaceBusy is returned when a streaming interface is already owned by another reader.
+var ErrInterfaceBusy = errors.New("interface busy")
+
+// interfaceClaimer is the subset of *usb.DeviceHandle used to claim and release interfaces.
+type interfaceClaimer interface {
+	DetachKernelDriver(iface uint8) error
+	ClaimInterface(iface uint8) error
+	ReleaseInterface(iface uint8) error
+}
+
+type claimKey struct {
+	handle   interfaceClaimer
+	ifaceNum uint8
+}
+
+type claimState struct {
+	count     int
+	exclusive bool
+}
+
+// interfaceClaims reference counts interface claims across every reader opened on a device.
+//
+// A device can have several streaming interfaces (preview and capture streams, depth and color
+// sensors, ...) that all share a single control interface. The control interface is claimed
+// by every reader but only released by the kernel once the last reader closes, while a
+// streaming interface can only be owned by one reader at a time.
+var interfaceClaims = struct {
+	sync.Mutex
+	states map[claimKey]*claimState
+}{states: make(map[claimKey]*claimState)}
+
+// claimInterface claims the interface, detaching the kernel driver on the first claim. If exclusive
+// is set, the claim fails with ErrInterfaceBusy when the interface is already claimed.
+func claimInterface(h interfaceClaimer, ifnum uint8, exclusive bool) error {
+	interfaceClaims.Lock()
+	defer interfaceClaims.Unlock()
+
+	key := claimKey{handle: h, ifaceNum: ifnum}
+	if state, ok := interfaceClaims.states[key]; ok {
+		if exclusive || state.exclusive {
+			return fmt.Errorf("interface %d: %w", ifnum, ErrInterfaceBusy)
+		}
+		state.count++
+		return nil
+	}
+
+	h.DetachKernelDriver(ifnum)
+	if err := h.ClaimInterface(ifnum); err != nil {
+		return err
+	}
+	interfaceClaims.states[key] = &claimState{count: 1, exclusive: exclusive}
+	return nil
+}
+
+// releaseInterface drops a reference to the interface and releases it once no references remain.
+func releaseInterface(h interfaceClaimer, ifnum uint8) error {
+	interfaceClaims.Lock()
+	defer interfaceClaims.Unlock()
+
+	key := claimKey{handle: h, ifaceNum: ifnum}
+	state, ok := interfaceClaims.states[key]
+	if !ok {
+		// the interface was claimed outside of the registry, release it directly.
+		return h.ReleaseInterface(ifnum)
+	}
+	state.count--
+	if state.count > 0 {
+		return nil
+	}
+	delete(interfaceClaims.states, key)
+	return h.ReleaseInterface(ifnum)
+}
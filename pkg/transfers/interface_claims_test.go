@@ -0,0 +1,103 @@
+package transfers
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeClaimer struct {
+	claimed  map[uint8]bool
+	claims   int
+	releases int
+}
+
+func (f *fakeClaimer) DetachKernelDriver(iface uint8) error { return nil }
+
+func (f *fakeClaimer) ClaimInterface(iface uint8) error {
+	if f.claimed == nil {
+		f.claimed = make(map[uint8]bool)
+	}
+	f.claimed[iface] = true
+	f.claims++
+	return nil
+}
+
+func (f *fakeClaimer) ReleaseInterface(iface uint8) error {
+	delete(f.claimed, iface)
+	f.releases++
+	return nil
+}
+
+func TestInterfaceClaims_SharedControlInterface(t *testing.T) {
+	h := &fakeClaimer{}
+
+	// two streams share the control interface
+	if err := claimInterface(h, 0, false); err != nil {
+		t.Fatalf("first claim failed: %v", err)
+	}
+	if err := claimInterface(h, 0, false); err != nil {
+		t.Fatalf("second claim failed: %v", err)
+	}
+	if h.claims != 1 {
+		t.Errorf("ClaimInterface called %d times, want 1", h.claims)
+	}
+
+	// closing the first stream must not tear down control access for the second
+	if err := releaseInterface(h, 0); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if !h.claimed[0] {
+		t.Error("control interface released while still referenced")
+	}
+
+	if err := releaseInterface(h, 0); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if h.claimed[0] {
+		t.Error("control interface still claimed after last release")
+	}
+	if h.releases != 1 {
+		t.Errorf("ReleaseInterface called %d times, want 1", h.releases)
+	}
+}
+
+func TestInterfaceClaims_ExclusiveStreamingInterface(t *testing.T) {
+	h := &fakeClaimer{}
+
+	if err := claimInterface(h, 1, true); err != nil {
+		t.Fatalf("claim failed: %v", err)
+	}
+	if err := claimInterface(h, 1, true); !errors.Is(err, ErrInterfaceBusy) {
+		t.Errorf("second exclusive claim error = %v, want ErrInterfaceBusy", err)
+	}
+	if err := claimInterface(h, 1, false); !errors.Is(err, ErrInterfaceBusy) {
+		t.Errorf("shared claim on exclusive interface error = %v, want ErrInterfaceBusy", err)
+	}
+
+	// a different streaming interface on the same device can be opened concurrently
+	if err := claimInterface(h, 2, true); err != nil {
+		t.Fatalf("claim of second streaming interface failed: %v", err)
+	}
+
+	if err := releaseInterface(h, 1); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := claimInterface(h, 1, true); err != nil {
+		t.Errorf("reclaim after release failed: %v", err)
+	}
+	releaseInterface(h, 1)
+	releaseInterface(h, 2)
+}
+
+func TestInterfaceClaims_SeparateDevices(t *testing.T) {
+	a, b := &fakeClaimer{}, &fakeClaimer{}
+
+	if err := claimInterface(a, 1, true); err != nil {
+		t.Fatalf("claim on first device failed: %v", err)
+	}
+	if err := claimInterface(b, 1, true); err != nil {
+		t.Fatalf("claim on second device failed: %v", err)
+	}
+	releaseInterface(a, 1)
+	releaseInterface(b, 1)
+}
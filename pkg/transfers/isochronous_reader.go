@@ -3,12 +3,20 @@ package transfers
 import (
 	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	usb "github.com/kevmo314/go-usb"
 )
 
 type IsochronousReader struct {
+	mu     sync.Mutex
+	closed atomic.Bool
+	stats  transferCounters
+
 	handle     *usb.DeviceHandle
+	syncReader *SyncIsochronousReader
 	transfers  []*usb.IsochronousTransfer
 	currentTx  int
 	packetIdx  int
@@ -16,11 +24,19 @@ type IsochronousReader struct {
 	packetSize int
 }
 
+// NewIsochronousReader creates a reader that keeps several transfers of the given number of packets
+// queued on the endpoint. On transports other than a *usb.DeviceHandle, which can not queue transfers, it reads
+// with one synchronous transfer at a time instead.
 func (si *StreamingInterface) NewIsochronousReader(endpointAddress uint8, packets, packetSize uint32) (*IsochronousReader, error) {
+	handle, ok := usbHandle(si.handle)
+	if !ok {
+		return &IsochronousReader{syncReader: si.NewSyncIsochronousReader(endpointAddress, packets, packetSize)}, nil
+	}
 	r := &IsochronousReader{
-		handle:     si.handle,
+		handle:     handle,
 		numPackets: int(packets),
 		packetSize: int(packetSize),
+		stats:      transferCounters{start: time.Now()},
 	}
 
 	// Create and submit multiple transfers for continuous streaming
@@ -30,7 +46,7 @@ func (si *StreamingInterface) NewIsochronousReader(endpointAddress uint8, packet
 	r.transfers = make([]*usb.IsochronousTransfer, numTransfers)
 
 	for i := 0; i < numTransfers; i++ {
-		tx, err := si.handle.NewIsochronousTransfer(endpointAddress, int(packets), int(packetSize))
+		tx, err := handle.NewIsochronousTransfer(endpointAddress, int(packets), int(packetSize))
 		if err != nil {
 			// Clean up any already created transfers
 			for j := 0; j < i; j++ {
@@ -46,24 +62,38 @@ func (si *StreamingInterface) NewIsochronousReader(endpointAddress uint8, packet
 			return nil, fmt.Errorf("failed to submit isochronous transfer: %w", err)
 		}
 		r.transfers[i] = tx
+		r.stats.submitted.Add(1)
 	}
 
 	return r, nil
 }
 
 func (r *IsochronousReader) Read(buf []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	for {
+		if r.closed.Load() {
+			return 0, fmt.Errorf("reader closed")
+		}
+		if r.syncReader != nil {
+			return r.syncReader.Read(buf)
+		}
+
 		tx := r.transfers[r.currentTx]
 
 		// Wait for the current transfer to complete
 		if err := tx.Wait(); err != nil {
+			r.stats.transferErrors.Add(1)
 			return 0, fmt.Errorf("isochronous transfer failed: %w", err)
 		}
 
 		packets := tx.Packets()
 		if r.packetIdx >= len(packets) {
 			// Resubmit this transfer and move to the next one
+			r.stats.transfers.Add(1)
 			if err := tx.Submit(); err != nil {
+				r.stats.submitted.Add(-1)
 				return 0, fmt.Errorf("failed to resubmit isochronous transfer: %w", err)
 			}
 			r.packetIdx = 0
@@ -73,10 +103,12 @@ func (r *IsochronousReader) Read(buf []byte) (int, error) {
 
 		pkt := packets[r.packetIdx]
 		if pkt.Status != 0 {
+			r.stats.packetError(int(pkt.Status))
 			r.packetIdx++
 			continue
 		}
 		if pkt.ActualLength == 0 {
+			r.stats.packet(0)
 			r.packetIdx++
 			continue
 		}
@@ -90,11 +122,39 @@ func (r *IsochronousReader) Read(buf []byte) (int, error) {
 			continue
 		}
 		r.packetIdx++
+		r.stats.packet(len(data))
 		return copy(buf, data), nil
 	}
 }
 
+// LostPackets returns the number of packets that completed with an error status and were skipped.
+func (r *IsochronousReader) LostPackets() uint64 {
+	if r.syncReader != nil {
+		return r.syncReader.LostPackets()
+	}
+	return r.stats.packetErrors.Load()
+}
+
+// Stats returns a snapshot of the transfer statistics of the reader.
+func (r *IsochronousReader) Stats() TransferStats {
+	if r.syncReader != nil {
+		return r.syncReader.Stats()
+	}
+	return r.stats.snapshot()
+}
+
+// Close cancels all pending transfers. It is safe to call while a Read is blocked, the Read returns an
+// error once its transfer is cancelled.
 func (r *IsochronousReader) Close() error {
+	if !r.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	for _, tx := range r.transfers {
+		tx.Cancel()
+	}
+	// Once Read has returned, nothing resubmits transfers so they can be reaped.
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	for _, tx := range r.transfers {
 		tx.Cancel()
 	}
@@ -102,5 +162,6 @@ func (r *IsochronousReader) Close() error {
 	for _, tx := range r.transfers {
 		tx.Wait() // Ignore error - we're closing
 	}
+	r.stats.submitted.Store(0)
 	return nil
 }
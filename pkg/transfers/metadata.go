@@ -0,0 +1,358 @@
+package transfers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+	"time"
+)
+
+// MetadataID identifies a metadata item in the Microsoft camera metadata format.
+type MetadataID uint32
+
+// Microsoft extensions to UVC 1.5, section 2.2.3: standard metadata identifiers, matching
+// KSCAMERA_MetadataId in ksmedia.h.
+const (
+	MetadataIDPhotoConfirmation          MetadataID = 1
+	MetadataIDUsbVideoHeader             MetadataID = 2
+	MetadataIDCaptureStats               MetadataID = 3
+	MetadataIDCameraExtrinsics           MetadataID = 4
+	MetadataIDCameraIntrinsics           MetadataID = 5
+	MetadataIDFrameIllumination          MetadataID = 6
+	MetadataIDDigitalWindow              MetadataID = 7
+	MetadataIDBackgroundSegmentationMask MetadataID = 8
+	MetadataIDCustomStart                MetadataID = 0x80000000
+)
+
+// metadataItemHeaderSize is the size of KSCAMERA_METADATA_ITEMHEADER.
+const metadataItemHeaderSize = 8
+
+// MetadataItem is a single metadata item. Data excludes the item header.
+type MetadataItem struct {
+	ID   MetadataID
+	Data []byte
+}
+
+// CaptureStats flags, matching KSCAMERA_METADATA_CAPTURESTATS_FLAG_*, report which fields are valid.
+const (
+	CaptureStatsExposureTime         = 0x00000001
+	CaptureStatsExposureCompensation = 0x00000002
+	CaptureStatsISOSpeed             = 0x00000004
+	CaptureStatsFocusState           = 0x00000008
+	CaptureStatsLensPosition         = 0x00000010
+	CaptureStatsWhiteBalance         = 0x00000020
+	CaptureStatsFlash                = 0x00000040
+	CaptureStatsFlashPower           = 0x00000080
+	CaptureStatsZoomFactor           = 0x00000100
+	CaptureStatsSceneMode            = 0x00000200
+	CaptureStatsSensorFramerate      = 0x00000400
+)
+
+// CaptureStats are the capture settings the device used for a frame (KSCAMERA_METADATA_CAPTURESTATS).
+type CaptureStats struct {
+	Flags                     uint32
+	ExposureTime              time.Duration
+	ExposureCompensationFlags uint64
+	ExposureCompensationValue int32
+	ISOSpeed                  uint32
+	FocusState                uint32
+	LensPosition              uint32
+	WhiteBalance              uint32 // in Kelvin
+	Flash                     uint32
+	FlashPower                uint32
+	ZoomFactor                uint32 // Q16 fixed point
+	SceneMode                 uint64
+	// SensorFramerate holds the numerator in the high 32 bits and the denominator in the low 32 bits.
+	SensorFramerate uint64
+}
+
+// Has reports whether the fields of the given CaptureStats flag are valid.
+func (cs *CaptureStats) Has(flag uint32) bool {
+	return cs.Flags&flag != 0
+}
+
+func (cs *CaptureStats) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 72 {
+		return io.ErrShortBuffer
+	}
+	cs.Flags = binary.LittleEndian.Uint32(buf[0:4])
+	// buf[4:8] is reserved
+	cs.ExposureTime = time.Duration(binary.LittleEndian.Uint64(buf[8:16])) * 100 * time.Nanosecond
+	cs.ExposureCompensationFlags = binary.LittleEndian.Uint64(buf[16:24])
+	cs.ExposureCompensationValue = int32(binary.LittleEndian.Uint32(buf[24:28]))
+	cs.ISOSpeed = binary.LittleEndian.Uint32(buf[28:32])
+	cs.FocusState = binary.LittleEndian.Uint32(buf[32:36])
+	cs.LensPosition = binary.LittleEndian.Uint32(buf[36:40])
+	cs.WhiteBalance = binary.LittleEndian.Uint32(buf[40:44])
+	cs.Flash = binary.LittleEndian.Uint32(buf[44:48])
+	cs.FlashPower = binary.LittleEndian.Uint32(buf[48:52])
+	cs.ZoomFactor = binary.LittleEndian.Uint32(buf[52:56])
+	cs.SceneMode = binary.LittleEndian.Uint64(buf[56:64])
+	cs.SensorFramerate = binary.LittleEndian.Uint64(buf[64:72])
+	return nil
+}
+
+// FaceRect is a detected face in the FaceRectInfo layout of MF_CAPTURE_METADATA_FACEROIS.
+type FaceRect struct {
+	Region     image.Rectangle
+	Confidence int32 // 0 to 100
+}
+
+// Metadata is the per-frame metadata sent by the device, either in extended payload headers or on a
+// dedicated metadata streaming interface.
+type Metadata struct {
+	// Items are all metadata items in the order they were received, including the ones decoded below.
+	Items []MetadataItem
+
+	// VideoHeader is the UVC payload header of the video frame the metadata belongs to, if the device
+	// sent a MetadataIDUsbVideoHeader item.
+	VideoHeader *Payload
+	// CaptureStats is decoded from the MetadataIDCaptureStats item.
+	CaptureStats *CaptureStats
+	// Illuminated is decoded from the MetadataIDFrameIllumination item and reports whether the frame
+	// was captured with active illumination, such as an IR emitter.
+	Illuminated bool
+}
+
+// ParseMetadata parses a buffer of metadata items, each a KSCAMERA_METADATA_ITEMHEADER followed by
+// its data. The item data is copied.
+func ParseMetadata(buf []byte) (*Metadata, error) {
+	m := &Metadata{}
+	if err := m.append(buf); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// append parses the items in buf and adds them to the metadata.
+func (m *Metadata) append(buf []byte) error {
+	for len(buf) > 0 {
+		if len(buf) < metadataItemHeaderSize {
+			return io.ErrShortBuffer
+		}
+		id := MetadataID(binary.LittleEndian.Uint32(buf[0:4]))
+		size := binary.LittleEndian.Uint32(buf[4:8])
+		if size < metadataItemHeaderSize || uint64(size) > uint64(len(buf)) {
+			return fmt.Errorf("metadata item %#x has invalid size %d", uint32(id), size)
+		}
+		data := append([]byte(nil), buf[metadataItemHeaderSize:size]...)
+		m.Items = append(m.Items, MetadataItem{ID: id, Data: data})
+
+		switch id {
+		case MetadataIDUsbVideoHeader:
+			p := &Payload{}
+			if err := p.UnmarshalBinary(data); err == nil {
+				m.VideoHeader = p
+			}
+		case MetadataIDCaptureStats:
+			cs := &CaptureStats{}
+			if err := cs.UnmarshalBinary(data); err == nil {
+				m.CaptureStats = cs
+			}
+		case MetadataIDFrameIllumination:
+			if len(data) >= 4 {
+				m.Illuminated = binary.LittleEndian.Uint32(data[0:4])&0x1 != 0
+			}
+		}
+		buf = buf[size:]
+	}
+	return nil
+}
+
+// Item returns the data of the first item with the given id.
+func (m *Metadata) Item(id MetadataID) ([]byte, bool) {
+	for _, item := range m.Items {
+		if item.ID == id {
+			return item.Data, true
+		}
+	}
+	return nil, false
+}
+
+// FaceRects decodes the item with the given id as face rectangles in the MF_CAPTURE_METADATA_FACEROIS
+// layout: a FaceRectInfoBlobHeader (size, count) followed by count FaceRectInfo entries. Microsoft does
+// not assign a standard item id to face rectangles, so devices send them as a custom item.
+func (m *Metadata) FaceRects(id MetadataID) ([]FaceRect, error) {
+	data, ok := m.Item(id)
+	if !ok {
+		return nil, nil
+	}
+	if len(data) < 8 {
+		return nil, io.ErrShortBuffer
+	}
+	count := binary.LittleEndian.Uint32(data[4:8])
+	data = data[8:]
+	if uint64(count)*20 > uint64(len(data)) {
+		return nil, io.ErrShortBuffer
+	}
+	faces := make([]FaceRect, count)
+	for i := range faces {
+		e := data[i*20:]
+		faces[i] = FaceRect{
+			Region: image.Rect(
+				int(int32(binary.LittleEndian.Uint32(e[0:4]))),
+				int(int32(binary.LittleEndian.Uint32(e[4:8]))),
+				int(int32(binary.LittleEndian.Uint32(e[8:12]))),
+				int(int32(binary.LittleEndian.Uint32(e[12:16]))),
+			),
+			Confidence: int32(binary.LittleEndian.Uint32(e[16:20])),
+		}
+	}
+	return faces, nil
+}
+
+// MetadataPairer attaches frames read from a metadata streaming interface to the video frames they
+// describe, matching them by presentation time stamp.
+//
+// Devices with a dedicated metadata interface send one metadata frame per video frame. The PTS of
+// the video frame is taken from the MetadataIDUsbVideoHeader item if present, otherwise from the PTS
+// of the metadata frame itself.
+type MetadataPairer struct {
+	mu      sync.Mutex
+	pending []pendingMetadata
+	depth   int
+}
+
+type pendingMetadata struct {
+	pts      uint32
+	metadata *Metadata
+}
+
+// NewMetadataPairer creates a pairer that keeps up to depth unmatched metadata frames.
+func NewMetadataPairer(depth int) *MetadataPairer {
+	return &MetadataPairer{depth: max(depth, 1)}
+}
+
+// AddMetadataFrame parses a frame read from the metadata streaming interface and queues it for pairing.
+// The frame can be released afterwards.
+func (mp *MetadataPairer) AddMetadataFrame(f *Frame) error {
+	m, err := ParseMetadata(f.Bytes())
+	if err != nil {
+		return err
+	}
+	pts, ok := f.PTS, f.HasPTS()
+	if m.VideoHeader != nil && m.VideoHeader.HasPTS() {
+		pts, ok = m.VideoHeader.PTS, true
+	}
+	if !ok {
+		return fmt.Errorf("metadata frame has no presentation time stamp")
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if len(mp.pending) == mp.depth {
+		mp.pending = mp.pending[1:]
+	}
+	mp.pending = append(mp.pending, pendingMetadata{pts: pts, metadata: m})
+	return nil
+}
+
+// Attach sets the metadata of a video frame to the queued metadata frame with the same PTS. Older
+// unmatched metadata frames are discarded. It reports whether a match was found.
+func (mp *MetadataPairer) Attach(f *Frame) bool {
+	if !f.HasPTS() {
+		return false
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	for i, p := range mp.pending {
+		if p.pts == f.PTS {
+			f.Metadata = p.metadata
+			mp.pending = mp.pending[i+1:]
+			return true
+		}
+	}
+	return false
+}
+
+// newest returns the PTS of the most recently queued metadata frame.
+func (mp *MetadataPairer) newest() (uint32, bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if len(mp.pending) == 0 {
+		return 0, false
+	}
+	return mp.pending[len(mp.pending)-1].pts, true
+}
+
+// pairedMetadataDepth is the number of unmatched metadata frames a PairedFrameReader keeps.
+const pairedMetadataDepth = 8
+
+// PairedFrameReader reads video frames with the frames of a metadata streaming interface attached to
+// them by PTS.
+type PairedFrameReader struct {
+	video    *FrameReader
+	metadata *FrameReader
+	pairer   *MetadataPairer
+}
+
+// NewPairedFrameReader pairs the frames of a video reader with the frames of a reader on the metadata
+// streaming interface of the same device. Closing the paired reader closes both.
+func NewPairedFrameReader(video, metadata *FrameReader) *PairedFrameReader {
+	return &PairedFrameReader{video: video, metadata: metadata, pairer: NewMetadataPairer(pairedMetadataDepth)}
+}
+
+// ClaimPairedFrameReader opens a frame reader for the given format and frame on this streaming
+// interface and one for the first format of the metadata interface, and pairs them.
+func (si *StreamingInterface) ClaimPairedFrameReader(formatIndex, frameIndex uint8, metadata *StreamingInterface) (*PairedFrameReader, error) {
+	if !metadata.IsMetadata() {
+		return nil, fmt.Errorf("interface %d is not a metadata interface", metadata.InterfaceNumber())
+	}
+	video, err := si.ClaimFrameReader(formatIndex, frameIndex)
+	if err != nil {
+		return nil, err
+	}
+	mr, err := metadata.ClaimFrameReader(metadata.FormatDescriptors()[0].Index(), 0)
+	if err != nil {
+		video.Close()
+		return nil, fmt.Errorf("failed to open metadata interface: %w", err)
+	}
+	return NewPairedFrameReader(video, mr), nil
+}
+
+// ReadFrame reads the next video frame and attaches the metadata frame with the same PTS. Metadata
+// frames are read until one matches or is newer than the video frame, in which case the frame is
+// returned without metadata. Metadata frames that cannot be parsed set the InvalidMetadata integrity
+// flag of the video frame.
+func (r *PairedFrameReader) ReadFrame() (*Frame, error) {
+	f, err := r.video.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	if !f.HasPTS() {
+		return f, nil
+	}
+	for !r.pairer.Attach(f) {
+		if pts, ok := r.pairer.newest(); ok && int32(pts-f.PTS) > 0 {
+			break
+		}
+		m, err := r.metadata.ReadFrame()
+		if err != nil {
+			f.Release()
+			return nil, fmt.Errorf("failed to read metadata frame: %w", err)
+		}
+		err = r.pairer.AddMetadataFrame(m)
+		m.Release()
+		if err != nil {
+			f.Integrity.InvalidMetadata = true
+			break
+		}
+	}
+	return f, nil
+}
+
+// Video returns the reader of the video frames.
+func (r *PairedFrameReader) Video() *FrameReader {
+	return r.video
+}
+
+// Close closes the video and metadata readers.
+func (r *PairedFrameReader) Close() error {
+	err := r.video.Close()
+	if merr := r.metadata.Close(); err == nil {
+		err = merr
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+package transfers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"slices"
+	"testing"
+	"time"
+)
+
+// metadataItem encodes a KSCAMERA_METADATA_ITEMHEADER followed by data.
+func metadataItem(id MetadataID, data []byte) []byte {
+	buf := make([]byte, 8, 8+len(data))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(id))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(8+len(data)))
+	return append(buf, data...)
+}
+
+func TestParseMetadata(t *testing.T) {
+	stats := make([]byte, 72)
+	binary.LittleEndian.PutUint32(stats[0:4], CaptureStatsExposureTime|CaptureStatsWhiteBalance)
+	binary.LittleEndian.PutUint64(stats[8:16], 333333)
+	binary.LittleEndian.PutUint32(stats[40:44], 5600)
+
+	faces := make([]byte, 8+20)
+	binary.LittleEndian.PutUint32(faces[0:4], 28)
+	binary.LittleEndian.PutUint32(faces[4:8], 1)
+	for i, v := range []uint32{10, 20, 110, 140, 87} {
+		binary.LittleEndian.PutUint32(faces[8+i*4:], v)
+	}
+
+	var buf []byte
+	buf = append(buf, metadataItem(MetadataIDUsbVideoHeader, []byte{12, 0x8D, 0x10, 0x27, 0, 0, 1, 2, 3, 4, 5, 6})...)
+	buf = append(buf, metadataItem(MetadataIDCaptureStats, stats)...)
+	buf = append(buf, metadataItem(MetadataIDFrameIllumination, []byte{1, 0, 0, 0})...)
+	buf = append(buf, metadataItem(MetadataIDCustomStart+1, faces)...)
+
+	m, err := ParseMetadata(buf)
+	if err != nil {
+		t.Fatalf("ParseMetadata failed: %v", err)
+	}
+	if len(m.Items) != 4 {
+		t.Fatalf("got %d items, want 4", len(m.Items))
+	}
+	if m.VideoHeader == nil || !m.VideoHeader.HasPTS() || m.VideoHeader.PTS != 10000 {
+		t.Errorf("VideoHeader = %+v, want PTS 10000", m.VideoHeader)
+	}
+	if cs := m.CaptureStats; cs == nil {
+		t.Error("CaptureStats not decoded")
+	} else {
+		if !cs.Has(CaptureStatsExposureTime) || cs.ExposureTime != 33333300*time.Nanosecond {
+			t.Errorf("ExposureTime = %v, want 33.3333ms", cs.ExposureTime)
+		}
+		if !cs.Has(CaptureStatsWhiteBalance) || cs.WhiteBalance != 5600 {
+			t.Errorf("WhiteBalance = %d, want 5600", cs.WhiteBalance)
+		}
+		if cs.Has(CaptureStatsISOSpeed) {
+			t.Error("ISOSpeed reported valid")
+		}
+	}
+	if !m.Illuminated {
+		t.Error("Illuminated = false, want true")
+	}
+
+	rects, err := m.FaceRects(MetadataIDCustomStart + 1)
+	if err != nil {
+		t.Fatalf("FaceRects failed: %v", err)
+	}
+	want := []FaceRect{{Region: image.Rect(10, 20, 110, 140), Confidence: 87}}
+	if len(rects) != 1 || rects[0] != want[0] {
+		t.Errorf("FaceRects = %+v, want %+v", rects, want)
+	}
+}
+
+func TestParseMetadata_InvalidSize(t *testing.T) {
+	item := metadataItem(MetadataIDCaptureStats, make([]byte, 16))
+	if _, err := ParseMetadata(item[:len(item)-1]); err == nil {
+		t.Error("ParseMetadata succeeded with a truncated item")
+	}
+	binary.LittleEndian.PutUint32(item[4:8], 4)
+	if _, err := ParseMetadata(item); err == nil {
+		t.Error("ParseMetadata succeeded with an item smaller than its header")
+	}
+}
+
+func TestFrameReader_HeaderMetadata(t *testing.T) {
+	item := metadataItem(MetadataIDFrameIllumination, []byte{1, 0, 0, 0})
+	frames := testFrames(2, 3000)
+	var packets [][]byte
+	for _, pkt := range framePayloads(frames, 1024) {
+		// extend the header of every payload with the metadata item.
+		ext := append([]byte{byte(12 + len(item))}, pkt[1:12]...)
+		ext = append(ext, item...)
+		packets = append(packets, append(ext, pkt[12:]...))
+	}
+	r := newTestFrameReader(packets, 3000, 1024+uint32(len(item)))
+
+	f, err := r.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	defer f.Release()
+	if !bytes.Equal(f.Bytes(), frames[0]) {
+		t.Error("frame data mismatch")
+	}
+	if f.Metadata == nil || !f.Metadata.Illuminated {
+		t.Fatalf("Metadata = %+v, want illuminated", f.Metadata)
+	}
+	if len(f.Metadata.Items) != len(f.Payloads) {
+		t.Errorf("got %d items, want one per payload", len(f.Metadata.Items))
+	}
+}
+
+func TestMetadataPairer(t *testing.T) {
+	metadataFrame := func(pts uint32) *Frame {
+		hdr := []byte{6, 0x84, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(hdr[2:6], pts)
+		item := metadataItem(MetadataIDUsbVideoHeader, hdr)
+		return &Frame{Payloads: []*Payload{{Data: item}}}
+	}
+	mp := NewMetadataPairer(2)
+	for _, pts := range []uint32{100, 200, 300} {
+		if err := mp.AddMetadataFrame(metadataFrame(pts)); err != nil {
+			t.Fatalf("AddMetadataFrame failed: %v", err)
+		}
+	}
+
+	// 100 was evicted by the queue depth.
+	f := &Frame{PTS: 100, hasPTS: true}
+	if mp.Attach(f) {
+		t.Error("attached evicted metadata")
+	}
+	f = &Frame{PTS: 300, hasPTS: true}
+	if !mp.Attach(f) || f.Metadata.VideoHeader.PTS != 300 {
+		t.Errorf("Metadata = %+v, want PTS 300", f.Metadata)
+	}
+	// 200 is older than the matched frame and was discarded.
+	f = &Frame{PTS: 200, hasPTS: true}
+	if mp.Attach(f) {
+		t.Error("attached stale metadata")
+	}
+}
+
+func TestFrameReader_InvalidHeaderMetadata(t *testing.T) {
+	item := metadataItem(MetadataIDFrameIllumination, []byte{1, 0, 0, 0})
+	frames := testFrames(1, 3000)
+	var packets [][]byte
+	for i, pkt := range framePayloads(frames, 1024) {
+		ext := item
+		if i == 1 {
+			// the item claims more data than the header holds.
+			ext = slices.Clone(item)
+			binary.LittleEndian.PutUint32(ext[4:8], 64)
+		}
+		hdr := append([]byte{byte(12 + len(ext))}, pkt[1:12]...)
+		hdr = append(hdr, ext...)
+		packets = append(packets, append(hdr, pkt[12:]...))
+	}
+	r := newTestFrameReader(packets, 3000, 1024+uint32(len(item)))
+
+	f, err := r.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	defer f.Release()
+	if !bytes.Equal(f.Bytes(), frames[0]) {
+		t.Error("frame data mismatch")
+	}
+	if !f.Integrity.InvalidMetadata {
+		t.Error("InvalidMetadata = false, want true")
+	}
+	if f.Metadata == nil || len(f.Metadata.Items) != len(f.Payloads)-1 {
+		t.Errorf("Metadata = %+v, want the items of the valid headers", f.Metadata)
+	}
+}
@@ -25,7 +25,7 @@ const (
 )
 
 type MIDIStreamingInterface struct {
-	handle *usb.DeviceHandle
+	handle Transport
 	iface  *usb.Interface
 
 	// MIDI specific fields
@@ -45,7 +45,7 @@ type MIDIJack struct {
 	StringIdx uint8 // String descriptor index
 }
 
-func NewMIDIStreamingInterface(handle *usb.DeviceHandle, iface *usb.Interface) *MIDIStreamingInterface {
+func NewMIDIStreamingInterface(handle Transport, iface *usb.Interface) *MIDIStreamingInterface {
 	return &MIDIStreamingInterface{handle: handle, iface: iface}
 }
 
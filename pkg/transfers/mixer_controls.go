@@ -3,8 +3,6 @@ package transfers
 import (
 	"fmt"
 	"time"
-
-	usb "github.com/kevmo314/go-usb"
 )
 
 // Mixer Unit Control Selectors
@@ -22,11 +20,11 @@ const (
 
 // MixerControl provides control over mixer units
 type MixerControl struct {
-	handle *usb.DeviceHandle
+	handle Transport
 	ifnum  uint8
 }
 
-func NewMixerControl(handle *usb.DeviceHandle, interfaceNumber uint8) *MixerControl {
+func NewMixerControl(handle Transport, interfaceNumber uint8) *MixerControl {
 	return &MixerControl{
 		handle: handle,
 		ifnum:  interfaceNumber,
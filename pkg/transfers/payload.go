@@ -9,11 +9,11 @@ import (
 type Payload struct {
 	HeaderInfoBitmask uint8
 	PTS               uint32
-	SCR               struct {
-		SourceTimeClock uint32
-		TokenCounter    uint16
-	}
-	Data []byte
+	SCR               SourceClockReference
+	// Extension holds the header bytes past the standard fields when bHeaderLength is larger than
+	// they need, for instance metadata items sent in the payload header.
+	Extension []byte
+	Data      []byte
 }
 
 func (f *Payload) FrameID() bool {
@@ -49,11 +49,22 @@ func (f *Payload) EndOfHeader() bool {
 }
 
 func (f *Payload) UnmarshalBinary(buf []byte) error {
-	if len(buf) < int(buf[0]) {
+	if len(buf) < 2 || len(buf) < int(buf[0]) {
 		return io.ErrShortBuffer
 	}
+	headerLength := int(buf[0])
 	f.HeaderInfoBitmask = buf[1]
 	offset := 2
+	if f.HasPTS() {
+		offset += 4
+	}
+	if f.HasSCR() {
+		offset += 6
+	}
+	if headerLength < offset {
+		return fmt.Errorf("payload header length %d too short for header info %08b", headerLength, f.HeaderInfoBitmask)
+	}
+	offset = 2
 	if f.HasPTS() {
 		f.PTS = binary.LittleEndian.Uint32(buf[offset : offset+4])
 		offset += 4
@@ -64,7 +75,10 @@ func (f *Payload) UnmarshalBinary(buf []byte) error {
 		f.SCR.TokenCounter = binary.LittleEndian.Uint16(buf[offset : offset+2])
 		offset += 2
 	}
-	f.Data = buf[offset:]
+	// UVC spec 1.5, section 2.4.3.3: bHeaderLength covers the whole header, including any fields
+	// that follow the standard ones.
+	f.Extension = buf[offset:headerLength]
+	f.Data = buf[headerLength:]
 	return nil
 }
 
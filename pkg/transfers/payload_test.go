@@ -195,3 +195,32 @@ func TestPayloadUnmarshalBinary_EndOfFrameAndFrameID(t *testing.T) {
 		t.Error("EndOfFrame() = false, want true")
 	}
 }
+
+func TestPayloadUnmarshalBinary_HeaderExtension(t *testing.T) {
+	// bHeaderLength 8 with only a PTS leaves 2 bytes of header extension before the data.
+	buf := []byte{8, 0x84, 1, 0, 0, 0, 0xAA, 0xBB, 0xDE, 0xAD}
+
+	p := &Payload{}
+	if err := p.UnmarshalBinary(buf); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if p.PTS != 1 {
+		t.Errorf("PTS = %d, want 1", p.PTS)
+	}
+	if len(p.Extension) != 2 || p.Extension[0] != 0xAA || p.Extension[1] != 0xBB {
+		t.Errorf("Extension = %x, want aabb", p.Extension)
+	}
+	if len(p.Data) != 2 || p.Data[0] != 0xDE {
+		t.Errorf("Data = %x, want dead", p.Data)
+	}
+}
+
+func TestPayloadUnmarshalBinary_HeaderTooShort(t *testing.T) {
+	// the bitmask announces PTS and SCR but bHeaderLength only covers the PTS.
+	buf := []byte{6, 0x8C, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	p := &Payload{}
+	if err := p.UnmarshalBinary(buf); err == nil {
+		t.Error("UnmarshalBinary succeeded with a header shorter than its fields")
+	}
+}
@@ -0,0 +1,234 @@
+package transfers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/requests"
+)
+
+// ProbeCacheKey identifies a streaming mode of a device.
+type ProbeCacheKey struct {
+	VendorID, ProductID, DeviceVersion uint16
+	// SerialNumber tells apart devices of the same model. It is empty for devices without one, which
+	// then share their entries.
+	SerialNumber string
+	Interface    uint8
+	FormatIndex  uint8
+	FrameIndex   uint8
+}
+
+func (k ProbeCacheKey) String() string {
+	return fmt.Sprintf("%04x:%04x:%04x:%s/%d/%d/%d", k.VendorID, k.ProductID, k.DeviceVersion, k.SerialNumber, k.Interface, k.FormatIndex, k.FrameIndex)
+}
+
+// ProbeCache persists committed probe controls so later opens of the same mode skip negotiation. It is
+// safe for concurrent use, but not for use by several processes at once.
+type ProbeCache struct {
+	// ValidationTimeout bounds the time ClaimFrameReaderCached waits for the first frames of a cached
+	// mode before falling back to full negotiation. Defaults to two seconds.
+	ValidationTimeout time.Duration
+
+	path string
+
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+// DefaultProbeCachePath returns the path of the probe cache in the user's cache directory.
+func DefaultProbeCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-uvc", "probe-commit.json"), nil
+}
+
+// OpenProbeCache loads the probe cache stored at path. A missing file is an empty cache, it is created
+// on the first Put.
+func OpenProbeCache(path string) (*ProbeCache, error) {
+	c := &ProbeCache{path: path, entries: make(map[string][]byte)}
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &c.entries); err != nil {
+		return nil, fmt.Errorf("probe cache %s is corrupt: %w", path, err)
+	}
+	return c, nil
+}
+
+// Get returns the cached probe control for key.
+func (c *ProbeCache) Get(key ProbeCacheKey) (*descriptors.VideoProbeCommitControl, bool) {
+	c.mu.Lock()
+	buf, ok := c.entries[key.String()]
+	c.mu.Unlock()
+	// UVC 1.0 controls are the shortest at 26 bytes, anything shorter was not written by Put.
+	if !ok || len(buf) < 26 {
+		return nil, false
+	}
+	vpcc := &descriptors.VideoProbeCommitControl{}
+	if err := vpcc.UnmarshalBinary(buf); err != nil {
+		return nil, false
+	}
+	return vpcc, true
+}
+
+// Put stores the probe control for key and saves the cache.
+func (c *ProbeCache) Put(key ProbeCacheKey, vpcc *descriptors.VideoProbeCommitControl) error {
+	buf, err := vpcc.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key.String()] = buf
+	return c.save()
+}
+
+// Delete removes the entry for key and saves the cache.
+func (c *ProbeCache) Delete(key ProbeCacheKey) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key.String()]; !ok {
+		return nil
+	}
+	delete(c.entries, key.String())
+	return c.save()
+}
+
+// save writes the cache to a temporary file and renames it over the cache, so readers never see a
+// partially written cache.
+func (c *ProbeCache) save() error {
+	buf, err := json.MarshalIndent(c.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// ProbeCacheKey returns the cache key of the given mode on this streaming interface.
+func (si *StreamingInterface) ProbeCacheKey(formatIndex, frameIndex uint8) ProbeCacheKey {
+	desc := si.handle.Descriptor()
+	key := ProbeCacheKey{
+		VendorID:      desc.VendorID,
+		ProductID:     desc.ProductID,
+		DeviceVersion: desc.DeviceVersion,
+		Interface:     si.InterfaceNumber(),
+		FormatIndex:   formatIndex,
+		FrameIndex:    frameIndex,
+	}
+	if desc.SerialNumberIndex != 0 {
+		key.SerialNumber, _ = si.handle.StringDescriptor(desc.SerialNumberIndex)
+	}
+	return key
+}
+
+// ClaimFrameReaderCached opens a frame reader like ClaimFrameReader, using the probe control cached
+// for the mode if there is one. The cached parameters are committed to the device in a single control
+// transfer, whose failure is ignored, and validated against the first frames, which are consumed. If
+// no complete frame arrives in time, the entry is dropped and the mode is negotiated in full. Newly
+// negotiated parameters are stored in the cache.
+func (si *StreamingInterface) ClaimFrameReaderCached(cache *ProbeCache, formatIndex, frameIndex uint8) (*FrameReader, error) {
+	key := si.ProbeCacheKey(formatIndex, frameIndex)
+	if vpcc, ok := cache.Get(key); ok {
+		fr, err := si.claimCommittedFrameReader(vpcc, true)
+		if err == nil {
+			if validateFrames(fr, 3, cache.validationTimeout()) == nil {
+				return fr, nil
+			}
+			fr.Close()
+		}
+		cache.Delete(key)
+	}
+	fr, err := si.ClaimFrameReader(formatIndex, frameIndex)
+	if err != nil {
+		return nil, err
+	}
+	// failing to persist the cache only costs the next open a negotiation.
+	cache.Put(key, fr.vpcc)
+	return fr, nil
+}
+
+func (c *ProbeCache) validationTimeout() time.Duration {
+	if c.ValidationTimeout > 0 {
+		return c.ValidationTimeout
+	}
+	return 2 * time.Second
+}
+
+// commit sends vpcc to the commit control without probing first.
+func (si *StreamingInterface) commit(vpcc *descriptors.VideoProbeCommitControl) error {
+	buf := make([]byte, si.probeCommitSize())
+	if err := vpcc.MarshalInto(buf); err != nil {
+		return err
+	}
+	_, err := si.handle.ControlTransfer(
+		uint8(requests.RequestTypeVideoInterfaceSetRequest),
+		uint8(requests.RequestCodeSetCur),
+		uint16(VideoStreamingInterfaceControlSelectorCommitControl)<<8,
+		uint16(si.InterfaceNumber()),
+		buf,
+		5*time.Second,
+	)
+	if err != nil {
+		return fmt.Errorf("control_transfer SET_CUR commit failed: %w", err)
+	}
+	return nil
+}
+
+// validateFrames reads up to n frames from fr and returns nil as soon as one of them is complete, or
+// an error describing the last frame otherwise. The first frame may start in the middle of a frame, so
+// it alone is not conclusive. If no frame completes within timeout, fr is closed to unblock the read.
+func validateFrames(fr *FrameReader, n int, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		var last FrameIntegrity
+		for range n {
+			f, err := fr.ReadFrame()
+			if err != nil {
+				result <- err
+				return
+			}
+			last = f.Integrity
+			f.Release()
+			if last.BytesReceived > 0 && last.Complete() {
+				result <- nil
+				return
+			}
+		}
+		result <- fmt.Errorf("incomplete frame of %d bytes, expected %d and at most %d", last.BytesReceived, last.BytesExpected, last.MaxBytes)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		fr.Close()
+		return fmt.Errorf("no complete frame within %v", timeout)
+	}
+}
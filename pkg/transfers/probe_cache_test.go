@@ -0,0 +1,102 @@
+package transfers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+func TestProbeCache_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go-uvc", "probe-commit.json")
+	c, err := OpenProbeCache(path)
+	if err != nil {
+		t.Fatalf("OpenProbeCache failed: %v", err)
+	}
+	key := ProbeCacheKey{VendorID: 0x046d, ProductID: 0x085e, DeviceVersion: 0x0011, SerialNumber: "ABC", Interface: 1, FormatIndex: 2, FrameIndex: 3}
+	other := key
+	other.SerialNumber = "DEF"
+	if _, ok := c.Get(key); ok {
+		t.Fatal("Get on an empty cache found an entry")
+	}
+	want := &descriptors.VideoProbeCommitControl{
+		FormatIndex:            2,
+		FrameIndex:             3,
+		FrameInterval:          333300 * time.Nanosecond,
+		MaxVideoFrameSize:      614400,
+		MaxPayloadTransferSize: 3072,
+		ClockFrequency:         48000000,
+	}
+	if err := c.Put(key, want); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	c, err = OpenProbeCache(path)
+	if err != nil {
+		t.Fatalf("OpenProbeCache failed: %v", err)
+	}
+	got, ok := c.Get(key)
+	if !ok {
+		t.Fatal("entry was not persisted")
+	}
+	if got.FrameInterval != want.FrameInterval || got.MaxVideoFrameSize != want.MaxVideoFrameSize ||
+		got.MaxPayloadTransferSize != want.MaxPayloadTransferSize || got.ClockFrequency != want.ClockFrequency {
+		t.Errorf("Get = %+v, want %+v", got, want)
+	}
+	if _, ok := c.Get(other); ok {
+		t.Error("Get found an entry for another serial number")
+	}
+
+	if err := c.Delete(key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	c, err = OpenProbeCache(path)
+	if err != nil {
+		t.Fatalf("OpenProbeCache failed: %v", err)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Error("deleted entry was persisted")
+	}
+}
+
+func TestProbeCache_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probe-commit.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenProbeCache(path); err == nil {
+		t.Error("OpenProbeCache accepted a corrupt cache")
+	}
+	if err := os.WriteFile(path, []byte(`{"x": "AAAA"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenProbeCache(path)
+	if err != nil {
+		t.Fatalf("OpenProbeCache failed: %v", err)
+	}
+	if _, ok := c.entries["x"]; !ok {
+		t.Fatal("entry was not loaded")
+	}
+	c.entries[ProbeCacheKey{}.String()] = c.entries["x"]
+	if _, ok := c.Get(ProbeCacheKey{}); ok {
+		t.Error("Get returned a truncated entry")
+	}
+}
+
+func TestValidateFrames(t *testing.T) {
+	// the stream starts in the middle of a frame, the next one is complete.
+	packets := framePayloads(testFrames(3, 3000), 1024)[1:]
+	r := newTestFrameReader(packets, 3000, 1024)
+	r.expectedSize = 3000
+	if err := validateFrames(r, 3, time.Second); err != nil {
+		t.Errorf("validateFrames rejected a stream with complete frames: %v", err)
+	}
+
+	// frames larger than negotiated mean the cached parameters no longer match the device.
+	r = newTestFrameReader(framePayloads(testFrames(3, 6000), 1024), 3000, 1024)
+	if validateFrames(r, 3, time.Second) == nil {
+		t.Error("validateFrames accepted oversized frames")
+	}
+}
@@ -0,0 +1,129 @@
+package transfers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// maxPayloadHeaderLength is the size of a payload header carrying both a PTS and an SCR. UVC spec 1.5,
+// section 2.4.3.3.
+const maxPayloadHeaderLength = 12
+
+// SynthesizeProbe builds the streaming parameters of the given format and frame from the descriptors
+// alone, without any class-specific control request. It is meant for hosts where probe and commit
+// requests fail, see ClaimFrameReaderWithProbeCommit.
+//
+// The frame interval is the default interval of the frame descriptor. The maximum frame size is exact
+// for uncompressed and DV formats and taken from dwMaxVideoFrameBufferSize for MJPEG. For other
+// compressed formats, which do not describe it, it is estimated as a 16 bits per pixel frame. The
+// payload size is the largest packet of the isochronous endpoint, or a whole frame for bulk endpoints.
+func (si *StreamingInterface) SynthesizeProbe(formatIndex, frameIndex uint8) (*descriptors.VideoProbeCommitControl, error) {
+	vpcc := &descriptors.VideoProbeCommitControl{
+		HintBitmask:    uint16(descriptors.ProbeHintFrameInterval),
+		FormatIndex:    formatIndex,
+		FrameIndex:     frameIndex,
+		ClockFrequency: si.clockFreq,
+	}
+	if err := si.synthesizeFrame(vpcc); err != nil {
+		return nil, err
+	}
+	input, err := si.InputHeaderForFormat(formatIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(si.iface.AltSettings) > 1 {
+		for i := range si.iface.AltSettings {
+			altsetting := &si.iface.AltSettings[i]
+			if j, err := findAltEndpoint(altsetting.Endpoints, input.EndpointAddress); err == nil {
+				vpcc.MaxPayloadTransferSize = max(vpcc.MaxPayloadTransferSize, getEndpointMaxPacketSize(altsetting.Endpoints[j]))
+			}
+		}
+		if vpcc.MaxPayloadTransferSize == 0 {
+			return nil, fmt.Errorf("endpoint %#02x not found", input.EndpointAddress)
+		}
+	} else {
+		// UVC spec 1.5, section 2.4.3.2: a bulk payload ends with a short packet, a device may send a
+		// whole frame in one payload.
+		vpcc.MaxPayloadTransferSize = vpcc.MaxVideoFrameSize + maxPayloadHeaderLength
+	}
+	return vpcc, nil
+}
+
+// synthesizeFrame fills in the frame interval and maximum frame size of the format and frame of vpcc.
+func (si *StreamingInterface) synthesizeFrame(vpcc *descriptors.VideoProbeCommitControl) error {
+	var format descriptors.FormatDescriptor
+	for _, desc := range si.Descriptors {
+		var (
+			index         uint8
+			width, height uint16
+			interval      time.Duration
+			size          uint32
+		)
+		switch d := desc.(type) {
+		case *descriptors.DVFormatDescriptor:
+			// DV formats have no frame descriptors, the frame rate follows from the video system.
+			if d.Index() != vpcc.FormatIndex {
+				continue
+			}
+			vpcc.MaxVideoFrameSize = uint32(d.FrameSize())
+			vpcc.FrameInterval = 100100 * time.Second / 3000000
+			if d.Is50Hz() {
+				vpcc.FrameInterval = time.Second / 25
+			}
+			return nil
+		case descriptors.FormatDescriptor:
+			format = d
+			continue
+		case *descriptors.UncompressedFrameDescriptor:
+			index, width, height, interval = d.FrameIndex, d.Width, d.Height, d.DefaultFrameInterval
+			if f, ok := format.(*descriptors.UncompressedFormatDescriptor); ok {
+				size = uint32(width) * uint32(height) * uint32(f.BitsPerPixel) / 8
+			}
+		case *descriptors.MJPEGFrameDescriptor:
+			index, width, height, interval = d.FrameIndex, d.Width, d.Height, d.DefaultFrameInterval
+			size = d.MaxVideoFrameBufferSize
+		case *descriptors.FrameBasedFrameDescriptor:
+			index, width, height, interval = d.FrameIndex, d.Width, d.Height, d.DefaultFrameInterval
+		case *descriptors.H264FrameDescriptor:
+			index, width, height, interval = d.FrameIndex, d.Width, d.Height, d.DefaultFrameInterval
+		case *descriptors.VP8FrameDescriptor:
+			index, width, height, interval = d.FrameIndex, d.Width, d.Height, d.DefaultFrameInterval
+		default:
+			continue
+		}
+		if format == nil || format.Index() != vpcc.FormatIndex || index != vpcc.FrameIndex {
+			continue
+		}
+		if size == 0 {
+			size = uint32(width) * uint32(height) * 2
+		}
+		vpcc.FrameInterval = interval
+		vpcc.MaxVideoFrameSize = size
+		return nil
+	}
+	return fmt.Errorf("frame %d of format %d not found", vpcc.FrameIndex, vpcc.FormatIndex)
+}
+
+// ClaimFrameReaderWithSynthesizedProbe opens a frame reader with the parameters returned by
+// SynthesizeProbe, without sending any class-specific control request. The device streams with the
+// parameters it was last committed, usually its defaults, so the parameters are validated against the
+// first frames, which are consumed. If no complete frame arrives within timeout the reader is closed
+// and an error describing the last frame is returned; the caller then has to provide the parameters to
+// ClaimFrameReaderWithProbeCommit itself.
+func (si *StreamingInterface) ClaimFrameReaderWithSynthesizedProbe(formatIndex, frameIndex uint8, timeout time.Duration) (*FrameReader, error) {
+	vpcc, err := si.SynthesizeProbe(formatIndex, frameIndex)
+	if err != nil {
+		return nil, err
+	}
+	fr, err := si.ClaimFrameReaderWithProbeCommit(vpcc)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateFrames(fr, 3, timeout); err != nil {
+		fr.Close()
+		return nil, fmt.Errorf("synthesized probe does not match the stream: %w", err)
+	}
+	return fr, nil
+}
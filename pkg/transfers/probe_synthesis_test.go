@@ -0,0 +1,72 @@
+package transfers
+
+import (
+	"testing"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+func TestStreamingInterface_SynthesizeProbe(t *testing.T) {
+	streamDescriptors := []descriptors.StreamingInterface{
+		&descriptors.InputHeaderDescriptor{EndpointAddress: 0x81},
+		&descriptors.UncompressedFormatDescriptor{FormatIndex: 1, BitsPerPixel: 16},
+		&descriptors.UncompressedFrameDescriptor{FrameIndex: 1, Width: 640, Height: 480, DefaultFrameInterval: time.Second / 30},
+		&descriptors.MJPEGFormatDescriptor{FormatIndex: 2},
+		&descriptors.MJPEGFrameDescriptor{FrameIndex: 1, Width: 1280, Height: 720, MaxVideoFrameBufferSize: 400000, DefaultFrameInterval: time.Second / 60},
+		&descriptors.H264FormatDescriptor{FormatIndex: 3},
+		&descriptors.H264FrameDescriptor{FrameIndex: 1, Width: 1920, Height: 1080, DefaultFrameInterval: time.Second / 30},
+	}
+	iso := &StreamingInterface{
+		iface:       isoInterface(isoEndpoint(1024, 1), isoEndpoint(1024|2<<11, 1)),
+		clockFreq:   48000000,
+		Descriptors: streamDescriptors,
+	}
+	bulk := &StreamingInterface{
+		iface: &usb.Interface{AltSettings: []usb.InterfaceAltSetting{{InterfaceNumber: 1, NumEndpoints: 1, Endpoints: []usb.Endpoint{
+			{EndpointAddr: 0x81, Attributes: uint8(usb.TransferTypeBulk), MaxPacketSize: 512},
+		}}}},
+		Descriptors: streamDescriptors,
+	}
+
+	tests := []struct {
+		name                  string
+		si                    *StreamingInterface
+		format, frame         uint8
+		interval              time.Duration
+		frameSize, payloadMax uint32
+	}{
+		{"uncompressed", iso, 1, 1, time.Second / 30, 640 * 480 * 2, 3 * 1024},
+		{"mjpeg", iso, 2, 1, time.Second / 60, 400000, 3 * 1024},
+		{"h264 estimate", iso, 3, 1, time.Second / 30, 1920 * 1080 * 2, 3 * 1024},
+		{"bulk", bulk, 1, 1, time.Second / 30, 640 * 480 * 2, 640*480*2 + maxPayloadHeaderLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vpcc, err := tt.si.SynthesizeProbe(tt.format, tt.frame)
+			if err != nil {
+				t.Fatalf("SynthesizeProbe failed: %v", err)
+			}
+			if vpcc.FormatIndex != tt.format || vpcc.FrameIndex != tt.frame {
+				t.Errorf("indices = %d/%d, want %d/%d", vpcc.FormatIndex, vpcc.FrameIndex, tt.format, tt.frame)
+			}
+			if vpcc.FrameInterval != tt.interval {
+				t.Errorf("FrameInterval = %v, want %v", vpcc.FrameInterval, tt.interval)
+			}
+			if vpcc.MaxVideoFrameSize != tt.frameSize {
+				t.Errorf("MaxVideoFrameSize = %d, want %d", vpcc.MaxVideoFrameSize, tt.frameSize)
+			}
+			if vpcc.MaxPayloadTransferSize != tt.payloadMax {
+				t.Errorf("MaxPayloadTransferSize = %d, want %d", vpcc.MaxPayloadTransferSize, tt.payloadMax)
+			}
+			if vpcc.ClockFrequency != tt.si.clockFreq {
+				t.Errorf("ClockFrequency = %d, want %d", vpcc.ClockFrequency, tt.si.clockFreq)
+			}
+		})
+	}
+
+	if _, err := iso.SynthesizeProbe(2, 2); err == nil {
+		t.Error("SynthesizeProbe accepted a missing frame")
+	}
+}
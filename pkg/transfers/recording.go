@@ -0,0 +1,374 @@
+package transfers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// recordingMagic starts every recording, followed by the format version.
+const recordingMagic = "UVCREC"
+
+const recordingVersion = 2
+
+// maxRecordingBlock bounds the blocks of a recording, so a corrupt length does not allocate without
+// limit. Bulk payloads may hold a whole uncompressed frame.
+const maxRecordingBlock = 64 << 20
+
+// A recording holds the raw payloads of a stream along with what is needed to assemble and decode them
+// again. It is laid out as
+//
+//	"UVCREC" version:u8
+//	device descriptor:18 bytes, interface:u8, bcdUVC:u16, clock frequency:u32, start:i64 unix ns
+//	probe length:uvarint, committed probe control
+//	configuration length:uvarint, configuration descriptor as read from the device
+//
+// followed by a record per payload
+//
+//	time since the previous payload:uvarint µs, packets lost before it:uvarint, length:uvarint, payload
+//
+// All fixed-size integers are little endian.
+
+// Recorder tees the payloads read by a FrameReader to a recording. Payloads are buffered, call Flush or
+// close the reader to write them out.
+type Recorder struct {
+	pr   io.Reader
+	last time.Time
+	lost uint64
+
+	mu  sync.Mutex
+	w   *bufio.Writer
+	err error
+}
+
+// Record starts recording the payloads of the stream to w, beginning with the next payload read. It
+// must be called before the reader is used, or from the goroutine reading frames. A failure to write
+// the recording does not interrupt the stream, it stops the recording and is reported by Flush.
+func (r *FrameReader) Record(w io.Writer) (*Recorder, error) {
+	if r.si == nil {
+		return nil, fmt.Errorf("frame reader has no streaming interface")
+	}
+	probe, err := r.vpcc.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	config, err := readConfigDescriptor(r.si.handle)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	hdr := append([]byte(recordingMagic), recordingVersion)
+	hdr, err = binary.Append(hdr, binary.LittleEndian, r.si.handle.Descriptor())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode device descriptor: %w", err)
+	}
+	hdr = append(hdr, r.si.InterfaceNumber())
+	hdr = binary.LittleEndian.AppendUint16(hdr, r.si.bcdUVC)
+	hdr = binary.LittleEndian.AppendUint32(hdr, r.si.clockFreq)
+	hdr = binary.LittleEndian.AppendUint64(hdr, uint64(start.UnixNano()))
+	hdr = binary.AppendUvarint(hdr, uint64(len(probe)))
+	hdr = append(hdr, probe...)
+	hdr = binary.AppendUvarint(hdr, uint64(len(config)))
+	hdr = append(hdr, config...)
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(hdr); err != nil {
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+
+	rec := &Recorder{pr: r.pr, last: start, w: bw}
+	if lc, ok := r.pr.(packetLossCounter); ok {
+		rec.lost = lc.LostPackets()
+	}
+	r.pr = rec
+	return rec, nil
+}
+
+// readConfigDescriptor reads the first configuration descriptor of the device with its interface,
+// endpoint and class-specific descriptors, as the device sends it. USB 2.0 spec, section 9.4.3: the
+// first nine bytes hold wTotalLength, the size of the whole descriptor.
+func readConfigDescriptor(t Transport) ([]byte, error) {
+	buf := make([]byte, 9)
+	for {
+		n, err := t.ControlTransfer(0x80, 0x06, 0x0200, 0, buf, 5*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configuration descriptor: %w", err)
+		}
+		if n < 9 || buf[1] != 0x02 {
+			return nil, fmt.Errorf("invalid configuration descriptor of %d bytes", n)
+		}
+		total := int(binary.LittleEndian.Uint16(buf[2:4]))
+		if n >= total {
+			return buf[:total], nil
+		}
+		if len(buf) >= total {
+			return nil, fmt.Errorf("configuration descriptor of %d bytes is shorter than its total length %d", n, total)
+		}
+		buf = make([]byte, total)
+	}
+}
+
+func (rec *Recorder) Read(buf []byte) (int, error) {
+	n, err := rec.pr.Read(buf)
+	if err != nil || n == 0 {
+		return n, err
+	}
+	elapsed := time.Since(rec.last).Truncate(time.Microsecond)
+	rec.last = rec.last.Add(elapsed)
+	var lost uint64
+	if lc, ok := rec.pr.(packetLossCounter); ok {
+		total := lc.LostPackets()
+		lost, rec.lost = total-rec.lost, total
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.err == nil {
+		var hdr [3 * binary.MaxVarintLen64]byte
+		h := binary.AppendUvarint(hdr[:0], uint64(elapsed/time.Microsecond))
+		h = binary.AppendUvarint(h, lost)
+		h = binary.AppendUvarint(h, uint64(n))
+		if _, err := rec.w.Write(h); err != nil {
+			rec.err = err
+		} else if _, err := rec.w.Write(buf[:n]); err != nil {
+			rec.err = err
+		}
+	}
+	return n, nil
+}
+
+// Flush writes the buffered payloads to the recording and returns the first error encountered while
+// recording.
+func (rec *Recorder) Flush() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.err == nil {
+		rec.err = rec.w.Flush()
+	}
+	return rec.err
+}
+
+// LostPackets forwards the packet loss count of the recorded reader.
+func (rec *Recorder) LostPackets() uint64 {
+	if lc, ok := rec.pr.(packetLossCounter); ok {
+		return lc.LostPackets()
+	}
+	return 0
+}
+
+// Stats forwards the transfer statistics of the recorded reader.
+func (rec *Recorder) Stats() TransferStats {
+	if ts, ok := rec.pr.(transferStatser); ok {
+		return ts.Stats()
+	}
+	return TransferStats{}
+}
+
+// Close flushes the recording and closes the recorded reader. The writer passed to Record is left open.
+func (rec *Recorder) Close() error {
+	err := rec.Flush()
+	if c, ok := rec.pr.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Recording is a stream recorded by a Recorder, opened for replay.
+type Recording struct {
+	// Device is the descriptor of the recorded device.
+	Device usb.DeviceDescriptor
+	// Interface is the number of the recorded streaming interface.
+	Interface uint8
+	// Start is the host time at which the recording started.
+	Start time.Time
+	// ProbeCommit holds the streaming parameters committed for the recorded stream.
+	ProbeCommit *descriptors.VideoProbeCommitControl
+
+	si *StreamingInterface
+	r  *bufio.Reader
+}
+
+// ReadRecording reads the header of a recording from r. The payloads are read as the recording is
+// replayed. parse builds the streaming interfaces of the recorded configuration descriptor, such as
+// the StreamingInterfaces of uvc.ParseConfigDescriptor, and the recorded interface is taken from them.
+func ReadRecording(r io.Reader, parse func(config []byte) ([]*StreamingInterface, error)) (*Recording, error) {
+	br := bufio.NewReader(r)
+	var hdr [len(recordingMagic) + 1]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, fmt.Errorf("failed to read recording header: %w", err)
+	}
+	if string(hdr[:len(recordingMagic)]) != recordingMagic {
+		return nil, fmt.Errorf("not a recording")
+	}
+	if hdr[len(recordingMagic)] != recordingVersion {
+		return nil, fmt.Errorf("unsupported recording version %d", hdr[len(recordingMagic)])
+	}
+
+	rec := &Recording{}
+	var fixed [1 + 2 + 4 + 8]byte
+	if err := binary.Read(br, binary.LittleEndian, &rec.Device); err != nil {
+		return nil, fmt.Errorf("failed to read device descriptor: %w", err)
+	}
+	if _, err := io.ReadFull(br, fixed[:]); err != nil {
+		return nil, fmt.Errorf("failed to read recording header: %w", err)
+	}
+	rec.Interface = fixed[0]
+	bcdUVC := binary.LittleEndian.Uint16(fixed[1:3])
+	clockFreq := binary.LittleEndian.Uint32(fixed[3:7])
+	rec.Start = time.Unix(0, int64(binary.LittleEndian.Uint64(fixed[7:15])))
+
+	probe, err := readRecordingBlock(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read probe control: %w", err)
+	}
+	if len(probe) < 26 {
+		return nil, fmt.Errorf("probe control too short: %d bytes", len(probe))
+	}
+	rec.ProbeCommit = &descriptors.VideoProbeCommitControl{}
+	if err := rec.ProbeCommit.UnmarshalBinary(probe); err != nil {
+		return nil, fmt.Errorf("failed to parse probe control: %w", err)
+	}
+	// readers allocate from these sizes, and payloads are recorded as blocks.
+	if rec.ProbeCommit.MaxPayloadTransferSize > maxRecordingBlock || rec.ProbeCommit.MaxVideoFrameSize > maxRecordingBlock {
+		return nil, fmt.Errorf("probe control sizes %d and %d exceed %d bytes", rec.ProbeCommit.MaxPayloadTransferSize, rec.ProbeCommit.MaxVideoFrameSize, maxRecordingBlock)
+	}
+
+	config, err := readRecordingBlock(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration descriptor: %w", err)
+	}
+	sis, err := parse(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration descriptor: %w", err)
+	}
+	for _, si := range sis {
+		if si.InterfaceNumber() == rec.Interface {
+			// replay with the version and clock the stream was read with.
+			replayed := *si
+			replayed.bcdUVC, replayed.clockFreq = bcdUVC, clockFreq
+			rec.si = &replayed
+			break
+		}
+	}
+	if rec.si == nil {
+		return nil, fmt.Errorf("streaming interface %d not found", rec.Interface)
+	}
+	rec.r = br
+	return rec, nil
+}
+
+func readRecordingBlock(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxRecordingBlock {
+		return nil, fmt.Errorf("block of %d bytes is too large", n)
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(br, buf)
+	return buf, err
+}
+
+// StreamingInterface returns the recorded streaming interface, as built by the parser passed to
+// ReadRecording. It is not backed by a device, only its descriptors are usable.
+func (rec *Recording) StreamingInterface() *StreamingInterface {
+	return rec.si
+}
+
+// Mode returns the format and frame descriptors of the recorded stream, as needed to decode it. The
+// frame descriptor is nil for formats without frame descriptors.
+func (rec *Recording) Mode() (descriptors.FormatDescriptor, descriptors.FrameDescriptor, error) {
+	var format descriptors.FormatDescriptor
+	for _, desc := range rec.si.Descriptors {
+		switch d := desc.(type) {
+		case descriptors.FormatDescriptor:
+			if format != nil && format.Index() == rec.ProbeCommit.FormatIndex {
+				// the frames of the recorded format ended without a match.
+				return format, nil, nil
+			}
+			format = d
+		case descriptors.FrameDescriptor:
+			if format != nil && format.Index() == rec.ProbeCommit.FormatIndex && d.Index() == rec.ProbeCommit.FrameIndex {
+				return format, d, nil
+			}
+		}
+	}
+	if format != nil && format.Index() == rec.ProbeCommit.FormatIndex {
+		return format, nil, nil
+	}
+	return nil, nil, fmt.Errorf("format %d not found", rec.ProbeCommit.FormatIndex)
+}
+
+// NewFrameReader returns a frame reader that assembles the recorded payloads. If realTime is set the
+// payloads are delivered with their recorded timing, otherwise as fast as they are read. The reader
+// returns io.EOF at the end of the recording.
+func (rec *Recording) NewFrameReader(realTime bool) *FrameReader {
+	vpcc := rec.ProbeCommit
+	rr := &replayReader{r: rec.r, realTime: realTime}
+	// the reader has no streaming interface, so closing it does not touch the recorded device.
+	r := newFrameReader(vpcc, rr, max(vpcc.MaxPayloadTransferSize, 1))
+	r.clock = NewClockModel(rec.si.clockFrequency(vpcc))
+	r.expectedSize = rec.si.fixedFrameSize(vpcc)
+	return r
+}
+
+// replayReader reads the payloads of a recording.
+type replayReader struct {
+	r        *bufio.Reader
+	realTime bool
+	next     time.Time
+	lost     uint64
+	pending  []byte
+}
+
+func (rr *replayReader) Read(buf []byte) (int, error) {
+	if rr.pending == nil {
+		delay, err := binary.ReadUvarint(rr.r)
+		if errors.Is(err, io.EOF) {
+			return 0, io.EOF
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to read recording: %w", err)
+		}
+		lost, err := binary.ReadUvarint(rr.r)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read recording: %w", io.ErrUnexpectedEOF)
+		}
+		pkt, err := readRecordingBlock(rr.r)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read recording: %w", io.ErrUnexpectedEOF)
+		}
+		rr.lost += lost
+		rr.pending = pkt
+		if rr.realTime {
+			if rr.next.IsZero() {
+				rr.next = time.Now()
+			}
+			rr.next = rr.next.Add(time.Duration(delay) * time.Microsecond)
+			time.Sleep(time.Until(rr.next))
+		}
+	}
+	if len(buf) < len(rr.pending) {
+		return 0, io.ErrShortBuffer
+	}
+	n := copy(buf, rr.pending)
+	rr.pending = nil
+	return n, nil
+}
+
+func (rr *replayReader) LostPackets() uint64 {
+	return rr.lost
+}
@@ -0,0 +1,127 @@
+package transfers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/simulator"
+)
+
+// parseRecordedConfig builds the video streaming interfaces of a recorded configuration descriptor
+// with the descriptors of their first alternate setting, like uvc.ParseConfigDescriptor.
+func parseRecordedConfig(config []byte) ([]*StreamingInterface, error) {
+	cd := &usb.ConfigDescriptor{}
+	if err := cd.Unmarshal(config); err != nil {
+		return nil, err
+	}
+	var sis []*StreamingInterface
+	for i := range cd.Interfaces {
+		iface := &cd.Interfaces[i]
+		if len(iface.AltSettings) == 0 || iface.AltSettings[0].InterfaceClass != 0x0e || iface.AltSettings[0].InterfaceSubClass != 0x02 {
+			continue
+		}
+		si := NewStreamingInterface(nil, iface, 0)
+		blocks, err := descriptors.SplitDescriptors(iface.AltSettings[0].Extra)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range blocks {
+			if block[1] != 0x24 {
+				continue
+			}
+			desc, err := descriptors.UnmarshalStreamingInterface(block)
+			if err != nil {
+				return nil, err
+			}
+			si.Descriptors = append(si.Descriptors, desc)
+		}
+		sis = append(sis, si)
+	}
+	return sis, nil
+}
+
+func TestRecording_Replay(t *testing.T) {
+	dev := simulator.Webcam()
+	si := webcamStreamingInterface(t, dev)
+	frames := testFrames(3, 320*240*2)
+	dev.SetPayloadSource(simulator.WebcamEndpoint, simulator.Frames(simulator.WebcamPacketSize, frames...))
+
+	r, err := si.ClaimFrameReader(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := r.Record(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Release()
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := ReadRecording(&buf, parseRecordedConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Device.VendorID != 0x1209 || rec.Interface != simulator.WebcamStreamingInterface {
+		t.Errorf("device = %04x, interface = %d", rec.Device.VendorID, rec.Interface)
+	}
+	if *rec.ProbeCommit != *r.ProbeCommit() {
+		t.Errorf("probe = %+v, want %+v", rec.ProbeCommit, r.ProbeCommit())
+	}
+	format, frame, err := rec.Mode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := format.(*descriptors.UncompressedFormatDescriptor); !ok {
+		t.Errorf("format = %T, want uncompressed", format)
+	}
+	if fd, ok := frame.(*descriptors.UncompressedFrameDescriptor); !ok || fd.Width != 320 {
+		t.Errorf("frame = %+v", frame)
+	}
+
+	replay := rec.NewFrameReader(false)
+	for i, want := range frames {
+		f, err := replay.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !bytes.Equal(f.Bytes(), want) || !f.Integrity.Complete() {
+			t.Errorf("frame %d differs from the recorded one", i)
+		}
+		f.Release()
+	}
+	if _, err := replay.ReadFrame(); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadFrame at end of recording = %v, want io.EOF", err)
+	}
+	if err := replay.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadRecording_Invalid(t *testing.T) {
+	if _, err := ReadRecording(bytes.NewReader([]byte("UVCREC\x01")), parseRecordedConfig); err == nil {
+		t.Error("unsupported version accepted")
+	}
+	if _, err := ReadRecording(bytes.NewReader([]byte("not a recording")), parseRecordedConfig); err == nil {
+		t.Error("invalid magic accepted")
+	}
+	for _, vpcc := range []*descriptors.VideoProbeCommitControl{
+		{FormatIndex: 1, FrameIndex: 1, MaxVideoFrameSize: 300, MaxPayloadTransferSize: 1381060687},
+		{FormatIndex: 1, FrameIndex: 1, MaxVideoFrameSize: 1313688651, MaxPayloadTransferSize: 128},
+	} {
+		if _, err := ReadRecording(bytes.NewReader(fuzzRecording(t, vpcc, nil)), parseRecordedConfig); err == nil {
+			t.Errorf("probe control with sizes %d and %d accepted", vpcc.MaxVideoFrameSize, vpcc.MaxPayloadTransferSize)
+		}
+	}
+}
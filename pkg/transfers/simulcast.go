@@ -0,0 +1,259 @@
+package transfers
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// layerState is the frame being assembled for one layer of a simulcast stream.
+type layerState struct {
+	fid   bool
+	frame *Frame
+}
+
+// SimulcastReader reads the frames of H.264 and VP8 simulcast and scalable streams, keeping the
+// frames of each stream and layer apart. UVC H.264 payload spec 1.5, section 2.2: the payloads of all
+// layers are interleaved on one endpoint and tagged with their wLayerOrViewID, and the frame id bit
+// toggles per layer.
+type SimulcastReader struct {
+	fr     *FrameReader
+	p      Payload
+	layers map[descriptors.LayerID]*layerState
+	ready  []*Frame
+
+	mu   sync.Mutex
+	subs map[descriptors.LayerID]chan *Frame
+}
+
+// ClaimSimulcastReader negotiates the given format and frame with the device, enabling the simulcast
+// streams described by layouts in bmLayoutPerStream, and opens a simulcast reader on this streaming
+// interface.
+func (si *StreamingInterface) ClaimSimulcastReader(formatIndex, frameIndex uint8, layouts [4]descriptors.StreamLayout) (*SimulcastReader, error) {
+	probe := descriptors.NewProbe(formatIndex, frameIndex)
+	for i, l := range layouts {
+		probe.Layout(i, l)
+	}
+	return si.ClaimSimulcastReaderWithProbe(probe)
+}
+
+// ClaimSimulcastReaderWithProbe is ClaimSimulcastReader with the streams and their rate control modes
+// set on probe with ProbeBuilder.Stream.
+func (si *StreamingInterface) ClaimSimulcastReaderWithProbe(probe *descriptors.ProbeBuilder) (*SimulcastReader, error) {
+	fr, err := si.ClaimFrameReaderWithProbe(probe)
+	if err != nil {
+		return nil, err
+	}
+	return newSimulcastReader(fr), nil
+}
+
+func newSimulcastReader(fr *FrameReader) *SimulcastReader {
+	return &SimulcastReader{
+		fr:     fr,
+		layers: make(map[descriptors.LayerID]*layerState),
+		subs:   make(map[descriptors.LayerID]chan *Frame),
+	}
+}
+
+// Layouts returns the stream layouts negotiated with the device.
+func (r *SimulcastReader) Layouts() [4]descriptors.StreamLayout {
+	var layouts [4]descriptors.StreamLayout
+	for i, l := range r.fr.vpcc.LayoutPerStream {
+		layouts[i] = descriptors.StreamLayout(l)
+	}
+	return layouts
+}
+
+// ReadFrame reads payloads until a frame of any layer is complete and returns it. The frame's Layer
+// tells which layer it belongs to. Call Release on the frame once it is no longer needed.
+func (r *SimulcastReader) ReadFrame() (*Frame, error) {
+	for len(r.ready) == 0 {
+		if err := r.readPayload(); err != nil {
+			return nil, err
+		}
+	}
+	f := r.ready[0]
+	r.ready[0] = nil
+	r.ready = r.ready[1:]
+	return f, nil
+}
+
+// readPayload reads a single payload into the frame of its layer, queueing the frames it completes.
+func (r *SimulcastReader) readPayload() error {
+	n, err := r.fr.pr.Read(r.fr.scratch)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	at := time.Now()
+	p := &r.p
+	if err := p.UnmarshalBinary(r.fr.scratch[:n]); err != nil {
+		return err
+	}
+	var id descriptors.LayerID
+	ext := p.Extension
+	if len(ext) >= 2 {
+		id = descriptors.LayerID(binary.LittleEndian.Uint16(ext[0:2]))
+		ext = ext[2:]
+	}
+
+	l, ok := r.layers[id]
+	if !ok {
+		l = &layerState{}
+		r.layers[id] = l
+	}
+	if l.frame != nil && p.FrameID() != l.fid {
+		// frame id bit flipped, the previous frame of this layer is complete.
+		r.finish(l, false)
+	}
+	if l.frame == nil {
+		l.frame = r.fr.newFrame()
+		l.frame.Layer = id
+		l.frame.CaptureTime = at
+		l.fid = p.FrameID()
+	}
+
+	f := l.frame
+	fp := f.nextPayload()
+	*fp = *p
+	fp.Extension = nil
+	if fp.Error() {
+		f.Integrity.ErrorFlagged = true
+	}
+	if fp.HasPTS() && !f.hasPTS {
+		f.PTS, f.hasPTS = fp.PTS, true
+	}
+	if fp.HasSCR() {
+		f.SCR, f.hasSCR = fp.SCR, true
+		r.fr.clock.AddSample(fp.SCR.SourceTimeClock, at)
+	}
+	if len(ext) > 0 {
+		if f.Metadata == nil {
+			f.Metadata = &Metadata{}
+		}
+		if err := f.Metadata.append(ext); err != nil {
+			f.Integrity.InvalidMetadata = true
+		}
+	}
+	if len(f.buf)+len(fp.Data) > cap(f.buf) {
+		f.grow(len(fp.Data))
+	}
+	start := len(f.buf)
+	f.buf = append(f.buf, fp.Data...)
+	fp.Data = f.buf[start:]
+
+	if fp.EndOfFrame() {
+		r.finish(l, true)
+	}
+	return nil
+}
+
+// finish detaches the frame being assembled for l and queues it, unless the integrity policy drops it.
+func (r *SimulcastReader) finish(l *layerState, eof bool) {
+	f := l.frame
+	l.frame = nil
+	if !r.fr.finish(f, eof, true) {
+		f.Release()
+		return
+	}
+	r.ready = append(r.ready, f)
+}
+
+// SetIntegrityPolicy sets the policy for incomplete frames. It must not be called concurrently with
+// ReadFrame.
+func (r *SimulcastReader) SetIntegrityPolicy(p IntegrityPolicy) {
+	r.fr.SetIntegrityPolicy(p)
+}
+
+// Subscribe returns a channel on which StartStream delivers the frames of the given layer, buffering
+// up to bufferDepth frames. Frames of layers without a subscriber are discarded. Subscribe must be
+// called before StartStream.
+func (r *SimulcastReader) Subscribe(id descriptors.LayerID, bufferDepth int) <-chan *Frame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch, ok := r.subs[id]
+	if !ok {
+		ch = make(chan *Frame, max(bufferDepth, 1))
+		r.subs[id] = ch
+	}
+	return ch
+}
+
+// StartStream reads frames on a dedicated goroutine and delivers each on the channel subscribed to
+// its layer, applying policy when a channel is full. The consumers own the received frames and should
+// Release them once done.
+//
+// The channels are closed when ctx is cancelled or reading fails, in which case Err returns the error.
+// Cancelling ctx also closes the reader.
+func (r *SimulcastReader) StartStream(ctx context.Context, policy DropPolicy) error {
+	if !r.fr.streaming.CompareAndSwap(false, true) {
+		return ErrStreamStarted
+	}
+	r.mu.Lock()
+	subs := make(map[descriptors.LayerID]chan *Frame, len(r.subs))
+	for id, ch := range r.subs {
+		subs[id] = ch
+	}
+	r.mu.Unlock()
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.Close()
+		case <-done:
+		}
+	}()
+
+	go func() {
+		defer func() {
+			for _, ch := range subs {
+				close(ch)
+			}
+		}()
+		defer close(done)
+		for {
+			f, err := r.ReadFrame()
+			if err != nil {
+				if ctx.Err() == nil {
+					r.fr.streamErr.Store(&err)
+				}
+				return
+			}
+			ch, ok := subs[f.Layer]
+			if !ok {
+				f.Release()
+				continue
+			}
+			if !r.fr.deliver(ctx, ch, f, policy) {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Err returns the error that ended the stream, or nil if the stream is running or was cancelled.
+func (r *SimulcastReader) Err() error {
+	return r.fr.Err()
+}
+
+// DroppedFrames returns the number of frames discarded by the stream's drop policy.
+func (r *SimulcastReader) DroppedFrames() uint64 {
+	return r.fr.DroppedFrames()
+}
+
+// Stats returns the statistics of the frames of all layers.
+func (r *SimulcastReader) Stats() FrameStats {
+	return r.fr.Stats()
+}
+
+// Close stops the transfers of this reader and releases its interfaces.
+func (r *SimulcastReader) Close() error {
+	return r.fr.Close()
+}
@@ -0,0 +1,103 @@
+package transfers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// simulcastPayloads splits the frames of each layer into payloads with a 4 byte header holding the
+// layer id, toggling the frame id per layer, and interleaves the layers payload by payload.
+func simulcastPayloads(layers map[descriptors.LayerID][][]byte, ids []descriptors.LayerID, payloadSize int) [][]byte {
+	perLayer := make([][][]byte, len(ids))
+	for i, id := range ids {
+		for j, frame := range layers[id] {
+			for off := 0; off < len(frame); off += payloadSize - 4 {
+				end := min(off+payloadSize-4, len(frame))
+				bitmask := uint8(0b10000000) | uint8(j&1)
+				if end == len(frame) {
+					bitmask |= 0b10
+				}
+				pkt := []byte{4, bitmask}
+				pkt = binary.LittleEndian.AppendUint16(pkt, uint16(id))
+				perLayer[i] = append(perLayer[i], append(pkt, frame[off:end]...))
+			}
+		}
+	}
+	var packets [][]byte
+	for total := len(perLayer[0]) + len(perLayer[1]); len(packets) < total; {
+		for i := range perLayer {
+			if len(perLayer[i]) > 0 {
+				packets = append(packets, perLayer[i][0])
+				perLayer[i] = perLayer[i][1:]
+			}
+		}
+	}
+	return packets
+}
+
+func TestSimulcastReader(t *testing.T) {
+	base := descriptors.LayerID(0)
+	high := descriptors.LayerID(1<<10 | 1<<3) // stream 1, temporal layer 1
+	layers := map[descriptors.LayerID][][]byte{
+		base: testFrames(3, 2500),
+		high: testFrames(3, 700),
+	}
+	packets := simulcastPayloads(layers, []descriptors.LayerID{base, high}, 1024)
+	r := newSimulcastReader(newTestFrameReader(packets, 3000, 1024))
+
+	// the small frames of the second layer complete before the first frame of the base layer.
+	got := map[descriptors.LayerID]int{}
+	for got[base] < 3 {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame failed: %v", err)
+		}
+		i := got[f.Layer]
+		if !bytes.Equal(f.Bytes(), layers[f.Layer][i%3]) {
+			t.Errorf("frame %d of %v mismatch", i, f.Layer)
+		}
+		if !f.Integrity.EndOfFrame {
+			t.Errorf("frame %d of %v has no end of frame", i, f.Layer)
+		}
+		got[f.Layer]++
+		f.Release()
+	}
+	if got[high] < 3 {
+		t.Errorf("frames per layer = %v", got)
+	}
+	if high.StreamID() != 1 || high.TemporalID() != 1 || high.DependencyID() != 0 {
+		t.Errorf("layer id fields = %v", high)
+	}
+}
+
+func TestSimulcastReader_StartStream(t *testing.T) {
+	base := descriptors.LayerID(0)
+	high := descriptors.LayerID(1 << 10)
+	layers := map[descriptors.LayerID][][]byte{
+		base: testFrames(2, 1500),
+		high: testFrames(2, 1500),
+	}
+	packets := simulcastPayloads(layers, []descriptors.LayerID{base, high}, 1024)
+	r := newSimulcastReader(newTestFrameReader(packets, 3000, 1024))
+
+	ch := r.Subscribe(high, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := r.StartStream(ctx, Block); err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	for i := range 4 {
+		f := <-ch
+		if f.Layer != high || !bytes.Equal(f.Bytes(), layers[high][i%2]) {
+			t.Errorf("frame %d = %d bytes of %v", i, f.Len(), f.Layer)
+		}
+		f.Release()
+	}
+	cancel()
+	for range ch {
+	}
+}
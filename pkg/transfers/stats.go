@@ -0,0 +1,199 @@
+package transfers
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TransferStats is a snapshot of the counters of a reader that pulls data from USB transfers.
+type TransferStats struct {
+	// Elapsed is the time since the reader was created.
+	Elapsed time.Duration
+	// Transfers is the number of transfers that completed and were resubmitted.
+	Transfers uint64
+	// Packets is the number of isochronous packets or bulk payloads that carried data.
+	Packets uint64
+	// EmptyPackets is the number of isochronous packets or bulk payloads without data.
+	EmptyPackets uint64
+	// Bytes is the number of bytes received, including payload headers.
+	Bytes uint64
+	// TransferErrors is the number of transfers that failed as a whole.
+	TransferErrors uint64
+	// PacketErrors counts isochronous packets that completed with an error, by their status code.
+	PacketErrors map[int]uint64
+	// Submitted is the number of transfers the reader keeps submitted to the host controller, whether
+	// or not they have completed. It only drops below the reader's transfer count when a transfer
+	// fails to be resubmitted, and is zero once the reader is closed.
+	Submitted int
+}
+
+// Throughput returns the average number of bytes received per second.
+func (s TransferStats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Elapsed.Seconds()
+}
+
+// transferCounters are the counters behind TransferStats, shared by the transfer based readers.
+type transferCounters struct {
+	start time.Time
+
+	transfers      atomic.Uint64
+	packets        atomic.Uint64
+	empty          atomic.Uint64
+	bytes          atomic.Uint64
+	transferErrors atomic.Uint64
+	packetErrors   atomic.Uint64
+	submitted      atomic.Int64
+
+	mu       sync.Mutex
+	byStatus map[int]uint64
+}
+
+// packet counts a packet or payload of n bytes.
+func (c *transferCounters) packet(n int) {
+	if n == 0 {
+		c.empty.Add(1)
+		return
+	}
+	c.packets.Add(1)
+	c.bytes.Add(uint64(n))
+}
+
+// packetError counts a packet that completed with the given status.
+func (c *transferCounters) packetError(status int) {
+	c.packetErrors.Add(1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byStatus == nil {
+		c.byStatus = make(map[int]uint64)
+	}
+	c.byStatus[status]++
+}
+
+func (c *transferCounters) snapshot() TransferStats {
+	s := TransferStats{
+		Elapsed:        time.Since(c.start),
+		Transfers:      c.transfers.Load(),
+		Packets:        c.packets.Load(),
+		EmptyPackets:   c.empty.Load(),
+		Bytes:          c.bytes.Load(),
+		TransferErrors: c.transferErrors.Load(),
+		Submitted:      int(max(c.submitted.Load(), 0)),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.byStatus) > 0 {
+		s.PacketErrors = make(map[int]uint64, len(c.byStatus))
+		for status, n := range c.byStatus {
+			s.PacketErrors[status] = n
+		}
+	}
+	return s
+}
+
+// transferStatser is implemented by the payload readers that keep transfer statistics.
+type transferStatser interface {
+	Stats() TransferStats
+}
+
+// FrameSizeHistogram counts frames by size in power of two buckets: bucket i holds the frames of at
+// least 2^(i-1) and less than 2^i bytes, bucket 0 the empty frames.
+type FrameSizeHistogram [33]uint64
+
+// Bucket returns the index of the bucket that counts frames of n bytes.
+func (h *FrameSizeHistogram) Bucket(n int) int {
+	return bits.Len32(uint32(n))
+}
+
+// frameStatsWindow is the number of recent frames the measured frame rate is averaged over.
+const frameStatsWindow = 32
+
+// FrameStats is a snapshot of the statistics of a FrameReader.
+type FrameStats struct {
+	// Elapsed is the time since the reader was created.
+	Elapsed time.Duration
+	// Frames is the number of frames received, including corrupt frames.
+	Frames uint64
+	// Bytes is the amount of frame data received, excluding payload headers.
+	Bytes uint64
+	// FPS is the frame rate measured over the last frames received.
+	FPS float64
+	// NominalFPS is the frame rate of the negotiated frame interval, or zero if unknown.
+	NominalFPS float64
+	// FrameSizes is a histogram of the sizes of the frames received.
+	FrameSizes FrameSizeHistogram
+	// DroppedFrames is the number of frames discarded by the drop policy of StartStream.
+	DroppedFrames uint64
+	// CorruptFrames is the number of frames that were not received completely.
+	CorruptFrames uint64
+	// QueueDepth is the number of frames buffered by StartStream that the consumer has yet to receive.
+	QueueDepth int
+	// Transfer holds the statistics of the underlying USB reader, if it keeps any.
+	Transfer *TransferStats
+}
+
+// Throughput returns the average number of frame bytes received per second.
+func (s FrameStats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Elapsed.Seconds()
+}
+
+// frameCounters are the counters behind FrameStats.
+type frameCounters struct {
+	mu     sync.Mutex
+	start  time.Time
+	frames uint64
+	bytes  uint64
+	sizes  FrameSizeHistogram
+	times  [frameStatsWindow]time.Time
+}
+
+// frame counts a frame of n bytes that started at t.
+func (c *frameCounters) frame(n int, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.times[c.frames%frameStatsWindow] = t
+	c.frames++
+	c.bytes += uint64(n)
+	c.sizes[c.sizes.Bucket(n)]++
+}
+
+// Stats returns a snapshot of the statistics of the reader.
+func (r *FrameReader) Stats() FrameStats {
+	c := &r.stats
+	c.mu.Lock()
+	s := FrameStats{
+		Elapsed:    time.Since(c.start),
+		Frames:     c.frames,
+		Bytes:      c.bytes,
+		FrameSizes: c.sizes,
+	}
+	if n := min(c.frames, frameStatsWindow); n > 1 {
+		first := c.times[(c.frames-n)%frameStatsWindow]
+		last := c.times[(c.frames-1)%frameStatsWindow]
+		if d := last.Sub(first); d > 0 {
+			s.FPS = float64(n-1) / d.Seconds()
+		}
+	}
+	c.mu.Unlock()
+
+	if r.vpcc.FrameInterval > 0 {
+		s.NominalFPS = float64(time.Second) / float64(r.vpcc.FrameInterval)
+	}
+	s.DroppedFrames = r.dropped.Load()
+	s.CorruptFrames = r.corrupt.Load()
+	if ch := r.streamCh.Load(); ch != nil {
+		s.QueueDepth = len(*ch)
+	}
+	if ts, ok := r.pr.(transferStatser); ok {
+		t := ts.Stats()
+		s.Transfer = &t
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package transfers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/simulator"
+)
+
+// statsReader is a packetReader that reports fixed transfer statistics.
+type statsReader struct {
+	packetReader
+	stats TransferStats
+}
+
+func (r *statsReader) Stats() TransferStats { return r.stats }
+
+func TestFrameReader_Stats(t *testing.T) {
+	frames := testFrames(4, 3000)
+	pr := &statsReader{packetReader: packetReader{packets: framePayloads(frames, 1024)}, stats: TransferStats{Bytes: 42}}
+	r := newTestFrameReader(nil, 3000, 1024)
+	r.pr = pr
+	r.vpcc.FrameInterval = 33333300 * time.Nanosecond
+
+	for range 4 {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame failed: %v", err)
+		}
+		f.Release()
+	}
+
+	s := r.Stats()
+	if s.Frames != 4 || s.Bytes != 12000 {
+		t.Errorf("Frames = %d, Bytes = %d, want 4 and 12000", s.Frames, s.Bytes)
+	}
+	if b := s.FrameSizes.Bucket(3000); s.FrameSizes[b] != 4 || b != 12 {
+		t.Errorf("FrameSizes[%d] = %d, want 4 in bucket 12", b, s.FrameSizes[b])
+	}
+	if s.NominalFPS < 29.99 || s.NominalFPS > 30.01 {
+		t.Errorf("NominalFPS = %v, want 30", s.NominalFPS)
+	}
+	if s.Transfer == nil || s.Transfer.Bytes != 42 {
+		t.Errorf("Transfer = %+v, want the payload reader's stats", s.Transfer)
+	}
+}
+
+func TestTransferCounters(t *testing.T) {
+	c := &transferCounters{start: time.Now().Add(-time.Second)}
+	c.packet(1000)
+	c.packet(0)
+	c.packetError(-18)
+	c.packetError(-18)
+	c.packetError(-71)
+	c.submitted.Add(3)
+
+	s := c.snapshot()
+	if s.Packets != 1 || s.EmptyPackets != 1 || s.Bytes != 1000 || s.Submitted != 3 {
+		t.Errorf("stats = %+v", s)
+	}
+	if s.PacketErrors[-18] != 2 || s.PacketErrors[-71] != 1 {
+		t.Errorf("PacketErrors = %v, want 2 for -18 and 1 for -71", s.PacketErrors)
+	}
+	if tp := s.Throughput(); tp < 900 || tp > 1000 {
+		t.Errorf("Throughput = %v, want ~1000 B/s", tp)
+	}
+}
+
+func TestBulkReader_Stats(t *testing.T) {
+	dev := simulator.MetadataWebcam()
+	cd, err := dev.ConfigDescriptorByValue(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	si := NewStreamingInterface(dev, cd.Interface(simulator.WebcamMetadataInterface), 0x0110)
+	dev.SetPayloadSource(simulator.WebcamMetadataEndpoint, simulator.Payloads(make([]byte, 100), nil, make([]byte, 12)))
+
+	r, err := si.NewBulkReader(simulator.WebcamMetadataEndpoint, simulator.WebcamMetadataSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, simulator.WebcamMetadataSize)
+	for i := 0; i < 3; i++ {
+		if _, err := r.Read(buf); err != nil {
+			t.Fatalf("Read %d failed: %v", i, err)
+		}
+	}
+	if _, err := r.Read(buf); err == nil {
+		t.Fatal("Read after the end of the stream succeeded")
+	}
+
+	s := r.Stats()
+	if s.Transfers != 3 || s.Packets != 2 || s.EmptyPackets != 1 || s.Bytes != 112 || s.TransferErrors != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
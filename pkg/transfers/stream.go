@@ -0,0 +1,137 @@
+package transfers
+
+import (
+	"context"
+	"errors"
+)
+
+// DropPolicy decides what happens to frames when the consumer of a stream falls behind.
+type DropPolicy int
+
+const (
+	// DropOldest discards the oldest buffered frame to make room for the newest one. This keeps
+	// latency low and is suited for previews.
+	DropOldest DropPolicy = iota
+	// DropNewest discards frames that arrive while the buffer is full.
+	DropNewest
+	// Block stops reading from the device until the consumer catches up. The device will drop
+	// frames on its side if the consumer is too slow.
+	Block
+)
+
+func (p DropPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "DropOldest"
+	case DropNewest:
+		return "DropNewest"
+	case Block:
+		return "Block"
+	}
+	return "Unknown"
+}
+
+// StreamOptions configures StartStream.
+type StreamOptions struct {
+	// BufferDepth is the number of frames buffered between the reader goroutine and the consumer.
+	// Defaults to 1.
+	BufferDepth int
+	// DropPolicy is applied when the buffer is full.
+	DropPolicy DropPolicy
+}
+
+// ErrStreamStarted is returned when StartStream is called on a reader that is already streaming.
+var ErrStreamStarted = errors.New("stream already started")
+
+// StartStream reads frames on a dedicated goroutine and delivers them on the returned channel. The
+// consumer owns the received frames and should Release them once done.
+//
+// The channel is closed when ctx is cancelled or reading fails, in which case Err returns the error.
+// Cancelling ctx also closes the reader, cancelling its in-flight transfers.
+func (r *FrameReader) StartStream(ctx context.Context, opts StreamOptions) (<-chan *Frame, error) {
+	if !r.streaming.CompareAndSwap(false, true) {
+		return nil, ErrStreamStarted
+	}
+	ch := make(chan *Frame, max(opts.BufferDepth, 1))
+	r.streamCh.Store(&ch)
+	done := make(chan struct{})
+
+	go func() {
+		// unblock ReadFrame when the stream is cancelled.
+		select {
+		case <-ctx.Done():
+			r.Close()
+		case <-done:
+		}
+	}()
+
+	go func() {
+		defer close(ch)
+		defer close(done)
+		for {
+			f, err := r.ReadFrame()
+			if err != nil {
+				if ctx.Err() == nil {
+					r.streamErr.Store(&err)
+				}
+				return
+			}
+			if !r.deliver(ctx, ch, f, opts.DropPolicy) {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// deliver sends f on ch according to the drop policy. It returns false if ctx was cancelled.
+func (r *FrameReader) deliver(ctx context.Context, ch chan *Frame, f *Frame, policy DropPolicy) bool {
+	switch policy {
+	case Block:
+		select {
+		case ch <- f:
+			return true
+		case <-ctx.Done():
+			f.Release()
+			return false
+		}
+	case DropNewest:
+		select {
+		case ch <- f:
+		default:
+			f.Release()
+			r.dropped.Add(1)
+		}
+		return true
+	default:
+		for {
+			select {
+			case ch <- f:
+				return true
+			default:
+			}
+			// the consumer may have drained the channel in the meantime, so only drop if a frame is
+			// still buffered.
+			select {
+			case old := <-ch:
+				old.Release()
+				r.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+// DroppedFrames returns the number of frames discarded by the stream's drop policy.
+func (r *FrameReader) DroppedFrames() uint64 {
+	return r.dropped.Load()
+}
+
+// Err returns the error that ended the stream, or nil if the stream is running or was cancelled.
+func (r *FrameReader) Err() error {
+	if err := r.streamErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package transfers
+
+import (
+	"sync/atomic"
+)
+
+// StreamReader reads the payload data of a stream based format, such as MPEG-2 TS, as one contiguous
+// byte stream. Unlike frame based formats, the payloads of these formats do not delimit frames, so
+// their headers are stripped without assembling frames.
+type StreamReader struct {
+	fr   *FrameReader
+	p    Payload
+	rest []byte
+
+	errorPayloads atomic.Uint64
+}
+
+// ClaimStreamReader negotiates the given stream based format with the device and opens a stream
+// reader on this streaming interface.
+func (si *StreamingInterface) ClaimStreamReader(formatIndex uint8) (*StreamReader, error) {
+	// UVC spec 1.5, section 4.3.1.1: bFrameIndex is ignored for formats without frame descriptors.
+	fr, err := si.ClaimFrameReader(formatIndex, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamReader{fr: fr}, nil
+}
+
+// Read implements io.Reader. It returns the data of at most one payload per call.
+func (r *StreamReader) Read(buf []byte) (int, error) {
+	for len(r.rest) == 0 {
+		n, err := r.fr.pr.Read(r.fr.scratch)
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			continue
+		}
+		if err := r.p.UnmarshalBinary(r.fr.scratch[:n]); err != nil {
+			return 0, err
+		}
+		if r.p.Error() {
+			r.errorPayloads.Add(1)
+		}
+		r.rest = r.p.Data
+	}
+	n := copy(buf, r.rest)
+	r.rest = r.rest[n:]
+	return n, nil
+}
+
+// ErrorPayloads returns the number of payloads received with the error bit set.
+func (r *StreamReader) ErrorPayloads() uint64 {
+	return r.errorPayloads.Load()
+}
+
+// Stats returns the statistics of the underlying reader. Frame statistics are not collected.
+func (r *StreamReader) Stats() FrameStats {
+	return r.fr.Stats()
+}
+
+// Close stops the transfers of this reader and releases its interfaces.
+func (r *StreamReader) Close() error {
+	return r.fr.Close()
+}
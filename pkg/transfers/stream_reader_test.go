@@ -0,0 +1,26 @@
+package transfers
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestStreamReader(t *testing.T) {
+	// stream based payloads carry a byte stream, the frame id and end of frame bits do not delimit it.
+	data := testFrames(1, 5000)[0]
+	packets := framePayloads([][]byte{data}, 1024)
+	packets[2][1] |= 0b01000000
+	r := &StreamReader{fr: newTestFrameReader(packets, 0, 1024)}
+
+	got := make([]byte, len(data))
+	if _, err := io.ReadFull(r, got); err != nil {
+		t.Fatalf("ReadFull failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("stream data mismatch")
+	}
+	if n := r.ErrorPayloads(); n != 1 {
+		t.Errorf("ErrorPayloads = %d, want 1", n)
+	}
+}
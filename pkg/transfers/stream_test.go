@@ -0,0 +1,164 @@
+package transfers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedReader hands out payloads one at a time as they are released with step and blocks in Read
+// until then, like a device that is not sending data. Closing it fails the blocked Read.
+type gatedReader struct {
+	packets [][]byte
+	i       int
+	gate    chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newGatedReader(packets [][]byte) *gatedReader {
+	return &gatedReader{packets: packets, gate: make(chan struct{}, 1024), closed: make(chan struct{})}
+}
+
+func (r *gatedReader) step(n int) {
+	for range n {
+		r.gate <- struct{}{}
+	}
+}
+
+func (r *gatedReader) Read(buf []byte) (int, error) {
+	select {
+	case <-r.gate:
+	case <-r.closed:
+		return 0, errors.New("reader closed")
+	}
+	pkt := r.packets[r.i%len(r.packets)]
+	r.i++
+	return copy(buf, pkt), nil
+}
+
+func (r *gatedReader) Close() error {
+	r.once.Do(func() { close(r.closed) })
+	return nil
+}
+
+func TestFrameReader_StartStream_Block(t *testing.T) {
+	frames := testFrames(4, 100)
+	r := newTestFrameReader(framePayloads(frames, 1024), 100, 1024)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := r.StartStream(ctx, StreamOptions{BufferDepth: 1, DropPolicy: Block})
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	if _, err := r.StartStream(ctx, StreamOptions{}); err != ErrStreamStarted {
+		t.Errorf("second StartStream error = %v, want ErrStreamStarted", err)
+	}
+
+	for i := range 8 {
+		f := <-ch
+		if !bytes.Equal(f.Bytes(), frames[i%4]) {
+			t.Errorf("frame %d data mismatch", i)
+		}
+		f.Release()
+	}
+	if n := r.DroppedFrames(); n != 0 {
+		t.Errorf("DroppedFrames = %d, want 0", n)
+	}
+}
+
+func TestFrameReader_StartStream_DropPolicies(t *testing.T) {
+	for _, policy := range []DropPolicy{DropOldest, DropNewest} {
+		t.Run(policy.String(), func(t *testing.T) {
+			frames := testFrames(5, 100)
+			pr := newGatedReader(framePayloads(frames, 1024))
+			r := newTestFrameReader(nil, 100, 1024)
+			r.pr = pr
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ch, err := r.StartStream(ctx, StreamOptions{BufferDepth: 2, DropPolicy: policy})
+			if err != nil {
+				t.Fatalf("StartStream failed: %v", err)
+			}
+
+			// five frames arrive while nobody is reading, the sixth read blocks.
+			pr.step(5)
+			deadline := time.Now().Add(time.Second)
+			for r.DroppedFrames() < 3 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if n := r.DroppedFrames(); n != 3 {
+				t.Fatalf("DroppedFrames = %d, want 3", n)
+			}
+
+			want := []int{0, 1}
+			if policy == DropOldest {
+				want = []int{3, 4}
+			}
+			for _, i := range want {
+				f := <-ch
+				if !bytes.Equal(f.Bytes(), frames[i]) {
+					t.Errorf("got wrong frame, want frame %d", i)
+				}
+				f.Release()
+			}
+		})
+	}
+}
+
+func TestFrameReader_StartStream_Cancel(t *testing.T) {
+	pr := newGatedReader(framePayloads(testFrames(1, 100), 1024))
+	r := newTestFrameReader(nil, 100, 1024)
+	r.pr = pr
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := r.StartStream(ctx, StreamOptions{})
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+
+	// the reader goroutine is blocked waiting for data, cancelling must close the reader to wake it.
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("received a frame after cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream did not stop after cancellation")
+	}
+	select {
+	case <-pr.closed:
+	default:
+		t.Error("underlying reader was not closed")
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err = %v, want nil after cancellation", err)
+	}
+}
+
+func TestFrameReader_StartStream_Error(t *testing.T) {
+	r := newTestFrameReader(nil, 100, 1024)
+	r.pr = &errReader{err: io.ErrUnexpectedEOF}
+
+	ch, err := r.StartStream(context.Background(), StreamOptions{})
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("received a frame from a failing reader")
+	}
+	if err := r.Err(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
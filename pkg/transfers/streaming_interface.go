@@ -26,15 +26,35 @@ const (
 
 type StreamingInterface struct {
 	bcdUVC      uint16 // cached since it's used a lot
-	handle      *usb.DeviceHandle
+	handle      Transport
 	iface       *usb.Interface
+	ctrlIfnum   uint8
+	clockFreq   uint32
 	Descriptors []descriptors.StreamingInterface
 }
 
-func NewStreamingInterface(handle *usb.DeviceHandle, iface *usb.Interface, bcdUVC uint16) *StreamingInterface {
+// NewStreamingInterface creates a streaming interface of a device whose control interface is
+// interface 0. The device clock frequency is taken from the probe, use NewStreamingInterfaceWithHeader
+// to fall back to the VC header for UVC 1.0 devices.
+func NewStreamingInterface(handle Transport, iface *usb.Interface, bcdUVC uint16) *StreamingInterface {
 	return &StreamingInterface{handle: handle, iface: iface, bcdUVC: bcdUVC}
 }
 
+// NewStreamingInterfaceWithHeader creates a streaming interface that belongs to the video function
+// described by the given control interface number and class-specific VC header.
+func NewStreamingInterfaceWithHeader(handle Transport, iface *usb.Interface, ctrlIfnum uint8, header *descriptors.HeaderDescriptor) *StreamingInterface {
+	return &StreamingInterface{handle: handle, iface: iface, ctrlIfnum: ctrlIfnum, bcdUVC: header.UVC, clockFreq: header.ClockFrequency}
+}
+
+// clockFrequency returns the frequency of the device clock used for PTS and SCR values. The probe
+// reports it since UVC 1.1, older devices only report it in the VideoControl header.
+func (si *StreamingInterface) clockFrequency(vpcc *descriptors.VideoProbeCommitControl) uint32 {
+	if vpcc.ClockFrequency != 0 {
+		return vpcc.ClockFrequency
+	}
+	return si.clockFreq
+}
+
 func (si *StreamingInterface) InterfaceNumber() uint8 {
 	if len(si.iface.AltSettings) == 0 {
 		return 0
@@ -42,6 +62,12 @@ func (si *StreamingInterface) InterfaceNumber() uint8 {
 	return si.iface.AltSettings[0].InterfaceNumber
 }
 
+// ControlInterfaceNumber returns the number of the VideoControl interface that this streaming
+// interface belongs to.
+func (si *StreamingInterface) ControlInterfaceNumber() uint8 {
+	return si.ctrlIfnum
+}
+
 func (si *StreamingInterface) UVCVersionString() string {
 	return fmt.Sprintf("%x.%02x", si.bcdUVC>>8, si.bcdUVC&0xff)
 }
@@ -66,6 +92,22 @@ func (si *StreamingInterface) FrameDescriptors() []descriptors.FrameDescriptor {
 	return descs
 }
 
+// IsMetadata reports whether the interface streams metadata instead of video, such as the Microsoft
+// camera metadata format (Microsoft extensions to UVC 1.5, section 2.2.3). Such interfaces only have
+// stream-based formats and no frame descriptors.
+func (si *StreamingInterface) IsMetadata() bool {
+	formats := si.FormatDescriptors()
+	if len(formats) == 0 || len(si.FrameDescriptors()) > 0 {
+		return false
+	}
+	for _, f := range formats {
+		if _, ok := f.(*descriptors.StreamBasedFormatDescriptor); !ok {
+			return false
+		}
+	}
+	return true
+}
+
 func (si *StreamingInterface) InputHeaderDescriptors() []*descriptors.InputHeaderDescriptor {
 	var descs []*descriptors.InputHeaderDescriptor
 	for _, desc := range si.Descriptors {
@@ -76,25 +118,151 @@ func (si *StreamingInterface) InputHeaderDescriptors() []*descriptors.InputHeade
 	return descs
 }
 
+// InputHeaderForFormat returns the input header that the format with the given index belongs to.
+//
+// UVC spec 1.5, section 3.9.2.1: the input header is followed by the format descriptors it
+// announces, so the header for a format is the last one that precedes it.
+func (si *StreamingInterface) InputHeaderForFormat(formatIndex uint8) (*descriptors.InputHeaderDescriptor, error) {
+	var header *descriptors.InputHeaderDescriptor
+	for _, desc := range si.Descriptors {
+		switch d := desc.(type) {
+		case *descriptors.InputHeaderDescriptor:
+			header = d
+		case descriptors.FormatDescriptor:
+			if d.Index() == formatIndex && header != nil {
+				return header, nil
+			}
+		}
+	}
+	inputs := si.InputHeaderDescriptors()
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no input header descriptors found")
+	}
+	// the format was not found after an input header, fall back to the first one.
+	return inputs[0], nil
+}
+
+func (si *StreamingInterface) OutputHeaderDescriptors() []*descriptors.OutputHeaderDescriptor {
+	var descs []*descriptors.OutputHeaderDescriptor
+	for _, desc := range si.Descriptors {
+		if d, ok := desc.(*descriptors.OutputHeaderDescriptor); ok {
+			descs = append(descs, d)
+		}
+	}
+	return descs
+}
+
+// OutputHeaderForFormat returns the output header that the format with the given index belongs to.
+//
+// UVC spec 1.5, section 3.9.2.2: like input headers, the output header precedes the format
+// descriptors it announces.
+func (si *StreamingInterface) OutputHeaderForFormat(formatIndex uint8) (*descriptors.OutputHeaderDescriptor, error) {
+	var header *descriptors.OutputHeaderDescriptor
+	for _, desc := range si.Descriptors {
+		switch d := desc.(type) {
+		case *descriptors.OutputHeaderDescriptor:
+			header = d
+		case descriptors.FormatDescriptor:
+			if d.Index() == formatIndex && header != nil {
+				return header, nil
+			}
+		}
+	}
+	outputs := si.OutputHeaderDescriptors()
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("no output header descriptors found")
+	}
+	return outputs[0], nil
+}
+
+// claimInterfaces claims the control interface, shared with other readers on the same device, and
+// this streaming interface, which is owned exclusively by the reader being opened.
+func (si *StreamingInterface) claimInterfaces() (ctrlClaimed bool, err error) {
+	// Control interface claim failure is not fatal, some devices may not require it.
+	ctrlClaimed = claimInterface(si.handle, si.ctrlIfnum, false) == nil
+
+	if err := claimInterface(si.handle, si.InterfaceNumber(), true); err != nil {
+		if ctrlClaimed {
+			releaseInterface(si.handle, si.ctrlIfnum)
+		}
+		return false, fmt.Errorf("claim_interface failed: %w", err)
+	}
+	return ctrlClaimed, nil
+}
+
+// releaseInterfaces undoes claimInterfaces.
+func (si *StreamingInterface) releaseInterfaces(ctrlClaimed bool) error {
+	err := releaseInterface(si.handle, si.InterfaceNumber())
+	if ctrlClaimed {
+		if cerr := releaseInterface(si.handle, si.ctrlIfnum); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ClaimFrameReader negotiates the given format and frame with the device and opens a frame reader
+// on this streaming interface.
+//
+// Frame readers on different streaming interfaces of the same device can be open concurrently. Formats
+// without frame descriptors, such as DV, ignore the frame index.
 func (si *StreamingInterface) ClaimFrameReader(formatIndex, frameIndex uint8) (*FrameReader, error) {
-	ifnum := si.InterfaceNumber()
+	return si.ClaimFrameReaderWithProbe(descriptors.NewProbe(formatIndex, frameIndex))
+}
+
+// ClaimFrameReaderWithProbe is ClaimFrameReader with the encoder and UVC 1.5 fields set on probe
+// requested from the device. Fields that are not set keep the values reported by GET_MAX. The device
+// may adjust the requested values, the negotiated ones are returned by the reader's ProbeCommit.
+func (si *StreamingInterface) ClaimFrameReaderWithProbe(probe *descriptors.ProbeBuilder) (*FrameReader, error) {
+	ctrlClaimed, err := si.claimInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	fr, err := si.negotiateFrameReader(probe)
+	if err != nil {
+		si.releaseInterfaces(ctrlClaimed)
+		return nil, err
+	}
+	fr.ctrlClaimed = ctrlClaimed
+	return fr, nil
+}
+
+func (si *StreamingInterface) negotiateFrameReader(probe *descriptors.ProbeBuilder) (*FrameReader, error) {
+	vpcc, err := si.negotiate(probe)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := si.InputHeaderForFormat(probe.FormatIndex())
+	if err != nil {
+		return nil, err
+	}
+
+	return si.NewFrameReader(input.EndpointAddress, vpcc)
+}
 
-	// Also claim the control interface (interface 0) for UVC control requests
-	si.handle.DetachKernelDriver(0)
-	if err := si.handle.ClaimInterface(0); err != nil {
-		// Control interface claim failure is not fatal, but log it
-		// Some devices may not require it
+// probeCommitSize returns the size of the probe and commit controls of the UVC version of the device.
+// UVC spec 1.5, section 4.3.1.1: 26 bytes in UVC 1.0, 34 bytes in UVC 1.1 and 48 bytes in UVC 1.5.
+func (si *StreamingInterface) probeCommitSize() int {
+	switch {
+	case si.bcdUVC < 0x0110:
+		return 26
+	case si.bcdUVC < 0x0150:
+		return 34
+	default:
+		return 48
 	}
+}
 
-	// Detach and claim the streaming interface
-	si.handle.DetachKernelDriver(ifnum)
-	if err := si.handle.ClaimInterface(ifnum); err != nil {
-		return nil, fmt.Errorf("claim_interface failed: %w", err)
+// negotiate runs the probe and commit sequence for probe and returns the committed parameters.
+func (si *StreamingInterface) negotiate(probe *descriptors.ProbeBuilder) (*descriptors.VideoProbeCommitControl, error) {
+	if err := probe.Err(); err != nil {
+		return nil, err
 	}
+	ifnum := si.InterfaceNumber()
 
 	vpcc := &descriptors.VideoProbeCommitControl{}
-	size := 48
-	buf := make([]byte, size)
+	buf := make([]byte, si.probeCommitSize())
 
 	// get the bounds
 	_, err := si.handle.ControlTransfer(
@@ -114,8 +282,9 @@ func (si *StreamingInterface) ClaimFrameReader(formatIndex, frameIndex uint8) (*
 		return nil, err
 	}
 
-	vpcc.FormatIndex = formatIndex
-	vpcc.FrameIndex = frameIndex
+	if err := probe.Apply(vpcc); err != nil {
+		return nil, err
+	}
 
 	if err := vpcc.MarshalInto(buf); err != nil {
 		return nil, err
@@ -164,14 +333,7 @@ func (si *StreamingInterface) ClaimFrameReader(formatIndex, frameIndex uint8) (*
 	if err := vpcc.UnmarshalBinary(buf); err != nil {
 		return nil, err
 	}
-
-	inputs := si.InputHeaderDescriptors()
-	if len(inputs) == 0 {
-		return nil, fmt.Errorf("no input header descriptors found")
-	}
-	endpointAddress := inputs[0].EndpointAddress // take the first input header. TODO: should we select an input header?
-
-	return si.NewFrameReader(endpointAddress, vpcc)
+	return vpcc, nil
 }
 
 // ClaimFrameReaderWithProbeCommit skips native UVC probe/commit and builds a
@@ -181,30 +343,50 @@ func (si *StreamingInterface) ClaimFrameReader(formatIndex, frameIndex uint8) (*
 // requests can fail even though descriptor reads and bulk/isochronous frame
 // transfers still work on the same handle.
 func (si *StreamingInterface) ClaimFrameReaderWithProbeCommit(vpcc *descriptors.VideoProbeCommitControl) (*FrameReader, error) {
+	return si.claimCommittedFrameReader(vpcc, false)
+}
+
+// claimCommittedFrameReader builds a frame reader from already negotiated parameters, first sending them
+// to the commit control if commit is set. A failed commit is ignored, the device may still stream with
+// the parameters it last committed.
+func (si *StreamingInterface) claimCommittedFrameReader(vpcc *descriptors.VideoProbeCommitControl, commit bool) (*FrameReader, error) {
 	if vpcc == nil {
 		return nil, fmt.Errorf("probe/commit control is nil")
 	}
 
-	ifnum := si.InterfaceNumber()
-
-	si.handle.DetachKernelDriver(0)
-	_ = si.handle.ClaimInterface(0)
+	ctrlClaimed, err := si.claimInterfaces()
+	if err != nil {
+		return nil, err
+	}
 
-	si.handle.DetachKernelDriver(ifnum)
-	if err := si.handle.ClaimInterface(ifnum); err != nil {
-		return nil, fmt.Errorf("claim_interface failed: %w", err)
+	if commit {
+		si.commit(vpcc)
 	}
 
-	inputs := si.InputHeaderDescriptors()
-	if len(inputs) == 0 {
-		return nil, fmt.Errorf("no input header descriptors found")
+	input, err := si.InputHeaderForFormat(vpcc.FormatIndex)
+	if err != nil {
+		si.releaseInterfaces(ctrlClaimed)
+		return nil, err
 	}
-	endpointAddress := inputs[0].EndpointAddress
 
-	return si.NewFrameReader(endpointAddress, vpcc)
+	fr, err := si.NewFrameReader(input.EndpointAddress, vpcc)
+	if err != nil {
+		si.releaseInterfaces(ctrlClaimed)
+		return nil, err
+	}
+	fr.ctrlClaimed = ctrlClaimed
+	return fr, nil
 }
 
+// Handle returns the USB device handle, or nil if the interface was created on another transport,
+// such as a simulated device.
 func (si *StreamingInterface) Handle() *usb.DeviceHandle {
+	h, _ := usbHandle(si.handle)
+	return h
+}
+
+// Transport returns the transport the interface communicates through.
+func (si *StreamingInterface) Transport() Transport {
 	return si.handle
 }
 
@@ -0,0 +1,245 @@
+package transfers
+
+import (
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+)
+
+// UnitMode selects how a UnitReader splits frames.
+type UnitMode int
+
+const (
+	// UnitPayloads delivers the data of every payload as it arrives.
+	UnitPayloads UnitMode = iota
+	// UnitSlices delivers complete H.264 slices. UVC H.264 payload spec 1.5, section 2.2: the payload
+	// specific bit of the payload header marks the end of a slice.
+	UnitSlices
+	// UnitRows delivers whole rows of uncompressed frames, which arrive in scan order.
+	UnitRows
+)
+
+func (m UnitMode) String() string {
+	switch m {
+	case UnitPayloads:
+		return "Payloads"
+	case UnitSlices:
+		return "Slices"
+	case UnitRows:
+		return "Rows"
+	}
+	return "Unknown"
+}
+
+// Unit is a part of a frame delivered before the rest of the frame has been received.
+type Unit struct {
+	// Sequence is the number of the frame the unit belongs to, counting from zero.
+	Sequence uint64
+	// Offset is the position of the unit's data in the frame.
+	Offset int
+	// Row and Rows are the first row and number of rows of the unit in UnitRows mode. Rows are Stride
+	// bytes long and counted in scan order, so for planar formats the rows past the frame height are
+	// in the chroma planes.
+	Row, Rows int
+	// EndOfFrame is set on the last unit of a frame.
+	EndOfFrame bool
+	// ErrorFlagged is set if a payload of the unit had the error bit set.
+	ErrorFlagged bool
+
+	// PTS is the presentation time stamp of the frame in device clock ticks, set if HasPTS.
+	PTS    uint32
+	HasPTS bool
+	// CaptureTime is the host time at which the first payload of the frame was received.
+	CaptureTime time.Time
+
+	// Data is the unit's data, owned by the caller.
+	Data []byte
+}
+
+// UnitReader reads frames in parts as their payloads arrive, for consumers that start processing a
+// frame before it is complete.
+type UnitReader struct {
+	fr     *FrameReader
+	p      Payload
+	mode   UnitMode
+	stride int
+
+	fid     bool
+	hasFID  bool
+	started bool
+	seq     uint64
+
+	// the unit being accumulated, emitted once complete.
+	unit    Unit
+	pending []byte
+	ready   []*Unit
+}
+
+// ClaimUnitReader negotiates the given format and frame with the device and opens a unit reader on
+// this streaming interface. H.264 formats are split into slices, uncompressed formats into rows and
+// other formats into payloads.
+func (si *StreamingInterface) ClaimUnitReader(formatIndex, frameIndex uint8) (*UnitReader, error) {
+	fr, err := si.ClaimFrameReader(formatIndex, frameIndex)
+	if err != nil {
+		return nil, err
+	}
+	mode, stride := si.unitMode(fr.vpcc)
+	return newUnitReader(fr, mode, stride), nil
+}
+
+func newUnitReader(fr *FrameReader, mode UnitMode, stride int) *UnitReader {
+	if mode == UnitRows && stride <= 0 {
+		mode = UnitPayloads
+	}
+	return &UnitReader{fr: fr, mode: mode, stride: stride}
+}
+
+// unitMode returns the unit mode and row stride of the negotiated format.
+func (si *StreamingInterface) unitMode(vpcc *descriptors.VideoProbeCommitControl) (UnitMode, int) {
+	var format descriptors.FormatDescriptor
+	for _, desc := range si.Descriptors {
+		switch d := desc.(type) {
+		case *descriptors.H264FormatDescriptor:
+			format = d
+			if d.Index() == vpcc.FormatIndex {
+				return UnitSlices, 0
+			}
+		case *descriptors.FrameBasedFormatDescriptor:
+			format = d
+			if d.Index() != vpcc.FormatIndex {
+				continue
+			}
+			if fcc, err := d.FourCC(); err == nil && (fcc == [4]byte{'h', '2', '6', '4'} || fcc == [4]byte{'H', '2', '6', '4'}) {
+				return UnitSlices, 0
+			}
+			return UnitPayloads, 0
+		case descriptors.FormatDescriptor:
+			format = d
+		case *descriptors.UncompressedFrameDescriptor:
+			f, ok := format.(*descriptors.UncompressedFormatDescriptor)
+			if !ok || f.Index() != vpcc.FormatIndex || d.Index() != vpcc.FrameIndex {
+				continue
+			}
+			if f.BitsPerPixel%8 != 0 {
+				// planar 4:2:0 formats, rows are the size of a luma row.
+				return UnitRows, int(d.Width)
+			}
+			return UnitRows, int(d.Width) * int(f.BitsPerPixel) / 8
+		}
+	}
+	return UnitPayloads, 0
+}
+
+// Mode returns how the reader splits frames.
+func (r *UnitReader) Mode() UnitMode {
+	return r.mode
+}
+
+// Stride returns the size of a row in UnitRows mode.
+func (r *UnitReader) Stride() int {
+	return r.stride
+}
+
+// ReadUnit returns the next unit of the current frame, reading payloads until one is complete.
+func (r *UnitReader) ReadUnit() (*Unit, error) {
+	for len(r.ready) == 0 {
+		if err := r.readPayload(); err != nil {
+			return nil, err
+		}
+	}
+	u := r.ready[0]
+	r.ready[0] = nil
+	r.ready = r.ready[1:]
+	return u, nil
+}
+
+func (r *UnitReader) readPayload() error {
+	n, err := r.fr.pr.Read(r.fr.scratch)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	at := time.Now()
+	p := &r.p
+	if err := p.UnmarshalBinary(r.fr.scratch[:n]); err != nil {
+		return err
+	}
+	if !r.hasFID || p.FrameID() != r.fid {
+		// frame id bit flipped, this is a new frame.
+		if r.started {
+			r.emit(true)
+			r.seq++
+		}
+		r.fid, r.hasFID, r.started = p.FrameID(), true, true
+		r.unit = Unit{Sequence: r.seq, CaptureTime: at}
+		r.pending = r.pending[:0]
+	}
+	if !r.started {
+		// the frame already ended with an end of frame bit.
+		return nil
+	}
+	if p.Error() {
+		r.unit.ErrorFlagged = true
+	}
+	if p.HasPTS() && !r.unit.HasPTS {
+		r.unit.PTS, r.unit.HasPTS = p.PTS, true
+	}
+	if p.HasSCR() {
+		r.fr.clock.AddSample(p.SCR.SourceTimeClock, at)
+	}
+	r.pending = append(r.pending, p.Data...)
+
+	switch {
+	case p.EndOfFrame():
+		r.emit(true)
+		r.seq++
+		r.started = false
+	case r.mode == UnitSlices:
+		if p.PayloadSpecificBit() {
+			r.emit(false)
+		}
+	case r.mode == UnitRows:
+		if rows := len(r.pending) / r.stride; rows > 0 {
+			r.emitBytes(rows*r.stride, false)
+		}
+	default:
+		r.emit(false)
+	}
+	return nil
+}
+
+// emit queues the pending data as a unit.
+func (r *UnitReader) emit(eof bool) {
+	if len(r.pending) == 0 && !eof {
+		return
+	}
+	r.emitBytes(len(r.pending), eof)
+}
+
+// emitBytes queues the first n pending bytes as a unit.
+func (r *UnitReader) emitBytes(n int, eof bool) {
+	u := r.unit
+	u.Data = append([]byte(nil), r.pending[:n]...)
+	u.EndOfFrame = eof
+	if r.mode == UnitRows {
+		u.Row = u.Offset / r.stride
+		u.Rows = (n + r.stride - 1) / r.stride
+	}
+	r.ready = append(r.ready, &u)
+
+	r.pending = append(r.pending[:0], r.pending[n:]...)
+	r.unit.Offset += n
+	r.unit.ErrorFlagged = false
+}
+
+// Stats returns the statistics of the underlying reader. Frame statistics are not collected.
+func (r *UnitReader) Stats() FrameStats {
+	return r.fr.Stats()
+}
+
+// Close stops the transfers of this reader and releases its interfaces.
+func (r *UnitReader) Close() error {
+	return r.fr.Close()
+}
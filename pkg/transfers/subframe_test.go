@@ -0,0 +1,66 @@
+package transfers
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnitReader_Slices(t *testing.T) {
+	frames := testFrames(2, 3000)
+	packets := framePayloads(frames, 512)
+	// slices end with the second and fourth payload of each frame, the frame ends the last one.
+	for _, i := range []int{1, 3, 7, 9} {
+		packets[i][1] |= 0b00010000
+	}
+	r := newUnitReader(newTestFrameReader(packets, 3000, 512), UnitSlices, 0)
+
+	want := []struct {
+		seq, offset, size int
+		eof               bool
+	}{
+		{0, 0, 1000, false}, {0, 1000, 1000, false}, {0, 2000, 1000, true},
+		{1, 0, 1000, false}, {1, 1000, 1000, false}, {1, 2000, 1000, true},
+	}
+	for i, w := range want {
+		u, err := r.ReadUnit()
+		if err != nil {
+			t.Fatalf("ReadUnit %d failed: %v", i, err)
+		}
+		if u.Sequence != uint64(w.seq) || u.Offset != w.offset || len(u.Data) != w.size || u.EndOfFrame != w.eof {
+			t.Errorf("unit %d = frame %d, offset %d, %d bytes, eof %v", i, u.Sequence, u.Offset, len(u.Data), u.EndOfFrame)
+			continue
+		}
+		if !bytes.Equal(u.Data, frames[w.seq][w.offset:w.offset+w.size]) {
+			t.Errorf("unit %d data mismatch", i)
+		}
+		if !u.HasPTS {
+			t.Errorf("unit %d has no PTS", i)
+		}
+	}
+}
+
+func TestUnitReader_Rows(t *testing.T) {
+	// 10 rows of 300 bytes in payloads of 700 bytes of data.
+	frames := testFrames(1, 3000)
+	r := newUnitReader(newTestFrameReader(framePayloads(frames, 712), 3000, 712), UnitRows, 300)
+
+	var got []byte
+	row := 0
+	for {
+		u, err := r.ReadUnit()
+		if err != nil {
+			t.Fatalf("ReadUnit failed: %v", err)
+		}
+		if u.Row != row || u.Offset != row*300 || len(u.Data) != u.Rows*300 {
+			t.Errorf("unit at row %d = row %d, %d rows, offset %d, %d bytes", row, u.Row, u.Rows, u.Offset, len(u.Data))
+		}
+		row += u.Rows
+		got = append(got, u.Data...)
+		if u.EndOfFrame {
+			break
+		}
+	}
+	if row != 10 || !bytes.Equal(got, frames[0]) {
+		t.Errorf("read %d rows, data equal %v", row, bytes.Equal(got, frames[0]))
+	}
+}
@@ -0,0 +1,120 @@
+package transfers
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	usb "github.com/kevmo314/go-usb"
+)
+
+// Transport is the access to a USB device used by the video and audio stacks. *usb.DeviceHandle
+// implements it, as does the in-memory device of pkg/simulator, which allows running negotiation,
+// controls and frame assembly without hardware.
+type Transport interface {
+	Descriptor() usb.DeviceDescriptor
+	ConfigDescriptorByValue(value uint8) (*usb.ConfigDescriptor, error)
+	StringDescriptor(index uint8) (string, error)
+	GetSpeed() (usb.Speed, error)
+
+	ControlTransfer(requestType, request uint8, value, index uint16, data []byte, timeout time.Duration) (int, error)
+	BulkTransfer(endpoint uint8, data []byte, timeout time.Duration) (int, error)
+	BulkTransferWithOptions(endpoint uint8, data []byte, timeout time.Duration, allowZeroLength bool) (int, error)
+	IsochronousTransfer(endpoint uint8, data []byte, numPackets int, packetSize int, timeout time.Duration) ([]usb.IsoPacketResult, error)
+
+	DetachKernelDriver(iface uint8) error
+	ClaimInterface(iface uint8) error
+	ReleaseInterface(iface uint8) error
+	SetInterfaceAltSetting(iface, altSetting uint8) error
+	ClearHalt(endpoint uint8) error
+
+	Close() error
+}
+
+var _ Transport = (*usb.DeviceHandle)(nil)
+
+// usbHandle returns the go-usb handle behind t, which the asynchronous readers need to queue
+// transfers. Other transports are read with synchronous transfers instead.
+func usbHandle(t Transport) (*usb.DeviceHandle, bool) {
+	h, ok := t.(*usb.DeviceHandle)
+	return h, ok && h != nil
+}
+
+// SyncIsochronousReader reads payloads from an isochronous IN endpoint with one synchronous transfer of
+// several packets at a time. It is used for transports that can not queue asynchronous transfers.
+type SyncIsochronousReader struct {
+	transport  Transport
+	endpoint   uint8
+	packets    int
+	packetSize int
+
+	buf     []byte
+	results []usb.IsoPacketResult
+	next    int
+
+	stats transferCounters
+}
+
+func (si *StreamingInterface) NewSyncIsochronousReader(endpointAddress uint8, packets, packetSize uint32) *SyncIsochronousReader {
+	return newSyncIsochronousReader(si.handle, endpointAddress, int(packets), int(packetSize))
+}
+
+func newSyncIsochronousReader(t Transport, endpointAddress uint8, packets, packetSize int) *SyncIsochronousReader {
+	return &SyncIsochronousReader{
+		transport:  t,
+		endpoint:   endpointAddress,
+		packets:    packets,
+		packetSize: packetSize,
+		buf:        make([]byte, packets*packetSize),
+		stats:      transferCounters{start: time.Now()},
+	}
+}
+
+// Read returns the data of the next packet that carried data, which holds one payload.
+func (r *SyncIsochronousReader) Read(buf []byte) (int, error) {
+	for {
+		if r.next >= len(r.results) {
+			results, err := r.transport.IsochronousTransfer(r.endpoint, r.buf, r.packets, r.packetSize, 5*time.Second)
+			if err != nil {
+				r.stats.transferErrors.Add(1)
+				return 0, fmt.Errorf("isochronous transfer failed: %w", err)
+			}
+			r.stats.transfers.Add(1)
+			r.results, r.next = results, 0
+			continue
+		}
+		i := r.next
+		pkt := r.results[i]
+		if pkt.Status != 0 {
+			r.stats.packetError(int(pkt.Status))
+			r.next++
+			continue
+		}
+		if pkt.ActualLength == 0 {
+			r.stats.packet(0)
+			r.next++
+			continue
+		}
+		if len(buf) < pkt.ActualLength {
+			return 0, io.ErrShortBuffer
+		}
+		r.next++
+		r.stats.packet(pkt.ActualLength)
+		off := i * r.packetSize
+		return copy(buf, r.buf[off:off+pkt.ActualLength]), nil
+	}
+}
+
+// LostPackets returns the number of packets that completed with an error status.
+func (r *SyncIsochronousReader) LostPackets() uint64 {
+	return r.stats.packetErrors.Load()
+}
+
+// Stats returns a snapshot of the transfer statistics of the reader.
+func (r *SyncIsochronousReader) Stats() TransferStats {
+	return r.stats.snapshot()
+}
+
+func (r *SyncIsochronousReader) Close() error {
+	return nil
+}
@@ -0,0 +1,170 @@
+package transfers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/simulator"
+)
+
+// webcamStreamingInterface returns the streaming interface of a simulated webcam.
+func webcamStreamingInterface(t *testing.T, dev *simulator.Device) *StreamingInterface {
+	t.Helper()
+	cd, err := dev.ConfigDescriptorByValue(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &descriptors.HeaderDescriptor{}
+	if err := header.UnmarshalBinary(cd.Interface(simulator.WebcamControlInterface).AltSettings[0].Extra); err != nil {
+		t.Fatal(err)
+	}
+	iface := cd.Interface(simulator.WebcamStreamingInterface)
+	si := NewStreamingInterfaceWithHeader(dev, iface, simulator.WebcamControlInterface, header)
+	extra := iface.AltSettings[0].Extra
+	for i := 0; i < len(extra); i += int(extra[i]) {
+		desc, err := descriptors.UnmarshalStreamingInterface(extra[i : i+int(extra[i])])
+		if err != nil {
+			t.Fatal(err)
+		}
+		si.Descriptors = append(si.Descriptors, desc)
+	}
+	return si
+}
+
+func TestStreamingInterface_SimulatedWebcam(t *testing.T) {
+	dev := simulator.Webcam()
+	si := webcamStreamingInterface(t, dev)
+	frames := [][]byte{bytes.Repeat([]byte{0x10}, 320*240*2), bytes.Repeat([]byte{0x20}, 320*240*2)}
+	dev.SetPayloadSource(simulator.WebcamEndpoint, simulator.Frames(simulator.WebcamPacketSize, frames...))
+
+	r, err := si.ClaimFrameReader(1, 2)
+	if err != nil {
+		t.Fatalf("ClaimFrameReader failed: %v", err)
+	}
+	committed, ok := dev.Committed(simulator.WebcamStreamingInterface)
+	if !ok || committed.FrameIndex != 2 || committed.MaxVideoFrameSize != 320*240*2 {
+		t.Errorf("committed = %+v", committed)
+	}
+	if alt := dev.AltSetting(simulator.WebcamStreamingInterface); alt != 1 {
+		t.Errorf("alternate setting = %d, want 1", alt)
+	}
+	if !dev.Claimed(simulator.WebcamStreamingInterface) || !dev.Claimed(simulator.WebcamControlInterface) {
+		t.Error("interfaces were not claimed")
+	}
+
+	for i, want := range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !f.Integrity.Complete() {
+			t.Errorf("frame %d integrity = %+v", i, f.Integrity)
+		}
+		if got := f.Bytes(); !bytes.Equal(got, want) {
+			t.Errorf("frame %d has %d bytes, want %d", i, len(got), len(want))
+		}
+		f.Release()
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if alt := dev.AltSetting(simulator.WebcamStreamingInterface); alt != 0 {
+		t.Errorf("alternate setting after Close = %d, want 0", alt)
+	}
+	if dev.Claimed(simulator.WebcamStreamingInterface) || dev.Claimed(simulator.WebcamControlInterface) {
+		t.Error("interfaces were not released")
+	}
+}
+
+func TestNewStreamingInterface_ControlInterfaceZero(t *testing.T) {
+	dev := simulator.Webcam()
+	cd, err := dev.ConfigDescriptorByValue(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	si := NewStreamingInterface(dev, cd.Interface(simulator.WebcamStreamingInterface), 0x0110)
+	si.Descriptors = webcamStreamingInterface(t, dev).Descriptors
+	dev.SetPayloadSource(simulator.WebcamEndpoint, simulator.Frames(simulator.WebcamPacketSize))
+
+	r, err := si.ClaimFrameReader(1, 1)
+	if err != nil {
+		t.Fatalf("ClaimFrameReader failed: %v", err)
+	}
+	defer r.Close()
+	if si.ControlInterfaceNumber() != simulator.WebcamControlInterface || !dev.Claimed(simulator.WebcamControlInterface) {
+		t.Error("control interface 0 was not claimed")
+	}
+}
+
+func TestStreamingInterface_SimulatedPacketLoss(t *testing.T) {
+	dev := simulator.Webcam()
+	si := webcamStreamingInterface(t, dev)
+	frame := bytes.Repeat([]byte{0x10}, 320*240*2)
+	src := simulator.Frames(simulator.WebcamPacketSize, frame)
+	n := 0
+	dev.SetPayloadSource(simulator.WebcamEndpoint, simulator.PayloadFunc(func() ([]byte, error) {
+		p, err := src.NextPayload()
+		if n++; n == 3 {
+			// babble, the simulated host controller drops the packet.
+			p = make([]byte, simulator.WebcamPacketSize+1)
+		}
+		return p, err
+	}))
+
+	r, err := si.ClaimFrameReader(1, 2)
+	if err != nil {
+		t.Fatalf("ClaimFrameReader failed: %v", err)
+	}
+	defer r.Close()
+	if _, ok := r.pr.(*IsochronousReader); !ok {
+		t.Fatalf("payload reader is a %T, want an *IsochronousReader", r.pr)
+	}
+
+	f, err := r.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	defer f.Release()
+	// the frames carry no PTS, their payload headers are two bytes.
+	payload := simulator.WebcamPacketSize - 2
+	if f.Integrity.LostPackets != 1 || f.Integrity.BytesReceived != len(frame)-payload || !f.Integrity.Truncated() {
+		t.Errorf("integrity = %+v", f.Integrity)
+	}
+	s := r.Stats().Transfer
+	if s == nil || s.PacketErrors[-75] != 1 || s.Transfers == 0 {
+		t.Errorf("transfer stats = %+v, want one overflowed packet", s)
+	}
+}
+
+func TestStreamingInterface_ProbeCommitSize(t *testing.T) {
+	for _, tc := range []struct {
+		bcdUVC uint16
+		size   int
+	}{
+		{0x0100, 26},
+		{0x0110, 34},
+		{0x0150, 48},
+	} {
+		dev := simulator.Webcam()
+		cd, err := dev.ConfigDescriptorByValue(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		si := NewStreamingInterface(dev, cd.Interface(simulator.WebcamStreamingInterface), tc.bcdUVC)
+		si.Descriptors = webcamStreamingInterface(t, dev).Descriptors
+		if _, err := si.negotiate(descriptors.NewProbe(1, 2)); err != nil {
+			t.Fatalf("UVC %#04x: negotiate failed: %v", tc.bcdUVC, err)
+		}
+		reqs := dev.Requests()
+		if len(reqs) == 0 {
+			t.Fatalf("UVC %#04x: no control requests", tc.bcdUVC)
+		}
+		for _, req := range reqs {
+			if len(req.Data) != tc.size {
+				t.Errorf("UVC %#04x: request %#02x of selector %d carried %d bytes, want %d", tc.bcdUVC, req.Request, req.Selector(), len(req.Data), tc.size)
+			}
+		}
+	}
+}
@@ -3,8 +3,6 @@ package transfers
 import (
 	"fmt"
 	"time"
-
-	usb "github.com/kevmo314/go-usb"
 )
 
 // UAC2 Clock Source Control Selectors
@@ -29,11 +27,11 @@ const (
 
 // UAC2ClockControl provides clock domain control for UAC2/3 devices
 type UAC2ClockControl struct {
-	handle *usb.DeviceHandle
+	handle Transport
 	ifnum  uint8
 }
 
-func NewUAC2ClockControl(handle *usb.DeviceHandle, interfaceNumber uint8) *UAC2ClockControl {
+func NewUAC2ClockControl(handle Transport, interfaceNumber uint8) *UAC2ClockControl {
 	return &UAC2ClockControl{
 		handle: handle,
 		ifnum:  interfaceNumber,
@@ -3,8 +3,6 @@ package transfers
 import (
 	"fmt"
 	"time"
-
-	usb "github.com/kevmo314/go-usb"
 )
 
 // UAC Request Codes
@@ -78,11 +76,11 @@ const (
 
 // UACControl provides methods to control audio features
 type UACControl struct {
-	handle *usb.DeviceHandle
+	handle Transport
 	ifnum  uint8
 }
 
-func NewUACControl(handle *usb.DeviceHandle, interfaceNumber uint8) *UACControl {
+func NewUACControl(handle Transport, interfaceNumber uint8) *UACControl {
 	return &UACControl{
 		handle: handle,
 		ifnum:  interfaceNumber,
@@ -0,0 +1,289 @@
+package usbmon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf16"
+
+	usb "github.com/kevmo314/go-usb"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/requests"
+	"github.com/kevmo314/go-uvc/pkg/simulator"
+)
+
+// ControlTransfer is a completed control transfer.
+type ControlTransfer struct {
+	Time                 time.Time
+	RequestType, Request uint8
+	Value, Index, Length uint16
+	// Data is the data sent by the host for OUT transfers and by the device for IN transfers.
+	Data []byte
+	// Status is 0 for transfers that succeeded and a negative errno otherwise, -32 (EPIPE) for stalls.
+	Status int32
+}
+
+// Capture is the traffic of one device recovered from a usbmon capture.
+type Capture struct {
+	Bus     uint16
+	Address uint8
+
+	// Descriptor and Config are the device and full configuration descriptors the host read. They are
+	// nil if the capture does not include the enumeration of the device.
+	Descriptor *usb.DeviceDescriptor
+	Config     []byte
+	// Strings are the string descriptors the host read, by index.
+	Strings map[uint8]string
+
+	// Controls are the completed control transfers, in order of completion.
+	Controls []ControlTransfer
+	// Payloads are the payloads received on each IN endpoint, one per isochronous packet or bulk
+	// transfer, in order. Empty payloads are skipped.
+	Payloads map[uint8][][]byte
+	// LostPayloads counts the payloads of each IN endpoint that failed or were truncated by the capture.
+	LostPayloads map[uint8]int
+
+	pending map[uint64]*Packet
+}
+
+// Import reads a pcap or pcapng usbmon capture and returns the traffic of each device in it, in order
+// of appearance. Descriptors are only recovered if the capture includes the enumeration of the device,
+// so start capturing before plugging it in.
+func Import(r io.Reader) ([]*Capture, error) {
+	mr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	type address struct {
+		bus    uint16
+		device uint8
+	}
+	var captures []*Capture
+	byAddress := make(map[address]*Capture)
+	for {
+		p, err := mr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		c, ok := byAddress[address{p.Bus, p.Device}]
+		if !ok {
+			c = &Capture{
+				Bus:          p.Bus,
+				Address:      p.Device,
+				Strings:      make(map[uint8]string),
+				Payloads:     make(map[uint8][][]byte),
+				LostPayloads: make(map[uint8]int),
+				pending:      make(map[uint64]*Packet),
+			}
+			byAddress[address{p.Bus, p.Device}] = c
+			captures = append(captures, c)
+		}
+		c.add(p)
+	}
+	for _, c := range captures {
+		c.pending = nil
+	}
+	return captures, nil
+}
+
+func (c *Capture) add(p *Packet) {
+	switch p.TransferType {
+	case TransferTypeControl:
+		switch p.Event {
+		case EventSubmission:
+			if p.HasSetup {
+				c.pending[p.ID] = p
+			}
+		case EventCallback:
+			if s, ok := c.pending[p.ID]; ok {
+				delete(c.pending, p.ID)
+				c.addControl(s, p)
+			}
+		case EventError:
+			delete(c.pending, p.ID)
+		}
+	case TransferTypeIsochronous:
+		if p.Event != EventCallback || p.Endpoint&0x80 == 0 {
+			return
+		}
+		for _, iso := range p.IsoPackets {
+			switch {
+			case iso.Status != 0 || uint64(iso.Offset)+uint64(iso.Length) > uint64(len(p.Data)):
+				c.LostPayloads[p.Endpoint]++
+			case iso.Length > 0:
+				c.Payloads[p.Endpoint] = append(c.Payloads[p.Endpoint], p.Data[iso.Offset:iso.Offset+iso.Length])
+			}
+		}
+	case TransferTypeBulk:
+		if p.Event != EventCallback || p.Endpoint&0x80 == 0 {
+			return
+		}
+		switch {
+		case p.Status != 0 || uint32(len(p.Data)) < p.Length:
+			c.LostPayloads[p.Endpoint]++
+		case len(p.Data) > 0:
+			c.Payloads[p.Endpoint] = append(c.Payloads[p.Endpoint], p.Data)
+		}
+	}
+}
+
+func (c *Capture) addControl(s, p *Packet) {
+	ct := ControlTransfer{
+		Time:        p.Time,
+		RequestType: s.Setup[0],
+		Request:     s.Setup[1],
+		Value:       binary.LittleEndian.Uint16(s.Setup[2:4]),
+		Index:       binary.LittleEndian.Uint16(s.Setup[4:6]),
+		Length:      binary.LittleEndian.Uint16(s.Setup[6:8]),
+		Status:      p.Status,
+	}
+	if ct.RequestType&0x80 != 0 {
+		ct.Data = p.Data
+	} else {
+		ct.Data = s.Data
+	}
+	c.Controls = append(c.Controls, ct)
+	if ct.Status != 0 || ct.RequestType != 0x80 || ct.Request != 0x06 {
+		return
+	}
+	// USB 2.0 spec, section 9.4.3: GET_DESCRIPTOR.
+	switch ct.Value >> 8 {
+	case 0x01:
+		desc := &usb.DeviceDescriptor{}
+		if len(ct.Data) == 18 && binary.Read(bytes.NewReader(ct.Data), binary.LittleEndian, desc) == nil {
+			c.Descriptor = desc
+		}
+	case 0x02:
+		// the host reads the 9 byte header first to learn the total length.
+		if len(ct.Data) > len(c.Config) {
+			c.Config = ct.Data
+		}
+	case 0x03:
+		if index := uint8(ct.Value); index != 0 && len(ct.Data) >= 2 {
+			c.Strings[index] = decodeString(ct.Data)
+		}
+	}
+}
+
+// decodeString decodes a UTF-16LE string descriptor.
+func decodeString(buf []byte) string {
+	buf = buf[2:min(int(buf[0]), len(buf))]
+	u := make([]uint16, len(buf)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(buf[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// streamingInterfaces returns the numbers of the video streaming interfaces of the configuration.
+func (c *Capture) streamingInterfaces() (map[uint8]bool, error) {
+	if c.Config == nil {
+		return nil, fmt.Errorf("capture has no configuration descriptor")
+	}
+	config := &usb.ConfigDescriptor{}
+	if err := config.Unmarshal(c.Config); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration descriptor: %w", err)
+	}
+	vs := make(map[uint8]bool)
+	for _, iface := range config.Interfaces {
+		if len(iface.AltSettings) == 0 {
+			continue
+		}
+		alt := iface.AltSettings[0]
+		if alt.InterfaceClass == 0x0e && alt.InterfaceSubClass == 0x02 {
+			vs[alt.InterfaceNumber] = true
+		}
+	}
+	return vs, nil
+}
+
+// isProbeCommit reports whether a transfer is a request to the probe or commit control of a video
+// streaming interface. UVC spec 1.5, section 4.3.1.1.
+func isProbeCommit(ct *ControlTransfer, vs map[uint8]bool) bool {
+	selector := ct.Value >> 8
+	return ct.RequestType&0x7f == 0x21 && ct.Index>>8 == 0 && vs[uint8(ct.Index)] && (selector == 0x01 || selector == 0x02)
+}
+
+// Commits returns the last streaming parameters the host committed on each video streaming interface.
+func (c *Capture) Commits() (map[uint8]*descriptors.VideoProbeCommitControl, error) {
+	vs, err := c.streamingInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	commits := make(map[uint8]*descriptors.VideoProbeCommitControl)
+	for i := range c.Controls {
+		ct := &c.Controls[i]
+		if ct.Status != 0 || !isProbeCommit(ct, vs) || ct.Value>>8 != 0x02 || requests.RequestCode(ct.Request) != requests.RequestCodeSetCur {
+			continue
+		}
+		if len(ct.Data) < 26 {
+			continue
+		}
+		vpcc := &descriptors.VideoProbeCommitControl{}
+		buf := make([]byte, 48)
+		copy(buf, ct.Data)
+		if err := vpcc.UnmarshalBinary(buf); err != nil {
+			continue
+		}
+		commits[uint8(ct.Index)] = vpcc
+	}
+	return commits, nil
+}
+
+// Device returns a simulated device that replays the capture. It has the captured descriptors and
+// strings, answers class-specific GET requests with the last captured responses, negotiates the
+// captured commits for their format and frame, and sends the captured payloads on each IN endpoint.
+// Build a DeviceInfo from it and claim a frame reader to replay the stream through the frame reader
+// and the decoders.
+func (c *Capture) Device() (*simulator.Device, error) {
+	if c.Descriptor == nil {
+		return nil, fmt.Errorf("capture has no device descriptor")
+	}
+	vs, err := c.streamingInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := c.Commits()
+	if err != nil {
+		return nil, err
+	}
+	dev, err := simulator.New(*c.Descriptor, c.Config)
+	if err != nil {
+		return nil, err
+	}
+	for index, s := range c.Strings {
+		dev.SetString(index, s)
+	}
+	for i := range c.Controls {
+		ct := &c.Controls[i]
+		// class-specific requests to an interface or endpoint.
+		if ct.Status != 0 || ct.RequestType&0x60 != 0x20 || isProbeCommit(ct, vs) {
+			continue
+		}
+		selector := uint8(ct.Value >> 8)
+		switch {
+		case ct.RequestType&0x80 != 0:
+			dev.SetControl(requests.RequestCode(ct.Request), selector, ct.Index, ct.Data)
+		case requests.RequestCode(ct.Request) == requests.RequestCodeSetCur:
+			dev.SetControl(requests.RequestCodeGetCur, selector, ct.Index, ct.Data)
+		}
+	}
+	dev.SetNegotiator(func(vpcc *descriptors.VideoProbeCommitControl) error {
+		for _, commit := range commits {
+			if commit.FormatIndex == vpcc.FormatIndex && commit.FrameIndex == vpcc.FrameIndex {
+				*vpcc = *commit
+				return nil
+			}
+		}
+		return nil
+	})
+	for endpoint, payloads := range c.Payloads {
+		dev.SetPayloadSource(endpoint, simulator.Payloads(payloads...))
+	}
+	return dev, nil
+}
@@ -0,0 +1,163 @@
+//go:build !windows
+
+package usbmon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/kevmo314/go-uvc"
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/simulator"
+)
+
+// webcamCapture builds a capture of the simulated webcam being enumerated, having its brightness read
+// and streaming frames at 320x240.
+func webcamCapture(t *testing.T, frames [][]byte) []byte {
+	t.Helper()
+	var packets [][]byte
+	id := uint64(0)
+	control := func(setup []byte, out, in []byte, status int32) {
+		id++
+		packets = append(packets,
+			event{id: id, typ: EventSubmission, xfer: TransferTypeControl, endpoint: setup[0] & 0x80, setup: setup, length: uint32(len(out)), data: out}.marshal(),
+			event{id: id, typ: EventCallback, xfer: TransferTypeControl, endpoint: setup[0] & 0x80, status: status, length: uint32(len(in)), data: in}.marshal())
+	}
+	setup := func(requestType, request uint8, value, index, length uint16) []byte {
+		b := []byte{requestType, request}
+		b = binary.LittleEndian.AppendUint16(b, value)
+		b = binary.LittleEndian.AppendUint16(b, index)
+		return binary.LittleEndian.AppendUint16(b, length)
+	}
+
+	var desc bytes.Buffer
+	binary.Write(&desc, binary.LittleEndian, simulator.Webcam().Descriptor())
+	config := simulator.WebcamConfig()
+	serial := []byte{0, 0x03}
+	for _, u := range utf16.Encode([]rune("SIM0001")) {
+		serial = binary.LittleEndian.AppendUint16(serial, u)
+	}
+	serial[0] = uint8(len(serial))
+
+	control(setup(0x80, 0x06, 0x0100, 0, 18), nil, desc.Bytes(), 0)
+	control(setup(0x80, 0x06, 0x0200, 0, 9), nil, config[:9], 0)
+	control(setup(0x80, 0x06, 0x0200, 0, uint16(len(config))), nil, config, 0)
+	control(setup(0x80, 0x06, 0x0301, 0x0409, 255), nil, serial, 0)
+	// GET_CUR brightness, and a stalled GET_CUR of an unsupported control.
+	control(setup(0xa1, 0x81, 0x0200, 0x0200, 2), nil, []byte{0x20, 0x00}, 0)
+	control(setup(0xa1, 0x81, 0x0300, 0x0200, 2), nil, nil, -32)
+
+	vpcc := &descriptors.VideoProbeCommitControl{
+		FormatIndex:            1,
+		FrameIndex:             2,
+		FrameInterval:          333333 * 100 * time.Nanosecond,
+		MaxVideoFrameSize:      320 * 240 * 2,
+		MaxPayloadTransferSize: 1024,
+		ClockFrequency:         48000000,
+	}
+	probe, err := vpcc.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe = probe[:34]
+	control(setup(0x21, 0x01, 0x0100, 0x0001, 34), probe, nil, 0)
+	control(setup(0xa1, 0x81, 0x0100, 0x0001, 34), nil, probe, 0)
+	control(setup(0x21, 0x01, 0x0200, 0x0001, 34), probe, nil, 0)
+
+	src := simulator.Frames(1024, frames...)
+	for {
+		id++
+		e := event{id: id, typ: EventCallback, xfer: TransferTypeIsochronous, endpoint: simulator.WebcamEndpoint, data: make([]byte, 8*1024)}
+		for i := 0; i < 8; i++ {
+			p, err := src.NextPayload()
+			if err != nil {
+				break
+			}
+			off := uint32(i * 1024)
+			e.iso = append(e.iso, IsoPacket{Offset: off, Length: uint32(len(p))})
+			copy(e.data[off:], p)
+		}
+		if len(e.iso) == 0 {
+			break
+		}
+		// a missed microframe, no data was lost.
+		e.data = e.data[:len(e.iso)*1024]
+		e.iso = append(e.iso, IsoPacket{Status: -18, Offset: uint32(len(e.data))})
+		packets = append(packets, e.marshal())
+	}
+	return writePCAPNG(packets, time.Unix(1700000000, 0))
+}
+
+func TestImport_Replay(t *testing.T) {
+	frames := [][]byte{bytes.Repeat([]byte{0x11}, 320*240*2), bytes.Repeat([]byte{0x22}, 320*240*2)}
+	captures, err := Import(bytes.NewReader(webcamCapture(t, frames)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(captures) != 1 {
+		t.Fatalf("got %d devices, want 1", len(captures))
+	}
+	c := captures[0]
+	if c.Descriptor == nil || c.Descriptor.VendorID != 0x1209 {
+		t.Fatalf("device descriptor = %+v", c.Descriptor)
+	}
+	if !bytes.Equal(c.Config, simulator.WebcamConfig()) {
+		t.Error("configuration descriptor differs")
+	}
+	if c.Strings[1] != "SIM0001" {
+		t.Errorf("serial number = %q", c.Strings[1])
+	}
+	if c.LostPayloads[simulator.WebcamEndpoint] == 0 {
+		t.Error("missed microframes were not counted")
+	}
+	commits, err := c.Commits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit := commits[simulator.WebcamStreamingInterface]; commit == nil || commit.FrameIndex != 2 || commit.MaxPayloadTransferSize != 1024 {
+		t.Fatalf("commit = %+v", commit)
+	}
+
+	dev, err := c.Device()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := uvc.NewUVCDeviceWithTransport(dev).DeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ci := range info.ControlInterfaces {
+		if ci.ProcessingUnit == nil {
+			continue
+		}
+		bc := &descriptors.BrightnessControl{}
+		if err := ci.ProcessingUnit.Get(bc); err != nil {
+			t.Fatal(err)
+		}
+		if bc.Brightness != 0x20 {
+			t.Errorf("brightness = %d, want 32", bc.Brightness)
+		}
+	}
+
+	r, err := info.StreamingInterfaces[0].ClaimFrameReader(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.ProbeCommit().MaxPayloadTransferSize != 1024 {
+		t.Errorf("negotiated %+v, want the captured commit", r.ProbeCommit())
+	}
+	for i, want := range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !bytes.Equal(f.Bytes(), want) || !f.Integrity.Complete() {
+			t.Errorf("frame %d differs from the captured one", i)
+		}
+		f.Release()
+	}
+}
@@ -0,0 +1,330 @@
+// Package usbmon reads USB traffic captured with usbmon, as written by Wireshark or tcpdump to pcap and
+// pcapng files, and recovers the descriptors, control transfers and streaming payloads of video devices
+// from it so they can be replayed through the stack offline.
+package usbmon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Link types of usbmon captures. LinkTypeUSBLinux packets carry the 48 byte header of the legacy binary
+// interface, LinkTypeUSBLinuxMMapped packets the 64 byte header of the memory-mapped interface followed
+// by the isochronous packet descriptors.
+const (
+	LinkTypeUSBLinux        = 189
+	LinkTypeUSBLinuxMMapped = 220
+)
+
+// EventType is the kind of a usbmon event.
+type EventType byte
+
+const (
+	EventSubmission EventType = 'S'
+	EventCallback   EventType = 'C'
+	EventError      EventType = 'E'
+)
+
+// TransferType is the transfer type of the endpoint of a usbmon event.
+type TransferType uint8
+
+const (
+	TransferTypeIsochronous TransferType = 0
+	TransferTypeInterrupt   TransferType = 1
+	TransferTypeControl     TransferType = 2
+	TransferTypeBulk        TransferType = 3
+)
+
+// IsoPacket describes an isochronous packet of a transfer. Offset is relative to the packet data.
+type IsoPacket struct {
+	Status int32
+	Offset uint32
+	Length uint32
+}
+
+// Packet is a usbmon event, the submission or completion of an URB.
+type Packet struct {
+	// Time is the capture time stamp of the event.
+	Time time.Time
+	// ID identifies the URB, the submission and completion events of an URB have the same ID.
+	ID           uint64
+	Event        EventType
+	TransferType TransferType
+	// Endpoint is the endpoint address, including the direction bit.
+	Endpoint uint8
+	Device   uint8
+	Bus      uint16
+	// Setup is the setup packet of a control transfer submission, valid if HasSetup.
+	Setup    [8]byte
+	HasSetup bool
+	// Status is the URB status, 0 or a negative errno.
+	Status int32
+	// Length is the length of the transfer, the requested length for submissions and the actual length
+	// for completions.
+	Length uint32
+	// IsoPackets are the packets of an isochronous transfer. They are only captured with
+	// LinkTypeUSBLinuxMMapped.
+	IsoPackets []IsoPacket
+	// Data is the captured transfer data. It is shorter than Length if the capture truncated it.
+	Data []byte
+}
+
+// Reader reads usbmon packets from a pcap or pcapng file.
+type Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	ng    bool
+
+	// pcap
+	linkType uint32
+	tsUnit   time.Duration
+
+	// pcapng
+	ifaces []pcapngInterface
+}
+
+type pcapngInterface struct {
+	linkType uint16
+	// tsPerSecond is the number of time stamp units per second.
+	tsPerSecond uint64
+}
+
+const pcapngSectionHeader = 0x0a0d0d0a
+
+// NewReader reads the file header of a pcap or pcapng capture.
+func NewReader(r io.Reader) (*Reader, error) {
+	mr := &Reader{r: bufio.NewReader(r)}
+	magic, err := mr.r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(magic) == pcapngSectionHeader {
+		mr.ng = true
+		if err := mr.readBlock(nil); err != nil {
+			return nil, err
+		}
+		return mr, nil
+	}
+
+	var hdr [24]byte
+	if _, err := io.ReadFull(mr.r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("failed to read capture header: %w", err)
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(hdr[0:4]) {
+		case 0xa1b2c3d4:
+			mr.order, mr.tsUnit = order, time.Microsecond
+		case 0xa1b23c4d:
+			mr.order, mr.tsUnit = order, time.Nanosecond
+		}
+	}
+	if mr.order == nil {
+		return nil, fmt.Errorf("not a pcap or pcapng file")
+	}
+	mr.linkType = mr.order.Uint32(hdr[20:24]) & 0xffff
+	return mr, nil
+}
+
+// Next returns the next usbmon packet of the capture, skipping packets of other link types. It returns
+// io.EOF at the end of the capture.
+func (r *Reader) Next() (*Packet, error) {
+	for {
+		var (
+			p   *Packet
+			err error
+		)
+		if r.ng {
+			err = r.readBlock(&p)
+		} else {
+			p, err = r.readRecord()
+		}
+		if err != nil || p != nil {
+			return p, err
+		}
+	}
+}
+
+// readRecord reads a pcap record. It returns a nil packet for records of other link types.
+func (r *Reader) readRecord() (*Packet, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated record header: %w", err)
+		}
+		return nil, err
+	}
+	ts := time.Unix(int64(r.order.Uint32(hdr[0:4])), int64(r.order.Uint32(hdr[4:8]))*int64(r.tsUnit))
+	data, err := r.readN(r.order.Uint32(hdr[8:12]))
+	if err != nil {
+		return nil, fmt.Errorf("truncated record: %w", err)
+	}
+	return parsePacket(data, r.linkType, r.order, ts)
+}
+
+// readBlock reads a pcapng block, setting *p for packet blocks of a usbmon interface.
+func (r *Reader) readBlock(p **Packet) error {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("truncated block header: %w", err)
+		}
+		return err
+	}
+	blockType := binary.LittleEndian.Uint32(hdr[0:4])
+	if blockType == pcapngSectionHeader {
+		// the byte order of a section is given by the magic following the block length.
+		bom, err := r.r.Peek(4)
+		if err != nil {
+			return fmt.Errorf("truncated section header: %w", err)
+		}
+		switch {
+		case binary.LittleEndian.Uint32(bom) == 0x1a2b3c4d:
+			r.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(bom) == 0x1a2b3c4d:
+			r.order = binary.BigEndian
+		default:
+			return fmt.Errorf("invalid section byte order magic")
+		}
+		r.ifaces = nil
+	} else if r.order == nil {
+		return fmt.Errorf("block %#x before section header", blockType)
+	}
+	length := r.order.Uint32(hdr[4:8])
+	if length < 12 || length%4 != 0 {
+		return fmt.Errorf("invalid block length %d", length)
+	}
+	body, err := r.readN(length - 8)
+	if err != nil {
+		return fmt.Errorf("truncated block: %w", err)
+	}
+	// the body ends with a copy of the block length.
+	body = body[:len(body)-4]
+
+	switch r.order.Uint32(hdr[0:4]) {
+	case 1: // interface description
+		if len(body) < 8 {
+			return fmt.Errorf("interface description block too short")
+		}
+		iface := pcapngInterface{linkType: r.order.Uint16(body[0:2]), tsPerSecond: 1000000}
+		for opts := body[8:]; len(opts) >= 4; {
+			code, n := r.order.Uint16(opts[0:2]), int(r.order.Uint16(opts[2:4]))
+			if code == 0 || 4+n > len(opts) {
+				break
+			}
+			if code == 9 && n >= 1 { // if_tsresol
+				if v := opts[4]; v&0x80 != 0 {
+					iface.tsPerSecond = 1 << min(v&0x7f, 63)
+				} else {
+					iface.tsPerSecond = uint64(math.Pow10(int(min(v, 19))))
+				}
+			}
+			opts = opts[min(4+(n+3)&^3, len(opts)):]
+		}
+		r.ifaces = append(r.ifaces, iface)
+	case 6: // enhanced packet
+		if len(body) < 20 {
+			return fmt.Errorf("enhanced packet block too short")
+		}
+		id := r.order.Uint32(body[0:4])
+		if int(id) >= len(r.ifaces) {
+			return fmt.Errorf("packet of unknown interface %d", id)
+		}
+		iface := r.ifaces[id]
+		ts := uint64(r.order.Uint32(body[4:8]))<<32 | uint64(r.order.Uint32(body[8:12]))
+		n := r.order.Uint32(body[12:16])
+		if uint64(n) > uint64(len(body)-20) {
+			return fmt.Errorf("packet length %d exceeds block", n)
+		}
+		t := time.Unix(int64(ts/iface.tsPerSecond), int64(ts%iface.tsPerSecond*uint64(time.Second)/iface.tsPerSecond))
+		pkt, err := parsePacket(body[20:20+n], uint32(iface.linkType), r.order, t)
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			*p = pkt
+		}
+	case 3: // simple packet, of the first interface and without time stamp
+		if len(body) < 4 || len(r.ifaces) == 0 {
+			return fmt.Errorf("invalid simple packet block")
+		}
+		n := min(int(r.order.Uint32(body[0:4])), len(body)-4)
+		pkt, err := parsePacket(body[4:4+n], uint32(r.ifaces[0].linkType), r.order, time.Time{})
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			*p = pkt
+		}
+	}
+	return nil
+}
+
+// readN reads n bytes, refusing implausible lengths so a corrupt file does not allocate without limit.
+func (r *Reader) readN(n uint32) ([]byte, error) {
+	if n > 64<<20 {
+		return nil, fmt.Errorf("length %d too large", n)
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r.r, buf)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf, err
+}
+
+// parsePacket parses the usbmon header of a packet. The header is in the byte order of the capturing
+// host, which is assumed to be the byte order of the file. It returns nil for other link types.
+func parsePacket(data []byte, linkType uint32, order binary.ByteOrder, ts time.Time) (*Packet, error) {
+	var hdrLen int
+	switch linkType {
+	case LinkTypeUSBLinux:
+		hdrLen = 48
+	case LinkTypeUSBLinuxMMapped:
+		hdrLen = 64
+	default:
+		return nil, nil
+	}
+	if len(data) < hdrLen {
+		return nil, fmt.Errorf("usbmon packet too short: %d bytes", len(data))
+	}
+	p := &Packet{
+		Time:         ts,
+		ID:           order.Uint64(data[0:8]),
+		Event:        EventType(data[8]),
+		TransferType: TransferType(data[9]),
+		Endpoint:     data[10],
+		Device:       data[11],
+		Bus:          order.Uint16(data[12:14]),
+		HasSetup:     data[14] == 0,
+		Status:       int32(order.Uint32(data[28:32])),
+		Length:       order.Uint32(data[32:36]),
+	}
+	if p.HasSetup {
+		copy(p.Setup[:], data[40:48])
+	}
+	lenCap := order.Uint32(data[36:40])
+	rest := data[hdrLen:]
+	if linkType == LinkTypeUSBLinuxMMapped && p.TransferType == TransferTypeIsochronous {
+		ndesc := order.Uint32(data[60:64])
+		if uint64(ndesc)*16 > uint64(len(rest)) {
+			return nil, fmt.Errorf("%d isochronous descriptors exceed packet", ndesc)
+		}
+		p.IsoPackets = make([]IsoPacket, ndesc)
+		for i := range p.IsoPackets {
+			d := rest[i*16:]
+			p.IsoPackets[i] = IsoPacket{
+				Status: int32(order.Uint32(d[0:4])),
+				Offset: order.Uint32(d[4:8]),
+				Length: order.Uint32(d[8:12]),
+			}
+		}
+		rest = rest[ndesc*16:]
+	}
+	p.Data = rest[:min(uint32(len(rest)), lenCap)]
+	return p, nil
+}
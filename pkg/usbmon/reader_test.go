@@ -0,0 +1,153 @@
+package usbmon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// event builds a memory-mapped usbmon packet.
+type event struct {
+	id       uint64
+	typ      EventType
+	xfer     TransferType
+	endpoint uint8
+	setup    []byte
+	status   int32
+	length   uint32
+	iso      []IsoPacket
+	data     []byte
+}
+
+func (e event) marshal() []byte {
+	buf := make([]byte, 64, 64+16*len(e.iso)+len(e.data))
+	binary.LittleEndian.PutUint64(buf[0:8], e.id)
+	buf[8], buf[9], buf[10], buf[11] = byte(e.typ), byte(e.xfer), e.endpoint, 5
+	binary.LittleEndian.PutUint16(buf[12:14], 1)
+	buf[14] = '-'
+	if e.setup != nil {
+		buf[14] = 0
+		copy(buf[40:48], e.setup)
+	}
+	binary.LittleEndian.PutUint32(buf[28:32], uint32(e.status))
+	binary.LittleEndian.PutUint32(buf[32:36], e.length)
+	binary.LittleEndian.PutUint32(buf[36:40], uint32(len(e.data)))
+	binary.LittleEndian.PutUint32(buf[60:64], uint32(len(e.iso)))
+	for _, p := range e.iso {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(p.Status))
+		buf = binary.LittleEndian.AppendUint32(buf, p.Offset)
+		buf = binary.LittleEndian.AppendUint32(buf, p.Length)
+		buf = binary.LittleEndian.AppendUint32(buf, 0)
+	}
+	return append(buf, e.data...)
+}
+
+// writePCAP writes packets to a classic pcap file with microsecond time stamps.
+func writePCAP(packets [][]byte, start time.Time) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, 0xa1b2c3d4)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, 4)
+	b = append(b, make([]byte, 8)...)
+	b = binary.LittleEndian.AppendUint32(b, 262144)
+	b = binary.LittleEndian.AppendUint32(b, LinkTypeUSBLinuxMMapped)
+	for i, p := range packets {
+		ts := start.Add(time.Duration(i) * time.Millisecond)
+		b = binary.LittleEndian.AppendUint32(b, uint32(ts.Unix()))
+		b = binary.LittleEndian.AppendUint32(b, uint32(ts.Nanosecond()/1000))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(p)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(p)))
+		b = append(b, p...)
+	}
+	return b
+}
+
+// writePCAPNG writes packets to a pcapng file with nanosecond time stamps, the resolution Wireshark
+// uses for usbmon captures.
+func writePCAPNG(packets [][]byte, start time.Time) []byte {
+	block := func(typ uint32, body []byte) []byte {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		var b []byte
+		b = binary.LittleEndian.AppendUint32(b, typ)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(body)+12))
+		b = append(b, body...)
+		return binary.LittleEndian.AppendUint32(b, uint32(len(body)+12))
+	}
+	var b []byte
+	shb := binary.LittleEndian.AppendUint32(nil, 0x1a2b3c4d)
+	shb = append(shb, 1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	b = append(b, block(pcapngSectionHeader, shb)...)
+	idb := binary.LittleEndian.AppendUint16(nil, LinkTypeUSBLinuxMMapped)
+	idb = append(idb, 0, 0, 0, 0, 4, 0)
+	// if_tsresol of 9, nanoseconds.
+	idb = append(idb, 9, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0)
+	b = append(b, block(1, idb)...)
+	// a block of an unknown type is skipped.
+	b = append(b, block(0x0bad, []byte{1, 2, 3, 4})...)
+	for i, p := range packets {
+		ts := uint64(start.Add(time.Duration(i) * time.Millisecond).UnixNano())
+		epb := binary.LittleEndian.AppendUint32(nil, 0)
+		epb = binary.LittleEndian.AppendUint32(epb, uint32(ts>>32))
+		epb = binary.LittleEndian.AppendUint32(epb, uint32(ts))
+		epb = binary.LittleEndian.AppendUint32(epb, uint32(len(p)))
+		epb = binary.LittleEndian.AppendUint32(epb, uint32(len(p)))
+		b = append(b, block(6, append(epb, p...))...)
+	}
+	return b
+}
+
+func TestReader(t *testing.T) {
+	start := time.Unix(1700000000, 123456000)
+	packets := [][]byte{
+		event{id: 1, typ: EventSubmission, xfer: TransferTypeControl, endpoint: 0x80, setup: []byte{0x80, 0x06, 0, 1, 0, 0, 18, 0}, length: 18}.marshal(),
+		event{id: 2, typ: EventCallback, xfer: TransferTypeIsochronous, endpoint: 0x81, length: 6, iso: []IsoPacket{{0, 0, 4}, {-18, 4, 0}, {0, 8, 2}}, data: []byte{1, 2, 3, 4, 0, 0, 0, 0, 5, 6}}.marshal(),
+	}
+	for name, file := range map[string][]byte{"pcap": writePCAP(packets, start), "pcapng": writePCAPNG(packets, start)} {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Event != EventSubmission || p.TransferType != TransferTypeControl || !p.HasSetup || p.Setup[1] != 0x06 {
+				t.Errorf("packet 0 = %+v", p)
+			}
+			if p.Bus != 1 || p.Device != 5 || !p.Time.Equal(start) {
+				t.Errorf("packet 0 address %d.%d at %v", p.Bus, p.Device, p.Time)
+			}
+			p, err = r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.IsoPackets) != 3 || p.IsoPackets[1].Status != -18 || p.IsoPackets[2].Offset != 8 {
+				t.Errorf("iso packets = %+v", p.IsoPackets)
+			}
+			if !bytes.Equal(p.Data, []byte{1, 2, 3, 4, 0, 0, 0, 0, 5, 6}) {
+				t.Errorf("data = %v", p.Data)
+			}
+			if _, err := r.Next(); err == nil {
+				t.Error("expected end of capture")
+			}
+		})
+	}
+}
+
+func TestReader_Invalid(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("not a capture file at all"))); err == nil {
+		t.Error("invalid file accepted")
+	}
+	file := writePCAP([][]byte{make([]byte, 20)}, time.Now())
+	r, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil {
+		t.Error("short usbmon header accepted")
+	}
+}
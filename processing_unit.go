@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"time"
 
-	usb "github.com/kevmo314/go-usb"
 	"github.com/kevmo314/go-uvc/pkg/descriptors"
 	"github.com/kevmo314/go-uvc/pkg/requests"
+	"github.com/kevmo314/go-uvc/pkg/transfers"
 )
 
 var puControls = []descriptors.ProcessingUnitControlDescriptor{
@@ -34,7 +34,7 @@ var puControls = []descriptors.ProcessingUnitControlDescriptor{
 }
 
 type ProcessingUnit struct {
-	handle         *usb.DeviceHandle
+	handle         transfers.Transport
 	ifaceNum       uint8
 	UnitDescriptor *descriptors.ProcessingUnitDescriptor
 }
@@ -0,0 +1,261 @@
+//go:build !windows
+
+package uvc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/kevmo314/go-uvc/pkg/descriptors"
+	"github.com/kevmo314/go-uvc/pkg/requests"
+	"github.com/kevmo314/go-uvc/pkg/simulator"
+	"github.com/kevmo314/go-uvc/pkg/transfers"
+)
+
+func TestSimulatedWebcamControls(t *testing.T) {
+	dev := simulator.Webcam()
+	d := NewUVCDeviceWithTransport(dev)
+	if d.Handle() != nil || d.Transport() != dev {
+		t.Error("simulated device has a USB handle or a different transport")
+	}
+	info, err := d.DeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.StreamingInterfaces) != 1 {
+		t.Fatalf("got %d streaming interfaces, want 1", len(info.StreamingInterfaces))
+	}
+	if info.StreamingInterfaces[0].Handle() != nil {
+		t.Error("simulated streaming interface has a USB handle")
+	}
+
+	var ct *CameraTerminal
+	var pu *ProcessingUnit
+	for _, ci := range info.ControlInterfaces {
+		if ci.CameraTerminal != nil {
+			ct = ci.CameraTerminal
+		}
+		if ci.ProcessingUnit != nil {
+			pu = ci.ProcessingUnit
+		}
+	}
+	if ct == nil || pu == nil {
+		t.Fatal("camera terminal or processing unit not found")
+	}
+
+	aemc := &descriptors.AutoExposureModeControl{}
+	if err := ct.Get(aemc); err != nil {
+		t.Fatal(err)
+	}
+	if aemc.Mode != descriptors.AutoExposureModeManual {
+		t.Errorf("auto-exposure mode = %v, want manual", aemc.Mode)
+	}
+
+	if err := ct.Set(&descriptors.ExposureTimeAbsoluteControl{Time: 100}); err != nil {
+		t.Fatal(err)
+	}
+	ctIndex := uint16(simulator.WebcamCameraTerminal)<<8 | simulator.WebcamControlInterface
+	v, _ := dev.Control(requests.RequestCodeGetCur, uint8(descriptors.CameraTerminalControlSelectorExposureTimeAbsoluteControl), ctIndex)
+	if got := binary.LittleEndian.Uint32(v); got != 100 {
+		t.Errorf("exposure time = %d, want 100", got)
+	}
+
+	if err := pu.Set(&descriptors.BrightnessControl{Brightness: 32}); err != nil {
+		t.Fatal(err)
+	}
+	bc := &descriptors.BrightnessControl{}
+	if err := pu.Get(bc); err != nil {
+		t.Fatal(err)
+	}
+	if bc.Brightness != 32 {
+		t.Errorf("brightness = %d, want 32", bc.Brightness)
+	}
+}
+
+// metadataFrame returns a metadata frame with a payload header item holding pts and capture stats
+// reporting the given ISO speed.
+func metadataFrame(pts, iso uint32) []byte {
+	item := func(id transfers.MetadataID, data []byte) []byte {
+		buf := binary.LittleEndian.AppendUint32(nil, uint32(id))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(8+len(data)))
+		return append(buf, data...)
+	}
+	header := binary.LittleEndian.AppendUint32([]byte{6, 0x84}, pts)
+	stats := make([]byte, 72)
+	binary.LittleEndian.PutUint32(stats[0:4], transfers.CaptureStatsISOSpeed)
+	binary.LittleEndian.PutUint32(stats[28:32], iso)
+	return append(item(transfers.MetadataIDUsbVideoHeader, header), item(transfers.MetadataIDCaptureStats, stats)...)
+}
+
+func TestSimulatedWebcamMetadata(t *testing.T) {
+	dev := simulator.MetadataWebcam()
+	info, err := NewUVCDeviceWithTransport(dev).DeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.StreamingInterfaces) != 2 {
+		t.Fatalf("got %d streaming interfaces, want 2", len(info.StreamingInterfaces))
+	}
+	video := info.StreamingInterfaces[0]
+	if video.IsMetadata() {
+		t.Error("video interface reported as a metadata interface")
+	}
+	md, ok := info.MetadataInterface(video)
+	if !ok || md.InterfaceNumber() != simulator.WebcamMetadataInterface {
+		t.Fatalf("MetadataInterface = %v, %v, want interface %d", md, ok, simulator.WebcamMetadataInterface)
+	}
+	if _, ok := info.MetadataInterface(md); ok {
+		t.Error("metadata interface has a metadata interface")
+	}
+
+	// the device clock ticks 1920000 times per frame, the second frame has no metadata.
+	frames := [][]byte{bytes.Repeat([]byte{0x10}, 320*240*2), bytes.Repeat([]byte{0x20}, 320*240*2), bytes.Repeat([]byte{0x30}, 320*240*2)}
+	src := simulator.Frames(simulator.WebcamPacketSize, frames...)
+	src.ClockFrequency, src.FrameInterval = 48000000, 40*time.Millisecond
+	dev.SetPayloadSource(simulator.WebcamEndpoint, src)
+	dev.SetPayloadSource(simulator.WebcamMetadataEndpoint, simulator.Frames(512, metadataFrame(0, 100), metadataFrame(3840000, 300)))
+
+	r, err := video.ClaimPairedFrameReader(1, 2, md)
+	if err != nil {
+		t.Fatalf("ClaimPairedFrameReader failed: %v", err)
+	}
+	if !dev.Claimed(simulator.WebcamMetadataInterface) {
+		t.Error("metadata interface was not claimed")
+	}
+	for i, iso := range []uint32{100, 0, 300} {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !bytes.Equal(f.Bytes(), frames[i]) {
+			t.Errorf("frame %d data mismatch", i)
+		}
+		switch {
+		case iso == 0 && f.Metadata != nil:
+			t.Errorf("frame %d has metadata %+v, want none", i, f.Metadata)
+		case iso != 0 && (f.Metadata == nil || f.Metadata.CaptureStats == nil || f.Metadata.CaptureStats.ISOSpeed != iso):
+			t.Errorf("frame %d metadata = %+v, want ISO %d", i, f.Metadata, iso)
+		}
+		f.Release()
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if dev.Claimed(simulator.WebcamMetadataInterface) || dev.Claimed(simulator.WebcamStreamingInterface) {
+		t.Error("interfaces were not released")
+	}
+}
+
+func TestSimulatedMicrophone(t *testing.T) {
+	dev := simulator.Microphone()
+	info, err := NewUACDeviceWithTransport(dev).DeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.bcdADC != 0x0100 || len(info.StreamingInterfaces) != 1 {
+		t.Fatalf("bcdADC = %#04x with %d streaming interfaces, want 0x0100 with 1", info.bcdADC, len(info.StreamingInterfaces))
+	}
+	asi := info.StreamingInterfaces[0]
+	if asi.AlternateSetting() != 1 || asi.NrChannels != 1 || asi.BitResolution != 16 || asi.EndpointAddress != simulator.MicrophoneEndpoint {
+		t.Errorf("streaming interface = %+v", asi)
+	}
+
+	// a packet larger than the endpoint's maximum is lost.
+	packets := [][]byte{bytes.Repeat([]byte{0x01}, simulator.MicrophonePacketSize), make([]byte, simulator.MicrophonePacketSize+1), nil, bytes.Repeat([]byte{0x03}, 32)}
+	dev.SetPayloadSource(simulator.MicrophoneEndpoint, simulator.Payloads(packets...))
+
+	r, err := asi.ClaimAudioReader(simulator.MicrophoneSampleRate)
+	if err != nil {
+		t.Fatalf("ClaimAudioReader failed: %v", err)
+	}
+	if !dev.Claimed(simulator.MicrophoneStreamingInterface) || dev.AltSetting(simulator.MicrophoneStreamingInterface) != 1 {
+		t.Error("streaming interface was not claimed at alternate setting 1")
+	}
+	freq, err := asi.GetCurrentSamplingFreq()
+	if err != nil || freq != simulator.MicrophoneSampleRate {
+		t.Errorf("GetCurrentSamplingFreq = %d, %v, want %d", freq, err, simulator.MicrophoneSampleRate)
+	}
+
+	buf := make([]byte, simulator.MicrophonePacketSize)
+	for _, i := range []int{0, 3} {
+		n, err := r.ReadAudio(buf)
+		if err != nil {
+			t.Fatalf("ReadAudio failed: %v", err)
+		}
+		if !bytes.Equal(buf[:n], packets[i]) {
+			t.Errorf("read %x, want packet %d", buf[:n], i)
+		}
+	}
+	if _, err := r.ReadAudio(buf); err == nil {
+		t.Error("ReadAudio after the end of the stream succeeded")
+	}
+	s := r.Stats()
+	if s.Packets != 2 || s.PacketErrors[-75] != 1 || s.Bytes != simulator.MicrophonePacketSize+32 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if dev.Claimed(simulator.MicrophoneStreamingInterface) {
+		t.Error("streaming interface was not released")
+	}
+}
+
+func TestSimulatedWebcamRecording(t *testing.T) {
+	dev := simulator.Webcam()
+	info, err := NewUVCDeviceWithTransport(dev).DeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	si := info.StreamingInterfaces[0]
+	frames := [][]byte{bytes.Repeat([]byte{0x10}, 320*240*2), bytes.Repeat([]byte{0x20}, 320*240*2)}
+	dev.SetPayloadSource(simulator.WebcamEndpoint, simulator.Frames(simulator.WebcamPacketSize, frames...))
+
+	r, err := si.ClaimFrameReader(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := r.Record(&buf); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	for range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Release()
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := ReadRecording(&buf)
+	if err != nil {
+		t.Fatalf("ReadRecording failed: %v", err)
+	}
+	replayed := rec.StreamingInterface()
+	if replayed.InterfaceNumber() != simulator.WebcamStreamingInterface || replayed.ControlInterfaceNumber() != simulator.WebcamControlInterface {
+		t.Errorf("replayed interface %d of control interface %d", replayed.InterfaceNumber(), replayed.ControlInterfaceNumber())
+	}
+	if replayed.UVCVersionString() != si.UVCVersionString() || len(replayed.Descriptors) != len(si.Descriptors) {
+		t.Errorf("replayed interface differs from the recorded one")
+	}
+	replay := rec.NewFrameReader(false)
+	for i, want := range frames {
+		f, err := replay.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d failed: %v", i, err)
+		}
+		if !bytes.Equal(f.Bytes(), want) {
+			t.Errorf("frame %d differs from the recorded one", i)
+		}
+		f.Release()
+	}
+	if err := replay.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
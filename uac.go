@@ -12,10 +12,15 @@ import (
 )
 
 type UACDevice struct {
-	handle *usb.DeviceHandle
+	handle transfers.Transport
 	closed *atomic.Bool
 }
 
+// NewUACDeviceWithTransport creates a device on the given transport, such as a simulated device.
+func NewUACDeviceWithTransport(t transfers.Transport) *UACDevice {
+	return &UACDevice{handle: t, closed: &atomic.Bool{}}
+}
+
 func (d *UACDevice) Close() error {
 	d.closed.Store(true)
 	return d.handle.Close()
@@ -27,14 +32,21 @@ type AudioControlInterface struct {
 
 type AudioDeviceInfo struct {
 	bcdADC              uint16
-	handle              *usb.DeviceHandle
+	handle              transfers.Transport
 	configDesc          *usb.ConfigDescriptor
 	ControlInterfaces   []*AudioControlInterface
 	StreamingInterfaces []*transfers.AudioStreamingInterface
 	MIDIInterfaces      []*transfers.MIDIStreamingInterface
 }
 
+// GetHandle returns the USB device handle, or nil if the device was created on another transport.
 func (info *AudioDeviceInfo) GetHandle() *usb.DeviceHandle {
+	h, _ := info.handle.(*usb.DeviceHandle)
+	return h
+}
+
+// Transport returns the transport the device communicates through.
+func (info *AudioDeviceInfo) Transport() transfers.Transport {
 	return info.handle
 }
 
@@ -65,11 +77,13 @@ func (d *UACDevice) DeviceInfo() (*AudioDeviceInfo, error) {
 	if len(audioInterface.AltSettings) == 0 {
 		return nil, fmt.Errorf("no alt settings for audio control interface")
 	}
-	acbuf := audioInterface.AltSettings[0].Extra
+	acblocks, err := descriptors.SplitDescriptors(audioInterface.AltSettings[0].Extra)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse audio control interface descriptors: %w", err)
+	}
 
 	// Parse audio control interface descriptors
-	for i := 0; i != len(acbuf); i += int(acbuf[i]) {
-		block := acbuf[i : i+int(acbuf[i])]
+	for _, block := range acblocks {
 		if len(block) < 3 {
 			continue
 		}
@@ -103,16 +117,20 @@ func (d *UACDevice) DeviceInfo() (*AudioDeviceInfo, error) {
 						continue
 					}
 
+					// Each streaming interface describes one alternate setting, the one ClaimAudioReader
+					// selects.
 					streamingIface := transfers.NewAudioStreamingInterface(
 						d.handle,
-						&iface,
+						&usb.Interface{AltSettings: []usb.InterfaceAltSetting{altsetting}},
 						info.bcdADC,
 					)
 
 					// Parse streaming interface descriptors
-					asbuf := altsetting.Extra
-					for j := 0; j != len(asbuf); j += int(asbuf[j]) {
-						block := asbuf[j : j+int(asbuf[j])]
+					asblocks, err := descriptors.SplitDescriptors(altsetting.Extra)
+					if err != nil {
+						return nil, fmt.Errorf("failed to parse audio streaming interface descriptors: %w", err)
+					}
+					for _, block := range asblocks {
 						if len(block) >= 3 && block[1] == 0x24 {
 							// Parse audio streaming descriptors
 							streamingIface.ParseDescriptor(block)
@@ -137,9 +155,11 @@ func (d *UACDevice) DeviceInfo() (*AudioDeviceInfo, error) {
 				)
 
 				// Parse MIDI descriptors
-				midibuf := iface.AltSettings[0].Extra
-				for j := 0; j != len(midibuf); j += int(midibuf[j]) {
-					block := midibuf[j : j+int(midibuf[j])]
+				midiblocks, err := descriptors.SplitDescriptors(iface.AltSettings[0].Extra)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse MIDI streaming interface descriptors: %w", err)
+				}
+				for _, block := range midiblocks {
 					if len(block) >= 3 && block[1] == 0x24 {
 						// Parse MIDI streaming descriptors
 						midiIface.ParseDescriptor(block)
@@ -13,11 +13,24 @@ import (
 )
 
 type UVCDevice struct {
-	handle *usb.DeviceHandle
+	handle transfers.Transport
 	closed *atomic.Bool
 }
 
+// NewUVCDeviceWithTransport creates a device on the given transport, such as a simulated device.
+func NewUVCDeviceWithTransport(t transfers.Transport) *UVCDevice {
+	return &UVCDevice{handle: t, closed: &atomic.Bool{}}
+}
+
+// Handle returns the USB device handle, or nil if the device was created on another transport by
+// NewUVCDeviceWithTransport.
 func (d *UVCDevice) Handle() *usb.DeviceHandle {
+	h, _ := d.handle.(*usb.DeviceHandle)
+	return h
+}
+
+// Transport returns the transport the device communicates through.
+func (d *UVCDevice) Transport() transfers.Transport {
 	return d.handle
 }
 
@@ -39,7 +52,7 @@ type ControlInterface struct {
 
 type DeviceInfo struct {
 	bcdUVC              uint16 // cached since it's used a lot
-	handle              *usb.DeviceHandle
+	handle              transfers.Transport
 	configDesc          *usb.ConfigDescriptor
 	ControlInterfaces   []*ControlInterface
 	StreamingInterfaces []*transfers.StreamingInterface
@@ -81,14 +94,12 @@ func (d *UVCDevice) DeviceInfo() (*DeviceInfo, error) {
 	if len(videoInterface.AltSettings) == 0 {
 		return nil, fmt.Errorf("no alt settings for control interface")
 	}
-	vcbuf := videoInterface.AltSettings[0].Extra
+	vcblocks, err := extraDescriptors(&videoInterface.AltSettings[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse video control interface descriptors: %w", err)
+	}
 
-	for i := 0; i != len(vcbuf); i += int(vcbuf[i]) {
-		block := vcbuf[i : i+int(vcbuf[i])]
-		if block[1] != 0x24 {
-			// ignore blocks that are not CS_INTERFACE 0x24
-			continue
-		}
+	for _, block := range vcblocks {
 		ci, err := descriptors.UnmarshalControlInterface(block)
 		if err != nil {
 			return nil, err
@@ -115,6 +126,8 @@ func (d *UVCDevice) DeviceInfo() (*DeviceInfo, error) {
 					CameraDescriptor: descriptor,
 				}
 				info.ControlInterfaces = append(info.ControlInterfaces, &ControlInterface{CameraTerminal: camera, Descriptor: descriptor})
+			default:
+				info.ControlInterfaces = append(info.ControlInterfaces, &ControlInterface{Descriptor: descriptor})
 			}
 		case *descriptors.HeaderDescriptor:
 			info.bcdUVC = ci.UVC
@@ -127,19 +140,10 @@ func (d *UVCDevice) DeviceInfo() (*DeviceInfo, error) {
 				if len(streamIface.AltSettings) == 0 {
 					continue
 				}
-				vsbuf := streamIface.AltSettings[0].Extra
-				asi := transfers.NewStreamingInterface(d.handle, streamIface, ci.UVC)
-				for j := 0; j != len(vsbuf); j += int(vsbuf[j]) {
-					block := vsbuf[j : j+int(vsbuf[j])]
-					// Only parse CS_INTERFACE (0x24) descriptors
-					if block[1] != 0x24 {
-						continue
-					}
-					si, err := descriptors.UnmarshalStreamingInterface(block)
-					if err != nil {
-						return nil, err
-					}
-					asi.Descriptors = append(asi.Descriptors, si)
+				asi := transfers.NewStreamingInterfaceWithHeader(d.handle, streamIface, videoInterface.AltSettings[0].InterfaceNumber, ci)
+				asi.Descriptors, err = streamingDescriptors(streamIface)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse video streaming interface descriptors: %w", err)
 				}
 				info.StreamingInterfaces = append(info.StreamingInterfaces, asi)
 			}
@@ -152,6 +156,81 @@ func (d *UVCDevice) DeviceInfo() (*DeviceInfo, error) {
 	return info, nil
 }
 
+// MetadataInterface returns the metadata streaming interface of the video function that si belongs
+// to, if the device has one. Its frames can be paired with the frames of si by
+// StreamingInterface.ClaimPairedFrameReader.
+func (d *DeviceInfo) MetadataInterface(si *transfers.StreamingInterface) (*transfers.StreamingInterface, bool) {
+	for _, other := range d.StreamingInterfaces {
+		if other != si && other.ControlInterfaceNumber() == si.ControlInterfaceNumber() && other.IsMetadata() {
+			return other, true
+		}
+	}
+	return nil, false
+}
+
+// streamingDescriptors parses the descriptors of a video streaming interface. UVC spec 1.5, section
+// 3.9: the class-specific VS interface descriptors follow alternate setting 0. The descriptors of
+// the endpoints and of the other alternate settings are kept as UnknownDescriptor, so that devices
+// repeating their formats after every alternate setting do not list them twice.
+func streamingDescriptors(iface *usb.Interface) ([]descriptors.StreamingInterface, error) {
+	var descs []descriptors.StreamingInterface
+	add := func(block []byte, interpret bool) error {
+		var desc descriptors.StreamingInterface = &descriptors.UnknownDescriptor{}
+		var err error
+		if interpret {
+			desc, err = descriptors.UnmarshalStreamingInterface(block)
+		} else {
+			err = desc.UnmarshalBinary(block)
+		}
+		if err != nil {
+			return err
+		}
+		descs = append(descs, desc)
+		return nil
+	}
+	for i := range iface.AltSettings {
+		alt := &iface.AltSettings[i]
+		blocks, err := descriptors.SplitDescriptors(alt.Extra)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range blocks {
+			if err := add(block, i == 0); err != nil {
+				return nil, err
+			}
+		}
+		for _, ep := range alt.Endpoints {
+			epblocks, err := descriptors.SplitDescriptors(ep.Extra)
+			if err != nil {
+				return nil, fmt.Errorf("endpoint %#02x: %w", ep.EndpointAddr, err)
+			}
+			for _, block := range epblocks {
+				if err := add(block, false); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return descs, nil
+}
+
+// extraDescriptors returns the descriptors that follow an alternate setting and each of its endpoints
+// in the configuration descriptor, in order.
+func extraDescriptors(alt *usb.InterfaceAltSetting) ([][]byte, error) {
+	blocks, err := descriptors.SplitDescriptors(alt.Extra)
+	if err != nil {
+		return nil, err
+	}
+	for _, ep := range alt.Endpoints {
+		epblocks, err := descriptors.SplitDescriptors(ep.Extra)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %#02x: %w", ep.EndpointAddr, err)
+		}
+		blocks = append(blocks, epblocks...)
+	}
+	return blocks, nil
+}
+
 func (d *DeviceInfo) Close() error {
 	return nil
 }